@@ -15,9 +15,10 @@ GTBANK_KEY_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-con
 GTBANK_MSP_ID="GTBankMSP"
 GTBANK_PEER_ENDPOINT="localhost:8051"
 
-# CentralBankPeerMSP Credentials
+# CentralBankMSP Credentials: the chaincode only accepts Central Bank operations from
+# CentralBankMSP, so the CBN services sign as the Central Bank org, not the CBN peer org
 CBN_TLS_CERT_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-config/peerOrganizations/cbn.naijachain.org/tlsca/tlsca.cbn.naijachain.org-cert.pem"
-CBN_ID_CERT_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-config/peerOrganizations/cbn.naijachain.org/users/User1@cbn.naijachain.org/msp/signcerts/User1@cbn.naijachain.org-cert.pem"
-CBN_KEY_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-config/peerOrganizations/cbn.naijachain.org/users/User1@cbn.naijachain.org/msp/keystore/priv_sk"
-CBN_MSP_ID="CentralBankPeerMSP"
+CBN_ID_CERT_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-config/ordererOrganizations/cbn.naijachain.org/users/Admin@cbn.naijachain.org/msp/signcerts/Admin@cbn.naijachain.org-cert.pem"
+CBN_KEY_PATH="/Users/sam/Documents/Blockchain/interbank_settlement/crypto-config/ordererOrganizations/cbn.naijachain.org/users/Admin@cbn.naijachain.org/msp/keystore/priv_sk"
+CBN_MSP_ID="CentralBankMSP"
 CBN_PEER_ENDPOINT="localhost:11051"
@@ -19,7 +19,7 @@ dotenv.config();
 const utf8Decoder = new TextDecoder();
 
 /* ---------- env / constants ------------------------------------------------ */
-const MSP_ID = process.env.CBN_MSP_ID ?? "CentralBankPeerMSP";
+const MSP_ID = process.env.CBN_MSP_ID ?? "CentralBankMSP";
 const PEER_ENDPOINT = process.env.CBN_PEER_ENDPOINT ?? "localhost:11051";
 const TLS_CERT_PATH = process.env.CBN_TLS_CERT_PATH;
 const ID_CERT_PATH = process.env.CBN_ID_CERT_PATH;
@@ -57,75 +57,34 @@ async function processSettlementEvent(evt, contract, cp) {
   console.log(`Processing event ${evt.eventName} for payment ID ${id}…`);
 
   try {
-    // Call SettlePayment transaction with the payment ID
+    // Debit the payer and credit the payee in one transaction
     const result = await contract.submit("SettlePayment", {
       arguments: [Buffer.from(evt.payload)],
     });
-    console.log(
-      `Payment ${id} successfully ${Buffer.from(result)} through CBN`
-    );
-  } catch (err) {
-    console.error(`Failed to settle payment ${id}:`, err);
-  }
-
-  await cp.checkpointChaincodeEvent(evt);
-}
-
-/* ---------- payment settlement processing ---------------------------------- */
-async function processSettlementEventV2(evt, contract, cp) {
-  const paymentData = JSON.parse(Buffer.from(evt.payload).toString("utf8"));
-  const { id, payerMSP, payeeMSP } = paymentData;
-  console.log(
-    `Processing event ${evt.eventName} for payment ID ${id} from ${payerMSP} to ${payeeMSP}...`
-  );
-
-  try {
-    // Step 1: Attempt to debit the payer's account
-    console.log(`Debiting from ${payerMSP}...`);
-    const debitResult = await contract.submit("DebitAccount", {
-      arguments: [Buffer.from(evt.payload)],
-    });
-
-    const debitStatus = Buffer.from(debitResult).toString();
-    console.log(`Debit result: ${debitStatus}`);
 
-    // Check if debit was successful
-    if (debitStatus === "QUEUED") {
+    const status = Buffer.from(result).toString();
+    if (status === "QUEUED") {
       console.log(
-        `Payment ${id} queued due to insufficient funds in ${payerMSP}. Settlement will be attempted later through netting.`
+        `Payment ${id} queued due to insufficient funds. Settlement will be attempted later.`
       );
-      await cp.checkpointChaincodeEvent(evt);
-      return; // Exit early - do not proceed to credit
-    }
-
-    if (debitStatus !== "SUCCESS") {
-      throw new Error(`Unexpected debit result: ${debitStatus}`);
+    } else {
+      console.log(`Payment ${id} successfully settled through CBN`);
     }
-
-    // Step 2: If debit was successful, proceed to credit the payee
-    console.log(`Crediting ${payeeMSP}...`);
-    await contract.submit("CreditAccount", {
-      arguments: [Buffer.from(evt.payload)],
-    });
-
-    console.log(
-      `Payment ${id} successfully settled: ${payerMSP} -> ${payeeMSP} in eNaira`
-    );
   } catch (err) {
     console.error(`Failed to settle payment ${id}:`, err);
-
-    // If credit failed after successful debit, we need to handle this scenario
-    // This would require implementing a rollback mechanism or compensation transaction
-    if (err.message && err.message.includes("CreditAccount")) {
-      console.error(
-        `CRITICAL: Debit succeeded but credit failed for payment ${id}. Manual intervention may be required.`
-      );
-    }
   }
 
   await cp.checkpointChaincodeEvent(evt);
 }
 
+/* SettlePayment only exists on GROSS channels; on DEFERRED_NET channels the
+   acknowledged payment is batched for netting instead. The mode is read per event
+   because the Central Bank can switch it while the listener runs. */
+async function getSettlementMode(contract) {
+  const configBytes = await contract.evaluateTransaction("GetSettlementConfig");
+  return JSON.parse(utf8Decoder.decode(configBytes)).mode;
+}
+
 async function startListener(gateway) {
   const network = gateway.getNetwork(CHANNEL);
   const contract = network.getContract(CHAINCODE);
@@ -142,7 +101,16 @@ async function startListener(gateway) {
     try {
       for await (const evt of stream) {
         if (evt.eventName !== "PaymentAcknowledged") continue;
-        await processSettlementEventV2(evt, contract, cp);
+
+        const mode = await getSettlementMode(contract);
+        if (mode !== "GROSS") {
+          console.log(
+            `Skipping ${evt.eventName}: channel settles in ${mode} mode, not GROSS`
+          );
+          await cp.checkpointChaincodeEvent(evt);
+          continue;
+        }
+        await processSettlementEvent(evt, contract, cp);
       }
     } catch (err) {
       console.error("🔌 event stream dropped, reconnecting…", err);
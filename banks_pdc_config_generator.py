@@ -57,5 +57,34 @@ for b in banks:
         "endorsementPolicy": {"signaturePolicy": policy}
     })
 
+# Intraday liquidity collections: collateral pledged by the bank and its CBN facility
+for b in banks:
+    coll = f"col-liquidity-{b}"
+    policy = f"OR('{b}.member','CentralBankPeerMSP.member')"
+    config.append({
+        "name": coll,
+        "policy": policy,
+        "memberOnlyRead": True,
+        "memberOnlyWrite": True,
+        "requiredPeerCount": 1,
+        "maxPeerCount": 2,
+        "blockToLive": 0,
+        "endorsementPolicy": {"signaturePolicy": policy}
+    })
+
+# Guarantee fund collection: the fund and its usage, seen by the CBN alone. Each bank's own
+# contribution and liability are copied to its col-settlement collection.
+guarantee_policy = "OR('CentralBankPeerMSP.member')"
+config.append({
+    "name": "col-guarantee",
+    "policy": guarantee_policy,
+    "memberOnlyRead": True,
+    "memberOnlyWrite": True,
+    "requiredPeerCount": 0,
+    "maxPeerCount": 1,
+    "blockToLive": 0,
+    "endorsementPolicy": {"signaturePolicy": guarantee_policy}
+})
+
 # Output JSON
 print(json.dumps(config, indent=2))
@@ -4,6 +4,7 @@ package settlement
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -122,35 +123,11 @@ func (s *SmartContract) GetQueuedTransactionDetails(ctx contractapi.TransactionC
 	// Get the bilateral collection name
 	collectionName := getCollectionName(callerMSP, otherMSP)
 
-	// Get all records from the private data collection
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, "", "")
+	// Only include QUEUED (and partially offset) transactions
+	queuedTransactions, err := s.queryPayments(ctx, collectionName, paymentFilter{Statuses: queuedStatuses})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get private data from collection %s: %v", collectionName, err)
 	}
-	defer resultsIterator.Close()
-
-	var queuedTransactions []*PaymentDetails
-
-	// Iterate through all records in the collection
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get next result: %v", err)
-		}
-
-		var payment PaymentDetails
-		err = json.Unmarshal(queryResponse.Value, &payment)
-		if err != nil {
-			// Log error but continue processing
-			fmt.Printf("Error unmarshaling payment record %s: %v\n", queryResponse.Key, err)
-			continue
-		}
-
-		// Only include QUEUED transactions
-		if payment.Status == "QUEUED" {
-			queuedTransactions = append(queuedTransactions, &payment)
-		}
-	}
 
 	return queuedTransactions, nil
 }
@@ -273,13 +250,23 @@ func (s *SmartContract) GetAllTransactionAnalytics(ctx contractapi.TransactionCo
 	collectionNames := s.getAllBilateralCollections(callerMSP)
 
 	// Process each collection
+	var settleSeconds int64
+	var timedSettlements int
 	for _, collectionName := range collectionNames {
-		err := s.processCollectionTransactions(ctx, collectionName, analytics)
+		settleTimes, err := s.processCollectionTransactions(ctx, collectionName, analytics)
 		if err != nil {
 			// Log error but continue with other collections
 			fmt.Printf("Error processing collection %s: %v\n", collectionName, err)
 			continue
 		}
+		for _, seconds := range settleTimes {
+			settleSeconds += seconds
+			timedSettlements++
+		}
+	}
+
+	if timedSettlements > 0 {
+		analytics.AverageTimeToSettleSeconds = float64(settleSeconds) / float64(timedSettlements)
 	}
 
 	return analytics, nil
@@ -302,37 +289,28 @@ func (s *SmartContract) getAllBilateralCollections(callerMSP string) []string {
 	return collections
 }
 
-// Helper function to process transactions from a single collection
-func (s *SmartContract) processCollectionTransactions(ctx contractapi.TransactionContextInterface, collectionName string, analytics *TransactionAnalytics) error {
-	// Get all records from the private data collection
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, "", "")
+// Helper function to process transactions from a single collection. Returns how long each
+// settled payment with recorded lifecycle times took to settle, in seconds.
+func (s *SmartContract) processCollectionTransactions(ctx contractapi.TransactionContextInterface, collectionName string, analytics *TransactionAnalytics) ([]int64, error) {
+	// Get every payment record from the private data collection
+	payments, err := s.queryPayments(ctx, collectionName, paymentFilter{})
 	if err != nil {
-		return fmt.Errorf("failed to get private data from collection %s: %v", collectionName, err)
+		return nil, fmt.Errorf("failed to get private data from collection %s: %v", collectionName, err)
 	}
-	defer resultsIterator.Close()
-
-	// Process each transaction record
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return fmt.Errorf("failed to get next result: %v", err)
-		}
 
-		var payment PaymentDetails
-		err = json.Unmarshal(queryResponse.Value, &payment)
-		if err != nil {
-			// Log error but continue processing other records
-			fmt.Printf("Error unmarshaling payment record %s in collection %s: %v\n",
-				queryResponse.Key, collectionName, err)
-			continue
-		}
+	var settleTimes []int64
 
+	// Process each transaction record
+	for _, payment := range payments {
 		// Aggregate based on status
 		switch strings.ToUpper(payment.Status) {
 		case "COMPLETED", "SETTLED":
 			analytics.Completed.Count++
 			analytics.Completed.Volume += payment.Amount
-		case "QUEUED":
+			if seconds, ok := timeToSettle(payment); ok {
+				settleTimes = append(settleTimes, seconds)
+			}
+		case "QUEUED", "PARTIALLY_SETTLED":
 			analytics.Queued.Count++
 			analytics.Queued.Volume += payment.AmountToSettle
 		case "PENDING", "ACKNOWLEDGED":
@@ -348,7 +326,7 @@ func (s *SmartContract) processCollectionTransactions(ctx contractapi.Transactio
 		}
 	}
 
-	return nil
+	return settleTimes, nil
 }
 
 func (s *SmartContract) GetAllBankTransactions(ctx contractapi.TransactionContextInterface) ([]*PaymentDetails, error) {
@@ -419,33 +397,44 @@ func (s *SmartContract) GetTransactionHistory(
 			continue
 		}
 
-		// format timestamps
-		ts := time.UnixMilli(pd.Timestamp).UTC().Format(time.RFC3339Nano)
-
-		var settledAt string
-		if pd.Status == "SETTLED" {
-			sa := ts
-			settledAt = sa
-		}
-
-		entry := TransactionHistoryEntry{
-			Amount:      pd.Amount,
-			Currency:    pd.Currency,
-			PayeeMSP:    pd.PayeeMSP,
-			PayerAcct:   pd.PayerAcct,
-			PayerMSP:    pd.PayerMSP,
-			PaymentId:   pd.ID,
-			SettledAt:   settledAt,
-			Status:      pd.Status,
-			Timestamp:   ts,
-			BatchWindow: pd.BatchWindow,
-		}
+		entry := newTransactionHistoryEntry(pd)
 		entries = append(entries, entry)
 	}
 
 	return entries, nil
 }
 
+// newTransactionHistoryEntry formats a payment for the transaction history views
+func newTransactionHistoryEntry(pd *PaymentDetails) TransactionHistoryEntry {
+	// format timestamps
+	ts := time.UnixMilli(pd.Timestamp).UTC().Format(time.RFC3339Nano)
+
+	entry := TransactionHistoryEntry{
+		Amount:      pd.Amount,
+		Currency:    pd.Currency,
+		PayeeMSP:    pd.PayeeMSP,
+		PayerAcct:   pd.PayerAcct,
+		PayerMSP:    pd.PayerMSP,
+		PaymentId:   pd.ID,
+		Status:      pd.Status,
+		Timestamp:   ts,
+		BatchWindow: pd.BatchWindow,
+	}
+
+	// settled time comes from the settling transaction, not the payment's creation
+	if pd.Status == "SETTLED" && pd.SettledAt > 0 {
+		entry.SettledAt = time.Unix(pd.SettledAt, 0).UTC().Format(time.RFC3339)
+		entry.SettlementCycleID = pd.SettlementCycleID
+	}
+	if seconds, ok := timeToSettle(pd); ok {
+		entry.TimeToSettleSeconds = seconds
+	}
+
+	return entry
+}
+
+// GetCounterpartyStats summarises the caller's activity and unsettled exposure with each
+// other bank, broken down by lifecycle stage, plus settled volume per day
 func (s *SmartContract) GetCounterpartyStats(
 	ctx contractapi.TransactionContextInterface,
 ) ([]*CounterpartyStats, error) {
@@ -454,7 +443,7 @@ func (s *SmartContract) GetCounterpartyStats(
 		return nil, fmt.Errorf("failed to get client MSP: %v", err)
 	}
 
-	var stats []*CounterpartyStats
+	stats := make([]*CounterpartyStats, 0)
 
 	for _, otherMSP := range getBankMSPs() {
 		if otherMSP == clientMSP {
@@ -462,48 +451,74 @@ func (s *SmartContract) GetCounterpartyStats(
 		}
 
 		coll := getCollectionName(clientMSP, otherMSP)
-		iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+		payments, err := s.queryPayments(ctx, coll, paymentFilter{})
 		if err != nil {
-			return nil, fmt.Errorf("failed to read private data collection %s: %v", coll, err)
+			return nil, err
 		}
 
-		count := 0
-		var volume, net float64
+		stat := &CounterpartyStats{BankMSP: otherMSP}
+		settled := make(map[int64]*SettledPeriod)
 
-		for iter.HasNext() {
-			qr, err := iter.Next()
-			if err != nil {
-				iter.Close()
-				return nil, fmt.Errorf("iterator error on %s: %v", coll, err)
-			}
+		for _, pd := range payments {
+			stat.TransactionCount++
+			stat.TransactionVolume += pd.Amount
 
-			var pd PaymentDetails
-			if err := json.Unmarshal(qr.Value, &pd); err != nil {
+			if pd.Status == "SETTLED" {
+				settledAt := pd.SettledAt
+				if settledAt == 0 {
+					settledAt = createdTime(pd)
+				}
+				day := settledAt - settledAt%86400
+				if settled[day] == nil {
+					settled[day] = &SettledPeriod{PeriodStart: day}
+				}
+				settled[day].Count++
+				settled[day].Volume = roundToKobo(settled[day].Volume + pd.Amount)
+				stat.SettledVolume += pd.Amount
 				continue
 			}
 
-			count++
-			volume += pd.Amount
+			// incoming (payee) minus outgoing (payer), on what is still to settle
+			var net *float64
+			switch {
+			case pd.Status == "PENDING" || pd.Status == "ACKNOWLEDGED":
+				net = &stat.NetPending
+			case pd.Status == "BATCHED":
+				net = &stat.NetBatched
+			case isQueuedStatus(pd.Status):
+				net = &stat.NetQueued
+			default:
+				continue // returned or otherwise closed without settling
+			}
 
-			// netPosition calculation considers QUEUED and BATCHED payments
-			if pd.Status == "QUEUED" || pd.Status == "BATCHED" {
-				// netPosition: incoming (payee) minus outgoing (payer)
-				if pd.PayeeMSP == clientMSP {
-					net += pd.AmountToSettle
-				}
-				if pd.PayerMSP == clientMSP {
-					net -= pd.AmountToSettle
-				}
+			if pd.PayeeMSP == clientMSP {
+				stat.GrossIncoming += pd.AmountToSettle
+				*net += pd.AmountToSettle
+			}
+			if pd.PayerMSP == clientMSP {
+				stat.GrossOutgoing += pd.AmountToSettle
+				*net -= pd.AmountToSettle
 			}
 		}
-		iter.Close()
 
-		stats = append(stats, &CounterpartyStats{
-			BankMSP:           otherMSP,
-			TransactionCount:  count,
-			TransactionVolume: volume,
-			NetPosition:       net,
+		stat.TransactionVolume = roundToKobo(stat.TransactionVolume)
+		stat.GrossIncoming = roundToKobo(stat.GrossIncoming)
+		stat.GrossOutgoing = roundToKobo(stat.GrossOutgoing)
+		stat.NetPending = roundToKobo(stat.NetPending)
+		stat.NetBatched = roundToKobo(stat.NetBatched)
+		stat.NetQueued = roundToKobo(stat.NetQueued)
+		stat.NetPosition = roundToKobo(stat.NetPending + stat.NetBatched + stat.NetQueued)
+		stat.SettledVolume = roundToKobo(stat.SettledVolume)
+
+		stat.SettledByPeriod = make([]SettledPeriod, 0, len(settled))
+		for _, period := range settled {
+			stat.SettledByPeriod = append(stat.SettledByPeriod, *period)
+		}
+		sort.Slice(stat.SettledByPeriod, func(i, j int) bool {
+			return stat.SettledByPeriod[i].PeriodStart < stat.SettledByPeriod[j].PeriodStart
 		})
+
+		stats = append(stats, stat)
 	}
 
 	return stats, nil
@@ -520,12 +535,17 @@ func (s *SmartContract) GetBatchWindowSummary(ctx contractapi.TransactionContext
 		return nil, fmt.Errorf("unauthorized MSP: %s", clientMSP)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	summary := &BatchWindowSummary{
 		BatchWindow:   batchWindow,
 		CallerMSP:     clientMSP,
 		StatusCounts:  make(map[string]int),
 		StatusAmounts: make(map[string]float64),
-		Timestamp:     time.Now().Unix(),
+		Timestamp:     now,
 	}
 
 	// Scan all bilateral collections for payments in this batch window
@@ -535,32 +555,19 @@ func (s *SmartContract) GetBatchWindowSummary(ctx contractapi.TransactionContext
 		}
 
 		coll := getCollectionName(clientMSP, otherMSP)
-		iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+		// Only include payments from the specified batch window
+		payments, err := s.queryPayments(ctx, coll, paymentFilter{BatchWindow: batchWindow})
 		if err != nil {
 			continue // Skip inaccessible collections
 		}
-		defer iter.Close()
-
-		for iter.HasNext() {
-			qr, err := iter.Next()
-			if err != nil {
-				continue
-			}
-
-			var payment PaymentDetails
-			if err := json.Unmarshal(qr.Value, &payment); err != nil {
-				continue
-			}
 
-			// Only include payments from the specified batch window
-			if payment.BatchWindow == batchWindow {
-				summary.TotalCount++
-				summary.TotalAmount += payment.Amount
+		for _, payment := range payments {
+			summary.TotalCount++
+			summary.TotalAmount += payment.Amount
 
-				// Track by status
-				summary.StatusCounts[payment.Status]++
-				summary.StatusAmounts[payment.Status] += payment.Amount
-			}
+			// Track by status
+			summary.StatusCounts[payment.Status]++
+			summary.StatusAmounts[payment.Status] += payment.Amount
 		}
 	}
 
@@ -635,29 +642,15 @@ func (s *SmartContract) GetBatchedTransactionsForWindow(ctx contractapi.Transact
 		}
 
 		collectionName := getCollectionName(clientMSP, otherMSP)
-		resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, "", "")
+		// Include BATCHED payments from the specified batch window
+		payments, err := s.queryPayments(ctx, collectionName, paymentFilter{
+			Statuses:    []string{"BATCHED"},
+			BatchWindow: batchWindow,
+		})
 		if err != nil {
 			continue // Skip inaccessible collections
 		}
-		defer resultsIterator.Close()
-
-		for resultsIterator.HasNext() {
-			queryResponse, err := resultsIterator.Next()
-			if err != nil {
-				continue
-			}
-
-			var payment PaymentDetails
-			err = json.Unmarshal(queryResponse.Value, &payment)
-			if err != nil {
-				continue
-			}
-
-			// Include BATCHED payments from the specified batch window
-			if payment.Status == "BATCHED" && payment.BatchWindow == batchWindow {
-				batchedTransactions = append(batchedTransactions, &payment)
-			}
-		}
+		batchedTransactions = append(batchedTransactions, payments...)
 	}
 
 	return batchedTransactions, nil
@@ -665,70 +658,33 @@ func (s *SmartContract) GetBatchedTransactionsForWindow(ctx contractapi.Transact
 
 // Helper function to get batched transactions from a specific collection
 func (s *SmartContract) getBatchedTransactionsFromCollection(ctx contractapi.TransactionContextInterface, collectionName string) (int, float64, error) {
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, "", "")
+	payments, err := s.queryPayments(ctx, collectionName, paymentFilter{Statuses: []string{"BATCHED"}})
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get private data from collection %s: %v", collectionName, err)
 	}
-	defer resultsIterator.Close()
 
-	var batchedCount int
 	var batchedTotalAmount float64
-
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to get next result: %v", err)
-		}
-
-		var payment PaymentDetails
-		err = json.Unmarshal(queryResponse.Value, &payment)
-		if err != nil {
-			fmt.Printf("Error unmarshaling payment record %s: %v\n", queryResponse.Key, err)
-			continue
-		}
-
-		if payment.Status == "BATCHED" {
-			batchedCount++
-			batchedTotalAmount += payment.AmountToSettle
-		}
+	for _, payment := range payments {
+		batchedTotalAmount += payment.AmountToSettle
 	}
+	batchedCount := len(payments)
 
 	return batchedCount, batchedTotalAmount, nil
 }
 
 // Helper function to get queued transactions from a specific collection
 func (s *SmartContract) getQueuedTransactionsFromCollection(ctx contractapi.TransactionContextInterface, collectionName string) (int, float64, error) {
-	// Get all records from the private data collection
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, "", "")
+	// Get QUEUED (or partially offset) records from the private data collection
+	payments, err := s.queryPayments(ctx, collectionName, paymentFilter{Statuses: queuedStatuses})
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get private data from collection %s: %v", collectionName, err)
 	}
-	defer resultsIterator.Close()
 
-	var queuedCount int
 	var queuedTotalAmount float64
-
-	// Iterate through all records in the collection
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to get next result: %v", err)
-		}
-
-		var payment PaymentDetails
-		err = json.Unmarshal(queryResponse.Value, &payment)
-		if err != nil {
-			// Log error but continue processing
-			fmt.Printf("Error unmarshaling payment record %s: %v\n", queryResponse.Key, err)
-			continue
-		}
-
-		// Check if status is QUEUED
-		if payment.Status == "QUEUED" {
-			queuedCount++
-			queuedTotalAmount += payment.AmountToSettle
-		}
+	for _, payment := range payments {
+		queuedTotalAmount += payment.AmountToSettle
 	}
+	queuedCount := len(payments)
 
 	return queuedCount, queuedTotalAmount, nil
 }
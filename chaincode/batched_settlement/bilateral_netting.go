@@ -4,76 +4,279 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// CalculateBilateralOffset offsets the queued payments between two banks against each
+// other. Each direction is consumed oldest-first (FIFO by timestamp), amounts are rounded
+// to kobo, and a payment only partly covered by the offset becomes PARTIALLY_SETTLED.
 func (s *SmartContract) CalculateBilateralOffset(ctx contractapi.TransactionContextInterface, mspA, mspB string) (*OffsetCalculation, error) {
-	coll := getCollectionName(mspA, mspB)
-	iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+	queueAB, queueBA, totalAB, totalBA, err := s.getBilateralQueues(ctx, mspA, mspB)
 	if err != nil {
-		return nil, fmt.Errorf("read PDC %s: %v", coll, err)
+		return nil, err
+	}
+
+	offset := roundToKobo(math.Min(totalAB, totalBA))
+
+	// build updates
+	updates := consumeQueueFIFO(queueAB, offset)
+	updates = append(updates, consumeQueueFIFO(queueBA, offset)...)
+
+	return &OffsetCalculation{
+		Offset:  offset,
+		Updates: updates,
+	}, nil
+}
+
+// ExecuteBilateralSettlement settles everything queued between two banks in one
+// transaction (CBN only): both directions are offset, then the residual owed by the
+// heavier side is moved between the banks' settlement accounts, up to the debtor's
+// balance. Whatever the debtor cannot fund stays queued. A single result record is
+// stored in the bilateral collection.
+func (s *SmartContract) ExecuteBilateralSettlement(ctx contractapi.TransactionContextInterface, mspA, mspB string) (*BilateralSettlementResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can execute bilateral settlement")
+	}
+	if mspA == mspB || !s.isAuthorizedBank(mspA) || !s.isAuthorizedBank(mspB) {
+		return nil, fmt.Errorf("bilateral settlement requires two different banks")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ExecuteBilateralSettlement"); err != nil {
+		return nil, err
+	}
+
+	queueAB, queueBA, totalAB, totalBA, err := s.getBilateralQueues(ctx, mspA, mspB)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BilateralSettlementResult{
+		ID:        ctx.GetStub().GetTxID(),
+		MSPA:      mspA,
+		MSPB:      mspB,
+		TotalAB:   roundToKobo(totalAB),
+		TotalBA:   roundToKobo(totalBA),
+		Offset:    roundToKobo(math.Min(totalAB, totalBA)),
+		Timestamp: now,
+	}
+
+	// The heavier side owes the residual
+	debtorQueue, creditorQueue := queueAB, queueBA
+	result.DebtorMSP, result.CreditorMSP = mspA, mspB
+	if totalBA > totalAB {
+		debtorQueue, creditorQueue = queueBA, queueAB
+		result.DebtorMSP, result.CreditorMSP = mspB, mspA
+	}
+	result.Residual = roundToKobo(math.Abs(totalAB - totalBA))
+
+	if result.Residual > 0 {
+		debtor, err := s.GetSettlementAccount(ctx, result.DebtorMSP)
+		if err != nil {
+			return nil, err
+		}
+		result.ResidualSettled = roundToKobo(math.Min(result.Residual, math.Max(debtor.Balance, 0)))
+		result.ResidualUnsettled = roundToKobo(result.Residual - result.ResidualSettled)
+	}
+
+	updates := consumeQueueFIFO(debtorQueue, roundToKobo(result.Offset+result.ResidualSettled))
+	updates = append(updates, consumeQueueFIFO(creditorQueue, result.Offset)...)
+
+	if result.Offset == 0 && result.ResidualSettled == 0 {
+		return nil, fmt.Errorf("nothing can be settled between %s and %s", mspA, mspB)
+	}
+
+	// Move the funded residual between the two settlement accounts
+	if result.ResidualSettled > 0 {
+		if _, err := s.debitSettlementAccount(ctx, result.DebtorMSP, result.ResidualSettled, nil); err != nil {
+			return nil, err
+		}
+		if err := s.creditSettlementAccount(ctx, result.CreditorMSP, result.ResidualSettled); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, u := range updates {
+		payment, err := s.getPaymentDetails(ctx, mspA, mspB, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		payment.AmountToSettle = u.AmountToSettle
+		payment.Status = u.Status
+		if err := s.putPaymentDetails(ctx, payment); err != nil {
+			return nil, err
+		}
+
+		if u.Status == "SETTLED" {
+			result.SettledPayments++
+		} else {
+			result.PartialPayments++
+		}
+	}
+	result.Updates = updates
+
+	// Persist the single result record alongside the payments it settled
+	key, err := ctx.GetStub().CreateCompositeKey(bilateralSettlementObjectType, []string{result.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bilateral settlement key: %v", err)
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bilateral settlement result: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(getCollectionName(mspA, mspB), key, resultBytes); err != nil {
+		return nil, fmt.Errorf("failed to store bilateral settlement result: %v", err)
+	}
+
+	evt := struct {
+		ID              string  `json:"id"`
+		MSPA            string  `json:"mspA"`
+		MSPB            string  `json:"mspB"`
+		Offset          float64 `json:"offset"`
+		ResidualSettled float64 `json:"residualSettled"`
+		SettledPayments int     `json:"settledPayments"`
+	}{result.ID, mspA, mspB, result.Offset, result.ResidualSettled, result.SettledPayments}
+	if err := s.emitSettlementEvent(ctx, "BilateralSettlementExecuted", evt); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetBilateralSettlementRecord returns a stored bilateral settlement result (either bank or CBN)
+func (s *SmartContract) GetBilateralSettlementRecord(ctx contractapi.TransactionContextInterface, mspA, mspB, id string) (*BilateralSettlementResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != mspA && clientMSP != mspB {
+		return nil, fmt.Errorf("unauthorized access to bilateral settlement records")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(bilateralSettlementObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bilateral settlement key: %v", err)
+	}
+	resultBytes, err := ctx.GetStub().GetPrivateData(getCollectionName(mspA, mspB), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bilateral settlement %s: %v", id, err)
+	}
+	if resultBytes == nil {
+		return nil, fmt.Errorf("bilateral settlement %s not found", id)
+	}
+
+	var result BilateralSettlementResult
+	if err := json.Unmarshal(resultBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bilateral settlement result: %v", err)
+	}
+	return &result, nil
+}
+
+// bilateralSettlementObjectType prefixes result records in the bilateral PDC; composite
+// keys are skipped by the plain range scans over payments
+const bilateralSettlementObjectType = "bilateralSettlement"
+
+// getBilateralQueues returns the queued payments in each direction, oldest first, leaving out
+// those of banks whose participant state bars them from netting
+func (s *SmartContract) getBilateralQueues(ctx contractapi.TransactionContextInterface, mspA, mspB string) ([]*PaymentDetails, []*PaymentDetails, float64, float64, error) {
+	queued, err := s.getQueuedPaymentsFromCollection(ctx, getCollectionName(mspA, mspB))
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	eligible, err := s.nettingEligibility(ctx)
+	if err != nil {
+		return nil, nil, 0, 0, err
 	}
-	defer iter.Close()
 
 	var queueAB, queueBA []*PaymentDetails
 	var totalAB, totalBA float64
-	for iter.HasNext() {
-		qr, _ := iter.Next()
-		var pd PaymentDetails
-		if err := json.Unmarshal(qr.Value, &pd); err != nil {
-			continue
-		}
-		if pd.Status != "QUEUED" {
+	for _, pd := range queued {
+		if !eligible(pd) {
 			continue
 		}
 		switch {
 		case pd.PayerMSP == mspA && pd.PayeeMSP == mspB:
-			queueAB = append(queueAB, &pd)
+			queueAB = append(queueAB, pd)
 			totalAB += pd.AmountToSettle
 		case pd.PayerMSP == mspB && pd.PayeeMSP == mspA:
-			queueBA = append(queueBA, &pd)
+			queueBA = append(queueBA, pd)
 			totalBA += pd.AmountToSettle
 		}
 	}
 
-	offset := math.Min(totalAB, totalBA)
+	// Oldest payments are offset first, independent of how their IDs sort
+	sortByTimestamp(queueAB)
+	sortByTimestamp(queueBA)
 
-	// build updates
+	return queueAB, queueBA, totalAB, totalBA, nil
+}
+
+// consumeQueueFIFO applies amount to the payments in order and returns one update per touched payment
+func consumeQueueFIFO(list []*PaymentDetails, amount float64) []OffsetUpdate {
 	updates := make([]OffsetUpdate, 0)
-	apply := func(list []*PaymentDetails, rem float64) float64 {
-		for _, pd := range list {
-			if rem == 0 {
-				break
-			}
-			deduct := math.Min(pd.AmountToSettle, rem)
-			pd.AmountToSettle -= deduct
-			rem -= deduct
-			status := "QUEUED"
-			if pd.AmountToSettle == 0 {
-				status = "SETTLED"
-			}
-			updates = append(updates, OffsetUpdate{
-				ID:             pd.ID,
-				AmountToSettle: pd.AmountToSettle,
-				Status:         status,
-			})
+	rem := roundToKobo(amount)
+	for _, pd := range list {
+		if rem <= 0 {
+			break
 		}
-		return rem
+		deduct := roundToKobo(math.Min(pd.AmountToSettle, rem))
+		pd.AmountToSettle = roundToKobo(pd.AmountToSettle - deduct)
+		rem = roundToKobo(rem - deduct)
+		status := "PARTIALLY_SETTLED"
+		if pd.AmountToSettle <= 0 {
+			pd.AmountToSettle = 0
+			status = "SETTLED"
+		}
+		updates = append(updates, OffsetUpdate{
+			ID:             pd.ID,
+			AmountToSettle: pd.AmountToSettle,
+			SettledPortion: deduct,
+			Status:         status,
+		})
 	}
-	apply(queueAB, offset)
-	apply(queueBA, offset)
+	return updates
+}
 
-	return &OffsetCalculation{
-		Offset:  offset,
-		Updates: updates,
-	}, nil
+// sortByTimestamp orders payments oldest first, breaking ties by ID
+func sortByTimestamp(list []*PaymentDetails) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Timestamp != list[j].Timestamp {
+			return list[i].Timestamp < list[j].Timestamp
+		}
+		return list[i].ID < list[j].ID
+	})
 }
 
+// ApplyBilateralOffset writes a calculated offset leg only (CBN only); no settlement-account
+// balance moves and any residual stays queued. The offset is recalculated from the queued
+// payments on the ledger and the one passed in transient data must match it, or it is
+// stale and rejected. Use ExecuteBilateralSettlement to settle it fully.
 func (s *SmartContract) ApplyBilateralOffset(
 	ctx contractapi.TransactionContextInterface,
 	mspA, mspB string,
 ) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can apply bilateral offsets")
+	}
+	if mspA == mspB || !s.isAuthorizedBank(mspA) || !s.isAuthorizedBank(mspB) {
+		return fmt.Errorf("bilateral offset requires two different banks")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ApplyBilateralOffset"); err != nil {
+		return err
+	}
+
 	trans, err := ctx.GetStub().GetTransient()
 	if err != nil {
 		return fmt.Errorf("transient error: %v", err)
@@ -83,36 +286,28 @@ func (s *SmartContract) ApplyBilateralOffset(
 		return fmt.Errorf("offsetUpdate required in transient")
 	}
 
-	type Update struct {
-		ID             string  `json:"id"`
-		AmountToSettle float64 `json:"amountToSettle"`
-		Status         string  `json:"status"`
-	}
-	var payload struct {
-		Offset  float64  `json:"offset"`
-		Updates []Update `json:"updates"`
-	}
+	var payload OffsetCalculation
 	if err := json.Unmarshal(data, &payload); err != nil {
 		return fmt.Errorf("unmarshal payload: %v", err)
 	}
 
-	coll := getCollectionName(mspA, mspB)
+	calculation, err := s.CalculateBilateralOffset(ctx, mspA, mspB)
+	if err != nil {
+		return err
+	}
+	if err := requireMatchingOffset(&payload, calculation); err != nil {
+		return err
+	}
 
-	for _, u := range payload.Updates {
-		existingBytes, err := ctx.GetStub().GetPrivateData(coll, u.ID)
-		if err != nil || existingBytes == nil {
-			return fmt.Errorf("payment %s not found", u.ID)
+	for _, u := range calculation.Updates {
+		pd, err := s.getPaymentDetails(ctx, mspA, mspB, u.ID)
+		if err != nil {
+			return err
 		}
-
-		// reconstruct PaymentDetails with new fields
-		var pd PaymentDetails
-		json.Unmarshal(existingBytes, &pd)
 		pd.AmountToSettle = u.AmountToSettle
 		pd.Status = u.Status
-
-		updated, _ := json.Marshal(pd)
-		if err := ctx.GetStub().PutPrivateData(coll, u.ID, updated); err != nil {
-			return fmt.Errorf("write failed for %s: %v", u.ID, err)
+		if err := s.putPaymentDetails(ctx, pd); err != nil {
+			return err
 		}
 	}
 
@@ -120,9 +315,26 @@ func (s *SmartContract) ApplyBilateralOffset(
 		MSPA   string  `json:"mspA"`
 		MSPB   string  `json:"mspB"`
 		Offset float64 `json:"offset"`
-	}{mspA, mspB, payload.Offset}
-	evtBytes, _ := json.Marshal(evt)
-	ctx.GetStub().SetEvent("BilateralOffsetExecuted", evtBytes)
+	}{mspA, mspB, calculation.Offset}
+	return s.emitSettlementEvent(ctx, "BilateralOffsetExecuted", evt)
+}
 
+// requireMatchingOffset rejects an offset that differs from the one the queued payments
+// give on the ledger, such as one calculated before a payment was queued or settled
+func requireMatchingOffset(calculated, ledger *OffsetCalculation) error {
+	if roundToKobo(calculated.Offset) != ledger.Offset {
+		return fmt.Errorf("offset does not match the queued payments: calculated %.2f, payments give %.2f",
+			calculated.Offset, ledger.Offset)
+	}
+	if len(calculated.Updates) != len(ledger.Updates) {
+		return fmt.Errorf("offset updates %d payments, the queued payments give %d",
+			len(calculated.Updates), len(ledger.Updates))
+	}
+	for i, u := range ledger.Updates {
+		c := calculated.Updates[i]
+		if c.ID != u.ID || c.Status != u.Status || roundToKobo(c.AmountToSettle) != u.AmountToSettle {
+			return fmt.Errorf("offset update for %s does not match the queued payments", c.ID)
+		}
+	}
 	return nil
 }
@@ -7,7 +7,8 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// initializeBVNRecords loads all BVN records into the col-BVN PDC
+// initializeBVNRecords loads all BVN records into the col-BVN PDC unless an earlier
+// onboarding already did
 func (s *SmartContract) initializeBVNRecords(ctx contractapi.TransactionContextInterface) error {
 	bvns := []BVNRecord{
 		// Original 10 records
@@ -55,12 +56,21 @@ func (s *SmartContract) initializeBVNRecords(ctx contractapi.TransactionContextI
 		{BVN: "61233445680", Firstname: "Nneka", Lastname: "Okoye", Middlename: "Chizoba", Gender: "Female", Phone: "08192345680", Birthdate: "26-11-1996"},
 	}
 
+	// The records are loaded in one write set, so the first one marks them all as present
+	loaded, err := ctx.GetStub().GetPrivateData(col_BVN, bvns[0].BVN)
+	if err != nil {
+		return fmt.Errorf("failed to read BVN record %s: %v", bvns[0].BVN, err)
+	}
+	if loaded != nil {
+		return nil
+	}
+
 	for _, rec := range bvns {
 		recBytes, err := json.Marshal(rec)
 		if err != nil {
 			return fmt.Errorf("marshal BVN record %s: %v", rec.BVN, err)
 		}
-		if err := ctx.GetStub().PutPrivateData("col-BVN", rec.BVN, recBytes); err != nil {
+		if err := ctx.GetStub().PutPrivateData(col_BVN, rec.BVN, recBytes); err != nil {
 			return fmt.Errorf("failed to put BVN record %s: %v", rec.BVN, err)
 		}
 	}
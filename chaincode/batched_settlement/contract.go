@@ -1,7 +1,6 @@
 package settlement
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -12,30 +11,90 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+// GetTransactionContextHandler gives every transaction a fresh settlementContext
+func (s *SmartContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(settlementContext)
+}
+
+// settlementContext is the context each transaction runs with. Reads only see committed
+// state, so it remembers the payment records the transaction has already written.
+type settlementContext struct {
+	contractapi.TransactionContext
+	paymentWrites map[string]*PaymentDetails
+}
+
+// paymentWriteTracker is implemented by contexts that remember a transaction's payment writes
+type paymentWriteTracker interface {
+	lastPaymentWrite(coll, id string) *PaymentDetails
+	recordPaymentWrite(coll string, payment *PaymentDetails)
+}
+
+// lastPaymentWrite returns the payment as this transaction last wrote it, or nil
+func (c *settlementContext) lastPaymentWrite(coll, id string) *PaymentDetails {
+	return c.paymentWrites[coll+"/"+id]
+}
+
+// recordPaymentWrite keeps a copy of a payment written by this transaction
+func (c *settlementContext) recordPaymentWrite(coll string, payment *PaymentDetails) {
+	if c.paymentWrites == nil {
+		c.paymentWrites = make(map[string]*PaymentDetails)
+	}
+	written := *payment
+	c.paymentWrites[coll+"/"+payment.ID] = &written
+}
+
 // Init - Method for initializing smart contract
 func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error {
 	return nil
 }
 
-// InitLedger seeds the invoking MSP with 15 billion eNaira and loads all BVN records into the col-BVN PDC.
-func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+// InitLedger onboards a bank (CBN only): it opens the bank's settlement account, mints the
+// opening balance into it and, on the first onboarding, loads all BVN records into the
+// col-BVN PDC. Each bank can be onboarded once; later funding goes through MintToBank.
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface, msp string, openingBalance float64) error {
 	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
 		return fmt.Errorf("failed to get client MSP: %v", err)
 	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can onboard banks")
+	}
+	if !s.isAuthorizedBank(msp) {
+		return fmt.Errorf("unknown bank %s", msp)
+	}
+	if openingBalance < 0 {
+		return fmt.Errorf("opening balance must not be negative")
+	}
 
-	// seed account balance
-	starting := 15_000_000.0 // 15 billion eNaira
-	acct := BankAccount{MSP: clientMSP, Balance: starting}
-	acctBytes, err := json.Marshal(acct)
+	acctColl := fmt.Sprintf("col-settlement-%s", msp)
+	acctBytes, err := ctx.GetStub().GetPrivateData(acctColl, msp)
 	if err != nil {
-		return fmt.Errorf("marshal init account for %s: %v", clientMSP, err)
+		return fmt.Errorf("failed to read settlement account for %s: %v", msp, err)
 	}
-	acctColl := fmt.Sprintf("col-settlement-%s", clientMSP)
-	if err := ctx.GetStub().PutPrivateData(acctColl, clientMSP, acctBytes); err != nil {
-		return fmt.Errorf("init account for %s: %v", clientMSP, err)
+	if acctBytes != nil {
+		return fmt.Errorf("bank %s is already onboarded", msp)
+	}
+
+	// Fabric keeps one event per transaction, so BankOnboarded carries the mint record
+	account := &BankAccount{MSP: msp}
+	var record *MintRecord
+	if openingBalance > 0 {
+		record, err = s.mint(ctx, account, openingBalance)
+		if err != nil {
+			return fmt.Errorf("init account for %s: %v", msp, err)
+		}
+	} else if err := s.putSettlementAccount(ctx, account); err != nil {
+		return err
+	}
+
+	if err := s.emitSettlementEvent(ctx, "BankOnboarded", map[string]interface{}{
+		"msp":            msp,
+		"openingBalance": openingBalance,
+		"mint":           record,
+	}); err != nil {
+		return err
 	}
 
-	// Initialize BVN records
+	// Initialize BVN records once for the network
 	return s.initializeBVNRecords(ctx)
 }
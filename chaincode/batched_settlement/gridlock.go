@@ -0,0 +1,209 @@
+// gridlock.go - Gridlock resolution for queued payments
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// gridlockTolerance absorbs float noise when comparing positions against balances and limits
+const gridlockTolerance = 1e-6
+
+// gridlockBreach describes the worst constraint violated by the current candidate set
+type gridlockBreach struct {
+	PayerMSP        string
+	CounterpartyMSP string // set only for bilateral limit breaches
+	Amount          float64
+	Reason          string
+}
+
+// resolveQueuedGridlock loads balances and limits for every bank and selects the
+// queued payments that can settle simultaneously
+func (s *SmartContract) resolveQueuedGridlock(ctx contractapi.TransactionContextInterface, queued []*PaymentDetails) (*MultiOffsetCalculation, error) {
+	balances := make(map[string]float64)
+	limits := make(map[string][]*ExposureLimit)
+
+	for _, bankMSP := range getBankMSPs() {
+		coll := fmt.Sprintf("col-settlement-%s", bankMSP)
+		accountBytes, err := ctx.GetStub().GetPrivateData(coll, bankMSP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get settlement account for %s: %v", bankMSP, err)
+		}
+		// A bank that has not been onboarded has nothing to settle with
+		if accountBytes != nil {
+			var account BankAccount
+			if err := json.Unmarshal(accountBytes, &account); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal account for %s: %v", bankMSP, err)
+			}
+			balances[bankMSP] = account.Balance
+		}
+
+		bankLimits, err := s.getExposureLimits(ctx, bankMSP)
+		if err != nil {
+			return nil, err
+		}
+		limits[bankMSP] = bankLimits
+	}
+
+	selected, decisions, iterations := selectSettleablePayments(queued, balances, limits)
+
+	netPos := make(map[string]float64)
+	updates := make([]MultiOffsetUpdate, 0, len(selected))
+	for _, pd := range selected {
+		// Build net position: incoming minus outgoing
+		netPos[pd.PayeeMSP] += pd.AmountToSettle
+		netPos[pd.PayerMSP] -= pd.AmountToSettle
+
+		// Buffer an update to mark this row SETTLED (zero out AmountToSettle)
+		updates = append(updates, MultiOffsetUpdate{
+			ID:             pd.ID,
+			PayerMSP:       pd.PayerMSP,
+			PayeeMSP:       pd.PayeeMSP,
+			AmountToSettle: 0,
+			Status:         "SETTLED",
+		})
+	}
+
+	return &MultiOffsetCalculation{
+		NetPositions: netPos,
+		Updates:      updates,
+		Decisions:    decisions,
+		Iterations:   iterations,
+		Efficiency:   buildNettingEfficiency(selected, netPos),
+		TransferPlan: buildTransferPlan(netPos),
+	}, nil
+}
+
+// selectSettleablePayments finds a large subset of queued payments whose combined net
+// positions every bank can fund. It repeatedly nets the candidate set, picks the payer
+// with the largest breach of its balance or exposure limits, and removes that payer's
+// last payment in queue order, until no bank breaches. The removed payments stay queued.
+func selectSettleablePayments(queued []*PaymentDetails, balances map[string]float64, limits map[string][]*ExposureLimit) ([]*PaymentDetails, []GridlockDecision, int) {
+	candidates := make([]*PaymentDetails, len(queued))
+	copy(candidates, queued)
+	sortQueue(candidates)
+
+	var decisions []GridlockDecision
+	iterations := 0
+
+	for {
+		breach := findWorstBreach(candidates, balances, limits)
+		if breach == nil {
+			break
+		}
+		iterations++
+
+		// Remove the payer's last candidate in queue order (towards the counterparty for bilateral breaches)
+		removeAt := -1
+		for i := len(candidates) - 1; i >= 0; i-- {
+			pd := candidates[i]
+			if pd.PayerMSP != breach.PayerMSP {
+				continue
+			}
+			if breach.CounterpartyMSP != "" && pd.PayeeMSP != breach.CounterpartyMSP {
+				continue
+			}
+			removeAt = i
+			break
+		}
+		if removeAt < 0 {
+			// A breaching payer always has outgoing candidates; guard against looping forever
+			break
+		}
+
+		removed := candidates[removeAt]
+		candidates = append(candidates[:removeAt], candidates[removeAt+1:]...)
+		decisions = append(decisions, GridlockDecision{
+			ID:             removed.ID,
+			PayerMSP:       removed.PayerMSP,
+			PayeeMSP:       removed.PayeeMSP,
+			AmountToSettle: removed.AmountToSettle,
+			Selected:       false,
+			Reason:         breach.Reason,
+		})
+	}
+
+	for _, pd := range candidates {
+		decisions = append(decisions, GridlockDecision{
+			ID:             pd.ID,
+			PayerMSP:       pd.PayerMSP,
+			PayeeMSP:       pd.PayeeMSP,
+			AmountToSettle: pd.AmountToSettle,
+			Selected:       true,
+			Reason:         "payer can fund its net position within balance and limits",
+		})
+	}
+
+	return candidates, decisions, iterations
+}
+
+// findWorstBreach nets the candidate set and returns the largest balance or limit breach, if any
+func findWorstBreach(candidates []*PaymentDetails, balances map[string]float64, limits map[string][]*ExposureLimit) *gridlockBreach {
+	netPos := make(map[string]float64)
+	owes := make(map[string]map[string]float64) // owes[payer][payee] gross amount
+	for _, pd := range candidates {
+		netPos[pd.PayeeMSP] += pd.AmountToSettle
+		netPos[pd.PayerMSP] -= pd.AmountToSettle
+		if owes[pd.PayerMSP] == nil {
+			owes[pd.PayerMSP] = make(map[string]float64)
+		}
+		owes[pd.PayerMSP][pd.PayeeMSP] += pd.AmountToSettle
+	}
+
+	banks := make([]string, 0, len(netPos))
+	for bank := range netPos {
+		banks = append(banks, bank)
+	}
+	sort.Strings(banks)
+
+	var worst *gridlockBreach
+	consider := func(b *gridlockBreach) {
+		if worst == nil || b.Amount > worst.Amount+gridlockTolerance {
+			worst = b
+		}
+	}
+
+	for _, bank := range banks {
+		// Net receivers can only breach a bilateral limit
+		netDebit := -netPos[bank]
+
+		if shortfall := netDebit - balances[bank]; netDebit > 0 && shortfall > gridlockTolerance {
+			consider(&gridlockBreach{
+				PayerMSP: bank,
+				Amount:   shortfall,
+				Reason: fmt.Sprintf("payer %s net debit %.2f exceeds balance %.2f by %.2f",
+					bank, netDebit, balances[bank], shortfall),
+			})
+		}
+
+		for _, limit := range limits[bank] {
+			switch limit.Type {
+			case "MULTILATERAL":
+				if excess := netDebit - limit.Limit; netDebit > 0 && excess > gridlockTolerance {
+					consider(&gridlockBreach{
+						PayerMSP: bank,
+						Amount:   excess,
+						Reason: fmt.Sprintf("payer %s net debit %.2f exceeds multilateral limit %.2f",
+							bank, netDebit, limit.Limit),
+					})
+				}
+			case "BILATERAL":
+				bilateral := owes[bank][limit.CounterpartyMSP] - owes[limit.CounterpartyMSP][bank]
+				if excess := bilateral - limit.Limit; excess > gridlockTolerance {
+					consider(&gridlockBreach{
+						PayerMSP:        bank,
+						CounterpartyMSP: limit.CounterpartyMSP,
+						Amount:          excess,
+						Reason: fmt.Sprintf("payer %s net debit to %s %.2f exceeds bilateral limit %.2f",
+							bank, limit.CounterpartyMSP, bilateral, limit.Limit),
+					})
+				}
+			}
+		}
+	}
+
+	return worst
+}
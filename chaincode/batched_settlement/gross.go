@@ -0,0 +1,222 @@
+// gross.go - Real-time gross settlement: GROSS channels and the urgent lane of DEFERRED_NET ones
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Debit recovery actions
+const (
+	RecoveryComplete = "COMPLETE" // credit the payee and settle the payment
+	RecoveryReverse  = "REVERSE"  // refund the payer and return the payment unsettled
+)
+
+// SettlePayment settles an acknowledged payment gross, debiting the payer and crediting
+// the payee in the same transaction (CBN only, GROSS mode). Returns "SUCCESS" if settled,
+// or "QUEUED" when the payer lacks funds; a queued payment can be settled again once the
+// payer is funded.
+func (s *SmartContract) SettlePayment(ctx contractapi.TransactionContextInterface, paymentDetails PaymentEventDetails) (string, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return "", fmt.Errorf("only Central Bank can settle payments")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeGross, "SettlePayment"); err != nil {
+		return "", err
+	}
+
+	payment, err := s.getPaymentDetails(ctx, paymentDetails.PayerMSP, paymentDetails.PayeeMSP, paymentDetails.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get payment details: %v", err)
+	}
+	if payment.Status != "ACKNOWLEDGED" && payment.Status != "QUEUED" {
+		return "", fmt.Errorf("payment %s is not in ACKNOWLEDGED or QUEUED state, current status: %s", payment.ID, payment.Status)
+	}
+
+	return s.settleGross(ctx, payment)
+}
+
+// RecoverDebitedPayments resolves payments left DEBITED for longer than olderThanSeconds
+// by the former split DebitAccount/CreditAccount transactions (CBN only). COMPLETE credits
+// the payee and settles each payment; REVERSE refunds the payer and returns it unsettled.
+func (s *SmartContract) RecoverDebitedPayments(ctx contractapi.TransactionContextInterface, olderThanSeconds int64, action string) (*DebitRecoveryResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can recover debited payments")
+	}
+	if action != RecoveryComplete && action != RecoveryReverse {
+		return nil, fmt.Errorf("unknown recovery action %s: must be %s or %s", action, RecoveryComplete, RecoveryReverse)
+	}
+	if olderThanSeconds < 0 {
+		return nil, fmt.Errorf("olderThanSeconds must not be negative")
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &DebitRecoveryResult{
+		Action:            action,
+		OlderThanSeconds:  olderThanSeconds,
+		RecoveredPayments: make([]string, 0),
+		Timestamp:         now,
+	}
+
+	bankMSPs := getBankMSPs()
+	for i, bankA := range bankMSPs {
+		for _, bankB := range bankMSPs[i+1:] {
+			coll := getCollectionName(bankA, bankB)
+			debited, err := s.rangePayments(ctx, coll, paymentFilter{Statuses: []string{"DEBITED"}})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pd := range debited {
+				if now-debitedSince(pd) < olderThanSeconds {
+					continue
+				}
+
+				// The payer's leg already moved; move the other one
+				creditMSP := pd.PayeeMSP
+				pd.Status = "SETTLED"
+				if action == RecoveryReverse {
+					creditMSP = pd.PayerMSP
+					pd.Status = "RETURNED_UNSETTLED"
+				}
+				account, err := s.GetSettlementAccount(ctx, creditMSP)
+				if err != nil {
+					return nil, err
+				}
+				account.Balance += pd.AmountToSettle
+				if err := s.putSettlementAccount(ctx, account); err != nil {
+					return nil, err
+				}
+
+				result.RecoveredCount++
+				result.RecoveredAmount += pd.AmountToSettle
+				result.RecoveredPayments = append(result.RecoveredPayments, pd.ID)
+
+				if pd.Status == "SETTLED" {
+					pd.AmountToSettle = 0
+				}
+				if err := s.putPaymentDetails(ctx, pd); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := s.emitSettlementEvent(ctx, "DebitedPaymentsRecovered", result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// settleGross moves a payment's amount from the payer's to the payee's settlement account
+// in this transaction and marks it SETTLED. A payer short of funds draws on its intraday
+// facility; one the facility cannot cover gets the payment queued with reason
+// insufficient_funds instead, and an already queued payment is left as is. Banks whose
+// participant state bars the payment cannot settle it at all.
+func (s *SmartContract) settleGross(ctx contractapi.TransactionContextInterface, payment *PaymentDetails) (string, error) {
+	if err := s.requireParticipantsCanPay(ctx, payment.PayerMSP, payment.PayeeMSP); err != nil {
+		return "", err
+	}
+
+	payerAccount, err := s.GetSettlementAccount(ctx, payment.PayerMSP)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.coverShortfall(ctx, payerAccount, payment.AmountToSettle, false); err != nil {
+		return "", err
+	}
+	if payerAccount.Balance < payment.AmountToSettle {
+		if payment.Status == "QUEUED" {
+			return "QUEUED", nil
+		}
+		if err := s.queueForFunds(ctx, payment, payerAccount.Balance); err != nil {
+			return "", err
+		}
+		return "QUEUED", nil
+	}
+
+	payeeAccount, err := s.GetSettlementAccount(ctx, payment.PayeeMSP)
+	if err != nil {
+		return "", err
+	}
+	payerAccount.Balance -= payment.AmountToSettle
+	payeeAccount.Balance += payment.AmountToSettle
+	if err := s.putSettlementAccount(ctx, payerAccount); err != nil {
+		return "", err
+	}
+	if err := s.putSettlementAccount(ctx, payeeAccount); err != nil {
+		return "", err
+	}
+
+	payment.Status = "SETTLED"
+	payment.AmountToSettle = 0
+	payment.QueueReason = ""
+	if err := s.putPaymentDetails(ctx, payment); err != nil {
+		return "", err
+	}
+
+	if err := s.emitPaymentEvent(ctx, "PaymentSettled", PaymentEventDetails{
+		ID:       payment.ID,
+		PayeeMSP: payment.PayeeMSP,
+		PayerMSP: payment.PayerMSP,
+	}); err != nil {
+		return "", err
+	}
+	return "SUCCESS", nil
+}
+
+// queueForFunds queues a payment its payer cannot fund gross, with reason insufficient_funds
+func (s *SmartContract) queueForFunds(ctx contractapi.TransactionContextInterface, payment *PaymentDetails, available float64) error {
+	payment.Status = "QUEUED"
+	payment.QueueReason = "insufficient_funds"
+	if err := s.putPaymentDetails(ctx, payment); err != nil {
+		return err
+	}
+
+	return s.emitSettlementEvent(ctx, "PaymentQueued", map[string]interface{}{
+		"paymentID":        payment.ID,
+		"payerMSP":         payment.PayerMSP,
+		"payeeMSP":         payment.PayeeMSP,
+		"amount":           payment.AmountToSettle,
+		"availableBalance": available,
+		"reason":           payment.QueueReason,
+	})
+}
+
+// debitedSince returns the last recorded stage before a payment was debited; the split
+// debit never stamped its own time
+func debitedSince(pd *PaymentDetails) int64 {
+	if pd.QueuedAt > pd.AcknowledgedAt {
+		return pd.QueuedAt
+	}
+	if pd.AcknowledgedAt > 0 {
+		return pd.AcknowledgedAt
+	}
+	return createdTime(pd)
+}
+
+// putSettlementAccount stores a bank's settlement account in its col-settlement-<MSP> collection
+func (s *SmartContract) putSettlementAccount(ctx contractapi.TransactionContextInterface, account *BankAccount) error {
+	accountBytes, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account for %s: %v", account.MSP, err)
+	}
+	coll := fmt.Sprintf("col-settlement-%s", account.MSP)
+	if err := ctx.GetStub().PutPrivateData(coll, account.MSP, accountBytes); err != nil {
+		return fmt.Errorf("failed to update settlement account for %s: %v", account.MSP, err)
+	}
+	return nil
+}
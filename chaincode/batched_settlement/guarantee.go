@@ -0,0 +1,380 @@
+// guarantee.go - Prefunded settlement guarantee fund: banks contribute in proportion to their
+// peak net debit, and the fund covers a debtor that cannot settle its net position
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// guaranteeCollection holds the fund and its usage; only the Central Bank is a member
+	guaranteeCollection = "col-guarantee"
+	// guaranteeFundKey is the guaranteeCollection key holding the GuaranteeFund
+	guaranteeFundKey = "GUARANTEE_FUND"
+	// guaranteeUsageObjectType keys GuaranteeUsage records in guaranteeCollection
+	guaranteeUsageObjectType = "guarantee-usage"
+	// guaranteeShareKey holds a bank's GuaranteeShare in its col-settlement-<MSP> collection
+	guaranteeShareKey = "GUARANTEE_SHARE"
+	// defaultCoverRatio sizes the fund to cover the largest peak net debit of any one bank
+	defaultCoverRatio = 1.0
+)
+
+// SetGuaranteeCoverRatio sets the fund's target as a multiple of the largest peak net debit
+// of any bank (CBN only). The next collection brings contributions to the new target.
+func (s *SmartContract) SetGuaranteeCoverRatio(ctx contractapi.TransactionContextInterface, ratio float64) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can set the guarantee fund cover ratio")
+	}
+	if ratio <= 0 {
+		return fmt.Errorf("cover ratio must be positive")
+	}
+
+	fund, err := s.getGuaranteeFund(ctx)
+	if err != nil {
+		return err
+	}
+	fund.CoverRatio = ratio
+	if err := s.putGuaranteeFund(ctx, fund); err != nil {
+		return err
+	}
+
+	return s.emitSettlementEvent(ctx, "GuaranteeCoverRatioSet", map[string]interface{}{
+		"coverRatio": ratio,
+	})
+}
+
+// CollectGuaranteeContributions brings every bank's contribution to what the formula
+// requires (CBN only): the fund's target is CoverRatio times the largest peak net debit,
+// shared between the banks in proportion to their own peaks. Shortfalls are taken from the
+// bank's settlement account as far as its balance allows; excess is refunded to it.
+func (s *SmartContract) CollectGuaranteeContributions(ctx contractapi.TransactionContextInterface) (*GuaranteeCollectionResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can collect guarantee contributions")
+	}
+
+	fund, err := s.getGuaranteeFund(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GuaranteeCollectionResult{
+		Required:   fund.requiredContributions(),
+		Collected:  make(map[string]float64),
+		Shortfalls: make(map[string]float64),
+		Timestamp:  now,
+	}
+	for _, msp := range getBankMSPs() {
+		delta := roundToKobo(result.Required[msp] - fund.Contributions[msp])
+		if delta == 0 {
+			continue
+		}
+
+		account, err := s.GetSettlementAccount(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		collected := delta
+		if delta > 0 {
+			collected = math.Min(delta, math.Max(account.Balance, 0))
+			if collected < delta {
+				result.Shortfalls[msp] = roundToKobo(delta - collected)
+			}
+		}
+		if collected == 0 {
+			continue
+		}
+
+		account.Balance -= collected
+		if err := s.putSettlementAccount(ctx, account); err != nil {
+			return nil, err
+		}
+		fund.Contributions[msp] = roundToKobo(fund.Contributions[msp] + collected)
+		fund.Balance = roundToKobo(fund.Balance + collected)
+		result.Collected[msp] = collected
+	}
+
+	if err := s.putGuaranteeFund(ctx, fund); err != nil {
+		return nil, err
+	}
+	result.FundBalance = fund.Balance
+
+	if err := s.emitSettlementEvent(ctx, "GuaranteeContributionsCollected", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetGuaranteeFund returns the fund's balance, each bank's contribution and peak net debit,
+// and the liabilities of banks whose shortfall it covered (CBN only). Banks read their own
+// part through GetGuaranteeShare.
+func (s *SmartContract) GetGuaranteeFund(ctx contractapi.TransactionContextInterface) (*GuaranteeFund, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can read the guarantee fund")
+	}
+	return s.getGuaranteeFund(ctx)
+}
+
+// GetGuaranteeShare returns a bank's contribution to the fund, its peak net debit and what it
+// owes for shortfalls the fund covered. Only the bank itself and the Central Bank can read it.
+func (s *SmartContract) GetGuaranteeShare(ctx contractapi.TransactionContextInterface, msp string) (*GuaranteeShare, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedBank(msp) {
+		return nil, fmt.Errorf("unknown bank %s", msp)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != msp {
+		return nil, fmt.Errorf("only %s or Central Bank can access its guarantee share", msp)
+	}
+
+	coll := fmt.Sprintf("col-settlement-%s", msp)
+	shareBytes, err := ctx.GetStub().GetPrivateData(coll, guaranteeShareKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guarantee share for %s: %v", msp, err)
+	}
+
+	// A bank the fund has not touched yet has nothing in it
+	share := &GuaranteeShare{MSP: msp}
+	if shareBytes != nil {
+		if err := json.Unmarshal(shareBytes, share); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guarantee share for %s: %v", msp, err)
+		}
+	}
+	return share, nil
+}
+
+// GetGuaranteeFundUsage lists every shortfall the fund covered, oldest cycle first (CBN only)
+func (s *SmartContract) GetGuaranteeFundUsage(ctx contractapi.TransactionContextInterface) ([]*GuaranteeUsage, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can read the guarantee fund usage")
+	}
+
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(guaranteeCollection, guaranteeUsageObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guarantee fund usage: %v", err)
+	}
+	defer iter.Close()
+
+	usages := make([]*GuaranteeUsage, 0)
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate guarantee fund usage: %v", err)
+		}
+
+		var usage GuaranteeUsage
+		if err := json.Unmarshal(qr.Value, &usage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guarantee usage %s: %v", qr.Key, err)
+		}
+		usages = append(usages, &usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Timestamp != usages[j].Timestamp {
+			return usages[i].Timestamp < usages[j].Timestamp
+		}
+		return usages[i].DebtorMSP < usages[j].DebtorMSP
+	})
+
+	return usages, nil
+}
+
+// coverFromGuaranteeFund covers what a debtor's settlement account lacks for a net debit of
+// amount from the fund, crediting the account in memory; the caller stores it and the fund.
+// Returns the amount covered.
+func (s *SmartContract) coverFromGuaranteeFund(ctx contractapi.TransactionContextInterface, fund *GuaranteeFund, account *BankAccount, amount float64) (float64, error) {
+	shortfall := roundToKobo(amount - math.Max(account.Balance, 0))
+	if shortfall <= 0 || fund.Balance <= 0 {
+		return 0, nil
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+	covered, charges := fund.cover(account.MSP, shortfall)
+	usage := &GuaranteeUsage{
+		CycleID:   ctx.GetStub().GetTxID(),
+		DebtorMSP: account.MSP,
+		NetDebit:  amount,
+		Covered:   covered,
+		Uncovered: roundToKobo(shortfall - covered),
+		Charges:   charges,
+		Timestamp: now,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(guaranteeUsageObjectType, []string{usage.CycleID, usage.DebtorMSP})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create guarantee usage key: %v", err)
+	}
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal guarantee usage: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(guaranteeCollection, key, usageBytes); err != nil {
+		return 0, fmt.Errorf("failed to store guarantee usage for %s: %v", account.MSP, err)
+	}
+
+	// Any shortfall the fund cannot cover becomes an overdraft, as before
+	account.Balance = roundToKobo(account.Balance + covered)
+	return covered, nil
+}
+
+// cover pays up to shortfall out of the fund on the debtor's behalf. The debtor's own
+// contribution goes first; the rest is shared between the other banks in proportion to
+// their contributions. Returns the amount covered and what each bank's contribution lost.
+func (f *GuaranteeFund) cover(debtor string, shortfall float64) (float64, map[string]float64) {
+	charges := make(map[string]float64)
+	covered := roundToKobo(math.Min(shortfall, f.Balance))
+	if covered <= 0 {
+		return 0, charges
+	}
+
+	own := math.Min(covered, f.Contributions[debtor])
+	if own > 0 {
+		charges[debtor] = own
+	}
+	rest := roundToKobo(covered - own)
+	survivors := roundToKobo(f.Balance - f.Contributions[debtor])
+	if rest > 0 && survivors > 0 {
+		// Banks in a fixed order, the last absorbing the rounding
+		var sharers []string
+		for _, msp := range getBankMSPs() {
+			if msp != debtor && f.Contributions[msp] > 0 {
+				sharers = append(sharers, msp)
+			}
+		}
+		remaining := rest
+		for i, msp := range sharers {
+			share := roundToKobo(rest * f.Contributions[msp] / survivors)
+			if i == len(sharers)-1 {
+				share = remaining
+			}
+			share = math.Min(share, f.Contributions[msp])
+			charges[msp] = share
+			remaining = roundToKobo(remaining - share)
+		}
+	}
+
+	for msp, charge := range charges {
+		f.Contributions[msp] = roundToKobo(f.Contributions[msp] - charge)
+	}
+	f.Balance = roundToKobo(f.Balance - covered)
+	f.Liabilities[debtor] = roundToKobo(f.Liabilities[debtor] + covered)
+	f.TotalUsed = roundToKobo(f.TotalUsed + covered)
+	return covered, charges
+}
+
+// recordPeakNetDebits raises each bank's peak net debit to what it owes in this cycle
+func (f *GuaranteeFund) recordPeakNetDebits(netPositions map[string]float64) {
+	for msp, position := range netPositions {
+		if -position > f.PeakNetDebits[msp] {
+			f.PeakNetDebits[msp] = roundToKobo(-position)
+		}
+	}
+}
+
+// requiredContributions applies the contribution formula to every bank
+func (f *GuaranteeFund) requiredContributions() map[string]float64 {
+	var largest, total float64
+	for _, peak := range f.PeakNetDebits {
+		largest = math.Max(largest, peak)
+		total += peak
+	}
+
+	required := make(map[string]float64)
+	for _, msp := range getBankMSPs() {
+		if total == 0 {
+			required[msp] = 0
+			continue
+		}
+		required[msp] = roundToKobo(f.CoverRatio * largest * f.PeakNetDebits[msp] / total)
+	}
+	return required
+}
+
+// getGuaranteeFund loads the fund; before the first cycle it is empty, sized to cover one
+func (s *SmartContract) getGuaranteeFund(ctx contractapi.TransactionContextInterface) (*GuaranteeFund, error) {
+	fundBytes, err := ctx.GetStub().GetPrivateData(guaranteeCollection, guaranteeFundKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guarantee fund: %v", err)
+	}
+
+	fund := &GuaranteeFund{CoverRatio: defaultCoverRatio}
+	if fundBytes != nil {
+		if err := json.Unmarshal(fundBytes, fund); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guarantee fund: %v", err)
+		}
+	}
+	if fund.Contributions == nil {
+		fund.Contributions = make(map[string]float64)
+	}
+	if fund.PeakNetDebits == nil {
+		fund.PeakNetDebits = make(map[string]float64)
+	}
+	if fund.Liabilities == nil {
+		fund.Liabilities = make(map[string]float64)
+	}
+	return fund, nil
+}
+
+// putGuaranteeFund stamps and stores the fund, and copies each bank's part of it to the bank's
+// own collection. Write it once per transaction: reads do not see this transaction's own writes.
+func (s *SmartContract) putGuaranteeFund(ctx contractapi.TransactionContextInterface, fund *GuaranteeFund) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	fund.UpdatedAt = now
+
+	fundBytes, err := json.Marshal(fund)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guarantee fund: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(guaranteeCollection, guaranteeFundKey, fundBytes); err != nil {
+		return fmt.Errorf("failed to store guarantee fund: %v", err)
+	}
+
+	for _, msp := range getBankMSPs() {
+		share := &GuaranteeShare{
+			MSP:          msp,
+			Contribution: fund.Contributions[msp],
+			PeakNetDebit: fund.PeakNetDebits[msp],
+			Liability:    fund.Liabilities[msp],
+			UpdatedAt:    now,
+		}
+		shareBytes, err := json.Marshal(share)
+		if err != nil {
+			return fmt.Errorf("failed to marshal guarantee share for %s: %v", msp, err)
+		}
+		coll := fmt.Sprintf("col-settlement-%s", msp)
+		if err := ctx.GetStub().PutPrivateData(coll, guaranteeShareKey, shareBytes); err != nil {
+			return fmt.Errorf("failed to store guarantee share for %s: %v", msp, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// limits.go - Bilateral and multilateral exposure limits
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Limits live in the payer's col-settlement-<MSP> collection under composite
+// keys, so they never show up in the plain-key account lookups.
+const exposureLimitObjectType = "limit"
+
+// multilateralCounterparty is the composite key attribute used for the payer's limit against all banks
+const multilateralCounterparty = "ALL"
+
+// SetBilateralLimit caps the net amount payerMSP may owe payeeMSP within a cycle (payer bank or CBN)
+func (s *SmartContract) SetBilateralLimit(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP string, limit float64) error {
+	if payerMSP == payeeMSP {
+		return fmt.Errorf("bilateral limit requires two different banks")
+	}
+	if !s.isAuthorizedBank(payeeMSP) {
+		return fmt.Errorf("unknown counterparty bank: %s", payeeMSP)
+	}
+	return s.putExposureLimit(ctx, payerMSP, payeeMSP, limit)
+}
+
+// SetMultilateralLimit caps the net amount payerMSP may owe all other banks within a cycle (payer bank or CBN)
+func (s *SmartContract) SetMultilateralLimit(ctx contractapi.TransactionContextInterface, payerMSP string, limit float64) error {
+	return s.putExposureLimit(ctx, payerMSP, "", limit)
+}
+
+// RemoveExposureLimit deletes a limit; an empty payeeMSP removes the multilateral limit
+func (s *SmartContract) RemoveExposureLimit(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP string) error {
+	callerMSP, err := s.authorizeLimitOwner(ctx, payerMSP)
+	if err != nil {
+		return err
+	}
+
+	key, err := exposureLimitKey(ctx, payerMSP, payeeMSP)
+	if err != nil {
+		return err
+	}
+	coll := fmt.Sprintf("col-settlement-%s", payerMSP)
+	if err := ctx.GetStub().DelPrivateData(coll, key); err != nil {
+		return fmt.Errorf("failed to remove exposure limit for %s: %v", payerMSP, err)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.emitSettlementEvent(ctx, "ExposureLimitRemoved", map[string]interface{}{
+		"payerMSP":        payerMSP,
+		"counterpartyMSP": payeeMSP,
+		"removedBy":       callerMSP,
+		"timestamp":       now,
+	})
+}
+
+// GetExposureLimits returns every limit configured for a bank with its current utilisation (bank itself or CBN)
+func (s *SmartContract) GetExposureLimits(ctx contractapi.TransactionContextInterface, payerMSP string) ([]*ExposureUtilisation, error) {
+	if _, err := s.authorizeLimitOwner(ctx, payerMSP); err != nil {
+		return nil, err
+	}
+
+	limits, err := s.getExposureLimits(ctx, payerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	bilateral, err := s.getBatchedNetDebits(ctx, payerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ExposureUtilisation, 0, len(limits))
+	for _, limit := range limits {
+		var used float64
+		if limit.Type == "MULTILATERAL" {
+			used = multilateralNetDebit(bilateral)
+		} else {
+			used = math.Max(bilateral[limit.CounterpartyMSP], 0)
+		}
+
+		utilisation := &ExposureUtilisation{
+			PayerMSP:        limit.PayerMSP,
+			CounterpartyMSP: limit.CounterpartyMSP,
+			Type:            limit.Type,
+			Limit:           limit.Limit,
+			Utilisation:     used,
+			Headroom:        math.Max(limit.Limit-used, 0),
+		}
+		if limit.Limit > 0 {
+			utilisation.UtilisationPct = used / limit.Limit * 100
+		}
+		results = append(results, utilisation)
+	}
+
+	return results, nil
+}
+
+// checkExposureLimits reports whether batching the payment would push its payer over a limit.
+// It returns an empty string when the payment fits, otherwise a description of the breach.
+func (s *SmartContract) checkExposureLimits(ctx contractapi.TransactionContextInterface, payment *PaymentDetails) (string, error) {
+	limits, err := s.getExposureLimits(ctx, payment.PayerMSP)
+	if err != nil {
+		return "", err
+	}
+	if len(limits) == 0 {
+		return "", nil
+	}
+
+	bilateral, err := s.getBatchedNetDebits(ctx, payment.PayerMSP)
+	if err != nil {
+		return "", err
+	}
+
+	// Project the positions as if this payment were already batched
+	bilateral[payment.PayeeMSP] += payment.AmountToSettle
+
+	for _, limit := range limits {
+		switch limit.Type {
+		case "MULTILATERAL":
+			if projected := multilateralNetDebit(bilateral); projected > limit.Limit {
+				return fmt.Sprintf("multilateral net debit %.2f would exceed limit %.2f", projected, limit.Limit), nil
+			}
+		case "BILATERAL":
+			if limit.CounterpartyMSP != payment.PayeeMSP {
+				continue
+			}
+			if projected := bilateral[payment.PayeeMSP]; projected > limit.Limit {
+				return fmt.Sprintf("bilateral net debit to %s %.2f would exceed limit %.2f", payment.PayeeMSP, projected, limit.Limit), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// putExposureLimit validates and stores a limit in the payer's settlement collection
+func (s *SmartContract) putExposureLimit(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP string, limit float64) error {
+	callerMSP, err := s.authorizeLimitOwner(ctx, payerMSP)
+	if err != nil {
+		return err
+	}
+	if limit < 0 {
+		return fmt.Errorf("exposure limit must not be negative")
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := ExposureLimit{
+		PayerMSP:        payerMSP,
+		CounterpartyMSP: payeeMSP,
+		Type:            "BILATERAL",
+		Limit:           limit,
+		SetBy:           callerMSP,
+		UpdatedAt:       now,
+	}
+	if payeeMSP == "" {
+		record.Type = "MULTILATERAL"
+	}
+
+	key, err := exposureLimitKey(ctx, payerMSP, payeeMSP)
+	if err != nil {
+		return err
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exposure limit: %v", err)
+	}
+	coll := fmt.Sprintf("col-settlement-%s", payerMSP)
+	if err := ctx.GetStub().PutPrivateData(coll, key, recordBytes); err != nil {
+		return fmt.Errorf("failed to store exposure limit for %s: %v", payerMSP, err)
+	}
+
+	return s.emitSettlementEvent(ctx, "ExposureLimitSet", record)
+}
+
+// getExposureLimits loads every limit stored for the payer
+func (s *SmartContract) getExposureLimits(ctx contractapi.TransactionContextInterface, payerMSP string) ([]*ExposureLimit, error) {
+	coll := fmt.Sprintf("col-settlement-%s", payerMSP)
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(coll, exposureLimitObjectType, []string{payerMSP})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exposure limits for %s: %v", payerMSP, err)
+	}
+	defer iter.Close()
+
+	var limits []*ExposureLimit
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate exposure limits for %s: %v", payerMSP, err)
+		}
+
+		var limit ExposureLimit
+		if err := json.Unmarshal(qr.Value, &limit); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal exposure limit %s: %v", qr.Key, err)
+		}
+		limits = append(limits, &limit)
+	}
+
+	return limits, nil
+}
+
+// getBatchedNetDebits returns, per counterparty, what the bank owes net of what it is owed
+// across all BATCHED payments (positive = net debit)
+func (s *SmartContract) getBatchedNetDebits(ctx contractapi.TransactionContextInterface, msp string) (map[string]float64, error) {
+	netDebits := make(map[string]float64)
+
+	for _, otherMSP := range getBankMSPs() {
+		if otherMSP == msp {
+			continue
+		}
+
+		coll := getCollectionName(msp, otherMSP)
+		batched, err := s.rangePayments(ctx, coll, paymentFilter{Statuses: []string{"BATCHED"}})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, payment := range batched {
+			if payment.PayerMSP == msp {
+				netDebits[otherMSP] += payment.AmountToSettle
+			} else {
+				netDebits[otherMSP] -= payment.AmountToSettle
+			}
+		}
+	}
+
+	return netDebits, nil
+}
+
+// multilateralNetDebit sums the bilateral positions into a single net debit (never negative)
+func multilateralNetDebit(bilateral map[string]float64) float64 {
+	var net float64
+	for _, amount := range bilateral {
+		net += amount
+	}
+	return math.Max(net, 0)
+}
+
+// authorizeLimitOwner allows only the payer bank itself or CBN to manage its limits
+func (s *SmartContract) authorizeLimitOwner(ctx contractapi.TransactionContextInterface, payerMSP string) (string, error) {
+	callerMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedBank(payerMSP) {
+		return "", fmt.Errorf("unknown bank: %s", payerMSP)
+	}
+	if callerMSP != "CentralBankMSP" && callerMSP != payerMSP {
+		return "", fmt.Errorf("only %s or Central Bank can manage its exposure limits", payerMSP)
+	}
+	return callerMSP, nil
+}
+
+// exposureLimitKey builds the composite key for a bilateral or multilateral limit
+func exposureLimitKey(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP string) (string, error) {
+	counterparty := payeeMSP
+	if counterparty == "" {
+		counterparty = multilateralCounterparty
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(exposureLimitObjectType, []string{payerMSP, counterparty})
+	if err != nil {
+		return "", fmt.Errorf("failed to create exposure limit key: %v", err)
+	}
+	return key, nil
+}
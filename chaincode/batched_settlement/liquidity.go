@@ -0,0 +1,534 @@
+// liquidity.go - Intraday liquidity facility: collateral pledged to the Central Bank and the
+// intraday credit it secures
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Collateral statuses
+const (
+	CollateralPledged  = "PLEDGED"
+	CollateralReleased = "RELEASED"
+)
+
+const (
+	// collateralObjectType keys Collateral records in the bank's col-liquidity-<MSP> collection
+	collateralObjectType = "collateral"
+	// haircutObjectType keys haircut overrides set by the Central Bank in public state
+	haircutObjectType = "haircut"
+)
+
+// defaultHaircuts are the share of face value discounted per eligible asset class
+var defaultHaircuts = map[string]float64{
+	"TREASURY_BILL":  0.02,
+	"CBN_BILL":       0.02,
+	"FGN_BOND":       0.05,
+	"STATE_BOND":     0.15,
+	"CORPORATE_BOND": 0.25,
+}
+
+// PledgeCollateral records an asset the calling bank pledges to the Central Bank. Its
+// value after the asset class's haircut counts toward the bank's intraday credit.
+func (s *SmartContract) PledgeCollateral(ctx contractapi.TransactionContextInterface, assetClass, reference string, faceValue float64) (*Collateral, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedBank(clientMSP) {
+		return nil, fmt.Errorf("only banks can pledge collateral")
+	}
+	if _, err := s.getHaircut(ctx, assetClass); err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		return nil, fmt.Errorf("collateral reference is required")
+	}
+	if err := validateIssuanceAmount(faceValue); err != nil {
+		return nil, fmt.Errorf("invalid face value: %v", err)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collateral := &Collateral{
+		ID:         ctx.GetStub().GetTxID(),
+		MSP:        clientMSP,
+		AssetClass: assetClass,
+		Reference:  reference,
+		FaceValue:  faceValue,
+		Status:     CollateralPledged,
+		PledgedAt:  now,
+	}
+	if err := s.putCollateral(ctx, collateral); err != nil {
+		return nil, err
+	}
+
+	if err := s.emitSettlementEvent(ctx, "CollateralPledged", collateral); err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+// ReleaseCollateral returns pledged collateral to the bank (CBN only). The collateral left
+// behind must still cover the bank's outstanding intraday credit.
+func (s *SmartContract) ReleaseCollateral(ctx contractapi.TransactionContextInterface, msp, id string) (*Collateral, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can release collateral")
+	}
+
+	pledged, err := s.getPledgedCollateral(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	var released *Collateral
+	remaining := make([]*Collateral, 0, len(pledged))
+	for _, c := range pledged {
+		if c.ID == id {
+			released = c
+			continue
+		}
+		remaining = append(remaining, c)
+	}
+	if released == nil {
+		return nil, fmt.Errorf("no pledged collateral %s for %s", id, msp)
+	}
+
+	facility, err := s.getIntradayFacility(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	remainingValue, err := s.valueCollateral(ctx, remaining)
+	if err != nil {
+		return nil, err
+	}
+	if remainingValue < facility.Drawn {
+		return nil, fmt.Errorf("cannot release collateral %s: remaining collateral %.2f would not cover %.2f of intraday credit drawn by %s",
+			id, remainingValue, facility.Drawn, msp)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	released.Status = CollateralReleased
+	released.ReleasedAt = now
+	if err := s.putCollateral(ctx, released); err != nil {
+		return nil, err
+	}
+
+	if err := s.emitSettlementEvent(ctx, "CollateralReleased", released); err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// GetCollateral lists the collateral a bank has pledged, including released records.
+// Only the bank itself and the Central Bank can read it.
+func (s *SmartContract) GetCollateral(ctx contractapi.TransactionContextInterface, msp string) ([]*Collateral, error) {
+	if err := s.authorizeLiquidityReader(ctx, msp); err != nil {
+		return nil, err
+	}
+	return s.getCollateral(ctx, msp)
+}
+
+// SetCollateralHaircut sets the haircut applied to an eligible asset class (CBN only).
+// A haircut is the share of face value discounted, from 0 up to but excluding 1.
+func (s *SmartContract) SetCollateralHaircut(ctx contractapi.TransactionContextInterface, assetClass string, haircut float64) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can set collateral haircuts")
+	}
+	if _, ok := defaultHaircuts[assetClass]; !ok {
+		return fmt.Errorf("unknown asset class %s", assetClass)
+	}
+	if haircut < 0 || haircut >= 1 {
+		return fmt.Errorf("haircut must be at least 0 and below 1")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(haircutObjectType, []string{assetClass})
+	if err != nil {
+		return fmt.Errorf("failed to create haircut key: %v", err)
+	}
+	haircutBytes, err := json.Marshal(haircut)
+	if err != nil {
+		return fmt.Errorf("failed to marshal haircut: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, haircutBytes); err != nil {
+		return fmt.Errorf("failed to store haircut for %s: %v", assetClass, err)
+	}
+
+	return s.emitSettlementEvent(ctx, "CollateralHaircutSet", map[string]interface{}{
+		"assetClass": assetClass,
+		"haircut":    haircut,
+	})
+}
+
+// GetCollateralHaircuts returns the haircut currently applied to every eligible asset class
+func (s *SmartContract) GetCollateralHaircuts(ctx contractapi.TransactionContextInterface) (map[string]float64, error) {
+	haircuts := make(map[string]float64, len(defaultHaircuts))
+	for assetClass := range defaultHaircuts {
+		haircut, err := s.getHaircut(ctx, assetClass)
+		if err != nil {
+			return nil, err
+		}
+		haircuts[assetClass] = haircut
+	}
+	return haircuts, nil
+}
+
+// GrantIntradayCredit sets the intraday credit the Central Bank extends to a bank (CBN only).
+// The limit cannot exceed the bank's collateral after haircuts.
+func (s *SmartContract) GrantIntradayCredit(ctx contractapi.TransactionContextInterface, msp string, limit float64) (*IntradayFacility, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can grant intraday credit")
+	}
+	if !s.isAuthorizedBank(msp) {
+		return nil, fmt.Errorf("unknown bank %s", msp)
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("credit limit must not be negative")
+	}
+
+	facility, err := s.getIntradayFacility(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	if limit > facility.CollateralValue {
+		return nil, fmt.Errorf("credit limit %.2f exceeds %.2f of collateral pledged by %s", limit, facility.CollateralValue, msp)
+	}
+
+	facility.CreditLimit = limit
+	if err := s.putIntradayFacility(ctx, facility); err != nil {
+		return nil, err
+	}
+
+	if err := s.emitSettlementEvent(ctx, "IntradayCreditGranted", facility); err != nil {
+		return nil, err
+	}
+	return facility, nil
+}
+
+// RepayIntradayCredit repays drawn intraday credit from the bank's settlement account
+// (the bank itself or CBN)
+func (s *SmartContract) RepayIntradayCredit(ctx contractapi.TransactionContextInterface, msp string, amount float64) (*IntradayFacility, error) {
+	if err := s.authorizeLiquidityReader(ctx, msp); err != nil {
+		return nil, err
+	}
+	if err := validateIssuanceAmount(amount); err != nil {
+		return nil, err
+	}
+
+	facility, err := s.getIntradayFacility(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	if amount > facility.Drawn {
+		return nil, fmt.Errorf("cannot repay %.2f: %s has drawn only %.2f", amount, msp, facility.Drawn)
+	}
+	account, err := s.GetSettlementAccount(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	if account.Balance < amount {
+		return nil, fmt.Errorf("cannot repay %.2f: %s has only %.2f in its settlement account", amount, msp, account.Balance)
+	}
+
+	if err := s.repayIntradayCredit(ctx, account, facility, amount); err != nil {
+		return nil, err
+	}
+
+	if err := s.emitSettlementEvent(ctx, "IntradayCreditRepaid", facility); err != nil {
+		return nil, err
+	}
+	return facility, nil
+}
+
+// CloseIntradayFacilities is the end-of-day sweep (CBN only): every bank repays its intraday
+// credit from its settlement account. Credit a bank cannot repay stays drawn and marks the
+// facility overdue, which stops further drawing until it is repaid.
+func (s *SmartContract) CloseIntradayFacilities(ctx contractapi.TransactionContextInterface) (*IntradayCloseResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can close intraday facilities")
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &IntradayCloseResult{
+		Repaid:      make(map[string]float64),
+		Outstanding: make(map[string]float64),
+		Timestamp:   now,
+	}
+
+	for _, msp := range getBankMSPs() {
+		facility, err := s.getIntradayFacility(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		if facility.Drawn == 0 {
+			continue
+		}
+
+		account, err := s.GetSettlementAccount(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		repay := math.Min(facility.Drawn, math.Max(account.Balance, 0))
+		if repay > 0 {
+			if err := s.repayIntradayCredit(ctx, account, facility, repay); err != nil {
+				return nil, err
+			}
+			result.Repaid[msp] = repay
+		}
+
+		if facility.Drawn > 0 {
+			facility.Overdue = true
+			if err := s.putIntradayFacility(ctx, facility); err != nil {
+				return nil, err
+			}
+			result.Outstanding[msp] = facility.Drawn
+		}
+	}
+
+	if err := s.emitSettlementEvent(ctx, "IntradayFacilitiesClosed", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetIntradayFacility returns a bank's intraday credit line with its collateral value and
+// what is still available to draw. Only the bank itself and the Central Bank can read it.
+func (s *SmartContract) GetIntradayFacility(ctx contractapi.TransactionContextInterface, msp string) (*IntradayFacility, error) {
+	if err := s.authorizeLiquidityReader(ctx, msp); err != nil {
+		return nil, err
+	}
+	return s.getIntradayFacility(ctx, msp)
+}
+
+// coverShortfall draws on the bank's intraday facility so that its balance covers amount,
+// crediting the account in memory; the caller stores it. With partial set it draws what the
+// facility has even if that leaves a shortfall, otherwise it draws only when the facility
+// covers the whole of it. Returns the amount drawn.
+func (s *SmartContract) coverShortfall(ctx contractapi.TransactionContextInterface, account *BankAccount, amount float64, partial bool) (float64, error) {
+	shortfall := roundToKobo(amount - account.Balance)
+	if shortfall <= 0 {
+		return 0, nil
+	}
+
+	facility, err := s.getIntradayFacility(ctx, account.MSP)
+	if err != nil {
+		return 0, err
+	}
+	draw := math.Min(shortfall, facility.Available)
+	if draw <= 0 || (!partial && draw < shortfall) {
+		return 0, nil
+	}
+
+	facility.Drawn = roundToKobo(facility.Drawn + draw)
+	if err := s.putIntradayFacility(ctx, facility); err != nil {
+		return 0, err
+	}
+
+	account.Balance += draw
+	return draw, nil
+}
+
+// repayIntradayCredit moves amount from the bank's settlement account back to the Central
+// Bank, reducing what the bank has drawn. A facility repaid in full is no longer overdue.
+func (s *SmartContract) repayIntradayCredit(ctx contractapi.TransactionContextInterface, account *BankAccount, facility *IntradayFacility, amount float64) error {
+	account.Balance -= amount
+	if err := s.putSettlementAccount(ctx, account); err != nil {
+		return err
+	}
+
+	facility.Drawn = roundToKobo(facility.Drawn - amount)
+	if facility.Drawn == 0 {
+		facility.Overdue = false
+	}
+	return s.putIntradayFacility(ctx, facility)
+}
+
+// getIntradayFacility loads a bank's facility, valuing its collateral at today's haircuts.
+// A bank without one has a facility with no credit limit.
+func (s *SmartContract) getIntradayFacility(ctx contractapi.TransactionContextInterface, msp string) (*IntradayFacility, error) {
+	coll := fmt.Sprintf("col-liquidity-%s", msp)
+	facilityBytes, err := ctx.GetStub().GetPrivateData(coll, msp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read intraday facility for %s: %v", msp, err)
+	}
+
+	facility := &IntradayFacility{MSP: msp}
+	if facilityBytes != nil {
+		if err := json.Unmarshal(facilityBytes, facility); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal intraday facility for %s: %v", msp, err)
+		}
+	}
+
+	pledged, err := s.getPledgedCollateral(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	facility.CollateralValue, err = s.valueCollateral(ctx, pledged)
+	if err != nil {
+		return nil, err
+	}
+
+	// Credit is only as good as the collateral behind it, and overdue credit must be repaid first
+	facility.Available = 0
+	if !facility.Overdue {
+		facility.Available = roundToKobo(math.Max(math.Min(facility.CreditLimit, facility.CollateralValue)-facility.Drawn, 0))
+	}
+	return facility, nil
+}
+
+// putIntradayFacility stores a bank's facility in its col-liquidity-<MSP> collection
+func (s *SmartContract) putIntradayFacility(ctx contractapi.TransactionContextInterface, facility *IntradayFacility) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	facility.UpdatedAt = now
+
+	facilityBytes, err := json.Marshal(facility)
+	if err != nil {
+		return fmt.Errorf("failed to marshal intraday facility for %s: %v", facility.MSP, err)
+	}
+	coll := fmt.Sprintf("col-liquidity-%s", facility.MSP)
+	if err := ctx.GetStub().PutPrivateData(coll, facility.MSP, facilityBytes); err != nil {
+		return fmt.Errorf("failed to store intraday facility for %s: %v", facility.MSP, err)
+	}
+	return nil
+}
+
+// getCollateral loads every collateral record of a bank
+func (s *SmartContract) getCollateral(ctx contractapi.TransactionContextInterface, msp string) ([]*Collateral, error) {
+	coll := fmt.Sprintf("col-liquidity-%s", msp)
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(coll, collateralObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collateral for %s: %v", msp, err)
+	}
+	defer iter.Close()
+
+	collateral := make([]*Collateral, 0)
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate collateral for %s: %v", msp, err)
+		}
+
+		var c Collateral
+		if err := json.Unmarshal(qr.Value, &c); err != nil {
+			continue
+		}
+		collateral = append(collateral, &c)
+	}
+
+	return collateral, nil
+}
+
+// getPledgedCollateral loads the collateral a bank still has pledged
+func (s *SmartContract) getPledgedCollateral(ctx contractapi.TransactionContextInterface, msp string) ([]*Collateral, error) {
+	collateral, err := s.getCollateral(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+
+	pledged := make([]*Collateral, 0, len(collateral))
+	for _, c := range collateral {
+		if c.Status == CollateralPledged {
+			pledged = append(pledged, c)
+		}
+	}
+	return pledged, nil
+}
+
+// putCollateral stores a collateral record in its bank's col-liquidity-<MSP> collection
+func (s *SmartContract) putCollateral(ctx contractapi.TransactionContextInterface, collateral *Collateral) error {
+	key, err := ctx.GetStub().CreateCompositeKey(collateralObjectType, []string{collateral.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create collateral key: %v", err)
+	}
+	collateralBytes, err := json.Marshal(collateral)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collateral %s: %v", collateral.ID, err)
+	}
+	coll := fmt.Sprintf("col-liquidity-%s", collateral.MSP)
+	if err := ctx.GetStub().PutPrivateData(coll, key, collateralBytes); err != nil {
+		return fmt.Errorf("failed to store collateral %s for %s: %v", collateral.ID, collateral.MSP, err)
+	}
+	return nil
+}
+
+// valueCollateral sums the face value of collateral net of each asset class's haircut
+func (s *SmartContract) valueCollateral(ctx contractapi.TransactionContextInterface, collateral []*Collateral) (float64, error) {
+	var value float64
+	for _, c := range collateral {
+		haircut, err := s.getHaircut(ctx, c.AssetClass)
+		if err != nil {
+			return 0, err
+		}
+		value += c.FaceValue * (1 - haircut)
+	}
+	return roundToKobo(value), nil
+}
+
+// getHaircut returns the haircut for an eligible asset class, the Central Bank's override
+// if it set one
+func (s *SmartContract) getHaircut(ctx contractapi.TransactionContextInterface, assetClass string) (float64, error) {
+	haircut, ok := defaultHaircuts[assetClass]
+	if !ok {
+		return 0, fmt.Errorf("unknown asset class %s", assetClass)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(haircutObjectType, []string{assetClass})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create haircut key: %v", err)
+	}
+	haircutBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read haircut for %s: %v", assetClass, err)
+	}
+	if haircutBytes != nil {
+		if err := json.Unmarshal(haircutBytes, &haircut); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal haircut for %s: %v", assetClass, err)
+		}
+	}
+	return haircut, nil
+}
+
+// authorizeLiquidityReader allows the bank itself and the Central Bank at its facility
+func (s *SmartContract) authorizeLiquidityReader(ctx contractapi.TransactionContextInterface, msp string) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedBank(msp) {
+		return fmt.Errorf("unknown bank %s", msp)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != msp {
+		return fmt.Errorf("only %s or Central Bank can access its intraday facility", msp)
+	}
+	return nil
+}
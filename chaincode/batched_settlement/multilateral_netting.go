@@ -3,121 +3,82 @@ package settlement
 import (
 	"encoding/json"
 	"fmt"
-	"time"
+	"sort"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// CalculateMultilateralOffset calculates netting across all banks for QUEUED payments
+// CalculateMultilateralOffset calculates netting across all banks for QUEUED payments.
+// Only the subset of queued payments that every payer can fund from its balance and
+// within its exposure limits is selected; the rest stay queued (see Decisions).
 func (s *SmartContract) CalculateMultilateralOffset(ctx contractapi.TransactionContextInterface,
 ) (*MultiOffsetCalculation, error) {
-	// Only process bilateral collections between actual banks (exclude CentralBankMSP)
-	bankMSPs := []string{"AccessBankMSP", "GTBankMSP", "ZenithBankMSP", "FirstBankMSP"}
-
-	// Scan **every** bilateral PDC for QUEUED items
-	netPos := make(map[string]float64)
-	var updates []MultiOffsetUpdate
-
-	// Process each bank pair combination only once by using nested loop with i < j
-	for i, a := range bankMSPs {
-		for j := i + 1; j < len(bankMSPs); j++ {
-			b := bankMSPs[j]
-			coll := getCollectionName(a, b)
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
-			if err != nil {
-				return nil, fmt.Errorf("failed to read PDC %s: %v", coll, err)
-			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, _ := iter.Next()
-
-				var pd PaymentDetails
-				if err := json.Unmarshal(qr.Value, &pd); err != nil {
-					continue
-				}
-				if pd.Status != "QUEUED" {
-					continue
-				}
-
-				// Build net position: incoming minus outgoing
-				netPos[pd.PayeeMSP] += pd.AmountToSettle
-				netPos[pd.PayerMSP] -= pd.AmountToSettle
-
-				// Buffer an update to mark this row SETTLED (zero out AmountToSettle)
-				updates = append(updates, MultiOffsetUpdate{
-					ID:             pd.ID,
-					PayerMSP:       pd.PayerMSP,
-					PayeeMSP:       pd.PayeeMSP,
-					AmountToSettle: 0,
-					Status:         "SETTLED",
-				})
-			}
-		}
+	queued, err := s.collectQueuedPayments(ctx, func(pd *PaymentDetails) bool { return true })
+	if err != nil {
+		return nil, err
 	}
 
-	return &MultiOffsetCalculation{
-		NetPositions: netPos,
-		Updates:      updates,
-	}, nil
+	return s.resolveQueuedGridlock(ctx, queued)
 }
 
 // CalculateMultilateralOffsetForBatch calculates netting for a specific batch window
 func (s *SmartContract) CalculateMultilateralOffsetForBatch(ctx contractapi.TransactionContextInterface, batchWindow int64) (*MultiOffsetCalculation, error) {
+	// Only process QUEUED payments from the specified batch window
+	queued, err := s.collectQueuedPayments(ctx, func(pd *PaymentDetails) bool { return pd.BatchWindow == batchWindow })
+	if err != nil {
+		return nil, err
+	}
+
+	return s.resolveQueuedGridlock(ctx, queued)
+}
+
+// collectQueuedPayments scans every bilateral PDC for QUEUED items accepted by the filter,
+// leaving out those of banks whose participant state bars them from netting
+func (s *SmartContract) collectQueuedPayments(ctx contractapi.TransactionContextInterface, include func(*PaymentDetails) bool) ([]*PaymentDetails, error) {
+	eligible, err := s.nettingEligibility(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Only process bilateral collections between actual banks (exclude CentralBankMSP)
-	bankMSPs := []string{"AccessBankMSP", "GTBankMSP", "ZenithBankMSP", "FirstBankMSP"}
+	bankMSPs := getBankMSPs()
 
-	// Scan bilateral PDCs for QUEUED items from the specified batch window
-	netPos := make(map[string]float64)
-	var updates []MultiOffsetUpdate
+	var queued []*PaymentDetails
 
+	// Process each bank pair combination only once by using nested loop with i < j
 	for i, a := range bankMSPs {
 		for j := i + 1; j < len(bankMSPs); j++ {
-			b := bankMSPs[j]
-			coll := getCollectionName(a, b)
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+			coll := getCollectionName(a, bankMSPs[j])
+			payments, err := s.getQueuedPaymentsFromCollection(ctx, coll)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read PDC %s: %v", coll, err)
+				return nil, err
 			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, _ := iter.Next()
 
-				var pd PaymentDetails
-				if err := json.Unmarshal(qr.Value, &pd); err != nil {
-					continue
+			for _, pd := range payments {
+				if include(pd) && eligible(pd) {
+					queued = append(queued, pd)
 				}
-
-				// Only process QUEUED payments from the specified batch window
-				if pd.Status != "QUEUED" || pd.BatchWindow != batchWindow {
-					continue
-				}
-
-				// Build net position: incoming minus outgoing
-				netPos[pd.PayeeMSP] += pd.AmountToSettle
-				netPos[pd.PayerMSP] -= pd.AmountToSettle
-
-				// Buffer an update to mark this row SETTLED (zero out AmountToSettle)
-				updates = append(updates, MultiOffsetUpdate{
-					ID:             pd.ID,
-					PayerMSP:       pd.PayerMSP,
-					PayeeMSP:       pd.PayeeMSP,
-					AmountToSettle: 0,
-					Status:         "SETTLED",
-				})
 			}
 		}
 	}
 
-	return &MultiOffsetCalculation{
-		NetPositions: netPos,
-		Updates:      updates,
-	}, nil
+	return queued, nil
 }
 
-// ApplyMultilateralOffset applies multilateral netting updates
+// ApplyMultilateralOffset applies a multilateral calculation passed in transient data,
+// once its payments on the ledger confirm it
 func (s *SmartContract) ApplyMultilateralOffset(ctx contractapi.TransactionContextInterface) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can apply multilateral offsets")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ApplyMultilateralOffset"); err != nil {
+		return err
+	}
+
 	// Read the payload from transient
 	trans, err := ctx.GetStub().GetTransient()
 	if err != nil {
@@ -132,43 +93,34 @@ func (s *SmartContract) ApplyMultilateralOffset(ctx contractapi.TransactionConte
 		return fmt.Errorf("unmarshal payload: %v", err)
 	}
 
-	// Apply every queued‐payment update
-	for _, u := range payload.Updates {
-		coll := getCollectionName(u.PayerMSP, u.PayeeMSP)
-		existing, err := ctx.GetStub().GetPrivateData(coll, u.ID)
-		if err != nil || existing == nil {
-			return fmt.Errorf("payment %s not found", u.ID)
-		}
-		var pd PaymentDetails
-		json.Unmarshal(existing, &pd)
-
-		pd.AmountToSettle = u.AmountToSettle
-		pd.Status = u.Status
-		updated, _ := json.Marshal(pd)
-		if err := ctx.GetStub().PutPrivateData(coll, u.ID, updated); err != nil {
-			return fmt.Errorf("write failed %s: %v", u.ID, err)
-		}
-
-		// Update public stub status as well
-		if err := s.updatePublicPaymentStatus(ctx, u.ID, u.Status); err != nil {
-			return fmt.Errorf("failed to update public payment status for %s: %v", u.ID, err)
-		}
+	// The payments decide what settles, not the payload
+	payments, netPositions, err := s.loadMultilateralPayments(ctx, &payload)
+	if err != nil {
+		return err
+	}
+	if err := s.settleMultilateralPayments(ctx, payments); err != nil {
+		return err
 	}
 
 	// Move real money once per bank
-	for msp, net := range payload.NetPositions {
+	for msp, net := range netPositions {
 		switch {
 		case net < 0:
-			if err := s.DebitNetting(ctx, msp, -net); err != nil {
+			if err := s.debitNetting(ctx, msp, -net); err != nil {
 				return err
 			}
 		case net > 0:
-			if err := s.CreditNetting(ctx, msp, net); err != nil {
+			if err := s.creditNetting(ctx, msp, net); err != nil {
 				return err
 			}
 		}
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create detailed event
 	evt := struct {
 		NetPositions   map[string]float64 `json:"netPositions"`
@@ -177,14 +129,14 @@ func (s *SmartContract) ApplyMultilateralOffset(ctx contractapi.TransactionConte
 		Timestamp      int64              `json:"timestamp"`
 		ProcessedBanks []string           `json:"processedBanks"`
 	}{
-		NetPositions:   payload.NetPositions,
-		UpdatesCount:   len(payload.Updates),
-		Timestamp:      time.Now().Unix(),
-		ProcessedBanks: getProcessedBanks(payload.NetPositions),
+		NetPositions:   netPositions,
+		UpdatesCount:   len(payments),
+		Timestamp:      now,
+		ProcessedBanks: getProcessedBanks(netPositions),
 	}
 
 	// Calculate total settled amount
-	for _, amount := range payload.NetPositions {
+	for _, amount := range netPositions {
 		if amount > 0 {
 			evt.TotalSettled += amount
 		}
@@ -194,15 +146,88 @@ func (s *SmartContract) ApplyMultilateralOffset(ctx contractapi.TransactionConte
 	return ctx.GetStub().SetEvent("MultilateralOffsetExecuted", evtBytes)
 }
 
+// loadMultilateralPayments loads the payments of a multilateral calculation from the ledger.
+// Each must be listed once and still be queued, and what they have left to settle must give
+// the calculated net positions, or the calculation is stale and rejected. Payments netting may
+// no longer include under the banks' participant states stay queued; the rest are returned
+// with the net positions they give.
+func (s *SmartContract) loadMultilateralPayments(ctx contractapi.TransactionContextInterface, payload *MultiOffsetCalculation) ([]*PaymentDetails, map[string]float64, error) {
+	listed := make(map[string]bool, len(payload.Updates))
+	payments := make([]*PaymentDetails, 0, len(payload.Updates))
+	for _, u := range payload.Updates {
+		if listed[u.ID] {
+			return nil, nil, fmt.Errorf("payment %s is listed more than once", u.ID)
+		}
+		listed[u.ID] = true
+
+		pd, err := s.getPaymentDetails(ctx, u.PayerMSP, u.PayeeMSP, u.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isQueuedStatus(pd.Status) {
+			return nil, nil, fmt.Errorf("payment %s is not queued, current status: %s", u.ID, pd.Status)
+		}
+		payments = append(payments, pd)
+	}
+
+	if err := requireMatchingNetPositions(payload.NetPositions, netPositionsOf(payload.NetPositions, payments, nil)); err != nil {
+		return nil, nil, err
+	}
+
+	eligible, err := s.nettingEligibility(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	held := make(map[string]bool)
+	settling := make([]*PaymentDetails, 0, len(payments))
+	for _, pd := range payments {
+		if !eligible(pd) {
+			held[pd.ID] = true
+			continue
+		}
+		settling = append(settling, pd)
+	}
+	return settling, netPositionsOf(payload.NetPositions, payments, held), nil
+}
+
+// settleMultilateralPayments marks every payment settled in full, in its bilateral PDC and
+// on its public stub
+func (s *SmartContract) settleMultilateralPayments(ctx contractapi.TransactionContextInterface, payments []*PaymentDetails) error {
+	for _, pd := range payments {
+		pd.AmountToSettle = 0
+		pd.Status = "SETTLED"
+		if err := s.putPaymentDetails(ctx, pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ExecuteScheduledMultilateralNetting performs system-wide multilateral netting
 // This should be called by Central Bank backend service
 func (s *SmartContract) ExecuteScheduledMultilateralNetting(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return "", fmt.Errorf("only Central Bank can execute multilateral netting")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ExecuteScheduledMultilateralNetting"); err != nil {
+		return "", err
+	}
+
 	// Calculate multilateral offset for all queued payments
 	offsetCalc, err := s.CalculateMultilateralOffset(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to calculate multilateral offset: %v", err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	// Create response structure
 	response := struct {
 		Success      bool               `json:"success"`
@@ -212,17 +237,26 @@ func (s *SmartContract) ExecuteScheduledMultilateralNetting(ctx contractapi.Tran
 		TotalSettled float64            `json:"totalSettled"`
 		Timestamp    int64              `json:"timestamp"`
 		EventType    string             `json:"eventType"`
+		Decisions    []GridlockDecision `json:"decisions"`
+		Efficiency   *NettingEfficiency `json:"efficiency"`
+		TransferPlan []FundsTransfer    `json:"transferPlan"`
 	}{
 		Success:      true,
 		NetPositions: offsetCalc.NetPositions,
 		UpdatesCount: len(offsetCalc.Updates),
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    now,
 		EventType:    "ScheduledMultilateralNetting",
+		Decisions:    offsetCalc.Decisions,
+		Efficiency:   offsetCalc.Efficiency,
+		TransferPlan: offsetCalc.TransferPlan,
 	}
 
 	// Check if there are any updates to apply
 	if len(offsetCalc.Updates) == 0 {
 		response.Message = "No queued payments found for multilateral netting"
+		if len(offsetCalc.Decisions) > 0 {
+			response.Message = "Queued payments remain gridlocked: none can settle within balances and limits"
+		}
 		response.NetPositions = make(map[string]float64) // Empty map instead of nil
 
 		// Emit event indicating no netting was needed
@@ -263,43 +297,34 @@ func (s *SmartContract) ExecuteScheduledMultilateralNetting(ctx contractapi.Tran
 
 // Internal function to apply multilateral offset (used by scheduled netting)
 func (s *SmartContract) applyMultilateralOffsetInternal(ctx contractapi.TransactionContextInterface, payload MultiOffsetCalculation) error {
-	// Apply every queued‐payment update
-	for _, u := range payload.Updates {
-		coll := getCollectionName(u.PayerMSP, u.PayeeMSP)
-		existing, err := ctx.GetStub().GetPrivateData(coll, u.ID)
-		if err != nil || existing == nil {
-			return fmt.Errorf("payment %s not found", u.ID)
-		}
-		var pd PaymentDetails
-		json.Unmarshal(existing, &pd)
-
-		pd.AmountToSettle = u.AmountToSettle
-		pd.Status = u.Status
-		updated, _ := json.Marshal(pd)
-		if err := ctx.GetStub().PutPrivateData(coll, u.ID, updated); err != nil {
-			return fmt.Errorf("write failed %s: %v", u.ID, err)
-		}
-
-		// Update public stub status as well
-		if err := s.updatePublicPaymentStatus(ctx, u.ID, u.Status); err != nil {
-			return fmt.Errorf("failed to update public payment status for %s: %v", u.ID, err)
-		}
+	// The payments decide what settles, not the payload
+	payments, netPositions, err := s.loadMultilateralPayments(ctx, &payload)
+	if err != nil {
+		return err
+	}
+	if err := s.settleMultilateralPayments(ctx, payments); err != nil {
+		return err
 	}
 
 	// Move real money once per bank
-	for msp, net := range payload.NetPositions {
+	for msp, net := range netPositions {
 		switch {
 		case net < 0:
-			if err := s.DebitNetting(ctx, msp, -net); err != nil {
+			if err := s.debitNetting(ctx, msp, -net); err != nil {
 				return err
 			}
 		case net > 0:
-			if err := s.CreditNetting(ctx, msp, net); err != nil {
+			if err := s.creditNetting(ctx, msp, net); err != nil {
 				return err
 			}
 		}
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create detailed event
 	evt := struct {
 		NetPositions   map[string]float64 `json:"netPositions"`
@@ -309,15 +334,15 @@ func (s *SmartContract) applyMultilateralOffsetInternal(ctx contractapi.Transact
 		ProcessedBanks []string           `json:"processedBanks"`
 		EventType      string             `json:"eventType"`
 	}{
-		NetPositions:   payload.NetPositions,
-		UpdatesCount:   len(payload.Updates),
-		Timestamp:      time.Now().Unix(),
-		ProcessedBanks: getProcessedBanks(payload.NetPositions),
+		NetPositions:   netPositions,
+		UpdatesCount:   len(payments),
+		Timestamp:      now,
+		ProcessedBanks: getProcessedBanks(netPositions),
 		EventType:      "ScheduledMultilateralNetting",
 	}
 
 	// Calculate total settled amount
-	for _, amount := range payload.NetPositions {
+	for _, amount := range netPositions {
 		if amount > 0 {
 			evt.TotalSettled += amount
 		}
@@ -327,9 +352,9 @@ func (s *SmartContract) applyMultilateralOffsetInternal(ctx contractapi.Transact
 	return ctx.GetStub().SetEvent("ScheduledMultilateralNettingExecuted", evtBytes)
 }
 
-// DebitNetting subtracts `amount` from the MSP's settlement account.
-// Errors if the account doesn't exist or has insufficient funds.
-func (s *SmartContract) DebitNetting(
+// debitNetting subtracts `amount` from the MSP's settlement account for a multilateral
+// offset the caller has checked. Errors if the account doesn't exist.
+func (s *SmartContract) debitNetting(
 	ctx contractapi.TransactionContextInterface,
 	msp string,
 	amount float64,
@@ -356,7 +381,10 @@ func (s *SmartContract) DebitNetting(
 	// 	)
 	// }
 
-	// Negative means the bank owes the Central Bank
+	// Draw on the intraday facility for a shortfall; negative means the bank owes the Central Bank
+	if _, err := s.coverShortfall(ctx, &acct, amount, true); err != nil {
+		return err
+	}
 	acct.Balance -= amount
 
 	updated, err := json.Marshal(acct)
@@ -367,6 +395,11 @@ func (s *SmartContract) DebitNetting(
 		return fmt.Errorf("failed to update settlement account for %s: %v", msp, err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Audit event
 	evt := struct {
 		MSP       string  `json:"msp"`
@@ -379,7 +412,7 @@ func (s *SmartContract) DebitNetting(
 		Amount:    amount,
 		Type:      "netting-debit",
 		Balance:   acct.Balance,
-		Timestamp: time.Now().Unix(),
+		Timestamp: now,
 	}
 	evtBytes, _ := json.Marshal(evt)
 	if err := ctx.GetStub().SetEvent("NettingDebitExecuted", evtBytes); err != nil {
@@ -389,9 +422,9 @@ func (s *SmartContract) DebitNetting(
 	return nil
 }
 
-// CreditNetting adds `amount` to the MSP's settlement account.
-// Creates the account if it doesn't already exist.
-func (s *SmartContract) CreditNetting(
+// creditNetting adds `amount` to the MSP's settlement account for a multilateral offset
+// the caller has checked. Creates the account if it doesn't already exist.
+func (s *SmartContract) creditNetting(
 	ctx contractapi.TransactionContextInterface,
 	msp string,
 	amount float64,
@@ -421,6 +454,11 @@ func (s *SmartContract) CreditNetting(
 		return fmt.Errorf("failed to update settlement account for %s: %v", msp, err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Audit event
 	evt := struct {
 		MSP       string  `json:"msp"`
@@ -433,7 +471,7 @@ func (s *SmartContract) CreditNetting(
 		Amount:    amount,
 		Type:      "netting-credit",
 		Balance:   acct.Balance,
-		Timestamp: time.Now().Unix(),
+		Timestamp: now,
 	}
 	evtBytes, _ := json.Marshal(evt)
 	if err := ctx.GetStub().SetEvent("NettingCreditExecuted", evtBytes); err != nil {
@@ -458,22 +496,12 @@ func (s *SmartContract) GetMultilateralNettingStatus(ctx contractapi.Transaction
 		for j := i + 1; j < len(bankMSPs); j++ {
 			b := bankMSPs[j]
 			coll := getCollectionName(a, b)
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+			queued, err := s.queryPayments(ctx, coll, paymentFilter{Statuses: queuedStatuses})
 			if err != nil {
 				continue // Skip inaccessible collections
 			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, _ := iter.Next()
-				var pd PaymentDetails
-				if err := json.Unmarshal(qr.Value, &pd); err != nil {
-					continue
-				}
-				if pd.Status != "QUEUED" {
-					continue
-				}
 
+			for _, pd := range queued {
 				totalQueued++
 				totalQueuedAmount += pd.AmountToSettle
 
@@ -484,21 +512,28 @@ func (s *SmartContract) GetMultilateralNettingStatus(ctx contractapi.Transaction
 		}
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MultilateralNettingStatus{
 		TotalQueuedPayments: totalQueued,
 		TotalQueuedAmount:   totalQueuedAmount,
 		BankCounts:          bankCounts,
 		BankAmounts:         bankAmounts,
-		LastUpdated:         time.Now().Unix(),
+		LastUpdated:         now,
 	}, nil
 }
 
-// Helper function to extract processed banks from net positions
+// Helper function to extract processed banks from net positions, sorted so every
+// endorser emits the same event
 func getProcessedBanks(netPositions map[string]float64) []string {
 	banks := make([]string, 0, len(netPositions))
 	for bank := range netPositions {
 		banks = append(banks, bank)
 	}
+	sort.Strings(banks)
 	return banks
 }
 
@@ -0,0 +1,97 @@
+// netting_efficiency.go - Liquidity savings and funds-flow plans for netting results
+package settlement
+
+import (
+	"math"
+	"sort"
+)
+
+// buildNettingEfficiency compares the gross obligations of a payment set with the net
+// positions it produces, per bank and system-wide
+func buildNettingEfficiency(payments []*PaymentDetails, netPositions map[string]float64) *NettingEfficiency {
+	efficiency := &NettingEfficiency{
+		GrossOutgoing: make(map[string]float64),
+		GrossIncoming: make(map[string]float64),
+	}
+
+	for _, pd := range payments {
+		efficiency.GrossOutgoing[pd.PayerMSP] += pd.AmountToSettle
+		efficiency.GrossIncoming[pd.PayeeMSP] += pd.AmountToSettle
+		efficiency.TotalGross += pd.AmountToSettle
+	}
+
+	// Net liquidity needed is what the net debtors pay in
+	for _, net := range netPositions {
+		if net < 0 {
+			efficiency.TotalNet -= net
+		}
+	}
+
+	efficiency.TotalGross = roundToKobo(efficiency.TotalGross)
+	efficiency.TotalNet = roundToKobo(efficiency.TotalNet)
+	efficiency.LiquiditySaved = roundToKobo(efficiency.TotalGross - efficiency.TotalNet)
+	if efficiency.TotalGross > 0 {
+		efficiency.NettingRatio = efficiency.TotalNet / efficiency.TotalGross
+		efficiency.SavingsRatio = efficiency.LiquiditySaved / efficiency.TotalGross
+	}
+
+	return efficiency
+}
+
+// buildTransferPlan derives bank-to-bank transfers that realise the net positions.
+// The largest remaining debtor always pays the largest remaining creditor, so each
+// step clears at least one bank and the plan needs at most N-1 transfers.
+func buildTransferPlan(netPositions map[string]float64) []FundsTransfer {
+	type position struct {
+		msp    string
+		amount float64
+	}
+
+	var debtors, creditors []*position
+	for msp, net := range netPositions {
+		net = roundToKobo(net)
+		switch {
+		case net < 0:
+			debtors = append(debtors, &position{msp, -net})
+		case net > 0:
+			creditors = append(creditors, &position{msp, net})
+		}
+	}
+
+	largestFirst := func(list []*position) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].amount != list[j].amount {
+				return list[i].amount > list[j].amount
+			}
+			return list[i].msp < list[j].msp
+		})
+	}
+
+	plan := make([]FundsTransfer, 0)
+	for {
+		largestFirst(debtors)
+		largestFirst(creditors)
+		if len(debtors) == 0 || len(creditors) == 0 || debtors[0].amount <= 0 || creditors[0].amount <= 0 {
+			break
+		}
+
+		debtor, creditor := debtors[0], creditors[0]
+		amount := roundToKobo(math.Min(debtor.amount, creditor.amount))
+		plan = append(plan, FundsTransfer{
+			FromMSP: debtor.msp,
+			ToMSP:   creditor.msp,
+			Amount:  amount,
+		})
+
+		debtor.amount = roundToKobo(debtor.amount - amount)
+		creditor.amount = roundToKobo(creditor.amount - amount)
+		if debtor.amount <= 0 {
+			debtors = debtors[1:]
+		}
+		if creditor.amount <= 0 {
+			creditors = creditors[1:]
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,212 @@
+// overview.go - System-wide dashboard for the Central Bank
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// lastSettlementCycleKey is the public state key holding the latest SettlementCycleRecord
+const lastSettlementCycleKey = "LAST_SETTLEMENT_CYCLE"
+
+// defaultStaleQueueWindows is how many batch windows a payment may wait before it is flagged
+const defaultStaleQueueWindows = 3
+
+// unsettledStatuses are the statuses that still carry settlement exposure
+var unsettledStatuses = []string{"PENDING", "ACKNOWLEDGED", "BATCHED", "QUEUED", "PARTIALLY_SETTLED"}
+
+// GetSystemOverview returns every bank's balance, limit and unsettled totals, the current
+// window, the last settlement cycle and any alerts, from one pass over the unsettled
+// payments (CBN only). Queued payments older than staleQueueWindows batch windows are
+// flagged; 0 uses the default of 3.
+func (s *SmartContract) GetSystemOverview(ctx contractapi.TransactionContextInterface, staleQueueWindows int) (*SystemOverview, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can view the system overview")
+	}
+	if staleQueueWindows < 0 {
+		return nil, fmt.Errorf("stale queue windows must not be negative")
+	}
+	if staleQueueWindows == 0 {
+		staleQueueWindows = defaultStaleQueueWindows
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cycle := getSettlementCycleInfo(now)
+	staleAfter := int64(staleQueueWindows) * int64(cycle.WindowEnd.Sub(cycle.WindowStart).Seconds())
+
+	bankMSPs := getBankMSPs()
+	banks := make(map[string]*BankOverview, len(bankMSPs))
+	for _, msp := range bankMSPs {
+		banks[msp] = &BankOverview{BankMSP: msp}
+	}
+
+	for i, bankA := range bankMSPs {
+		for j := i + 1; j < len(bankMSPs); j++ {
+			coll := getCollectionName(bankA, bankMSPs[j])
+			payments, err := s.queryPayments(ctx, coll, paymentFilter{Statuses: unsettledStatuses})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pd := range payments {
+				payer, payee := banks[pd.PayerMSP], banks[pd.PayeeMSP]
+				if payer == nil || payee == nil {
+					continue
+				}
+
+				switch {
+				case pd.Status == "PENDING" || pd.Status == "ACKNOWLEDGED":
+					addStats(&payer.Pending, pd.AmountToSettle)
+				case pd.Status == "BATCHED":
+					addStats(&payer.Batched, pd.AmountToSettle)
+					payer.BatchedNetDebit += pd.AmountToSettle
+					payee.BatchedNetDebit -= pd.AmountToSettle
+				case isQueuedStatus(pd.Status):
+					addStats(&payer.Queued, pd.AmountToSettle)
+					if age := now - queuedSince(pd); age > payer.OldestQueuedSeconds {
+						payer.OldestQueuedSeconds = age
+					}
+				}
+			}
+		}
+	}
+
+	overview := &SystemOverview{
+		Banks:       make([]BankOverview, 0, len(bankMSPs)),
+		Cycle:       cycle,
+		Alerts:      make([]SystemAlert, 0),
+		GeneratedAt: now,
+	}
+
+	for _, msp := range bankMSPs {
+		bank := banks[msp]
+		bank.BatchedNetDebit = roundToKobo(bank.BatchedNetDebit)
+
+		// banks that have not opened their account yet show a zero balance
+		if account, err := s.GetSettlementAccount(ctx, msp); err == nil {
+			bank.Balance = account.Balance
+		}
+
+		limits, err := s.getExposureLimits(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		for _, limit := range limits {
+			if limit.Type == "MULTILATERAL" {
+				bank.MultilateralLimit = limit.Limit
+			}
+		}
+
+		overview.Alerts = append(overview.Alerts, bankAlerts(bank, staleQueueWindows, staleAfter)...)
+		overview.Banks = append(overview.Banks, *bank)
+	}
+
+	lastCycle, err := s.getLastSettlementCycle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lastCycle != nil {
+		overview.LastCycle = lastCycle
+		for _, failed := range lastCycle.Result.FailedBanks {
+			overview.Alerts = append(overview.Alerts, SystemAlert{
+				Type:     "SETTLEMENT_FAILURE",
+				Severity: "CRITICAL",
+				BankMSP:  failed.BankMSP,
+				Message:  fmt.Sprintf("net settlement of %.2f failed in cycle %s: %s", failed.NetAmount, lastCycle.CycleID, failed.Error),
+			})
+		}
+	}
+
+	return overview, nil
+}
+
+// bankAlerts checks one bank's overview row against the alert thresholds
+func bankAlerts(bank *BankOverview, staleQueueWindows int, staleAfter int64) []SystemAlert {
+	var alerts []SystemAlert
+
+	if bank.Balance < 0 {
+		alerts = append(alerts, SystemAlert{
+			Type:     "NEGATIVE_BALANCE",
+			Severity: "CRITICAL",
+			BankMSP:  bank.BankMSP,
+			Message:  fmt.Sprintf("settlement account balance is %.2f", bank.Balance),
+		})
+	}
+	if bank.Queued.Count > 0 && bank.OldestQueuedSeconds >= staleAfter {
+		alerts = append(alerts, SystemAlert{
+			Type:     "STALE_QUEUE",
+			Severity: "WARNING",
+			BankMSP:  bank.BankMSP,
+			Message: fmt.Sprintf("%d queued payments, the oldest waiting %ds (more than %d windows)",
+				bank.Queued.Count, bank.OldestQueuedSeconds, staleQueueWindows),
+		})
+	}
+	if bank.MultilateralLimit > 0 && bank.BatchedNetDebit > bank.MultilateralLimit {
+		alerts = append(alerts, SystemAlert{
+			Type:     "LIMIT_EXCEEDED",
+			Severity: "WARNING",
+			BankMSP:  bank.BankMSP,
+			Message:  fmt.Sprintf("batched net debit %.2f exceeds multilateral limit %.2f", bank.BatchedNetDebit, bank.MultilateralLimit),
+		})
+	}
+
+	return alerts
+}
+
+// addStats counts one payment into a status total
+func addStats(stats *TransactionStats, amount float64) {
+	stats.Count++
+	stats.Volume = roundToKobo(stats.Volume + amount)
+}
+
+// recordSettlementCycle keeps the outcome of a netting application for the overview
+func (s *SmartContract) recordSettlementCycle(ctx contractapi.TransactionContextInterface, netPositions map[string]float64, result *NettingApplicationResult) error {
+	if netPositions == nil {
+		netPositions = make(map[string]float64)
+	}
+	batchWindow, err := txBatchWindow(ctx)
+	if err != nil {
+		return err
+	}
+	record := SettlementCycleRecord{
+		CycleID:      ctx.GetStub().GetTxID(),
+		BatchWindow:  batchWindow,
+		NetPositions: netPositions,
+		Result:       *result,
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal settlement cycle record: %v", err)
+	}
+	if err := ctx.GetStub().PutState(lastSettlementCycleKey, recordBytes); err != nil {
+		return fmt.Errorf("failed to store settlement cycle record: %v", err)
+	}
+	return nil
+}
+
+// getLastSettlementCycle loads the latest settlement cycle record, or nil before the first cycle
+func (s *SmartContract) getLastSettlementCycle(ctx contractapi.TransactionContextInterface) (*SettlementCycleRecord, error) {
+	recordBytes, err := ctx.GetStub().GetState(lastSettlementCycleKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement cycle record: %v", err)
+	}
+	if recordBytes == nil {
+		return nil, nil
+	}
+
+	var record SettlementCycleRecord
+	if err := json.Unmarshal(recordBytes, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settlement cycle record: %v", err)
+	}
+	return &record, nil
+}
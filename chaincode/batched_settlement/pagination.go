@@ -0,0 +1,384 @@
+// pagination.go - Bookmark-based paginated variants of the payment listing queries
+package settlement
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// pageCursor is the position of the last record returned, encoded into the bookmark: its
+// collection and the key it was found under, a payment ID or, when the page walked the
+// status index, the index entry. Pages resume after the cursor in key order, so records
+// added or settled between calls neither repeat nor shift later pages.
+type pageCursor struct {
+	Collection string `json:"c"`
+	Key        string `json:"k"`
+	Indexed    bool   `json:"i,omitempty"`
+}
+
+// pageEntry is a payment found by a page walk and the key it was found under
+type pageEntry struct {
+	key     string
+	payment *PaymentDetails
+}
+
+// GetAllBankTransactionsPaginated returns one page of the caller's payments across its
+// bilateral collections (all collections for CBN)
+func (s *SmartContract) GetAllBankTransactionsPaginated(ctx contractapi.TransactionContextInterface, query PaymentPageQuery) (*PaymentPage, error) {
+	callerMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller MSP ID: %v", err)
+	}
+	if !s.isAuthorizedMSP(callerMSP) {
+		return nil, fmt.Errorf("unauthorized MSP: %s", callerMSP)
+	}
+
+	return s.pagePayments(ctx, s.pageCollections(callerMSP, query.Counterparty), query)
+}
+
+// GetTransactionHistoryPaginated returns one page of the caller's transaction history (every bank's for CBN)
+func (s *SmartContract) GetTransactionHistoryPaginated(ctx contractapi.TransactionContextInterface, query PaymentPageQuery) (*TransactionHistoryPage, error) {
+	page, err := s.GetAllBankTransactionsPaginated(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &TransactionHistoryPage{
+		Records:  make([]TransactionHistoryEntry, 0, len(page.Records)),
+		Metadata: page.Metadata,
+	}
+	for _, pd := range page.Records {
+		history.Records = append(history.Records, newTransactionHistoryEntry(pd))
+	}
+	return history, nil
+}
+
+// GetBilateralPaymentsPaginated returns one page of the payments in a bilateral PDC (either bank or CBN)
+func (s *SmartContract) GetBilateralPaymentsPaginated(ctx contractapi.TransactionContextInterface, msp1, msp2 string, query PaymentPageQuery) (*PaymentPage, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != msp1 && clientMSP != msp2 {
+		return nil, fmt.Errorf("unauthorized access to bilateral payments")
+	}
+
+	return s.pagePayments(ctx, []string{getCollectionName(msp1, msp2)}, query)
+}
+
+// GetAllPrivateDataPaginated returns one page of raw records from a collection in key
+// order (CBN only). Only pageSize and bookmark apply: the records are not necessarily
+// payments, and key order lets each page read no more than it returns.
+func (s *SmartContract) GetAllPrivateDataPaginated(ctx contractapi.TransactionContextInterface, collection string, pageSize int, bookmark string) (string, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return "", fmt.Errorf("only Central Bank can access all private data")
+	}
+
+	pageSize, err = normalizePageSize(pageSize)
+	if err != nil {
+		return "", err
+	}
+
+	startKey := ""
+	if bookmark != "" {
+		decoded, err := base64.URLEncoding.DecodeString(bookmark)
+		if err != nil {
+			return "", fmt.Errorf("invalid bookmark: %v", err)
+		}
+		// Smallest key after the last one returned
+		startKey = string(decoded) + "\x00"
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collection, startKey, "")
+	if err != nil {
+		return "", err
+	}
+	defer resultsIterator.Close()
+
+	records := make([]json.RawMessage, 0, pageSize)
+	metadata := PageMetadata{}
+	lastKey, hasMore := "", false
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return "", err
+		}
+		if len(records) == pageSize {
+			hasMore = true
+			break
+		}
+
+		var value interface{} = json.RawMessage(queryResult.Value)
+		if !json.Valid(queryResult.Value) {
+			value = string(queryResult.Value)
+		}
+		record, err := json.Marshal(map[string]interface{}{
+			"key":   queryResult.Key,
+			"value": value,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal record %s: %v", queryResult.Key, err)
+		}
+		records = append(records, record)
+		lastKey = queryResult.Key
+	}
+	if hasMore {
+		metadata.Bookmark = base64.URLEncoding.EncodeToString([]byte(lastKey))
+	}
+	metadata.FetchedRecordsCount = len(records)
+
+	pageBytes, err := json.Marshal(map[string]interface{}{
+		"records":  records,
+		"metadata": metadata,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal page: %v", err)
+	}
+	return string(pageBytes), nil
+}
+
+// pageCollections lists the bilateral collections a caller may page through, narrowed to
+// one counterparty when given. CBN sees every bank pair.
+func (s *SmartContract) pageCollections(callerMSP, counterparty string) []string {
+	var collections []string
+	bankMSPs := getBankMSPs()
+
+	for i, a := range bankMSPs {
+		for j := i + 1; j < len(bankMSPs); j++ {
+			b := bankMSPs[j]
+			if callerMSP != "CentralBankMSP" && callerMSP != a && callerMSP != b {
+				continue
+			}
+			if counterparty != "" && counterparty != a && counterparty != b {
+				continue
+			}
+			collections = append(collections, getCollectionName(a, b))
+		}
+	}
+
+	return collections
+}
+
+// pagePayments walks the collections in order from the bookmark and returns the next page
+// of matching payments. Only records after the cursor are loaded, and only until the page
+// is full plus one more, which tells whether another page follows.
+func (s *SmartContract) pagePayments(ctx contractapi.TransactionContextInterface, collections []string, query PaymentPageQuery) (*PaymentPage, error) {
+	pageSize, err := normalizePageSize(query.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor *pageCursor
+	if query.Bookmark != "" {
+		if cursor, err = decodePageCursor(query.Bookmark); err != nil {
+			return nil, err
+		}
+		for len(collections) > 0 && collections[0] != cursor.Collection {
+			collections = collections[1:]
+		}
+		if len(collections) == 0 {
+			return nil, fmt.Errorf("invalid bookmark: collection %s is not part of this query", cursor.Collection)
+		}
+	}
+
+	page := &PaymentPage{Records: make([]*PaymentDetails, 0, pageSize)}
+	var last pageCursor
+	for _, coll := range collections {
+		indexed := false
+		if query.Status != "" {
+			if indexed, err = statusIndexReady(ctx, coll); err != nil {
+				return nil, err
+			}
+		}
+
+		after := ""
+		if cursor != nil && coll == cursor.Collection {
+			if cursor.Indexed != indexed {
+				return nil, fmt.Errorf("invalid bookmark: collection %s was reindexed since the previous page", coll)
+			}
+			after = cursor.Key
+		}
+
+		limit := pageSize + 1 - len(page.Records)
+		var entries []pageEntry
+		if indexed {
+			entries, err = s.walkStatusIndex(ctx, coll, query, after, limit)
+		} else {
+			entries, err = s.walkCollection(ctx, coll, query, after, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if len(page.Records) == pageSize {
+				page.Metadata.Bookmark = encodePageCursor(last)
+				break
+			}
+			page.Records = append(page.Records, entry.payment)
+			last = pageCursor{Collection: coll, Key: entry.key, Indexed: indexed}
+		}
+		if page.Metadata.Bookmark != "" {
+			break
+		}
+	}
+	page.Metadata.FetchedRecordsCount = len(page.Records)
+
+	return page, nil
+}
+
+// walkCollection reads a collection's payments in ID order from the key after the cursor,
+// stopping once limit of them match the query
+func (s *SmartContract) walkCollection(ctx contractapi.TransactionContextInterface, coll string, query PaymentPageQuery, after string, limit int) ([]pageEntry, error) {
+	startKey := ""
+	if after != "" {
+		// Smallest key after the last one returned
+		startKey = after + "\x00"
+	}
+	iter, err := ctx.GetStub().GetPrivateDataByRange(coll, startKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDC %s: %v", coll, err)
+	}
+	defer iter.Close()
+
+	filter := pageFilter(query)
+	var entries []pageEntry
+	for len(entries) < limit && iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterator error on %s: %v", coll, err)
+		}
+
+		var pd PaymentDetails
+		if err := json.Unmarshal(qr.Value, &pd); err != nil {
+			continue
+		}
+		if filter.matches(&pd) && matchesPageQuery(&pd, query) {
+			entries = append(entries, pageEntry{key: qr.Key, payment: &pd})
+		}
+	}
+
+	return entries, nil
+}
+
+// walkStatusIndex reads the status index entries of the queried status in window and ID
+// order, loading the payments behind the entries after the cursor until limit of them
+// match the query. Private data has no range reads over composite keys, so the entries up
+// to the cursor are still iterated, but their payments are not read.
+func (s *SmartContract) walkStatusIndex(ctx contractapi.TransactionContextInterface, coll string, query PaymentPageQuery, after string, limit int) ([]pageEntry, error) {
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(coll, paymentStatusIndex, []string{query.Status})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status index of %s: %v", coll, err)
+	}
+	defer iter.Close()
+
+	var entries []pageEntry
+	for len(entries) < limit && iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterator error on %s: %v", coll, err)
+		}
+		if qr.Key <= after {
+			continue
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+		if err != nil || len(parts) != 3 {
+			continue
+		}
+		paymentBytes, err := ctx.GetStub().GetPrivateData(coll, parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payment %s: %v", parts[2], err)
+		}
+		if paymentBytes == nil {
+			continue
+		}
+
+		var pd PaymentDetails
+		if err := json.Unmarshal(paymentBytes, &pd); err != nil {
+			continue
+		}
+		// Skip stale entries whose payment has since moved on
+		if pd.Status != parts[0] || strconv.FormatInt(pd.BatchWindow, 10) != parts[1] {
+			continue
+		}
+		if matchesPageQuery(&pd, query) {
+			entries = append(entries, pageEntry{key: qr.Key, payment: &pd})
+		}
+	}
+
+	return entries, nil
+}
+
+// pageFilter is the status filter of a page query
+func pageFilter(query PaymentPageQuery) paymentFilter {
+	filter := paymentFilter{}
+	if query.Status != "" {
+		filter.Statuses = []string{query.Status}
+	}
+	return filter
+}
+
+// matchesPageQuery applies the non-status filters of a page query
+func matchesPageQuery(pd *PaymentDetails, query PaymentPageQuery) bool {
+	if query.Counterparty != "" && pd.PayerMSP != query.Counterparty && pd.PayeeMSP != query.Counterparty {
+		return false
+	}
+	if query.FromTimestamp != 0 && pd.Timestamp < query.FromTimestamp {
+		return false
+	}
+	if query.ToTimestamp != 0 && pd.Timestamp > query.ToTimestamp {
+		return false
+	}
+	if query.MinAmount != 0 && pd.Amount < query.MinAmount {
+		return false
+	}
+	if query.MaxAmount != 0 && pd.Amount > query.MaxAmount {
+		return false
+	}
+	return true
+}
+
+// normalizePageSize applies the default page size and rejects oversized pages
+func normalizePageSize(pageSize int) (int, error) {
+	switch {
+	case pageSize == 0:
+		return defaultPageSize, nil
+	case pageSize < 0 || pageSize > maxPageSize:
+		return 0, fmt.Errorf("page size must be between 1 and %d", maxPageSize)
+	default:
+		return pageSize, nil
+	}
+}
+
+// encodePageCursor turns a cursor into an opaque bookmark
+func encodePageCursor(cursor pageCursor) string {
+	cursorBytes, _ := json.Marshal(cursor)
+	return base64.URLEncoding.EncodeToString(cursorBytes)
+}
+
+// decodePageCursor parses a bookmark produced by encodePageCursor
+func decodePageCursor(bookmark string) (*pageCursor, error) {
+	cursorBytes, err := base64.URLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bookmark: %v", err)
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(cursorBytes, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid bookmark: %v", err)
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,197 @@
+// participant.go - Participant states: the Central Bank suspends banks or declares them in
+// default, which bars them from sending or receiving payments and from netting
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Participant states. A bank SUSPENDED_SENDING may still receive payments; a SUSPENDED or
+// DEFAULTED bank may neither send nor receive them.
+const (
+	ParticipantActive           = "ACTIVE"
+	ParticipantSuspendedSending = "SUSPENDED_SENDING"
+	ParticipantSuspended        = "SUSPENDED"
+	ParticipantDefaulted        = "DEFAULTED"
+)
+
+// participantObjectType keys ParticipantStatus records in public state
+const participantObjectType = "participant"
+
+// SetParticipantState moves a bank to another participant state (CBN only). Every other bank
+// is notified through the ParticipantStateChanged event.
+func (s *SmartContract) SetParticipantState(ctx contractapi.TransactionContextInterface, msp, state, reason string) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can set participant states")
+	}
+	if !s.isAuthorizedBank(msp) {
+		return fmt.Errorf("unknown bank %s", msp)
+	}
+	switch state {
+	case ParticipantActive, ParticipantSuspendedSending, ParticipantSuspended, ParticipantDefaulted:
+	default:
+		return fmt.Errorf("unknown participant state %s: must be %s, %s, %s or %s", state,
+			ParticipantActive, ParticipantSuspendedSending, ParticipantSuspended, ParticipantDefaulted)
+	}
+
+	current, err := s.getParticipantStatus(ctx, msp)
+	if err != nil {
+		return err
+	}
+	if current.State == state {
+		return nil
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	status := &ParticipantStatus{
+		MSP:       msp,
+		State:     state,
+		Reason:    reason,
+		UpdatedBy: clientMSP,
+		UpdatedAt: now,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(participantObjectType, []string{msp})
+	if err != nil {
+		return fmt.Errorf("failed to create participant key: %v", err)
+	}
+	statusBytes, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal participant status: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, statusBytes); err != nil {
+		return fmt.Errorf("failed to store participant status for %s: %v", msp, err)
+	}
+
+	counterparties := make([]string, 0)
+	for _, bank := range getBankMSPs() {
+		if bank != msp {
+			counterparties = append(counterparties, bank)
+		}
+	}
+	return s.emitSettlementEvent(ctx, "ParticipantStateChanged", map[string]interface{}{
+		"msp":            msp,
+		"previousState":  current.State,
+		"state":          state,
+		"reason":         reason,
+		"counterparties": counterparties,
+		"updatedBy":      clientMSP,
+		"timestamp":      now,
+	})
+}
+
+// GetParticipantState returns a bank's participant state
+func (s *SmartContract) GetParticipantState(ctx contractapi.TransactionContextInterface, msp string) (*ParticipantStatus, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedMSP(clientMSP) {
+		return nil, fmt.Errorf("unauthorized MSP: %s", clientMSP)
+	}
+	if !s.isAuthorizedBank(msp) {
+		return nil, fmt.Errorf("unknown bank %s", msp)
+	}
+	return s.getParticipantStatus(ctx, msp)
+}
+
+// GetParticipantStates returns the participant state of every bank
+func (s *SmartContract) GetParticipantStates(ctx contractapi.TransactionContextInterface) ([]*ParticipantStatus, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if !s.isAuthorizedMSP(clientMSP) {
+		return nil, fmt.Errorf("unauthorized MSP: %s", clientMSP)
+	}
+
+	statuses := make([]*ParticipantStatus, 0)
+	for _, msp := range getBankMSPs() {
+		status, err := s.getParticipantStatus(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// requireParticipantsCanPay rejects a payment whose payer may not send or whose payee may
+// not receive
+func (s *SmartContract) requireParticipantsCanPay(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP string) error {
+	payer, err := s.getParticipantStatus(ctx, payerMSP)
+	if err != nil {
+		return err
+	}
+	if !canSend(payer.State) {
+		return fmt.Errorf("%s cannot send payments: participant is %s", payerMSP, payer.State)
+	}
+
+	payee, err := s.getParticipantStatus(ctx, payeeMSP)
+	if err != nil {
+		return err
+	}
+	if !canReceive(payee.State) {
+		return fmt.Errorf("%s cannot receive payments: participant is %s", payeeMSP, payee.State)
+	}
+	return nil
+}
+
+// nettingEligibility returns a filter accepting the payments netting may include under the
+// banks' current participant states. The rest wait where they are until the banks are
+// reinstated.
+func (s *SmartContract) nettingEligibility(ctx contractapi.TransactionContextInterface) (func(*PaymentDetails) bool, error) {
+	states := make(map[string]string)
+	for _, msp := range getBankMSPs() {
+		status, err := s.getParticipantStatus(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		states[msp] = status.State
+	}
+
+	return func(pd *PaymentDetails) bool {
+		return canSend(states[pd.PayerMSP]) && canReceive(states[pd.PayeeMSP])
+	}, nil
+}
+
+// getParticipantStatus loads a bank's participant status; banks the Central Bank never
+// acted on are ACTIVE
+func (s *SmartContract) getParticipantStatus(ctx contractapi.TransactionContextInterface, msp string) (*ParticipantStatus, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(participantObjectType, []string{msp})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create participant key: %v", err)
+	}
+	statusBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read participant status for %s: %v", msp, err)
+	}
+	if statusBytes == nil {
+		return &ParticipantStatus{MSP: msp, State: ParticipantActive}, nil
+	}
+
+	var status ParticipantStatus
+	if err := json.Unmarshal(statusBytes, &status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal participant status for %s: %v", msp, err)
+	}
+	return &status, nil
+}
+
+// canSend reports whether a bank in the given participant state may send payments
+func canSend(state string) bool {
+	return state == ParticipantActive
+}
+
+// canReceive reports whether a bank in the given participant state may receive payments
+func canReceive(state string) bool {
+	return state == ParticipantActive || state == ParticipantSuspendedSending
+}
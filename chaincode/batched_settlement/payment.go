@@ -35,25 +35,39 @@ func (s *SmartContract) CreatePayment(ctx contractapi.TransactionContextInterfac
 	if details.PayerMSP != clientMSP {
 		return fmt.Errorf("payer MSP must match calling MSP")
 	}
+	if err := s.requireParticipantsCanPay(ctx, details.PayerMSP, details.PayeeMSP); err != nil {
+		return err
+	}
 
 	// Set mandatory fields
 	details.AmountToSettle = details.Amount
 	details.Status = "PENDING"
-	details.BatchWindow = getCurrentBatchWindow()
-
-	// Verify BVN
-	if err := s.verifyBVN(ctx, details.User); err != nil {
+	details.BatchWindow, err = txBatchWindow(ctx)
+	if err != nil {
 		return err
 	}
+	details.QueueReason = ""
+	details.QueuedAt = 0
+	details.CreatedAt = 0
+	details.AcknowledgedAt = 0
+	details.BatchedAt = 0
+	details.SettledAt = 0
+	details.SettlementCycleID = ""
 
-	updatedBytes, err := json.Marshal(details)
+	priority, err := normalizePriority(details.Priority)
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated PaymentDetails: %v", err)
+		return err
+	}
+	details.Priority = priority
+
+	// Verify BVN
+	if err := s.verifyBVN(ctx, details.User); err != nil {
+		return err
 	}
 
 	// Store full details in bilateral collection
 	coll := getCollectionName(details.PayerMSP, details.PayeeMSP)
-	if err := ctx.GetStub().PutPrivateData(coll, details.ID, updatedBytes); err != nil {
+	if err := s.writePayment(ctx, coll, &details); err != nil {
 		return fmt.Errorf("failed to put private payment data: %v", err)
 	}
 
@@ -98,6 +112,9 @@ func (s *SmartContract) AcknowledgePayment(ctx contractapi.TransactionContextInt
 	if paymentDetails.PayeeMSP != clientMSP {
 		return fmt.Errorf("only payee bank can acknowledge payment")
 	}
+	if err := s.requireParticipantsCanPay(ctx, paymentDetails.PayerMSP, paymentDetails.PayeeMSP); err != nil {
+		return err
+	}
 
 	// Update payment status to ACKNOWLEDGED
 	err = s.updatePaymentStatusInPDC(ctx, paymentDetails.PayerMSP, paymentDetails.PayeeMSP, paymentDetails.ID, "ACKNOWLEDGED")
@@ -131,6 +148,9 @@ func (s *SmartContract) AcknowledgePaymentSimple(ctx contractapi.TransactionCont
 	if payeeMSP != clientMSP {
 		return fmt.Errorf("only payee bank can acknowledge payment")
 	}
+	if err := s.requireParticipantsCanPay(ctx, payerMSP, payeeMSP); err != nil {
+		return err
+	}
 
 	// Update payment status to ACKNOWLEDGED
 	err = s.updatePaymentStatusInPDC(ctx, payerMSP, payeeMSP, id, "ACKNOWLEDGED")
@@ -174,28 +194,19 @@ func (s *SmartContract) BatchAcknowledgedPayment(ctx contractapi.TransactionCont
 		return fmt.Errorf("payment %s is not in ACKNOWLEDGED status, current status: %s", paymentDetails.ID, payment.Status)
 	}
 
-	// Update payment status to BATCHED
-	err = s.updatePaymentStatusInPDC(ctx, paymentDetails.PayerMSP, paymentDetails.PayeeMSP, paymentDetails.ID, "BATCHED")
-	if err != nil {
-		return fmt.Errorf("failed to update payment status to BATCHED: %v", err)
-	}
-
-	// Update public stub status
-	err = s.updatePublicPaymentStatus(ctx, paymentDetails.ID, "BATCHED")
-	if err != nil {
-		return fmt.Errorf("failed to update public payment status to BATCHED: %v", err)
-	}
-
-	// Emit batching event
-	return s.emitPaymentEvent(ctx, "PaymentBatched", PaymentEventDetails{
-		ID:       paymentDetails.ID,
-		PayeeMSP: paymentDetails.PayeeMSP,
-		PayerMSP: paymentDetails.PayerMSP,
-	})
+	return s.batchOrQueuePayment(ctx, payment)
 }
 
 // BatchAcknowledgedPaymentSimple - CBN ONLY function with simple parameters
 func (s *SmartContract) BatchAcknowledgedPaymentSimple(ctx contractapi.TransactionContextInterface, id, payerMSP, payeeMSP string) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can batch payments")
+	}
+
 	// Verify payment exists and is in ACKNOWLEDGED status
 	payment, err := s.getPaymentDetails(ctx, payerMSP, payeeMSP, id)
 	if err != nil {
@@ -206,23 +217,56 @@ func (s *SmartContract) BatchAcknowledgedPaymentSimple(ctx contractapi.Transacti
 		return fmt.Errorf("payment %s is not in ACKNOWLEDGED status, current status: %s", id, payment.Status)
 	}
 
-	// Update payment status to BATCHED
-	err = s.updatePaymentStatusInPDC(ctx, payerMSP, payeeMSP, id, "BATCHED")
-	if err != nil {
-		return fmt.Errorf("failed to update payment status to BATCHED: %v", err)
+	return s.batchOrQueuePayment(ctx, payment)
+}
+
+// batchOrQueuePayment moves an ACKNOWLEDGED payment to BATCHED, or to QUEUED with
+// reason limit_exceeded when batching it would breach one of the payer's exposure limits.
+// URGENT payments skip the batch and settle gross straight away.
+func (s *SmartContract) batchOrQueuePayment(ctx contractapi.TransactionContextInterface, payment *PaymentDetails) error {
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "batching"); err != nil {
+		return err
 	}
 
-	// Update public stub status
-	err = s.updatePublicPaymentStatus(ctx, id, "BATCHED")
+	// The RTGS lane: URGENT payments settle gross in this transaction. It is the Central
+	// Bank's rather than the acknowledgement, since the payee cannot write the payer's
+	// settlement collection. Unfunded ones queue for multilateral netting.
+	if payment.Priority == "URGENT" {
+		_, err := s.settleGross(ctx, payment)
+		return err
+	}
+
+	breach, err := s.checkExposureLimits(ctx, payment)
 	if err != nil {
-		return fmt.Errorf("failed to update public payment status to BATCHED: %v", err)
+		return fmt.Errorf("failed to check exposure limits: %v", err)
+	}
+
+	status := "BATCHED"
+	if breach != "" {
+		status = "QUEUED"
+		payment.QueueReason = "limit_exceeded"
+	}
+	payment.Status = status
+	if err := s.putPaymentDetails(ctx, payment); err != nil {
+		return err
+	}
+
+	if status == "QUEUED" {
+		return s.emitSettlementEvent(ctx, "PaymentQueued", map[string]interface{}{
+			"paymentID": payment.ID,
+			"payerMSP":  payment.PayerMSP,
+			"payeeMSP":  payment.PayeeMSP,
+			"amount":    payment.AmountToSettle,
+			"reason":    payment.QueueReason,
+			"detail":    breach,
+		})
 	}
 
 	// Emit batching event
 	return s.emitPaymentEvent(ctx, "PaymentBatched", PaymentEventDetails{
-		ID:       id,
-		PayeeMSP: payeeMSP,
-		PayerMSP: payerMSP,
+		ID:       payment.ID,
+		PayeeMSP: payment.PayeeMSP,
+		PayerMSP: payment.PayerMSP,
 	})
 }
 
@@ -280,6 +324,32 @@ func (s *SmartContract) getPaymentDetails(ctx contractapi.TransactionContextInte
 	return &details, nil
 }
 
+// Helper function to load a payment by ID, deriving its collection from the public stub
+func (s *SmartContract) getPaymentByID(ctx contractapi.TransactionContextInterface, id string) (*PaymentDetails, error) {
+	stubBytes, err := ctx.GetStub().GetState(id)
+	if err != nil || stubBytes == nil {
+		return nil, fmt.Errorf("payment stub %s not found", id)
+	}
+	var stub PaymentStub
+	if err := json.Unmarshal(stubBytes, &stub); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stub: %v", err)
+	}
+	return s.getPaymentDetails(ctx, stub.PayerMSP, stub.PayeeMSP, id)
+}
+
+// Helper function to write a full payment record to its bilateral PDC and sync the public stub status
+func (s *SmartContract) putPaymentDetails(ctx contractapi.TransactionContextInterface, payment *PaymentDetails) error {
+	coll := getCollectionName(payment.PayerMSP, payment.PayeeMSP)
+	if err := s.writePayment(ctx, coll, payment); err != nil {
+		return fmt.Errorf("failed to update payment %s: %v", payment.ID, err)
+	}
+
+	if err := s.updatePublicPaymentStatus(ctx, payment.ID, payment.Status); err != nil {
+		return fmt.Errorf("failed to update public payment status to %s: %v", payment.Status, err)
+	}
+	return nil
+}
+
 // Helper function to update payment status in bilateral PDC
 func (s *SmartContract) updatePaymentStatusInPDC(ctx contractapi.TransactionContextInterface, payerMSP, payeeMSP, paymentID, status string) error {
 	paymentColl := getCollectionName(payerMSP, payeeMSP)
@@ -294,8 +364,7 @@ func (s *SmartContract) updatePaymentStatusInPDC(ctx contractapi.TransactionCont
 	}
 
 	paymentDetails.Status = status
-	updatedPaymentBytes, _ := json.Marshal(paymentDetails)
-	return ctx.GetStub().PutPrivateData(paymentColl, paymentID, updatedPaymentBytes)
+	return s.writePayment(ctx, paymentColl, &paymentDetails)
 }
 
 // Helper function to update payment status in public state
@@ -315,11 +384,14 @@ func (s *SmartContract) updatePublicPaymentStatus(ctx contractapi.TransactionCon
 	return ctx.GetStub().PutState(paymentID, updatedStubBytes)
 }
 
-// GetCurrentBatchWindow returns the current batch window identifier
-func getCurrentBatchWindow() int64 {
-	now := time.Now()
-	// Create 2-minute windows: divide by 120 seconds and round down
-	return now.Unix() / 120
+// txBatchWindow returns the batch window of the transaction timestamp, so every
+// endorser stamps the same window. Windows are 2 minutes: Unix seconds divided by 120.
+func txBatchWindow(ctx contractapi.TransactionContextInterface) (int64, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return now / 120, nil
 }
 
 // GetBatchWindowStart returns the start time of a batch window
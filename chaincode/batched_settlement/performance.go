@@ -0,0 +1,231 @@
+// performance.go - Settlement latency, queue age and throughput metrics from lifecycle timestamps
+package settlement
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// queueAgeLimits are the upper bounds of the queue age buckets, in seconds
+var queueAgeLimits = []struct {
+	label  string
+	maxAge int64
+}{
+	{"<15m", 15 * 60},
+	{"15m-1h", 60 * 60},
+	{"1h-4h", 4 * 60 * 60},
+	{"4h-24h", 24 * 60 * 60},
+	{">=24h", 0},
+}
+
+// stageDurations collects the raw stage latencies behind a StagePerformance
+type stageDurations struct {
+	acknowledgement []float64
+	settlement      []float64
+}
+
+// GetSettlementPerformance reports p50/p95/p99 stage latencies for payments created
+// between from and to (Unix seconds, to=0 means now), per counterparty and per batch
+// window, together with the current queue age distribution and hourly settled
+// throughput. Banks see their own collections; CBN sees every bank, with each payment
+// counted under both of its banks.
+func (s *SmartContract) GetSettlementPerformance(ctx contractapi.TransactionContextInterface, from, to int64) (*SettlementPerformance, error) {
+	callerMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller MSP ID: %v", err)
+	}
+	if !s.isAuthorizedMSP(callerMSP) {
+		return nil, fmt.Errorf("unauthorized MSP: %s", callerMSP)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = now
+	}
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid range: from %d must not be after to %d", from, to)
+	}
+
+	overall := &stageDurations{}
+	byCounterparty := make(map[string]*stageDurations)
+	byWindow := make(map[int64]*stageDurations)
+	throughput := make(map[int64]*HourlyThroughput)
+	queueAge := newQueueAgeBuckets()
+	var oldestQueued int64
+
+	for _, coll := range s.pageCollections(callerMSP, "") {
+		payments, err := s.queryPayments(ctx, coll, paymentFilter{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pd := range payments {
+			if isQueuedStatus(pd.Status) {
+				age := now - queuedSince(pd)
+				addQueueAge(queueAge, age, pd.AmountToSettle)
+				if age > oldestQueued {
+					oldestQueued = age
+				}
+			}
+
+			if pd.Status == "SETTLED" && pd.SettledAt >= from && pd.SettledAt <= to {
+				hour := pd.SettledAt - pd.SettledAt%3600
+				if throughput[hour] == nil {
+					throughput[hour] = &HourlyThroughput{HourStart: hour}
+				}
+				throughput[hour].SettledCount++
+				throughput[hour].SettledVolume = roundToKobo(throughput[hour].SettledVolume + pd.Amount)
+			}
+
+			createdAt := createdTime(pd)
+			if createdAt < from || createdAt > to {
+				continue
+			}
+
+			groups := []*stageDurations{overall, windowDurations(byWindow, pd.BatchWindow)}
+			for _, msp := range []string{pd.PayerMSP, pd.PayeeMSP} {
+				if msp != callerMSP {
+					groups = append(groups, counterpartyDurations(byCounterparty, msp))
+				}
+			}
+			for _, group := range groups {
+				group.add(pd)
+			}
+		}
+	}
+
+	perf := &SettlementPerformance{
+		From:           from,
+		To:             to,
+		Overall:        overall.summarise(),
+		ByCounterparty: make([]StagePerformance, 0, len(byCounterparty)),
+		ByWindow:       make([]StagePerformance, 0, len(byWindow)),
+		QueueAge:       queueAge,
+		OldestQueued:   oldestQueued,
+		Throughput:     make([]HourlyThroughput, 0, len(throughput)),
+		GeneratedAt:    now,
+	}
+
+	for msp, durations := range byCounterparty {
+		stage := durations.summarise()
+		stage.Counterparty = msp
+		perf.ByCounterparty = append(perf.ByCounterparty, stage)
+	}
+	sort.Slice(perf.ByCounterparty, func(i, j int) bool {
+		return perf.ByCounterparty[i].Counterparty < perf.ByCounterparty[j].Counterparty
+	})
+
+	for window, durations := range byWindow {
+		stage := durations.summarise()
+		stage.BatchWindow = window
+		perf.ByWindow = append(perf.ByWindow, stage)
+	}
+	sort.Slice(perf.ByWindow, func(i, j int) bool {
+		return perf.ByWindow[i].BatchWindow < perf.ByWindow[j].BatchWindow
+	})
+
+	for _, hour := range throughput {
+		perf.Throughput = append(perf.Throughput, *hour)
+	}
+	sort.Slice(perf.Throughput, func(i, j int) bool {
+		return perf.Throughput[i].HourStart < perf.Throughput[j].HourStart
+	})
+
+	return perf, nil
+}
+
+// add records the stages a payment has completed. Payments from before lifecycle
+// times were recorded have no AcknowledgedAt and are left out.
+func (d *stageDurations) add(pd *PaymentDetails) {
+	if pd.AcknowledgedAt == 0 {
+		return
+	}
+	if createdAt := createdTime(pd); createdAt > 0 && pd.AcknowledgedAt >= createdAt {
+		d.acknowledgement = append(d.acknowledgement, float64(pd.AcknowledgedAt-createdAt))
+	}
+	if pd.Status == "SETTLED" && pd.SettledAt >= pd.AcknowledgedAt {
+		d.settlement = append(d.settlement, float64(pd.SettledAt-pd.AcknowledgedAt))
+	}
+}
+
+// summarise turns the collected durations into percentiles
+func (d *stageDurations) summarise() StagePerformance {
+	return StagePerformance{
+		Acknowledgement: latencyPercentiles(d.acknowledgement),
+		Settlement:      latencyPercentiles(d.settlement),
+	}
+}
+
+// latencyPercentiles computes nearest-rank percentiles of the durations
+func latencyPercentiles(durations []float64) LatencyPercentiles {
+	result := LatencyPercentiles{Count: len(durations)}
+	if len(durations) == 0 {
+		return result
+	}
+
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+	rank := func(p float64) float64 {
+		idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return sorted[idx]
+	}
+
+	result.P50 = rank(50)
+	result.P95 = rank(95)
+	result.P99 = rank(99)
+	return result
+}
+
+// createdTime returns when a payment was created, falling back to the client
+// timestamp (milliseconds) for payments from before CreatedAt was recorded
+func createdTime(pd *PaymentDetails) int64 {
+	if pd.CreatedAt > 0 {
+		return pd.CreatedAt
+	}
+	return pd.Timestamp / 1000
+}
+
+// counterpartyDurations returns the durations collected for one counterparty
+func counterpartyDurations(groups map[string]*stageDurations, msp string) *stageDurations {
+	if groups[msp] == nil {
+		groups[msp] = &stageDurations{}
+	}
+	return groups[msp]
+}
+
+// windowDurations returns the durations collected for one batch window
+func windowDurations(groups map[int64]*stageDurations, window int64) *stageDurations {
+	if groups[window] == nil {
+		groups[window] = &stageDurations{}
+	}
+	return groups[window]
+}
+
+// newQueueAgeBuckets returns the empty queue age distribution
+func newQueueAgeBuckets() []QueueAgeBucket {
+	buckets := make([]QueueAgeBucket, 0, len(queueAgeLimits))
+	for _, limit := range queueAgeLimits {
+		buckets = append(buckets, QueueAgeBucket{Label: limit.label, MaxAgeSeconds: limit.maxAge})
+	}
+	return buckets
+}
+
+// addQueueAge counts a queued payment in the first bucket its age fits
+func addQueueAge(buckets []QueueAgeBucket, age int64, amount float64) {
+	for i := range buckets {
+		if buckets[i].MaxAgeSeconds == 0 || age < buckets[i].MaxAgeSeconds {
+			buckets[i].Count++
+			buckets[i].Amount = roundToKobo(buckets[i].Amount + amount)
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// query.go - Indexed payment queries over bilateral PDCs with a LevelDB fallback
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// leveldbQueryUnsupported is part of the error a LevelDB peer returns for rich queries
+const leveldbQueryUnsupported = "not supported for leveldb"
+
+// paymentFilter narrows a payment query. Zero-valued fields match anything; an empty
+// Statuses list matches every payment record (anything with a status).
+//
+// Each filter maps onto the CouchDB indexes shipped under
+// META-INF/statedb/couchdb/collections/<collection>/indexes:
+// status, status+batchWindow, batchWindow, payerMSP+status and payeeMSP+status.
+type paymentFilter struct {
+	Statuses    []string
+	BatchWindow int64
+	PayerMSP    string
+	PayeeMSP    string
+}
+
+// queryPayments returns the payments in a bilateral collection that match the filter.
+// On CouchDB peers it runs one indexed selector per status, so a query only reads the
+// rows it needs. LevelDB peers reject rich queries; there we fall back to rangePayments.
+//
+// Rich query results are not re-checked at validation, so queryPayments is for
+// evaluate-only functions. Transactions that write must use rangePayments.
+func (s *SmartContract) queryPayments(ctx contractapi.TransactionContextInterface, coll string, filter paymentFilter) ([]*PaymentDetails, error) {
+	var payments []*PaymentDetails
+
+	for _, selector := range filter.selectors() {
+		queryBytes, err := json.Marshal(map[string]interface{}{"selector": selector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal query for %s: %v", coll, err)
+		}
+
+		iter, err := ctx.GetStub().GetPrivateDataQueryResult(coll, string(queryBytes))
+		if err != nil {
+			if strings.Contains(err.Error(), leveldbQueryUnsupported) {
+				return s.rangePayments(ctx, coll, filter)
+			}
+			return nil, fmt.Errorf("failed to query PDC %s: %v", coll, err)
+		}
+
+		matched, err := collectPayments(iter, coll, filter)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, matched...)
+	}
+
+	return payments, nil
+}
+
+// rangePayments serves a query with key-range reads only, so it works on LevelDB and
+// inside update transactions. It walks the status~window~paymentID index when the
+// collection has one and the filter names statuses, and scans the collection otherwise.
+func (s *SmartContract) rangePayments(ctx contractapi.TransactionContextInterface, coll string, filter paymentFilter) ([]*PaymentDetails, error) {
+	payments, ok, err := s.lookupStatusIndex(ctx, coll, filter)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return payments, nil
+	}
+	return s.scanPayments(ctx, coll, filter)
+}
+
+// scanPayments reads the whole collection and filters in Go
+func (s *SmartContract) scanPayments(ctx contractapi.TransactionContextInterface, coll string, filter paymentFilter) ([]*PaymentDetails, error) {
+	iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDC %s: %v", coll, err)
+	}
+	return collectPayments(iter, coll, filter)
+}
+
+// collectPayments drains an iterator, keeping the payments that match the filter.
+// Query results are re-checked so both paths return exactly the same rows.
+func collectPayments(iter shim.StateQueryIteratorInterface, coll string, filter paymentFilter) ([]*PaymentDetails, error) {
+	defer iter.Close()
+
+	var payments []*PaymentDetails
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterator error on %s: %v", coll, err)
+		}
+
+		var pd PaymentDetails
+		if err := json.Unmarshal(qr.Value, &pd); err != nil {
+			continue
+		}
+		if filter.matches(&pd) {
+			payments = append(payments, &pd)
+		}
+	}
+
+	return payments, nil
+}
+
+// selectors builds one Mango selector per requested status. Equality on status keeps
+// every selector on an index; $in would make CouchDB fall back to a full scan.
+func (f paymentFilter) selectors() []map[string]interface{} {
+	build := func(status interface{}) map[string]interface{} {
+		selector := map[string]interface{}{"status": status}
+		if f.BatchWindow != 0 {
+			selector["batchWindow"] = f.BatchWindow
+		}
+		if f.PayerMSP != "" {
+			selector["payerMSP"] = f.PayerMSP
+		}
+		if f.PayeeMSP != "" {
+			selector["payeeMSP"] = f.PayeeMSP
+		}
+		return selector
+	}
+
+	if len(f.Statuses) == 0 {
+		return []map[string]interface{}{build(map[string]interface{}{"$gt": ""})}
+	}
+
+	selectors := make([]map[string]interface{}, 0, len(f.Statuses))
+	for _, status := range f.Statuses {
+		selectors = append(selectors, build(status))
+	}
+	return selectors
+}
+
+// matches applies the filter in Go
+func (f paymentFilter) matches(pd *PaymentDetails) bool {
+	if pd.Status == "" {
+		return false
+	}
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if pd.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.BatchWindow != 0 && pd.BatchWindow != f.BatchWindow {
+		return false
+	}
+	if f.PayerMSP != "" && pd.PayerMSP != f.PayerMSP {
+		return false
+	}
+	if f.PayeeMSP != "" && pd.PayeeMSP != f.PayeeMSP {
+		return false
+	}
+	return true
+}
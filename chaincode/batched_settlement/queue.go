@@ -0,0 +1,345 @@
+// queue.go - Priority queue management for QUEUED payments
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// queueConfigKey is the public state key holding the QueueConfig
+const queueConfigKey = "QUEUE_CONFIG"
+
+// defaultQueueTTLSeconds applies until CBN configures a TTL (one business day)
+const defaultQueueTTLSeconds int64 = 24 * 60 * 60
+
+// SetQueueTTL sets how long a payment may stay QUEUED before it is returned unsettled (CBN only)
+func (s *SmartContract) SetQueueTTL(ctx contractapi.TransactionContextInterface, ttlSeconds int64) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return fmt.Errorf("only Central Bank can configure the payment queue")
+	}
+	if ttlSeconds <= 0 {
+		return fmt.Errorf("queue TTL must be positive")
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := QueueConfig{
+		TTLSeconds: ttlSeconds,
+		UpdatedBy:  clientMSP,
+		UpdatedAt:  now,
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue config: %v", err)
+	}
+	if err := ctx.GetStub().PutState(queueConfigKey, configBytes); err != nil {
+		return fmt.Errorf("failed to store queue config: %v", err)
+	}
+
+	return s.emitSettlementEvent(ctx, "QueueConfigUpdated", config)
+}
+
+// GetQueueConfig returns the current queue settings
+func (s *SmartContract) GetQueueConfig(ctx contractapi.TransactionContextInterface) (*QueueConfig, error) {
+	return s.getQueueConfig(ctx)
+}
+
+// GetPaymentQueue returns the payer's QUEUED payments in release order (payer bank or CBN)
+func (s *SmartContract) GetPaymentQueue(ctx contractapi.TransactionContextInterface, payerMSP string) ([]*QueueEntry, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != payerMSP {
+		return nil, fmt.Errorf("only %s or Central Bank can view its payment queue", payerMSP)
+	}
+
+	config, err := s.getQueueConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := s.getPayerQueue(ctx, payerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*QueueEntry, 0, len(queue))
+	for i, pd := range queue {
+		queuedAt := queuedSince(pd)
+		entries = append(entries, &QueueEntry{
+			Position:       i + 1,
+			ID:             pd.ID,
+			PayerMSP:       pd.PayerMSP,
+			PayeeMSP:       pd.PayeeMSP,
+			AmountToSettle: pd.AmountToSettle,
+			Priority:       pd.Priority,
+			QueueReason:    pd.QueueReason,
+			QueuedAt:       queuedAt,
+			ExpiresAt:      queuedAt + config.TTLSeconds,
+		})
+	}
+
+	return entries, nil
+}
+
+// ReprioritizePayment changes the priority of a queued payment, or of an ACKNOWLEDGED one
+// before the Central Bank routes it to the batch or the gross lane (payer bank or CBN)
+func (s *SmartContract) ReprioritizePayment(ctx contractapi.TransactionContextInterface, id, priority string) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+
+	newPriority, err := normalizePriority(priority)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.getPaymentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != payment.PayerMSP {
+		return fmt.Errorf("only payer bank or Central Bank can reprioritize payment %s", id)
+	}
+	if !isQueuedStatus(payment.Status) && payment.Status != "ACKNOWLEDGED" {
+		return fmt.Errorf("payment %s cannot be reprioritized in %s status", id, payment.Status)
+	}
+
+	oldPriority := payment.Priority
+	payment.Priority = newPriority
+	if err := s.putPaymentDetails(ctx, payment); err != nil {
+		return err
+	}
+
+	return s.emitSettlementEvent(ctx, "PaymentReprioritized", map[string]interface{}{
+		"paymentID":   payment.ID,
+		"payerMSP":    payment.PayerMSP,
+		"payeeMSP":    payment.PayeeMSP,
+		"oldPriority": oldPriority,
+		"newPriority": newPriority,
+		"changedBy":   clientMSP,
+	})
+}
+
+// ReleaseQueuedPayment moves a QUEUED payment back to BATCHED for the current window.
+// The payer may only release the head of its own queue (URGENT items sort ahead of
+// NORMAL ones); CBN may bypass FIFO order. Exposure limits and the banks' participant
+// states are always re-checked.
+func (s *SmartContract) ReleaseQueuedPayment(ctx contractapi.TransactionContextInterface, id string) error {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP: %v", err)
+	}
+
+	payment, err := s.getPaymentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != payment.PayerMSP {
+		return fmt.Errorf("only payer bank or Central Bank can release payment %s", id)
+	}
+	if !isQueuedStatus(payment.Status) {
+		return fmt.Errorf("payment %s is not in QUEUED status, current status: %s", id, payment.Status)
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ReleaseQueuedPayment"); err != nil {
+		return err
+	}
+
+	if clientMSP != "CentralBankMSP" {
+		queue, err := s.getPayerQueue(ctx, payment.PayerMSP)
+		if err != nil {
+			return err
+		}
+		if len(queue) > 0 && queue[0].ID != payment.ID {
+			return fmt.Errorf("payment %s is not at the head of the queue (next is %s)", id, queue[0].ID)
+		}
+	}
+
+	if err := s.requireParticipantsCanPay(ctx, payment.PayerMSP, payment.PayeeMSP); err != nil {
+		return fmt.Errorf("payment %s cannot be released: %v", id, err)
+	}
+	breach, err := s.checkExposureLimits(ctx, payment)
+	if err != nil {
+		return fmt.Errorf("failed to check exposure limits: %v", err)
+	}
+	if breach != "" {
+		return fmt.Errorf("payment %s cannot be released: %s", id, breach)
+	}
+
+	payment.Status = "BATCHED"
+	payment.QueueReason = ""
+	payment.BatchWindow, err = txBatchWindow(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.putPaymentDetails(ctx, payment); err != nil {
+		return err
+	}
+
+	return s.emitPaymentEvent(ctx, "PaymentReleased", PaymentEventDetails{
+		ID:          payment.ID,
+		PayeeMSP:    payment.PayeeMSP,
+		PayerMSP:    payment.PayerMSP,
+		BatchWindow: payment.BatchWindow,
+	})
+}
+
+// ExpireQueuedPayments returns every QUEUED payment older than the queue TTL
+// with status RETURNED_UNSETTLED (CBN only)
+func (s *SmartContract) ExpireQueuedPayments(ctx contractapi.TransactionContextInterface) (*QueueExpiryResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can expire queued payments")
+	}
+
+	config, err := s.getQueueConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &QueueExpiryResult{
+		TTLSeconds:      config.TTLSeconds,
+		ExpiredPayments: make([]string, 0),
+		Timestamp:       now,
+	}
+
+	bankMSPs := getBankMSPs()
+	for i, bankA := range bankMSPs {
+		for j := i + 1; j < len(bankMSPs); j++ {
+			coll := getCollectionName(bankA, bankMSPs[j])
+			queued, err := s.getQueuedPaymentsFromCollection(ctx, coll)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pd := range queued {
+				if now-queuedSince(pd) < config.TTLSeconds {
+					continue
+				}
+
+				pd.Status = "RETURNED_UNSETTLED"
+				if err := s.putPaymentDetails(ctx, pd); err != nil {
+					return nil, err
+				}
+
+				result.ExpiredCount++
+				result.ExpiredAmount += pd.AmountToSettle
+				result.ExpiredPayments = append(result.ExpiredPayments, pd.ID)
+			}
+		}
+	}
+
+	if err := s.emitSettlementEvent(ctx, "QueuedPaymentsExpired", result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getQueueConfig loads the queue settings, falling back to the default TTL
+func (s *SmartContract) getQueueConfig(ctx contractapi.TransactionContextInterface) (*QueueConfig, error) {
+	configBytes, err := ctx.GetStub().GetState(queueConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue config: %v", err)
+	}
+	if configBytes == nil {
+		return &QueueConfig{TTLSeconds: defaultQueueTTLSeconds}, nil
+	}
+
+	var config QueueConfig
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queue config: %v", err)
+	}
+	return &config, nil
+}
+
+// getPayerQueue returns the payer's QUEUED payments sorted into release order
+func (s *SmartContract) getPayerQueue(ctx contractapi.TransactionContextInterface, payerMSP string) ([]*PaymentDetails, error) {
+	var queue []*PaymentDetails
+
+	for _, otherMSP := range getBankMSPs() {
+		if otherMSP == payerMSP {
+			continue
+		}
+
+		queued, err := s.getQueuedPaymentsFromCollection(ctx, getCollectionName(payerMSP, otherMSP))
+		if err != nil {
+			return nil, err
+		}
+		for _, pd := range queued {
+			if pd.PayerMSP == payerMSP {
+				queue = append(queue, pd)
+			}
+		}
+	}
+
+	sortQueue(queue)
+	return queue, nil
+}
+
+// getQueuedPaymentsFromCollection returns every QUEUED or PARTIALLY_SETTLED payment in a bilateral collection
+func (s *SmartContract) getQueuedPaymentsFromCollection(ctx contractapi.TransactionContextInterface, coll string) ([]*PaymentDetails, error) {
+	return s.rangePayments(ctx, coll, paymentFilter{Statuses: queuedStatuses})
+}
+
+// sortQueue orders payments by priority, then FIFO by queue entry time, then ID
+func sortQueue(queue []*PaymentDetails) {
+	sort.SliceStable(queue, func(i, j int) bool {
+		a, b := queue[i], queue[j]
+		if priorityRank(a.Priority) != priorityRank(b.Priority) {
+			return priorityRank(a.Priority) < priorityRank(b.Priority)
+		}
+		if queuedSince(a) != queuedSince(b) {
+			return queuedSince(a) < queuedSince(b)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// queuedSince returns when the payment entered the queue. Payments queued before
+// QueuedAt was recorded fall back to the start of their batch window.
+func queuedSince(pd *PaymentDetails) int64 {
+	if pd.QueuedAt > 0 {
+		return pd.QueuedAt
+	}
+	return getBatchWindowStart(pd.BatchWindow).Unix()
+}
+
+// normalizePriority validates a priority level, defaulting to NORMAL
+func normalizePriority(priority string) (string, error) {
+	switch strings.ToUpper(priority) {
+	case "", "NORMAL":
+		return "NORMAL", nil
+	case "URGENT":
+		return "URGENT", nil
+	default:
+		return "", fmt.Errorf("invalid priority %q: must be URGENT or NORMAL", priority)
+	}
+}
+
+// priorityRank orders priorities with URGENT first
+func priorityRank(priority string) int {
+	if priority == "URGENT" {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,291 @@
+// reserve.go - Central Bank issuance: minting, burning and the eNaira total supply
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Issuance record types
+const (
+	MintTypeMint = "MINT"
+	MintTypeBurn = "BURN"
+)
+
+const (
+	// totalSupplyKey is the public state key holding the SupplyRecord
+	totalSupplyKey = "TOTAL_SUPPLY"
+	// mintRecordObjectType keys MintRecords in the bank's col-settlement-<MSP> collection
+	mintRecordObjectType = "mint"
+)
+
+// MintToBank issues new eNaira into a bank's settlement account (CBN only)
+func (s *SmartContract) MintToBank(ctx contractapi.TransactionContextInterface, msp string, amount float64) (*MintRecord, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can mint eNaira")
+	}
+
+	account, err := s.GetSettlementAccount(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	return s.mint(ctx, account, amount)
+}
+
+// BurnFromBank withdraws eNaira from a bank's settlement account and out of circulation
+// (CBN only). A bank cannot be burned below a zero balance.
+func (s *SmartContract) BurnFromBank(ctx contractapi.TransactionContextInterface, msp string, amount float64) (*MintRecord, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can burn eNaira")
+	}
+	if err := validateIssuanceAmount(amount); err != nil {
+		return nil, err
+	}
+
+	account, err := s.GetSettlementAccount(ctx, msp)
+	if err != nil {
+		return nil, err
+	}
+	if account.Balance < amount {
+		return nil, fmt.Errorf("cannot burn %.2f from %s: balance is only %.2f", amount, msp, account.Balance)
+	}
+	account.Balance -= amount
+	if err := s.putSettlementAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	return s.recordIssuance(ctx, MintTypeBurn, msp, -amount)
+}
+
+// GetTotalSupply returns the eNaira in circulation and how much was minted and burned
+func (s *SmartContract) GetTotalSupply(ctx contractapi.TransactionContextInterface) (*SupplyRecord, error) {
+	return s.getTotalSupply(ctx)
+}
+
+// GetMintRecords lists the mints and burns applied to a bank. Only the bank itself and
+// the Central Bank can read them.
+func (s *SmartContract) GetMintRecords(ctx contractapi.TransactionContextInterface, msp string) ([]*MintRecord, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" && clientMSP != msp {
+		return nil, fmt.Errorf("%s cannot read mint records of %s", clientMSP, msp)
+	}
+
+	coll := fmt.Sprintf("col-settlement-%s", msp)
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(coll, mintRecordObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mint records for %s: %v", msp, err)
+	}
+	defer iter.Close()
+
+	records := make([]*MintRecord, 0)
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate mint records for %s: %v", msp, err)
+		}
+
+		var record MintRecord
+		if err := json.Unmarshal(qr.Value, &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
+
+// CheckSupplyInvariant confirms the settlement balances of all banks and the guarantee fund
+// add up to the total supply plus the intraday credit they have drawn (CBN only). Any
+// difference means money moved outside a mint, burn, credit drawing or transfer.
+func (s *SmartContract) CheckSupplyInvariant(ctx contractapi.TransactionContextInterface) (*SupplyInvariantResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can check the supply invariant")
+	}
+
+	supply, err := s.getTotalSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SupplyInvariantResult{
+		TotalSupply: supply.TotalSupply,
+		Balances:    make(map[string]float64),
+		Timestamp:   now,
+	}
+	for _, msp := range getBankMSPs() {
+		facility, err := s.getIntradayFacility(ctx, msp)
+		if err != nil {
+			return nil, err
+		}
+		result.IntradayCredit += facility.Drawn
+
+		coll := fmt.Sprintf("col-settlement-%s", msp)
+		accountBytes, err := ctx.GetStub().GetPrivateData(coll, msp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get settlement account for %s: %v", msp, err)
+		}
+		// A bank that has not been onboarded holds nothing
+		if accountBytes == nil {
+			continue
+		}
+
+		var account BankAccount
+		if err := json.Unmarshal(accountBytes, &account); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account for %s: %v", msp, err)
+		}
+		result.Balances[msp] = account.Balance
+		result.TotalBalances += account.Balance
+	}
+
+	fund, err := s.getGuaranteeFund(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.GuaranteeFund = fund.Balance
+
+	result.TotalBalances = roundToKobo(result.TotalBalances)
+	result.IntradayCredit = roundToKobo(result.IntradayCredit)
+	result.Difference = roundToKobo(result.TotalBalances + result.GuaranteeFund - result.TotalSupply - result.IntradayCredit)
+	result.Holds = result.Difference == 0
+	return result, nil
+}
+
+// mint credits a bank's settlement account with newly issued eNaira and records the issuance
+func (s *SmartContract) mint(ctx contractapi.TransactionContextInterface, account *BankAccount, amount float64) (*MintRecord, error) {
+	if err := validateIssuanceAmount(amount); err != nil {
+		return nil, err
+	}
+
+	account.Balance += amount
+	if err := s.putSettlementAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return s.recordIssuance(ctx, MintTypeMint, account.MSP, amount)
+}
+
+// recordIssuance stores the MintRecord for a mint (positive delta) or burn (negative delta),
+// moves the total supply by delta and emits the matching event
+func (s *SmartContract) recordIssuance(ctx contractapi.TransactionContextInterface, recordType, msp string, delta float64) (*MintRecord, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	supply, err := s.getTotalSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	supply.TotalSupply = roundToKobo(supply.TotalSupply + delta)
+	if delta > 0 {
+		supply.TotalMinted = roundToKobo(supply.TotalMinted + delta)
+	} else {
+		supply.TotalBurned = roundToKobo(supply.TotalBurned - delta)
+	}
+	if err := s.putTotalSupply(ctx, supply); err != nil {
+		return nil, err
+	}
+
+	record := &MintRecord{
+		ID:          ctx.GetStub().GetTxID(),
+		Type:        recordType,
+		Amount:      math.Abs(delta),
+		Currency:    "NGN",
+		TotalSupply: supply.TotalSupply,
+		Timestamp:   now,
+	}
+	if recordType == MintTypeBurn {
+		record.FromMSP = msp
+	} else {
+		record.ToMSP = msp
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(mintRecordObjectType, []string{record.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mint record key: %v", err)
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mint record: %v", err)
+	}
+	coll := fmt.Sprintf("col-settlement-%s", msp)
+	if err := ctx.GetStub().PutPrivateData(coll, key, recordBytes); err != nil {
+		return nil, fmt.Errorf("failed to store mint record for %s: %v", msp, err)
+	}
+
+	eventName := "EnairaMinted"
+	if recordType == MintTypeBurn {
+		eventName = "EnairaBurned"
+	}
+	if err := s.emitSettlementEvent(ctx, eventName, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// putTotalSupply stamps and stores the SupplyRecord
+func (s *SmartContract) putTotalSupply(ctx contractapi.TransactionContextInterface, supply *SupplyRecord) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	supply.UpdatedAt = now
+
+	supplyBytes, err := json.Marshal(supply)
+	if err != nil {
+		return fmt.Errorf("failed to marshal total supply: %v", err)
+	}
+	if err := ctx.GetStub().PutState(totalSupplyKey, supplyBytes); err != nil {
+		return fmt.Errorf("failed to store total supply: %v", err)
+	}
+	return nil
+}
+
+// getTotalSupply loads the SupplyRecord; nothing is in circulation before the first mint
+func (s *SmartContract) getTotalSupply(ctx contractapi.TransactionContextInterface) (*SupplyRecord, error) {
+	supplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read total supply: %v", err)
+	}
+
+	supply := &SupplyRecord{Currency: "NGN"}
+	if supplyBytes == nil {
+		return supply, nil
+	}
+	if err := json.Unmarshal(supplyBytes, supply); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal total supply: %v", err)
+	}
+	return supply, nil
+}
+
+// validateIssuanceAmount rejects non-positive amounts and fractions of a kobo
+func validateIssuanceAmount(amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if roundToKobo(amount) != amount {
+		return fmt.Errorf("amount %v has more precision than a kobo", amount)
+	}
+	return nil
+}
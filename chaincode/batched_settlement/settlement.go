@@ -4,7 +4,7 @@ package settlement
 import (
 	"encoding/json"
 	"fmt"
-	"time"
+	"sort"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -31,19 +31,30 @@ func (s *SmartContract) GetSettlementAccount(ctx contractapi.TransactionContextI
 
 // CalculateNettingOffsets calculates net positions and payment updates without applying them
 func (s *SmartContract) CalculateNettingOffsets(ctx contractapi.TransactionContextInterface) (string, error) {
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "CalculateNettingOffsets"); err != nil {
+		return "", err
+	}
+
 	// Get all BATCHED payments and calculate net positions
 	netPositions, batchedPayments, err := s.calculateNetPositionsFromBatchedPayments(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to calculate net positions: %v", err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	// Initialize calculation result
 	result := &NettingCalculationResult{
 		NetPositions:   netPositions,
 		PaymentUpdates: make([]PaymentUpdate, 0),
 		TotalPayments:  len(batchedPayments),
 		TotalNetAmount: 0,
-		Timestamp:      time.Now().Unix(),
+		Efficiency:     buildNettingEfficiency(batchedPayments, netPositions),
+		TransferPlan:   buildTransferPlan(netPositions),
+		Timestamp:      now,
 	}
 
 	// Calculate total net amount
@@ -75,6 +86,17 @@ func (s *SmartContract) CalculateNettingOffsets(ctx contractapi.TransactionConte
 
 // ApplyNettingOffsets applies the calculated netting offsets to settlement accounts and payments
 func (s *SmartContract) ApplyNettingOffsets(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return "", fmt.Errorf("only Central Bank can apply netting offsets")
+	}
+	if err := s.requireSettlementMode(ctx, SettlementModeDeferredNet, "ApplyNettingOffsets"); err != nil {
+		return "", err
+	}
+
 	// Get calculation result from transient data
 	transMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
@@ -91,23 +113,80 @@ func (s *SmartContract) ApplyNettingOffsets(ctx contractapi.TransactionContextIn
 		return "", fmt.Errorf("failed to unmarshal netting calculation: %v", err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	// Initialize application result
 	result := &NettingApplicationResult{
-		SettledBanks:    make(map[string]float64),
-		FailedBanks:     make([]FailedBankSettlement, 0),
-		SettledPayments: 0,
-		FailedPayments:  0,
-		TotalNetAmount:  calculation.TotalNetAmount,
-		Timestamp:       time.Now().Unix(),
+		SettledBanks:     make(map[string]float64),
+		FailedBanks:      make([]FailedBankSettlement, 0),
+		GuaranteeCovered: make(map[string]float64),
+		SettledPayments:  0,
+		FailedPayments:   0,
+		TotalNetAmount:   calculation.TotalNetAmount,
+		Timestamp:        now,
+	}
+
+	// The guarantee fund covers debtors that cannot settle
+	fund, err := s.getGuaranteeFund(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Payments of banks barred from netting since the calculation stay BATCHED
+	payments, held, err := s.loadNettingPayments(ctx, &calculation)
+	if err != nil {
+		return "", err
+	}
+	for _, update := range calculation.PaymentUpdates {
+		if held[update.ID] {
+			result.HeldPayments = append(result.HeldPayments, update.ID)
+		}
 	}
 
-	// Step 1: Apply net settlements to bank accounts
-	for bankMSP, netAmount := range calculation.NetPositions {
+	// A debtor that cannot pay even with the fund is excluded and the cycle re-run without it
+	netPositions, defaulted, unwind, err := s.unwindDefaultingDebtors(ctx, &calculation, payments, fund)
+	if err != nil {
+		return "", err
+	}
+	// Only what this cycle actually settles may raise the banks' peaks
+	fund.recordPeakNetDebits(netPositions)
+	if unwind != nil || len(held) > 0 {
+		result.TotalNetAmount = 0
+		for _, netAmount := range netPositions {
+			if netAmount > 0 {
+				result.TotalNetAmount = roundToKobo(result.TotalNetAmount + netAmount)
+			}
+		}
+	}
+	excluded := make(map[string]bool)
+	if unwind != nil {
+		result.FailedBanks = append(result.FailedBanks, defaulted...)
+		result.Unwind = unwind
+		for _, id := range unwind.ExcludedPayments {
+			excluded[id] = true
+		}
+	}
+
+	// Step 1: Apply net settlements to bank accounts, in a fixed order so every endorser
+	// shares losses out of the fund the same way
+	bankMSPs := make([]string, 0, len(netPositions))
+	for bankMSP := range netPositions {
+		bankMSPs = append(bankMSPs, bankMSP)
+	}
+	sort.Strings(bankMSPs)
+	for _, bankMSP := range bankMSPs {
+		netAmount := netPositions[bankMSP]
 		if netAmount == 0 {
 			continue // No net position, skip
 		}
 
-		err := s.applyNetSettlement(ctx, bankMSP, netAmount)
+		covered, err := s.applyNetSettlement(ctx, bankMSP, netAmount, fund)
+		if covered > 0 {
+			result.GuaranteeCovered[bankMSP] = covered
+		}
 		if err != nil {
 			result.FailedBanks = append(result.FailedBanks, FailedBankSettlement{
 				BankMSP:   bankMSP,
@@ -120,9 +199,25 @@ func (s *SmartContract) ApplyNettingOffsets(ctx contractapi.TransactionContextIn
 		}
 	}
 
-	// Step 2: Update all payment statuses to SETTLED
+	if err := s.putGuaranteeFund(ctx, fund); err != nil {
+		return "", err
+	}
+
+	// Step 2: Update all payment statuses to SETTLED, returning those of defaulted banks to the
+	// queue and leaving held ones BATCHED
 	for _, update := range calculation.PaymentUpdates {
-		err := s.updatePaymentStatusAndAmount(ctx, update.PayerMSP, update.PayeeMSP, update.ID, update.Status, update.AmountToSettle)
+		if held[update.ID] {
+			continue
+		}
+		if excluded[update.ID] {
+			if err := s.requeueForDefault(ctx, update); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		// Netting settles what is left of the payment; the status and amount are not the caller's
+		err := s.updatePaymentStatusAndAmount(ctx, update.PayerMSP, update.PayeeMSP, update.ID, "SETTLED", 0)
 		if err != nil {
 			result.FailedPayments++
 			fmt.Printf("Failed to update payment %s: %v\n", update.ID, err)
@@ -160,7 +255,7 @@ func (s *SmartContract) ApplyNettingOffsets(ctx contractapi.TransactionContextIn
 		TotalPayments:   calculation.TotalPayments,
 		SettledPayments: result.SettledPayments,
 		FailedPayments:  result.FailedPayments,
-		NetPositions:    calculation.NetPositions,
+		NetPositions:    netPositions,
 		SettledBanks:    result.SettledBanks,
 		TotalNetAmount:  result.TotalNetAmount,
 		Timestamp:       result.Timestamp,
@@ -170,6 +265,10 @@ func (s *SmartContract) ApplyNettingOffsets(ctx contractapi.TransactionContextIn
 	eventBytes, _ := json.Marshal(settlementEvent)
 	ctx.GetStub().SetEvent("NettingSettlementExecuted", eventBytes)
 
+	if err := s.recordSettlementCycle(ctx, netPositions, result); err != nil {
+		return "", err
+	}
+
 	// Return application result as JSON
 	resultBytes, err := json.Marshal(result)
 	if err != nil {
@@ -219,14 +318,20 @@ func (s *SmartContract) applyOffsetsWithTransientData(ctx contractapi.Transactio
 		return "", fmt.Errorf("failed to unmarshal netting calculation: %v", err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	// Apply the settlement logic (same as ApplyNettingOffsets)
 	result := &NettingApplicationResult{
-		SettledBanks:    make(map[string]float64),
-		FailedBanks:     make([]FailedBankSettlement, 0),
-		SettledPayments: 0,
-		FailedPayments:  0,
-		TotalNetAmount:  calculation.TotalNetAmount,
-		Timestamp:       time.Now().Unix(),
+		SettledBanks:     make(map[string]float64),
+		FailedBanks:      make([]FailedBankSettlement, 0),
+		GuaranteeCovered: make(map[string]float64),
+		SettledPayments:  0,
+		FailedPayments:   0,
+		TotalNetAmount:   calculation.TotalNetAmount,
+		Timestamp:        now,
 	}
 
 	// Apply net settlements and update payments (same logic as ApplyNettingOffsets)
@@ -236,9 +341,37 @@ func (s *SmartContract) applyOffsetsWithTransientData(ctx contractapi.Transactio
 	return string(resultBytes), nil
 }
 
-// calculateNetPositionsFromBatchedPayments calculates net positions for all banks from BATCHED payments
+// calculateNetPositionsFromBatchedPayments calculates net positions for all banks from BATCHED
+// payments. Payments of banks whose participant state bars them from netting stay BATCHED.
 func (s *SmartContract) calculateNetPositionsFromBatchedPayments(ctx contractapi.TransactionContextInterface) (map[string]float64, []*PaymentDetails, error) {
+	payments, err := s.getBatchedPayments(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	eligible, err := s.nettingEligibility(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	netPositions := make(map[string]float64)
+	var batchedPayments []*PaymentDetails
+	for _, payment := range payments {
+		if !eligible(payment) {
+			continue
+		}
+		batchedPayments = append(batchedPayments, payment)
+
+		// Calculate net positions: incoming (+) minus outgoing (-).
+		// AmountToSettle excludes any portion already offset bilaterally.
+		netPositions[payment.PayeeMSP] += payment.AmountToSettle // Payee receives
+		netPositions[payment.PayerMSP] -= payment.AmountToSettle // Payer pays
+	}
+
+	return netPositions, batchedPayments, nil
+}
+
+// getBatchedPayments collects the BATCHED payments of every bilateral collection
+func (s *SmartContract) getBatchedPayments(ctx contractapi.TransactionContextInterface) ([]*PaymentDetails, error) {
 	var batchedPayments []*PaymentDetails
 	bankMSPs := getBankMSPs()
 
@@ -248,51 +381,30 @@ func (s *SmartContract) calculateNetPositionsFromBatchedPayments(ctx contractapi
 			bankB := bankMSPs[j]
 			coll := getCollectionName(bankA, bankB)
 
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+			batched, err := s.rangePayments(ctx, coll, paymentFilter{Statuses: []string{"BATCHED"}})
 			if err != nil {
-				// Log error but continue with other collections
-				fmt.Printf("Failed to access collection %s: %v\n", coll, err)
-				continue
-			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, err := iter.Next()
-				if err != nil {
-					continue
-				}
-
-				var payment PaymentDetails
-				if err := json.Unmarshal(qr.Value, &payment); err != nil {
-					continue
-				}
-
-				// Only process BATCHED payments
-				if payment.Status == "BATCHED" {
-					batchedPayments = append(batchedPayments, &payment)
-
-					// Calculate net positions: incoming (+) minus outgoing (-)
-					netPositions[payment.PayeeMSP] += payment.Amount // Payee receives
-					netPositions[payment.PayerMSP] -= payment.Amount // Payer pays
-				}
+				return nil, err
 			}
+
+			batchedPayments = append(batchedPayments, batched...)
 		}
 	}
 
-	return netPositions, batchedPayments, nil
+	return batchedPayments, nil
 }
 
-// applyNetSettlement applies the net settlement amount to a bank's settlement account
-func (s *SmartContract) applyNetSettlement(ctx contractapi.TransactionContextInterface, bankMSP string, netAmount float64) error {
+// applyNetSettlement applies the net settlement amount to a bank's settlement account.
+// Returns what the guarantee fund covered of a debtor's shortfall.
+func (s *SmartContract) applyNetSettlement(ctx contractapi.TransactionContextInterface, bankMSP string, netAmount float64, fund *GuaranteeFund) (float64, error) {
 	if netAmount > 0 {
 		// Bank receives money - credit settlement account
-		return s.creditSettlementAccount(ctx, bankMSP, netAmount)
+		return 0, s.creditSettlementAccount(ctx, bankMSP, netAmount)
 	} else if netAmount < 0 {
 		// Bank pays money - debit settlement account
-		return s.debitSettlementAccount(ctx, bankMSP, -netAmount) // Use positive amount for debit
+		return s.debitSettlementAccount(ctx, bankMSP, -netAmount, fund) // Use positive amount for debit
 	}
 	// netAmount == 0, no action needed
-	return nil
+	return 0, nil
 }
 
 // markPaymentAsSettled updates a payment from BATCHED to SETTLED
@@ -320,33 +432,50 @@ func (s *SmartContract) markPaymentAsSettled(ctx contractapi.TransactionContextI
 	return nil
 }
 
-// debitSettlementAccount debits amount from MSP settlement account (allows negative balances)
-func (s *SmartContract) debitSettlementAccount(ctx contractapi.TransactionContextInterface, msp string, amount float64) error {
+// debitSettlementAccount debits amount from MSP settlement account (allows negative balances).
+// With a guarantee fund, the fund covers what the intraday facility could not; the caller
+// stores the fund. Returns the amount the fund covered.
+func (s *SmartContract) debitSettlementAccount(ctx contractapi.TransactionContextInterface, msp string, amount float64, fund *GuaranteeFund) (float64, error) {
 	coll := fmt.Sprintf("col-settlement-%s", msp)
 	accountBytes, err := ctx.GetStub().GetPrivateData(coll, msp)
 	if err != nil {
-		return fmt.Errorf("failed to read settlement account for %s: %v", msp, err)
+		return 0, fmt.Errorf("failed to read settlement account for %s: %v", msp, err)
 	}
 
 	var account BankAccount
 	if accountBytes != nil {
 		if err := json.Unmarshal(accountBytes, &account); err != nil {
-			return fmt.Errorf("failed to unmarshal account for %s: %v", msp, err)
+			return 0, fmt.Errorf("failed to unmarshal account for %s: %v", msp, err)
 		}
 	} else {
 		// Create account if it doesn't exist (start with zero balance)
 		account = BankAccount{MSP: msp, Balance: 0}
 	}
 
-	// Allow negative balances - CBN provides liquidity
+	// A shortfall draws on the bank's intraday facility first. Beyond it the balance
+	// may still go negative - CBN backs the netting
+	if _, err := s.coverShortfall(ctx, &account, amount, true); err != nil {
+		return 0, err
+	}
+	var covered float64
+	if fund != nil {
+		if covered, err = s.coverFromGuaranteeFund(ctx, fund, &account, amount); err != nil {
+			return 0, err
+		}
+	}
 	account.Balance -= amount
 
 	updated, err := json.Marshal(account)
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated account for %s: %v", msp, err)
+		return 0, fmt.Errorf("failed to marshal updated account for %s: %v", msp, err)
 	}
 	if err := ctx.GetStub().PutPrivateData(coll, msp, updated); err != nil {
-		return fmt.Errorf("failed to update settlement account for %s: %v", msp, err)
+		return 0, fmt.Errorf("failed to update settlement account for %s: %v", msp, err)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	// Emit debit event
@@ -361,12 +490,12 @@ func (s *SmartContract) debitSettlementAccount(ctx contractapi.TransactionContex
 		Amount:    amount,
 		Type:      "netting-debit",
 		Balance:   account.Balance,
-		Timestamp: time.Now().Unix(),
+		Timestamp: now,
 	}
 	evtBytes, _ := json.Marshal(evt)
 	ctx.GetStub().SetEvent("SettlementDebitExecuted", evtBytes)
 
-	return nil
+	return covered, nil
 }
 
 // creditSettlementAccount credits amount to MSP settlement account
@@ -396,6 +525,11 @@ func (s *SmartContract) creditSettlementAccount(ctx contractapi.TransactionConte
 		return fmt.Errorf("failed to update settlement account for %s: %v", msp, err)
 	}
 
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Emit credit event
 	evt := struct {
 		MSP       string  `json:"msp"`
@@ -408,7 +542,7 @@ func (s *SmartContract) creditSettlementAccount(ctx contractapi.TransactionConte
 		Amount:    amount,
 		Type:      "netting-credit",
 		Balance:   account.Balance,
-		Timestamp: time.Now().Unix(),
+		Timestamp: now,
 	}
 	evtBytes, _ := json.Marshal(evt)
 	ctx.GetStub().SetEvent("SettlementCreditExecuted", evtBytes)
@@ -431,23 +565,26 @@ func (s *SmartContract) updatePaymentStatusAndAmount(ctx contractapi.Transaction
 
 	paymentDetails.Status = status
 	paymentDetails.AmountToSettle = amountToSettle
-	updatedPaymentBytes, _ := json.Marshal(paymentDetails)
-	return ctx.GetStub().PutPrivateData(paymentColl, paymentID, updatedPaymentBytes)
+	return s.writePayment(ctx, paymentColl, &paymentDetails)
 }
 
 // GetAllBatchedPayments returns all batched payments system-wide
 func (s *SmartContract) GetAllBatchedPayments(ctx contractapi.TransactionContextInterface) ([]*PaymentDetails, error) {
-	_, batchedPayments, err := s.calculateNetPositionsFromBatchedPayments(ctx)
-	return batchedPayments, err
+	return s.getBatchedPayments(ctx)
 }
 
 // GetSettlementStatistics returns system-wide settlement statistics
 func (s *SmartContract) GetSettlementStatistics(ctx contractapi.TransactionContextInterface) (*SettlementStatistics, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	stats := &SettlementStatistics{
 		StatusCounts:  make(map[string]int),
 		StatusAmounts: make(map[string]float64),
 		BankBalances:  make(map[string]float64),
-		LastUpdated:   time.Now().Unix(),
+		LastUpdated:   now,
 	}
 
 	bankMSPs := getBankMSPs()
@@ -458,23 +595,12 @@ func (s *SmartContract) GetSettlementStatistics(ctx contractapi.TransactionConte
 			bankB := bankMSPs[j]
 			coll := getCollectionName(bankA, bankB)
 
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+			payments, err := s.queryPayments(ctx, coll, paymentFilter{})
 			if err != nil {
 				continue // Skip inaccessible collections
 			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, err := iter.Next()
-				if err != nil {
-					continue
-				}
-
-				var payment PaymentDetails
-				if err := json.Unmarshal(qr.Value, &payment); err != nil {
-					continue
-				}
 
+			for _, payment := range payments {
 				stats.TotalPayments++
 				stats.TotalAmount += payment.Amount
 				stats.StatusCounts[payment.Status]++
@@ -494,10 +620,24 @@ func (s *SmartContract) GetSettlementStatistics(ctx contractapi.TransactionConte
 	return stats, nil
 }
 
-// GetNetPositions calculates current net positions without executing settlement
-func (s *SmartContract) GetNetPositions(ctx contractapi.TransactionContextInterface) (map[string]float64, error) {
-	netPositions, _, err := s.calculateNetPositionsFromBatchedPayments(ctx)
-	return netPositions, err
+// GetNetPositions previews current net positions, netting efficiency and the transfer plan without executing settlement
+func (s *SmartContract) GetNetPositions(ctx contractapi.TransactionContextInterface) (*NetPositionPreview, error) {
+	netPositions, batchedPayments, err := s.calculateNetPositionsFromBatchedPayments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetPositionPreview{
+		NetPositions:  netPositions,
+		Efficiency:    buildNettingEfficiency(batchedPayments, netPositions),
+		TransferPlan:  buildTransferPlan(netPositions),
+		TotalPayments: len(batchedPayments),
+		Timestamp:     now,
+	}, nil
 }
 
 // GetBatchedPaymentsByStatus returns payments filtered by status
@@ -510,27 +650,11 @@ func (s *SmartContract) GetBatchedPaymentsByStatus(ctx contractapi.TransactionCo
 			bankB := bankMSPs[j]
 			coll := getCollectionName(bankA, bankB)
 
-			iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+			payments, err := s.queryPayments(ctx, coll, paymentFilter{Statuses: []string{status}})
 			if err != nil {
 				continue
 			}
-			defer iter.Close()
-
-			for iter.HasNext() {
-				qr, err := iter.Next()
-				if err != nil {
-					continue
-				}
-
-				var payment PaymentDetails
-				if err := json.Unmarshal(qr.Value, &payment); err != nil {
-					continue
-				}
-
-				if payment.Status == status {
-					filteredPayments = append(filteredPayments, &payment)
-				}
-			}
+			filteredPayments = append(filteredPayments, payments...)
 		}
 	}
 
@@ -543,13 +667,3 @@ func (s *SmartContract) GetBatchedPaymentsByStatus(ctx contractapi.TransactionCo
 func (s *SmartContract) SettleAllBatchedPayments(ctx contractapi.TransactionContextInterface) (string, error) {
 	return s.ExecuteNettingSettlement(ctx)
 }
-
-// DebitAccount - Legacy function for compatibility
-func (s *SmartContract) DebitAccount(ctx contractapi.TransactionContextInterface, paymentDetails PaymentEventDetails) (string, error) {
-	return "", fmt.Errorf("DebitAccount is deprecated - settlement is now handled centrally by CBN netting")
-}
-
-// CreditAccount - Legacy function for compatibility
-func (s *SmartContract) CreditAccount(ctx contractapi.TransactionContextInterface, paymentDetails PaymentEventDetails) error {
-	return fmt.Errorf("CreditAccount is deprecated - settlement is now handled centrally by CBN netting")
-}
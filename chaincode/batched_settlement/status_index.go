@@ -0,0 +1,267 @@
+// status_index.go - status~window~paymentID index entries kept inside each bilateral PDC
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// paymentStatusIndex is the composite key object type for index entries. Entries
+// carry no data; the payment record under its plain ID key stays authoritative.
+const paymentStatusIndex = "status~window~paymentID"
+
+// statusIndexStateType keys the ReindexResult that marks a collection's index as ready
+const statusIndexStateType = "statusIndexState"
+
+// indexEntryValue is stored under each index key (an empty value would read as a delete)
+var indexEntryValue = []byte{0x00}
+
+// ReindexCollection rebuilds the status index of a bilateral collection from its payment
+// records (CBN only). Collections with payments from before the index existed must be
+// reindexed once; until then lookups on LevelDB peers keep using full range scans.
+func (s *SmartContract) ReindexCollection(ctx contractapi.TransactionContextInterface, collection string) (*ReindexResult, error) {
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP: %v", err)
+	}
+	if clientMSP != "CentralBankMSP" {
+		return nil, fmt.Errorf("only Central Bank can reindex collections")
+	}
+	if !isBilateralCollection(collection) {
+		return nil, fmt.Errorf("unknown bilateral collection: %s", collection)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReindexResult{
+		Collection: collection,
+		RebuiltBy:  clientMSP,
+		Timestamp:  now,
+	}
+
+	// Drop every existing entry, including stale ones left by older chaincode versions
+	iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, paymentStatusIndex, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status index of %s: %v", collection, err)
+	}
+	for iter.HasNext() {
+		qr, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("iterator error on %s: %v", collection, err)
+		}
+		if err := ctx.GetStub().DelPrivateData(collection, qr.Key); err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("failed to remove index entry in %s: %v", collection, err)
+		}
+		result.RemovedEntries++
+	}
+	iter.Close()
+
+	// Re-create one entry per payment; a put after a delete of the same key wins
+	payments, err := s.scanPayments(ctx, collection, paymentFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pd := range payments {
+		key, err := statusIndexKey(ctx, pd)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutPrivateData(collection, key, indexEntryValue); err != nil {
+			return nil, fmt.Errorf("failed to write index entry for %s: %v", pd.ID, err)
+		}
+		result.IndexedPayments++
+	}
+
+	stateKey, err := ctx.GetStub().CreateCompositeKey(statusIndexStateType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create status index state key: %v", err)
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reindex result: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, stateKey, resultBytes); err != nil {
+		return nil, fmt.Errorf("failed to store status index state for %s: %v", collection, err)
+	}
+
+	if err := s.emitSettlementEvent(ctx, "CollectionReindexed", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writePayment stores a payment record in its bilateral collection, stamping the
+// lifecycle time of a new status and moving its status index entry in the same write
+// set, so the index changes atomically with the record. The previous state is what this
+// transaction last wrote for the payment, falling back to the committed record, so a
+// second write in one transaction replaces the index entry the first one created.
+func (s *SmartContract) writePayment(ctx contractapi.TransactionContextInterface, coll string, payment *PaymentDetails) error {
+	previous, err := previousPaymentWrite(ctx, coll, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := stampLifecycle(ctx, previous, payment); err != nil {
+		return err
+	}
+
+	newKey, err := statusIndexKey(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil {
+		oldKey, err := statusIndexKey(ctx, previous)
+		if err != nil {
+			return err
+		}
+		if oldKey != newKey {
+			if err := ctx.GetStub().DelPrivateData(coll, oldKey); err != nil {
+				return fmt.Errorf("failed to remove index entry for %s: %v", payment.ID, err)
+			}
+		}
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment %s: %v", payment.ID, err)
+	}
+	if err := ctx.GetStub().PutPrivateData(coll, payment.ID, paymentBytes); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutPrivateData(coll, newKey, indexEntryValue); err != nil {
+		return fmt.Errorf("failed to write index entry for %s: %v", payment.ID, err)
+	}
+
+	if tracker, ok := ctx.(paymentWriteTracker); ok {
+		tracker.recordPaymentWrite(coll, payment)
+	}
+	return nil
+}
+
+// previousPaymentWrite returns the payment as this transaction last wrote it, or the
+// committed record when it has not written the payment yet
+func previousPaymentWrite(ctx contractapi.TransactionContextInterface, coll, id string) (*PaymentDetails, error) {
+	if tracker, ok := ctx.(paymentWriteTracker); ok {
+		if written := tracker.lastPaymentWrite(coll, id); written != nil {
+			return written, nil
+		}
+	}
+
+	previousBytes, err := ctx.GetStub().GetPrivateData(coll, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment %s: %v", id, err)
+	}
+	if previousBytes == nil {
+		return nil, nil
+	}
+
+	var previous PaymentDetails
+	if err := json.Unmarshal(previousBytes, &previous); err != nil {
+		return nil, nil
+	}
+	return &previous, nil
+}
+
+// lookupStatusIndex finds payments through the status index and loads each record.
+// It returns ok=false when the collection has not been reindexed yet.
+func (s *SmartContract) lookupStatusIndex(ctx contractapi.TransactionContextInterface, coll string, filter paymentFilter) ([]*PaymentDetails, bool, error) {
+	ready, err := statusIndexReady(ctx, coll)
+	if err != nil || !ready || len(filter.Statuses) == 0 {
+		return nil, false, err
+	}
+
+	var payments []*PaymentDetails
+	seen := make(map[string]bool)
+
+	for _, status := range filter.Statuses {
+		attrs := []string{status}
+		if filter.BatchWindow != 0 {
+			attrs = append(attrs, strconv.FormatInt(filter.BatchWindow, 10))
+		}
+
+		iter, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(coll, paymentStatusIndex, attrs)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read status index of %s: %v", coll, err)
+		}
+
+		for iter.HasNext() {
+			qr, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, false, fmt.Errorf("iterator error on %s: %v", coll, err)
+			}
+
+			_, parts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+			if err != nil || len(parts) != 3 || seen[parts[2]] {
+				continue
+			}
+
+			paymentBytes, err := ctx.GetStub().GetPrivateData(coll, parts[2])
+			if err != nil {
+				iter.Close()
+				return nil, false, fmt.Errorf("failed to read payment %s: %v", parts[2], err)
+			}
+			if paymentBytes == nil {
+				continue
+			}
+
+			var pd PaymentDetails
+			if err := json.Unmarshal(paymentBytes, &pd); err != nil {
+				continue
+			}
+			// Skip stale entries whose payment has since moved on
+			if filter.matches(&pd) {
+				seen[pd.ID] = true
+				payments = append(payments, &pd)
+			}
+		}
+		iter.Close()
+	}
+
+	return payments, true, nil
+}
+
+// statusIndexReady reports whether ReindexCollection has built the collection's index
+func statusIndexReady(ctx contractapi.TransactionContextInterface, coll string) (bool, error) {
+	stateKey, err := ctx.GetStub().CreateCompositeKey(statusIndexStateType, []string{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create status index state key: %v", err)
+	}
+	stateBytes, err := ctx.GetStub().GetPrivateData(coll, stateKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read status index state of %s: %v", coll, err)
+	}
+	return stateBytes != nil, nil
+}
+
+// statusIndexKey builds the index entry key for a payment's current status and window
+func statusIndexKey(ctx contractapi.TransactionContextInterface, pd *PaymentDetails) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(paymentStatusIndex,
+		[]string{pd.Status, strconv.FormatInt(pd.BatchWindow, 10), pd.ID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create status index key for %s: %v", pd.ID, err)
+	}
+	return key, nil
+}
+
+// isBilateralCollection reports whether coll is the PDC of two known banks
+func isBilateralCollection(coll string) bool {
+	bankMSPs := getBankMSPs()
+	for i, a := range bankMSPs {
+		for j := i + 1; j < len(bankMSPs); j++ {
+			if getCollectionName(a, bankMSPs[j]) == coll {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -21,10 +21,20 @@ type PaymentDetails struct {
 	BVN            string   `json:"bvn"`
 	PayerMSP       string   `json:"payerMSP"`
 	PayeeMSP       string   `json:"payeeMSP"`
-	Status         string   `json:"status"` // PENDING, ACKNOWLEDGED, BATCHED, QUEUED, DEBITED, SETTLED...
+	Status         string   `json:"status"` // PENDING, ACKNOWLEDGED, BATCHED, QUEUED, PARTIALLY_SETTLED, DEBITED, SETTLED...
 	Timestamp      int64    `json:"timestamp"`
-	BatchWindow    int64    `json:"batchWindow"` // Which 2-minute window this payment belongs to
+	BatchWindow    int64    `json:"batchWindow"`                                           // Which 2-minute window this payment belongs to
+	QueueReason    string   `json:"queueReason,omitempty" metadata:"queueReason,optional"` // Why the payment was QUEUED instead of BATCHED
+	Priority       string   `json:"priority,omitempty" metadata:"priority,optional"`       // URGENT or NORMAL (default)
+	QueuedAt       int64    `json:"queuedAt,omitempty" metadata:"queuedAt,optional"`       // When the payment entered the queue
 	User           BankUser `json:"user"`
+
+	// Lifecycle times in Unix seconds, taken from the transaction that reached each stage
+	CreatedAt         int64  `json:"createdAt,omitempty" metadata:"createdAt,optional"`
+	AcknowledgedAt    int64  `json:"acknowledgedAt,omitempty" metadata:"acknowledgedAt,optional"`
+	BatchedAt         int64  `json:"batchedAt,omitempty" metadata:"batchedAt,optional"`
+	SettledAt         int64  `json:"settledAt,omitempty" metadata:"settledAt,optional"`
+	SettlementCycleID string `json:"settlementCycleId,omitempty" metadata:"settlementCycleId,optional"` // ID of the settling transaction
 }
 
 // PaymentEventDetails for events
@@ -52,13 +62,16 @@ type BankAccount struct {
 	Balance float64 `json:"balance"` // eNaira, decimals for kobo
 }
 
-// MintRecord logs eNaira issuance by CentralBankMSP
+// MintRecord logs eNaira issuance (MINT) or withdrawal (BURN) by CentralBankMSP
 type MintRecord struct {
-	ID        string  `json:"id"`
-	Amount    float64 `json:"amount"`
-	Currency  string  `json:"currency"`
-	ToMSP     string  `json:"toMsp"`
-	Timestamp int64   `json:"timestamp"`
+	ID          string  `json:"id"` // ID of the issuing transaction
+	Type        string  `json:"type"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	ToMSP       string  `json:"toMsp,omitempty" metadata:"toMsp,optional"`     // bank credited by a MINT
+	FromMSP     string  `json:"fromMsp,omitempty" metadata:"fromMsp,optional"` // bank debited by a BURN
+	TotalSupply float64 `json:"totalSupply"`                                   // total supply after this record
+	Timestamp   int64   `json:"timestamp"`
 }
 
 // BVNRecord holds basic identity information for the BVN PDC
@@ -95,6 +108,9 @@ type TransactionAnalytics struct {
 	Queued    TransactionStats `json:"queued"`
 	Pending   TransactionStats `json:"pending"`
 	Batched   TransactionStats `json:"batched"` // New status for batch processing
+
+	// AverageTimeToSettleSeconds covers settled payments, creation to settlement
+	AverageTimeToSettleSeconds float64 `json:"averageTimeToSettleSeconds"`
 }
 
 // TransactionStats holds count and volume for each status
@@ -103,12 +119,29 @@ type TransactionStats struct {
 	Volume float64 `json:"volume"`
 }
 
-// CounterpartyStats holds the summary for one counterparty MSP.
+// CounterpartyStats holds the summary for one counterparty MSP. Exposure figures use
+// the unsettled AmountToSettle and are seen from the caller: positive nets are owed to it.
 type CounterpartyStats struct {
 	BankMSP           string  `json:"bankMSP"`
 	TransactionCount  int     `json:"transactionCount"`
 	TransactionVolume float64 `json:"transactionVolume"`
-	NetPosition       float64 `json:"netPosition"`
+	NetPosition       float64 `json:"netPosition"` // NetPending + NetBatched + NetQueued
+
+	GrossIncoming float64 `json:"grossIncoming"` // unsettled, owed by the counterparty
+	GrossOutgoing float64 `json:"grossOutgoing"` // unsettled, owed to the counterparty
+	NetPending    float64 `json:"netPending"`    // PENDING and ACKNOWLEDGED
+	NetBatched    float64 `json:"netBatched"`
+	NetQueued     float64 `json:"netQueued"` // QUEUED and PARTIALLY_SETTLED remainders
+
+	SettledVolume   float64         `json:"settledVolume"`
+	SettledByPeriod []SettledPeriod `json:"settledByPeriod"` // one entry per UTC day, oldest first
+}
+
+// SettledPeriod is the volume settled with a counterparty during one UTC day
+type SettledPeriod struct {
+	PeriodStart int64   `json:"periodStart"` // Unix seconds
+	Count       int     `json:"count"`
+	Volume      float64 `json:"volume"`
 }
 
 // QueuedTransactionSummary holds the summary for each MSP pair
@@ -135,17 +168,21 @@ type TransactionHistoryEntry struct {
 	PayerAcct   string  `json:"payerAcct"`
 	PayerMSP    string  `json:"payerMSP"`
 	PaymentId   string  `json:"paymentId"`
-	SettledAt   string  `json:"settledAt"` // nil unless SETTLED
+	SettledAt   string  `json:"settledAt"` // empty unless SETTLED
 	Status      string  `json:"status"`
 	Timestamp   string  `json:"timestamp"`   // RFC3339
 	BatchWindow int64   `json:"batchWindow"` // Which batch window
+
+	SettlementCycleID   string `json:"settlementCycleId,omitempty" metadata:"settlementCycleId,optional"`     // tx that settled it
+	TimeToSettleSeconds int64  `json:"timeToSettleSeconds,omitempty" metadata:"timeToSettleSeconds,optional"` // creation to settlement
 }
 
 // OffsetUpdate describes how a single PaymentDetails record should change.
 type OffsetUpdate struct {
 	ID             string  `json:"id"`
-	AmountToSettle float64 `json:"amountToSettle"`
-	Status         string  `json:"status"`
+	AmountToSettle float64 `json:"amountToSettle"` // remaining after this offset
+	SettledPortion float64 `json:"settledPortion"` // amount covered by this offset
+	Status         string  `json:"status"`         // SETTLED or PARTIALLY_SETTLED
 }
 
 // OffsetCalculation - full payload to client
@@ -154,6 +191,25 @@ type OffsetCalculation struct {
 	Updates []OffsetUpdate `json:"updates"`
 }
 
+// BilateralSettlementResult is the single record written by a complete bilateral settlement
+type BilateralSettlementResult struct {
+	ID                string         `json:"id"` // transaction ID
+	MSPA              string         `json:"mspA"`
+	MSPB              string         `json:"mspB"`
+	TotalAB           float64        `json:"totalAB"` // queued A -> B before settlement
+	TotalBA           float64        `json:"totalBA"` // queued B -> A before settlement
+	Offset            float64        `json:"offset"`
+	DebtorMSP         string         `json:"debtorMSP"`
+	CreditorMSP       string         `json:"creditorMSP"`
+	Residual          float64        `json:"residual"`          // owed by the debtor after offsetting
+	ResidualSettled   float64        `json:"residualSettled"`   // moved between settlement accounts
+	ResidualUnsettled float64        `json:"residualUnsettled"` // left queued for lack of funds
+	SettledPayments   int            `json:"settledPayments"`
+	PartialPayments   int            `json:"partialPayments"`
+	Updates           []OffsetUpdate `json:"updates"`
+	Timestamp         int64          `json:"timestamp"`
+}
+
 // MultiOffsetUpdate describes how a single queued payment should change.
 type MultiOffsetUpdate struct {
 	ID             string  `json:"id"`
@@ -169,6 +225,51 @@ type MultiOffsetCalculation struct {
 	NetPositions map[string]float64 `json:"netPositions"`
 	// Exactly which rows in which PDCs to update
 	Updates []MultiOffsetUpdate `json:"updates"`
+	// Why each queued payment was or was not chosen by the gridlock resolver
+	Decisions []GridlockDecision `json:"decisions,omitempty" metadata:"decisions,optional"`
+	// Number of removal passes the resolver needed to reach a feasible set
+	Iterations int `json:"iterations"`
+	// Gross obligations versus net positions for the selected payments
+	Efficiency *NettingEfficiency `json:"efficiency"`
+	// Bank-to-bank transfers that realise the net positions
+	TransferPlan []FundsTransfer `json:"transferPlan"`
+}
+
+// NettingEfficiency measures the liquidity saved by settling net instead of gross
+type NettingEfficiency struct {
+	GrossOutgoing  map[string]float64 `json:"grossOutgoing"` // per bank, as payer
+	GrossIncoming  map[string]float64 `json:"grossIncoming"` // per bank, as payee
+	TotalGross     float64            `json:"totalGross"`    // liquidity needed to settle every payment gross
+	TotalNet       float64            `json:"totalNet"`      // liquidity needed to settle the net positions
+	LiquiditySaved float64            `json:"liquiditySaved"`
+	NettingRatio   float64            `json:"nettingRatio"` // TotalNet / TotalGross
+	SavingsRatio   float64            `json:"savingsRatio"` // LiquiditySaved / TotalGross
+}
+
+// FundsTransfer is one bank-to-bank movement in a netting transfer plan
+type FundsTransfer struct {
+	FromMSP string  `json:"fromMSP"`
+	ToMSP   string  `json:"toMSP"`
+	Amount  float64 `json:"amount"`
+}
+
+// NetPositionPreview shows what settling the current BATCHED payments would do, without applying it
+type NetPositionPreview struct {
+	NetPositions  map[string]float64 `json:"netPositions"`
+	Efficiency    *NettingEfficiency `json:"efficiency"`
+	TransferPlan  []FundsTransfer    `json:"transferPlan"`
+	TotalPayments int                `json:"totalPayments"`
+	Timestamp     int64              `json:"timestamp"`
+}
+
+// GridlockDecision records whether a queued payment was chosen for simultaneous settlement
+type GridlockDecision struct {
+	ID             string  `json:"id"`
+	PayerMSP       string  `json:"payerMSP"`
+	PayeeMSP       string  `json:"payeeMSP"`
+	AmountToSettle float64 `json:"amountToSettle"`
+	Selected       bool    `json:"selected"`
+	Reason         string  `json:"reason"`
 }
 
 // BatchSettlementRequest represents a batch settlement operation
@@ -250,6 +351,8 @@ type NettingCalculationResult struct {
 	PaymentUpdates []PaymentUpdate    `json:"paymentUpdates"`
 	TotalPayments  int                `json:"totalPayments"`
 	TotalNetAmount float64            `json:"totalNetAmount"`
+	Efficiency     *NettingEfficiency `json:"efficiency"`
+	TransferPlan   []FundsTransfer    `json:"transferPlan"`
 	Timestamp      int64              `json:"timestamp"`
 }
 
@@ -264,10 +367,323 @@ type PaymentUpdate struct {
 
 // NettingApplicationResult represents the result of applying netting offsets
 type NettingApplicationResult struct {
-	SettledBanks    map[string]float64     `json:"settledBanks"`
-	FailedBanks     []FailedBankSettlement `json:"failedBanks"`
-	SettledPayments int                    `json:"settledPayments"`
-	FailedPayments  int                    `json:"failedPayments"`
-	TotalNetAmount  float64                `json:"totalNetAmount"`
-	Timestamp       int64                  `json:"timestamp"`
+	SettledBanks map[string]float64     `json:"settledBanks"`
+	FailedBanks  []FailedBankSettlement `json:"failedBanks"`
+	// GuaranteeCovered is what the guarantee fund paid towards each debtor's net position
+	GuaranteeCovered map[string]float64 `json:"guaranteeCovered,omitempty" metadata:"guaranteeCovered,optional"`
+	// Unwind reports the debtors excluded from the cycle, when any could not pay
+	Unwind *NettingUnwind `json:"unwind,omitempty" metadata:"unwind,optional"`
+	// HeldPayments stay BATCHED because a participant state change since the calculation
+	// bars their banks from netting
+	HeldPayments    []string `json:"heldPayments,omitempty" metadata:"heldPayments,optional"`
+	SettledPayments int      `json:"settledPayments"`
+	FailedPayments  int      `json:"failedPayments"`
+	TotalNetAmount  float64  `json:"totalNetAmount"`
+	Timestamp       int64    `json:"timestamp"`
+}
+
+// NettingUnwind reports a cycle re-run without the debtors that could not cover their net
+// debit. Their payments, sent and received, returned to the queue.
+type NettingUnwind struct {
+	OriginalNetPositions map[string]float64 `json:"originalNetPositions"`
+	ExcludedPayments     []string           `json:"excludedPayments"`
+	Reruns               int                `json:"reruns"` // one per defaulted bank
+}
+
+// ExposureLimit caps how much a payer bank may owe within a settlement cycle.
+// A bilateral limit applies to one counterparty; a multilateral limit
+// (empty CounterpartyMSP) applies to the payer's net debit against all banks.
+type ExposureLimit struct {
+	PayerMSP        string  `json:"payerMSP"`
+	CounterpartyMSP string  `json:"counterpartyMSP,omitempty" metadata:"counterpartyMSP,optional"`
+	Type            string  `json:"type"` // BILATERAL, MULTILATERAL
+	Limit           float64 `json:"limit"`
+	SetBy           string  `json:"setBy"`
+	UpdatedAt       int64   `json:"updatedAt"`
+}
+
+// ExposureUtilisation reports a limit together with the current net debit counted against it
+type ExposureUtilisation struct {
+	PayerMSP        string  `json:"payerMSP"`
+	CounterpartyMSP string  `json:"counterpartyMSP,omitempty" metadata:"counterpartyMSP,optional"`
+	Type            string  `json:"type"`
+	Limit           float64 `json:"limit"`
+	Utilisation     float64 `json:"utilisation"`
+	Headroom        float64 `json:"headroom"`
+	UtilisationPct  float64 `json:"utilisationPct"`
+}
+
+// QueueConfig holds the on-ledger settings for the payment queue
+type QueueConfig struct {
+	TTLSeconds int64  `json:"ttlSeconds"` // QUEUED payments older than this are returned unsettled
+	UpdatedBy  string `json:"updatedBy"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// QueueEntry is one payment in a payer's queue, in release order
+type QueueEntry struct {
+	Position       int     `json:"position"`
+	ID             string  `json:"id"`
+	PayerMSP       string  `json:"payerMSP"`
+	PayeeMSP       string  `json:"payeeMSP"`
+	AmountToSettle float64 `json:"amountToSettle"`
+	Priority       string  `json:"priority"`
+	QueueReason    string  `json:"queueReason,omitempty" metadata:"queueReason,optional"`
+	QueuedAt       int64   `json:"queuedAt"`
+	ExpiresAt      int64   `json:"expiresAt"`
+}
+
+// QueueExpiryResult summarises a sweep of expired queued payments
+type QueueExpiryResult struct {
+	TTLSeconds      int64    `json:"ttlSeconds"`
+	ExpiredCount    int      `json:"expiredCount"`
+	ExpiredAmount   float64  `json:"expiredAmount"`
+	ExpiredPayments []string `json:"expiredPayments"`
+	Timestamp       int64    `json:"timestamp"`
+}
+
+// ReindexResult summarises a rebuild of a bilateral collection's status index. The
+// latest result is kept in the collection and marks its index as ready for lookups.
+type ReindexResult struct {
+	Collection      string `json:"collection"`
+	IndexedPayments int    `json:"indexedPayments"`
+	RemovedEntries  int    `json:"removedEntries"`
+	RebuiltBy       string `json:"rebuiltBy"`
+	Timestamp       int64  `json:"timestamp"`
+}
+
+// PaymentPageQuery selects one page of payments. Only pageSize is required; zero-valued filters match everything.
+// Pages follow key order collection by collection: payment ID order, or batch window then ID
+// when a status is given and the collection's status index has been built.
+type PaymentPageQuery struct {
+	PageSize      int     `json:"pageSize"`                              // 0 selects the default of 50; at most 500
+	Bookmark      string  `json:"bookmark" metadata:"bookmark,optional"` // from the previous page; empty for the first page
+	Status        string  `json:"status" metadata:"status,optional"`
+	Counterparty  string  `json:"counterparty" metadata:"counterparty,optional"`   // bank that must be payer or payee
+	FromTimestamp int64   `json:"fromTimestamp" metadata:"fromTimestamp,optional"` // payment timestamp (Unix millis), inclusive
+	ToTimestamp   int64   `json:"toTimestamp" metadata:"toTimestamp,optional"`     // payment timestamp (Unix millis), inclusive
+	MinAmount     float64 `json:"minAmount" metadata:"minAmount,optional"`
+	MaxAmount     float64 `json:"maxAmount" metadata:"maxAmount,optional"`
+}
+
+// PageMetadata mirrors Fabric's query response metadata; an empty bookmark means no more pages
+type PageMetadata struct {
+	FetchedRecordsCount int    `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// PaymentPage is one page of payment records
+type PaymentPage struct {
+	Records  []*PaymentDetails `json:"records"`
+	Metadata PageMetadata      `json:"metadata"`
+}
+
+// TransactionHistoryPage is one page of transaction history entries
+type TransactionHistoryPage struct {
+	Records  []TransactionHistoryEntry `json:"records"`
+	Metadata PageMetadata              `json:"metadata"`
+}
+
+// LatencyPercentiles summarises how long payments took to cross one lifecycle stage, in seconds
+type LatencyPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// StagePerformance holds the stage latencies of all payments, one counterparty or one batch window
+type StagePerformance struct {
+	Counterparty    string             `json:"counterparty,omitempty" metadata:"counterparty,optional"`
+	BatchWindow     int64              `json:"batchWindow,omitempty" metadata:"batchWindow,optional"`
+	Acknowledgement LatencyPercentiles `json:"acknowledgement"` // PENDING to ACKNOWLEDGED
+	Settlement      LatencyPercentiles `json:"settlement"`      // ACKNOWLEDGED to SETTLED
+}
+
+// QueueAgeBucket counts the payments that have waited in the queue up to MaxAgeSeconds
+type QueueAgeBucket struct {
+	Label         string  `json:"label"`
+	MaxAgeSeconds int64   `json:"maxAgeSeconds"` // 0 for the open-ended last bucket
+	Count         int     `json:"count"`
+	Amount        float64 `json:"amount"`
+}
+
+// HourlyThroughput reports the payments settled within one clock hour
+type HourlyThroughput struct {
+	HourStart     int64   `json:"hourStart"` // Unix seconds, UTC
+	SettledCount  int     `json:"settledCount"`
+	SettledVolume float64 `json:"settledVolume"`
+}
+
+// SettlementPerformance holds SLA metrics for payments created between From and To
+type SettlementPerformance struct {
+	From           int64              `json:"from"`
+	To             int64              `json:"to"`
+	Overall        StagePerformance   `json:"overall"`
+	ByCounterparty []StagePerformance `json:"byCounterparty"`
+	ByWindow       []StagePerformance `json:"byWindow"`
+	QueueAge       []QueueAgeBucket   `json:"queueAge"` // current queue, regardless of range
+	OldestQueued   int64              `json:"oldestQueuedSeconds"`
+	Throughput     []HourlyThroughput `json:"throughput"`
+	GeneratedAt    int64              `json:"generatedAt"`
+}
+
+// SettlementCycleRecord is the outcome of the latest ApplyNettingOffsets, kept in public state
+type SettlementCycleRecord struct {
+	CycleID      string                   `json:"cycleId"` // ID of the applying transaction
+	BatchWindow  int64                    `json:"batchWindow"`
+	NetPositions map[string]float64       `json:"netPositions"`
+	Result       NettingApplicationResult `json:"result"`
+}
+
+// BankOverview is one bank's row in the system overview. Payment totals count the
+// bank's outgoing obligations (AmountToSettle) as payer.
+type BankOverview struct {
+	BankMSP             string           `json:"bankMSP"`
+	Balance             float64          `json:"balance"`
+	MultilateralLimit   float64          `json:"multilateralLimit"` // 0 when no limit is set
+	BatchedNetDebit     float64          `json:"batchedNetDebit"`   // counted against the limit
+	Pending             TransactionStats `json:"pending"`           // PENDING and ACKNOWLEDGED
+	Batched             TransactionStats `json:"batched"`
+	Queued              TransactionStats `json:"queued"` // QUEUED and PARTIALLY_SETTLED
+	OldestQueuedSeconds int64            `json:"oldestQueuedSeconds"`
+}
+
+// SystemAlert flags a condition CBN operators should act on
+type SystemAlert struct {
+	Type     string `json:"type"`     // NEGATIVE_BALANCE, STALE_QUEUE, LIMIT_EXCEEDED, SETTLEMENT_FAILURE
+	Severity string `json:"severity"` // WARNING or CRITICAL
+	BankMSP  string `json:"bankMSP"`
+	Message  string `json:"message"`
+}
+
+// SystemOverview is the CBN dashboard view of every bank and the settlement cycle
+type SystemOverview struct {
+	Banks       []BankOverview         `json:"banks"`
+	Cycle       SettlementCycleInfo    `json:"cycle"`
+	LastCycle   *SettlementCycleRecord `json:"lastCycle,omitempty" metadata:"lastCycle,optional"` // absent before the first settlement
+	Alerts      []SystemAlert          `json:"alerts"`
+	GeneratedAt int64                  `json:"generatedAt"`
+}
+
+// SettlementConfig selects how payments on the channel settle
+type SettlementConfig struct {
+	Mode      string `json:"mode"`                                              // GROSS or DEFERRED_NET (default)
+	Version   string `json:"version"`                                           // version of the running contract
+	UpdatedBy string `json:"updatedBy,omitempty" metadata:"updatedBy,optional"` // empty until CBN first sets a mode
+	UpdatedAt int64  `json:"updatedAt,omitempty" metadata:"updatedAt,optional"`
+}
+
+// DebitRecoveryResult summarises a sweep of payments left DEBITED by the split debit/credit
+type DebitRecoveryResult struct {
+	Action            string   `json:"action"`
+	OlderThanSeconds  int64    `json:"olderThanSeconds"`
+	RecoveredCount    int      `json:"recoveredCount"`
+	RecoveredAmount   float64  `json:"recoveredAmount"`
+	RecoveredPayments []string `json:"recoveredPayments"`
+	Timestamp         int64    `json:"timestamp"`
+}
+
+// SupplyRecord is the on-ledger figure for eNaira in circulation
+type SupplyRecord struct {
+	TotalSupply float64 `json:"totalSupply"`
+	TotalMinted float64 `json:"totalMinted"`
+	TotalBurned float64 `json:"totalBurned"`
+	Currency    string  `json:"currency"`
+	UpdatedAt   int64   `json:"updatedAt,omitempty" metadata:"updatedAt,optional"` // zero until the first mint
+}
+
+// SupplyInvariantResult compares the total supply and drawn intraday credit with the sum
+// of all bank balances
+type SupplyInvariantResult struct {
+	TotalSupply   float64 `json:"totalSupply"`
+	TotalBalances float64 `json:"totalBalances"`
+	// IntradayCredit is what the banks have drawn on their intraday facilities and not repaid
+	IntradayCredit float64 `json:"intradayCredit"`
+	// GuaranteeFund is what the banks have paid into the settlement guarantee fund
+	GuaranteeFund float64            `json:"guaranteeFund"`
+	Difference    float64            `json:"difference"` // TotalBalances + GuaranteeFund - TotalSupply - IntradayCredit
+	Holds         bool               `json:"holds"`
+	Balances      map[string]float64 `json:"balances"`
+	Timestamp     int64              `json:"timestamp"`
+}
+
+// Collateral is an asset a bank pledges to the Central Bank against intraday credit
+type Collateral struct {
+	ID         string  `json:"id"`
+	MSP        string  `json:"msp"`
+	AssetClass string  `json:"assetClass"` // TREASURY_BILL, CBN_BILL, FGN_BOND, STATE_BOND or CORPORATE_BOND
+	Reference  string  `json:"reference"`  // identifies the pledged asset, e.g. its ISIN
+	FaceValue  float64 `json:"faceValue"`
+	Status     string  `json:"status"` // PLEDGED or RELEASED
+	PledgedAt  int64   `json:"pledgedAt"`
+	ReleasedAt int64   `json:"releasedAt,omitempty" metadata:"releasedAt,optional"`
+}
+
+// IntradayFacility is a bank's collateralised intraday credit line with the Central Bank
+type IntradayFacility struct {
+	MSP             string  `json:"msp"`
+	CreditLimit     float64 `json:"creditLimit"`     // granted by CBN, at most CollateralValue
+	Drawn           float64 `json:"drawn"`           // credit outstanding
+	CollateralValue float64 `json:"collateralValue"` // pledged collateral after haircuts
+	Available       float64 `json:"available"`       // what can still be drawn
+	Overdue         bool    `json:"overdue"`         // credit was left unrepaid at the last end of day
+	UpdatedAt       int64   `json:"updatedAt,omitempty" metadata:"updatedAt,optional"`
+}
+
+// IntradayCloseResult summarises the end-of-day repayment of intraday credit
+type IntradayCloseResult struct {
+	Repaid      map[string]float64 `json:"repaid"`
+	Outstanding map[string]float64 `json:"outstanding"` // credit left unrepaid, per bank
+	Timestamp   int64              `json:"timestamp"`
+}
+
+// GuaranteeFund is the prefunded pool the banks share to cover a debtor that cannot settle
+// its net position. Contributions follow each bank's peak net debit.
+type GuaranteeFund struct {
+	CoverRatio    float64            `json:"coverRatio"` // target as a multiple of the largest peak net debit
+	Balance       float64            `json:"balance"`    // sum of Contributions
+	Contributions map[string]float64 `json:"contributions"`
+	PeakNetDebits map[string]float64 `json:"peakNetDebits"`
+	Liabilities   map[string]float64 `json:"liabilities"` // shortfalls the fund covered, owed by the debtor
+	TotalUsed     float64            `json:"totalUsed"`
+	UpdatedAt     int64              `json:"updatedAt,omitempty" metadata:"updatedAt,optional"`
+}
+
+// GuaranteeShare is a bank's own part of the guarantee fund, kept in its settlement collection
+type GuaranteeShare struct {
+	MSP          string  `json:"msp"`
+	Contribution float64 `json:"contribution"`
+	PeakNetDebit float64 `json:"peakNetDebit"`
+	Liability    float64 `json:"liability"` // shortfalls of this bank the fund covered
+	UpdatedAt    int64   `json:"updatedAt,omitempty" metadata:"updatedAt,optional"`
+}
+
+// GuaranteeUsage records the fund covering a debtor's shortfall in a netting cycle
+type GuaranteeUsage struct {
+	CycleID   string             `json:"cycleId"`
+	DebtorMSP string             `json:"debtorMSP"`
+	NetDebit  float64            `json:"netDebit"`
+	Covered   float64            `json:"covered"`
+	Uncovered float64            `json:"uncovered"` // beyond the fund, overdrawn
+	Charges   map[string]float64 `json:"charges"`   // what each bank's contribution lost
+	Timestamp int64              `json:"timestamp"`
+}
+
+// GuaranteeCollectionResult summarises a round of guarantee fund contributions
+type GuaranteeCollectionResult struct {
+	Required    map[string]float64 `json:"required"`
+	Collected   map[string]float64 `json:"collected"`  // negative where excess was refunded
+	Shortfalls  map[string]float64 `json:"shortfalls"` // required but not collected for lack of balance
+	FundBalance float64            `json:"fundBalance"`
+	Timestamp   int64              `json:"timestamp"`
+}
+
+// ParticipantStatus is a bank's participant state, as last set by the Central Bank
+type ParticipantStatus struct {
+	MSP       string `json:"msp"`
+	State     string `json:"state"` // ACTIVE, SUSPENDED_SENDING, SUSPENDED or DEFAULTED
+	Reason    string `json:"reason,omitempty" metadata:"reason,optional"`
+	UpdatedBy string `json:"updatedBy,omitempty" metadata:"updatedBy,optional"`
+	UpdatedAt int64  `json:"updatedAt,omitempty" metadata:"updatedAt,optional"`
 }
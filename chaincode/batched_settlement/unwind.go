@@ -0,0 +1,187 @@
+// unwind.go - Settlement failure handling: a debtor that cannot cover its net debit is
+// excluded from the cycle and the net positions of the remaining banks are recomputed
+package settlement
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// loadNettingPayments loads the payments of a calculation from the ledger and checks that
+// each is listed once and still BATCHED, and that what they have left to settle gives its net
+// positions, or the calculation is stale and rejected. Payments netting may no longer include
+// under the banks' participant states are returned as held; they stay BATCHED until the banks
+// are reinstated.
+func (s *SmartContract) loadNettingPayments(ctx contractapi.TransactionContextInterface, calculation *NettingCalculationResult) ([]*PaymentDetails, map[string]bool, error) {
+	listed := make(map[string]bool, len(calculation.PaymentUpdates))
+	all := make([]*PaymentDetails, 0, len(calculation.PaymentUpdates))
+	for _, update := range calculation.PaymentUpdates {
+		if listed[update.ID] {
+			return nil, nil, fmt.Errorf("payment %s is listed more than once", update.ID)
+		}
+		listed[update.ID] = true
+
+		payment, err := s.getPaymentDetails(ctx, update.PayerMSP, update.PayeeMSP, update.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if payment.Status != "BATCHED" {
+			return nil, nil, fmt.Errorf("payment %s is not batched, current status: %s", update.ID, payment.Status)
+		}
+		all = append(all, payment)
+	}
+	if err := requireMatchingNetPositions(calculation.NetPositions, netPositionsOf(calculation.NetPositions, all, nil)); err != nil {
+		return nil, nil, err
+	}
+
+	eligible, err := s.nettingEligibility(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	payments := make([]*PaymentDetails, 0, len(all))
+	held := make(map[string]bool)
+	for _, payment := range all {
+		if !eligible(payment) {
+			held[payment.ID] = true
+			continue
+		}
+		payments = append(payments, payment)
+	}
+	return payments, held, nil
+}
+
+// unwindDefaultingDebtors excludes, one at a time, the first debtor in settlement order that
+// cannot cover its net debit, along with every payment it sends or receives, and recomputes
+// the net positions of the banks left from the amounts the remaining payments have to settle
+// on the ledger until every debtor can pay. Returns the net positions to settle, the banks
+// that failed and, if any did, the unwind to report. Nothing is written.
+func (s *SmartContract) unwindDefaultingDebtors(ctx contractapi.TransactionContextInterface, calculation *NettingCalculationResult, payments []*PaymentDetails, fund *GuaranteeFund) (map[string]float64, []FailedBankSettlement, *NettingUnwind, error) {
+	excluded := make(map[string]bool)
+	positions := netPositionsOf(calculation.NetPositions, payments, excluded)
+	failed := make([]FailedBankSettlement, 0)
+	var unwind *NettingUnwind
+
+	for {
+		debtor, shortfall, err := s.findDefaultingDebtor(ctx, positions, fund)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if debtor == "" {
+			return positions, failed, unwind, nil
+		}
+
+		if unwind == nil {
+			unwind = &NettingUnwind{
+				OriginalNetPositions: calculation.NetPositions,
+				ExcludedPayments:     make([]string, 0),
+			}
+		}
+		failed = append(failed, FailedBankSettlement{
+			BankMSP:   debtor,
+			NetAmount: positions[debtor],
+			Error:     fmt.Sprintf("cannot cover net debit of %.2f: short by %.2f", -positions[debtor], shortfall),
+		})
+
+		for _, payment := range payments {
+			if excluded[payment.ID] || (payment.PayerMSP != debtor && payment.PayeeMSP != debtor) {
+				continue
+			}
+			excluded[payment.ID] = true
+			unwind.ExcludedPayments = append(unwind.ExcludedPayments, payment.ID)
+		}
+		positions = netPositionsOf(calculation.NetPositions, payments, excluded)
+		unwind.Reruns++
+	}
+}
+
+// netPositionsOf nets what the payments not excluded have left to settle, giving every bank
+// of the calculation a position even when none of its payments remain
+func netPositionsOf(banks map[string]float64, payments []*PaymentDetails, excluded map[string]bool) map[string]float64 {
+	positions := make(map[string]float64, len(banks))
+	for msp := range banks {
+		positions[msp] = 0
+	}
+	for _, payment := range payments {
+		if excluded[payment.ID] {
+			continue
+		}
+		positions[payment.PayeeMSP] += payment.AmountToSettle
+		positions[payment.PayerMSP] -= payment.AmountToSettle
+	}
+	for msp, position := range positions {
+		positions[msp] = roundToKobo(position)
+	}
+	return positions
+}
+
+// requireMatchingNetPositions rejects calculated net positions that differ from what the
+// payments give on the ledger, such as a calculation made before one of them changed
+func requireMatchingNetPositions(calculated, ledger map[string]float64) error {
+	bankMSPs := make([]string, 0, len(ledger))
+	for msp := range ledger {
+		bankMSPs = append(bankMSPs, msp)
+	}
+	sort.Strings(bankMSPs)
+
+	for _, msp := range bankMSPs {
+		if roundToKobo(calculated[msp]) != ledger[msp] {
+			return fmt.Errorf("net position of %s does not match its payments: calculated %.2f, payments give %.2f",
+				msp, calculated[msp], ledger[msp])
+		}
+	}
+	return nil
+}
+
+// findDefaultingDebtor walks the debtors in settlement order and returns the first whose net
+// debit exceeds its balance, the intraday credit it can still draw and what is left of the
+// guarantee fund, with the amount it is short; "" when every debtor can pay
+func (s *SmartContract) findDefaultingDebtor(ctx contractapi.TransactionContextInterface, positions map[string]float64, fund *GuaranteeFund) (string, float64, error) {
+	bankMSPs := make([]string, 0, len(positions))
+	for msp := range positions {
+		bankMSPs = append(bankMSPs, msp)
+	}
+	sort.Strings(bankMSPs)
+
+	fundLeft := fund.Balance
+	for _, msp := range bankMSPs {
+		debit := -positions[msp]
+		if debit <= 0 {
+			continue
+		}
+
+		account, err := s.GetSettlementAccount(ctx, msp)
+		if err != nil {
+			return "", 0, err
+		}
+		balance := math.Max(account.Balance, 0)
+		facility, err := s.getIntradayFacility(ctx, msp)
+		if err != nil {
+			return "", 0, err
+		}
+
+		shortfall := roundToKobo(debit - balance - facility.Available)
+		if shortfall <= 0 {
+			continue
+		}
+		if shortfall > fundLeft {
+			return msp, roundToKobo(shortfall - fundLeft), nil
+		}
+		fundLeft = roundToKobo(fundLeft - shortfall)
+	}
+	return "", 0, nil
+}
+
+// requeueForDefault returns a payment excluded from the cycle to the queue, with reason
+// participant_default
+func (s *SmartContract) requeueForDefault(ctx contractapi.TransactionContextInterface, update PaymentUpdate) error {
+	payment, err := s.getPaymentDetails(ctx, update.PayerMSP, update.PayeeMSP, update.ID)
+	if err != nil {
+		return err
+	}
+	payment.Status = "QUEUED"
+	payment.QueueReason = "participant_default"
+	return s.putPaymentDetails(ctx, payment)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -72,7 +73,11 @@ func (s *SmartContract) emitPaymentEvent(ctx contractapi.TransactionContextInter
 
 // emitBatchEvent emits a batch-related event with enhanced details
 func (s *SmartContract) emitBatchEvent(ctx contractapi.TransactionContextInterface, eventName string, payload BatchProcessingEvent) error {
-	payload.Timestamp = time.Now().Unix()
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	payload.Timestamp = now
 
 	evtBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -98,6 +103,74 @@ func (s *SmartContract) emitSettlementEvent(ctx contractapi.TransactionContextIn
 	return nil
 }
 
+// isQueuedStatus reports whether a payment still waits in the queue; partially
+// offset payments keep their remainder queued
+func isQueuedStatus(status string) bool {
+	return status == "QUEUED" || status == "PARTIALLY_SETTLED"
+}
+
+// txTimestamp returns the transaction timestamp in Unix seconds; unlike time.Now it is
+// identical on every endorsing peer
+func txTimestamp(ctx contractapi.TransactionContextInterface) (int64, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return ts.GetSeconds(), nil
+}
+
+// stampLifecycle records when the payment reached its current status. Stages are
+// stamped on entry, so a payment released from the queue gets a fresh BatchedAt.
+func stampLifecycle(ctx contractapi.TransactionContextInterface, previous, payment *PaymentDetails) error {
+	if previous != nil && previous.Status == payment.Status {
+		return nil
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if previous == nil && payment.CreatedAt == 0 {
+		payment.CreatedAt = now
+	}
+	switch payment.Status {
+	case "ACKNOWLEDGED":
+		payment.AcknowledgedAt = now
+	case "BATCHED":
+		payment.BatchedAt = now
+	case "QUEUED":
+		if previous == nil || !isQueuedStatus(previous.Status) {
+			payment.QueuedAt = now
+		}
+	case "SETTLED":
+		payment.SettledAt = now
+		payment.SettlementCycleID = ctx.GetStub().GetTxID()
+	}
+	return nil
+}
+
+// timeToSettle returns how long a settled payment took from creation, in seconds.
+// Payments created before lifecycle times were recorded fall back to the client timestamp.
+func timeToSettle(pd *PaymentDetails) (int64, bool) {
+	if pd.Status != "SETTLED" || pd.SettledAt == 0 {
+		return 0, false
+	}
+	createdAt := createdTime(pd)
+	if createdAt == 0 || pd.SettledAt < createdAt {
+		return 0, false
+	}
+	return pd.SettledAt - createdAt, true
+}
+
+// queuedStatuses lists the statuses isQueuedStatus accepts, for indexed queries
+var queuedStatuses = []string{"QUEUED", "PARTIALLY_SETTLED"}
+
+// roundToKobo rounds a Naira amount to the nearest kobo
+func roundToKobo(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
 // Helper function to check if MSP is authorized
 func (s *SmartContract) isAuthorizedMSP(mspID string) bool {
 	for _, authorizedMSP := range authorizedMSPs {
@@ -119,20 +192,19 @@ func (s *SmartContract) isAuthorizedBank(mspID string) bool {
 	return false
 }
 
-// validateBatchWindow checks if a batch window is valid (not in the future)
-func validateBatchWindow(batchWindow int64) error {
-	currentWindow := getCurrentBatchWindow()
+// validateBatchWindow checks if a batch window is valid (not after the current window)
+func validateBatchWindow(batchWindow, currentWindow int64) error {
 	if batchWindow > currentWindow {
 		return fmt.Errorf("batch window %d is in the future (current: %d)", batchWindow, currentWindow)
 	}
 	return nil
 }
 
-// getBatchWindowInfo returns detailed information about a batch window
-func getBatchWindowInfo(batchWindow int64) BatchWindowInfo {
+// getBatchWindowInfo returns detailed information about a batch window, relative to
+// the current one
+func getBatchWindowInfo(batchWindow, currentWindow int64) BatchWindowInfo {
 	startTime := getBatchWindowStart(batchWindow)
 	endTime := getBatchWindowEnd(batchWindow)
-	currentWindow := getCurrentBatchWindow()
 
 	var status string
 	switch {
@@ -179,9 +251,9 @@ func getPreviousBatchWindow(currentWindow int64) int64 {
 	return currentWindow - 1
 }
 
-// generatePaymentEventId generates a unique event ID for payment events
-func generatePaymentEventId(paymentID string, eventType string) string {
-	timestamp := time.Now().Unix()
+// generatePaymentEventId generates a unique event ID for payment events at timestamp
+// (Unix seconds)
+func generatePaymentEventId(paymentID string, eventType string, timestamp int64) string {
 	data := fmt.Sprintf("%s:%s:%d", paymentID, eventType, timestamp)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:8]) // Use first 8 bytes for shorter ID
@@ -190,12 +262,14 @@ func generatePaymentEventId(paymentID string, eventType string) string {
 // validatePaymentStatus checks if a payment status transition is valid
 func validatePaymentStatus(currentStatus, newStatus string) error {
 	validTransitions := map[string][]string{
-		"PENDING":      {"ACKNOWLEDGED"},
-		"ACKNOWLEDGED": {"BATCHED", "QUEUED"},
-		"BATCHED":      {"DEBITED", "QUEUED"},
-		"DEBITED":      {"SETTLED"},
-		"QUEUED":       {"SETTLED", "BATCHED"}, // Can be re-batched or settled through netting
-		"SETTLED":      {},                     // Terminal state
+		"PENDING":            {"ACKNOWLEDGED"},
+		"ACKNOWLEDGED":       {"BATCHED", "QUEUED", "SETTLED"}, // SETTLED gross
+		"BATCHED":            {"DEBITED", "QUEUED"},
+		"DEBITED":            {"SETTLED", "RETURNED_UNSETTLED"},                                 // Left by the former split debit/credit; recovered either way
+		"QUEUED":             {"SETTLED", "PARTIALLY_SETTLED", "BATCHED", "RETURNED_UNSETTLED"}, // Can be re-batched, settled through netting or gross, or expire
+		"PARTIALLY_SETTLED":  {"SETTLED", "PARTIALLY_SETTLED", "BATCHED", "RETURNED_UNSETTLED"}, // Remainder behaves like a queued payment
+		"SETTLED":            {},                                                                // Terminal state
+		"RETURNED_UNSETTLED": {},                                                                // Terminal state (queue TTL expired or debit reversed)
 	}
 
 	allowedNext, exists := validTransitions[currentStatus]
@@ -212,29 +286,30 @@ func validatePaymentStatus(currentStatus, newStatus string) error {
 	return fmt.Errorf("invalid status transition from %s to %s", currentStatus, newStatus)
 }
 
-// createAuditTrail creates an audit trail entry for payment status changes
-func createAuditTrail(paymentID, oldStatus, newStatus, msp string) AuditTrailEntry {
+// createAuditTrail creates an audit trail entry for a payment status change at timestamp
+// (Unix seconds)
+func createAuditTrail(paymentID, oldStatus, newStatus, msp string, timestamp int64) AuditTrailEntry {
 	return AuditTrailEntry{
 		PaymentID: paymentID,
 		OldStatus: oldStatus,
 		NewStatus: newStatus,
 		ChangedBy: msp,
-		Timestamp: time.Now().Unix(),
-		EventID:   generatePaymentEventId(paymentID, "status_change"),
+		Timestamp: timestamp,
+		EventID:   generatePaymentEventId(paymentID, "status_change", timestamp),
 	}
 }
 
 // Helper function to determine if a batch window is ready for settlement
-func isBatchWindowReadyForSettlement(batchWindow int64) bool {
-	currentWindow := getCurrentBatchWindow()
+func isBatchWindowReadyForSettlement(batchWindow, currentWindow int64) bool {
 	// A window is ready for settlement if it's the previous window or older
 	return batchWindow < currentWindow
 }
 
-// Helper function to get settlement cycle information
-func getSettlementCycleInfo() SettlementCycleInfo {
-	now := time.Now()
-	currentWindow := getCurrentBatchWindow()
+// Helper function to get settlement cycle information at timestamp (Unix seconds),
+// normally the transaction timestamp
+func getSettlementCycleInfo(timestamp int64) SettlementCycleInfo {
+	now := time.Unix(timestamp, 0)
+	currentWindow := timestamp / 120
 	windowStart := getBatchWindowStart(currentWindow)
 	windowEnd := getBatchWindowEnd(currentWindow)
 
@@ -12,6 +12,7 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("Error creating chaincode: %v", err))
 	}
+	chaincode.Info.Version = cc.ContractVersion
 	if err := chaincode.Start(); err != nil {
 		panic(fmt.Sprintf("Error starting chaincode: %v", err))
 	}
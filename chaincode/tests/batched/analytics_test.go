@@ -0,0 +1,67 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAllTransactionAnalytics_AveragesRealTimeToSettle(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(zenithBankMSP, 0)
+
+	slow, err := n.createPayment(accessBankMSP, gtBankMSP, 100)
+	require.NoError(t, err)
+	slowCreated := n.ledger.Now().Unix()
+	n.ledger.Advance(10 * time.Minute)
+	require.NoError(t, n.acknowledge(slow, accessBankMSP, gtBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, slow, accessBankMSP, gtBankMSP))
+	batched := n.ledger.Now().Unix()
+
+	fast := n.pay(gtBankMSP, accessBankMSP, 40)
+	fastCreated := n.payment(fast, gtBankMSP, accessBankMSP).CreatedAt
+	queued := n.pay(zenithBankMSP, accessBankMSP, 70)
+	_, err = n.createPayment(firstBankMSP, accessBankMSP, 25)
+	require.NoError(t, err)
+
+	n.ledger.Advance(time.Minute)
+	n.settleBatch()
+	settled := n.ledger.Now().Unix()
+
+	pd := n.payment(slow, accessBankMSP, gtBankMSP)
+	require.Equal(t, slowCreated, pd.CreatedAt)
+	require.Equal(t, slowCreated+10*60+1, pd.AcknowledgedAt)
+	require.Equal(t, batched, pd.BatchedAt)
+	require.Equal(t, settled, pd.SettledAt)
+	require.NotEmpty(t, pd.SettlementCycleID)
+	require.Zero(t, n.payment(queued, zenithBankMSP, accessBankMSP).SettledAt)
+
+	var analytics *settlement.TransactionAnalytics
+	require.NoError(t, n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		analytics, err = n.contract.GetAllTransactionAnalytics(ctx)
+		return err
+	}))
+	require.Equal(t, settlement.TransactionStats{Count: 2, Volume: 140}, analytics.Completed)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 70}, analytics.Queued)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 25}, analytics.Pending)
+	require.Zero(t, analytics.Batched.Count)
+	require.Equal(t, float64(settled-slowCreated+settled-fastCreated)/2, analytics.AverageTimeToSettleSeconds)
+
+	var history []settlement.TransactionHistoryEntry
+	require.NoError(t, n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		history, err = n.contract.GetTransactionHistory(ctx)
+		return err
+	}))
+	entries := make(map[string]settlement.TransactionHistoryEntry, len(history))
+	for _, entry := range history {
+		entries[entry.PaymentId] = entry
+	}
+	require.Equal(t, time.Unix(settled, 0).UTC().Format(time.RFC3339), entries[slow].SettledAt)
+	require.Equal(t, pd.SettlementCycleID, entries[slow].SettlementCycleID)
+	require.Equal(t, settled-slowCreated, entries[slow].TimeToSettleSeconds)
+	require.Empty(t, entries[queued].SettledAt)
+	require.Zero(t, entries[queued].TimeToSettleSeconds)
+}
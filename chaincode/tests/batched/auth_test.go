@@ -0,0 +1,138 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// cbnOnlyFunctions are the contract functions only the Central Bank may invoke
+var cbnOnlyFunctions = map[string]func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error{
+	"BatchAcknowledgedPayment": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.BatchAcknowledgedPayment(ctx, settlement.PaymentEventDetails{ID: id, PayerMSP: payer, PayeeMSP: payee})
+	},
+	"BatchAcknowledgedPaymentSimple": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.BatchAcknowledgedPaymentSimple(ctx, id, payer, payee)
+	},
+	"ApplyNettingOffsets": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.ApplyNettingOffsets(ctx)
+		return err
+	},
+	"ApplyMultilateralOffset": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.ApplyMultilateralOffset(ctx)
+	},
+	"ExecuteScheduledMultilateralNetting": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.ExecuteScheduledMultilateralNetting(ctx)
+		return err
+	},
+	"ExecuteBilateralSettlement": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.ExecuteBilateralSettlement(ctx, payer, payee)
+		return err
+	},
+	"ExpireQueuedPayments": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.ExpireQueuedPayments(ctx)
+		return err
+	},
+	"SetQueueTTL": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.SetQueueTTL(ctx, 60)
+	},
+	"SetSettlementMode": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.SetSettlementMode(ctx, settlement.SettlementModeGross)
+	},
+	"SettlePayment": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.SettlePayment(ctx, settlement.PaymentEventDetails{ID: id, PayerMSP: payer, PayeeMSP: payee})
+		return err
+	},
+	"RecoverDebitedPayments": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.RecoverDebitedPayments(ctx, 0, settlement.RecoveryReverse)
+		return err
+	},
+	"InitLedger": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.InitLedger(ctx, payer, startingBalance)
+	},
+	"MintToBank": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.MintToBank(ctx, payer, 1000)
+		return err
+	},
+	"BurnFromBank": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.BurnFromBank(ctx, payee, 1000)
+		return err
+	},
+	"CheckSupplyInvariant": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.CheckSupplyInvariant(ctx)
+		return err
+	},
+	"GrantIntradayCredit": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.GrantIntradayCredit(ctx, payer, 0)
+		return err
+	},
+	"ReleaseCollateral": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.ReleaseCollateral(ctx, payer, id)
+		return err
+	},
+	"SetCollateralHaircut": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.SetCollateralHaircut(ctx, "FGN_BOND", 0)
+	},
+	"CloseIntradayFacilities": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.CloseIntradayFacilities(ctx)
+		return err
+	},
+	"SetGuaranteeCoverRatio": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.SetGuaranteeCoverRatio(ctx, 2)
+	},
+	"CollectGuaranteeContributions": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.CollectGuaranteeContributions(ctx)
+		return err
+	},
+	"GetGuaranteeFund": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.GetGuaranteeFund(ctx)
+		return err
+	},
+	"GetGuaranteeFundUsage": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.GetGuaranteeFundUsage(ctx)
+		return err
+	},
+	"SetParticipantState": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		return s.SetParticipantState(ctx, payer, settlement.ParticipantSuspended, "operational failure")
+	},
+	"GetSystemOverview": func(s *settlement.SmartContract, ctx contractapi.TransactionContextInterface, id, payer, payee string) error {
+		_, err := s.GetSystemOverview(ctx, 0)
+		return err
+	},
+}
+
+func TestCBNOnlyFunctionsRejectBanks(t *testing.T) {
+	n := newNetwork(t)
+	id, err := n.createPayment(accessBankMSP, gtBankMSP, 500)
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(id, accessBankMSP, gtBankMSP))
+
+	for name, invoke := range cbnOnlyFunctions {
+		for _, bank := range banks {
+			// Every bank tries it on a payment it is party to, and on its own accounts
+			err := n.submit(bank, func(ctx contractapi.TransactionContextInterface) error {
+				return invoke(n.contract, ctx, id, accessBankMSP, gtBankMSP)
+			})
+			require.ErrorContains(t, err, "only Central Bank can", "%s invoked by %s", name, bank)
+		}
+	}
+
+	require.Equal(t, "ACKNOWLEDGED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+	for _, bank := range banks {
+		requireAmount(t, startingBalance, n.balance(bank), bank)
+	}
+}
+
+func TestCBNOnlyFunctionsAcceptCentralBank(t *testing.T) {
+	n := newNetwork(t)
+	id, err := n.createPayment(accessBankMSP, gtBankMSP, 500)
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(id, accessBankMSP, gtBankMSP))
+
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.BatchAcknowledgedPaymentSimple(ctx, id, accessBankMSP, gtBankMSP)
+	}))
+	require.Equal(t, "BATCHED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+}
@@ -0,0 +1,121 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// bilateralOffset evaluates CalculateBilateralOffset between two banks as the Central Bank
+func (n *network) bilateralOffset(mspA, mspB string) *settlement.OffsetCalculation {
+	n.t.Helper()
+	var calculation *settlement.OffsetCalculation
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		calculation, err = n.contract.CalculateBilateralOffset(ctx, mspA, mspB)
+		return err
+	}))
+	return calculation
+}
+
+func TestCalculateBilateralOffset_ConsumesEachDirectionOldestFirst(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	n.setMultilateralLimit(gtBankMSP, 0)
+
+	older := n.pay(accessBankMSP, gtBankMSP, 300.10)
+	newer := n.pay(accessBankMSP, gtBankMSP, 200.205)
+	back := n.pay(gtBankMSP, accessBankMSP, 400.30)
+
+	calculation := n.bilateralOffset(accessBankMSP, gtBankMSP)
+	requireAmount(t, 400.30, calculation.Offset)
+	require.Equal(t, []settlement.OffsetUpdate{
+		{ID: older, AmountToSettle: 0, SettledPortion: 300.10, Status: "SETTLED"},
+		{ID: newer, AmountToSettle: 100.01, SettledPortion: 100.20, Status: "PARTIALLY_SETTLED"},
+		{ID: back, AmountToSettle: 0, SettledPortion: 400.30, Status: "SETTLED"},
+	}, calculation.Updates)
+
+	// Applying the leg moves no balances and leaves the remainder queued
+	payload, err := json.Marshal(calculation)
+	require.NoError(t, err)
+	n.ledger.Advance(time.Second)
+	require.NoError(t, n.ledger.SubmitWithTransient(memstub.NewIdentity(centralBankMSP), map[string][]byte{"offsetUpdate": payload},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ApplyBilateralOffset(ctx, accessBankMSP, gtBankMSP)
+		}))
+
+	pd := n.payment(newer, accessBankMSP, gtBankMSP)
+	require.Equal(t, "PARTIALLY_SETTLED", pd.Status)
+	requireAmount(t, 100.01, pd.AmountToSettle)
+	require.Equal(t, "SETTLED", n.payment(older, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "SETTLED", n.stubStatus(back))
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+
+	// The partly settled payment stays in the queue for the next offset
+	calculation = n.bilateralOffset(accessBankMSP, gtBankMSP)
+	require.Zero(t, calculation.Offset)
+	require.Empty(t, calculation.Updates)
+}
+
+// executeBilateral submits ExecuteBilateralSettlement between two banks as msp
+func (n *network) executeBilateral(msp, mspA, mspB string) (*settlement.BilateralSettlementResult, error) {
+	var result *settlement.BilateralSettlementResult
+	err := n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = n.contract.ExecuteBilateralSettlement(ctx, mspA, mspB)
+		return err
+	})
+	return result, err
+}
+
+func TestExecuteBilateralSettlement_MovesTheResidualTheDebtorCanFund(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	n.setMultilateralLimit(gtBankMSP, 0)
+
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 400)
+	n.drain(accessBankMSP, 250)
+
+	_, err := n.executeBilateral(accessBankMSP, accessBankMSP, gtBankMSP)
+	require.ErrorContains(t, err, "only Central Bank can execute bilateral settlement")
+
+	result, err := n.executeBilateral(centralBankMSP, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, accessBankMSP, result.DebtorMSP)
+	require.Equal(t, gtBankMSP, result.CreditorMSP)
+	requireAmount(t, 400, result.Offset)
+	requireAmount(t, 600, result.Residual)
+	requireAmount(t, 250, result.ResidualSettled)
+	requireAmount(t, 350, result.ResidualUnsettled)
+	require.Equal(t, 1, result.SettledPayments)
+	require.Equal(t, 1, result.PartialPayments)
+	require.Equal(t, n.ledger.Now().Unix(), result.Timestamp)
+
+	requireAmount(t, 0, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+250, n.balance(gtBankMSP))
+	pd := n.payment(out, accessBankMSP, gtBankMSP)
+	require.Equal(t, "PARTIALLY_SETTLED", pd.Status)
+	requireAmount(t, 350, pd.AmountToSettle)
+	require.Equal(t, "SETTLED", n.payment(back, accessBankMSP, gtBankMSP).Status)
+
+	events := n.ledger.Events()
+	require.Equal(t, "BilateralSettlementExecuted", events[len(events)-1].Name)
+
+	var record *settlement.BilateralSettlementResult
+	require.NoError(t, n.evaluate(gtBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		record, err = n.contract.GetBilateralSettlementRecord(ctx, accessBankMSP, gtBankMSP, result.ID)
+		return err
+	}))
+	require.Equal(t, result, record)
+
+	// The debtor has nothing left to fund the remaining residual
+	_, err = n.executeBilateral(centralBankMSP, accessBankMSP, gtBankMSP)
+	require.ErrorContains(t, err, "nothing can be settled between AccessBankMSP and GTBankMSP")
+}
@@ -0,0 +1,82 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// counterpartyStats evaluates GetCounterpartyStats as msp, keyed by counterparty
+func (n *network) counterpartyStats(msp string) map[string]*settlement.CounterpartyStats {
+	n.t.Helper()
+	var stats []*settlement.CounterpartyStats
+	require.NoError(n.t, n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		stats, err = n.contract.GetCounterpartyStats(ctx)
+		return err
+	}))
+
+	byBank := make(map[string]*settlement.CounterpartyStats, len(stats))
+	for _, stat := range stats {
+		byBank[stat.BankMSP] = stat
+	}
+	return byBank
+}
+
+func TestGetCounterpartyStats_BreaksExposureDownByStage(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 100)
+	n.pay(gtBankMSP, accessBankMSP, 40)
+	n.settleBatch()
+	firstDay := n.ledger.Now().Unix() - n.ledger.Now().Unix()%86400
+
+	n.ledger.Advance(24 * time.Hour)
+	n.setMultilateralLimit(gtBankMSP, 0)
+	n.pay(gtBankMSP, accessBankMSP, 200)
+	n.pay(accessBankMSP, gtBankMSP, 250)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	partial := n.pay(accessBankMSP, gtBankMSP, 500)
+
+	// The offset settles the 200 back; AccessBank funds only 100 of the residual
+	n.drain(accessBankMSP, 100)
+	_, err := n.executeBilateral(centralBankMSP, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 200, n.payment(partial, accessBankMSP, gtBankMSP).AmountToSettle)
+
+	_, err = n.createPayment(gtBankMSP, accessBankMSP, 70)
+	require.NoError(t, err)
+	acknowledged, err := n.createPayment(accessBankMSP, gtBankMSP, 30)
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(acknowledged, accessBankMSP, gtBankMSP))
+	secondDay := n.ledger.Now().Unix() - n.ledger.Now().Unix()%86400
+
+	stats := n.counterpartyStats(accessBankMSP)
+	require.Len(t, stats, 3)
+	require.Equal(t, &settlement.CounterpartyStats{
+		BankMSP:           gtBankMSP,
+		TransactionCount:  7,
+		TransactionVolume: 1190,
+		NetPosition:       -410,
+		GrossIncoming:     70,
+		GrossOutgoing:     480,
+		NetPending:        40,
+		NetBatched:        -250,
+		NetQueued:         -200,
+		SettledVolume:     340,
+		SettledByPeriod: []settlement.SettledPeriod{
+			{PeriodStart: firstDay, Count: 2, Volume: 140},
+			{PeriodStart: secondDay, Count: 1, Volume: 200},
+		},
+	}, stats[gtBankMSP])
+	require.Zero(t, stats[zenithBankMSP].TransactionCount)
+	require.Empty(t, stats[zenithBankMSP].SettledByPeriod)
+
+	// The same payments seen from GTBank flip every net figure
+	mirror := n.counterpartyStats(gtBankMSP)[accessBankMSP]
+	requireAmount(t, 410, mirror.NetPosition)
+	requireAmount(t, 480, mirror.GrossIncoming)
+	requireAmount(t, -40, mirror.NetPending)
+}
@@ -0,0 +1,81 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// multilateralOffset evaluates CalculateMultilateralOffset as the Central Bank
+func (n *network) multilateralOffset() *settlement.MultiOffsetCalculation {
+	n.t.Helper()
+	var calculation *settlement.MultiOffsetCalculation
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		calculation, err = n.contract.CalculateMultilateralOffset(ctx)
+		return err
+	}))
+	return calculation
+}
+
+// decisionsByID indexes the resolver's decisions by payment ID
+func decisionsByID(calculation *settlement.MultiOffsetCalculation) map[string]settlement.GridlockDecision {
+	decisions := make(map[string]settlement.GridlockDecision, len(calculation.Decisions))
+	for _, decision := range calculation.Decisions {
+		decisions[decision.ID] = decision
+	}
+	return decisions
+}
+
+func TestCalculateMultilateralOffset_RemovesPaymentsOfPayersShortOfBalance(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 500)
+	n.setMultilateralLimit(gtBankMSP, 500)
+	n.setMultilateralLimit(zenithBankMSP, 0)
+
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 800)
+	short := n.pay(zenithBankMSP, firstBankMSP, 900)
+
+	// Zenith's limit is lifted, but it no longer holds enough to fund the payment
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RemoveExposureLimit(ctx, zenithBankMSP, "")
+	}))
+	n.drain(zenithBankMSP, 100)
+
+	calculation := n.multilateralOffset()
+	require.Equal(t, 1, calculation.Iterations)
+	require.Len(t, calculation.Updates, 2)
+	requireAmount(t, -200, calculation.NetPositions[accessBankMSP])
+	requireAmount(t, 200, calculation.NetPositions[gtBankMSP])
+	require.NotContains(t, calculation.NetPositions, zenithBankMSP)
+
+	decisions := decisionsByID(calculation)
+	require.True(t, decisions[out].Selected)
+	require.True(t, decisions[back].Selected)
+	require.False(t, decisions[short].Selected)
+	require.Equal(t, "payer ZenithBankMSP net debit 900.00 exceeds balance 100.00 by 800.00", decisions[short].Reason)
+}
+
+func TestCalculateMultilateralOffset_DropsTheLastQueuedPaymentOfABreachingPayer(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+
+	first := n.pay(accessBankMSP, gtBankMSP, 100)
+	second := n.pay(accessBankMSP, zenithBankMSP, 200)
+	last := n.pay(accessBankMSP, firstBankMSP, 300)
+	n.setMultilateralLimit(accessBankMSP, 350)
+
+	calculation := n.multilateralOffset()
+	require.Equal(t, 1, calculation.Iterations)
+	requireAmount(t, -300, calculation.NetPositions[accessBankMSP])
+	requireAmount(t, 300, calculation.Efficiency.TotalGross)
+
+	decisions := decisionsByID(calculation)
+	require.True(t, decisions[first].Selected)
+	require.True(t, decisions[second].Selected)
+	require.False(t, decisions[last].Selected)
+	require.Equal(t, "payer AccessBankMSP net debit 600.00 exceeds multilateral limit 350.00", decisions[last].Reason)
+}
@@ -0,0 +1,195 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// settle submits SettlePayment as the Central Bank and returns its outcome
+func (n *network) settle(id, payer, payee string) (string, error) {
+	var outcome string
+	err := n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		outcome, err = n.contract.SettlePayment(ctx, settlement.PaymentEventDetails{ID: id, PayerMSP: payer, PayeeMSP: payee})
+		return err
+	})
+	return outcome, err
+}
+
+// recoverDebited submits RecoverDebitedPayments as the Central Bank
+func (n *network) recoverDebited(olderThan time.Duration, action string) (*settlement.DebitRecoveryResult, error) {
+	var result *settlement.DebitRecoveryResult
+	err := n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = n.contract.RecoverDebitedPayments(ctx, int64(olderThan.Seconds()), action)
+		return err
+	})
+	return result, err
+}
+
+// strandDebit leaves an acknowledged payment the way a crash between the former
+// DebitAccount and CreditAccount did: payer debited, payee never credited
+func (n *network) strandDebit(payer, payee string, amount float64) string {
+	n.t.Helper()
+	id := n.acknowledged(payer, payee, amount)
+	pd := n.payment(id, payer, payee)
+	pd.Status = "DEBITED"
+
+	require.NoError(n.t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		paymentJSON, err := json.Marshal(pd)
+		require.NoError(n.t, err)
+		require.NoError(n.t, ctx.GetStub().PutPrivateData(collectionName(payer, payee), id, paymentJSON))
+
+		account := settlement.BankAccount{MSP: payer, Balance: n.balance(payer) - amount}
+		accountJSON, err := json.Marshal(account)
+		require.NoError(n.t, err)
+		return ctx.GetStub().PutPrivateData("col-settlement-"+payer, payer, accountJSON)
+	}))
+	return id
+}
+
+// collectionName returns the bilateral collection of two banks
+func collectionName(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return "col-" + a + "-" + b
+}
+
+func TestSettlePayment_MovesBothLegsInOneTransaction(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	id := n.acknowledged(zenithBankMSP, firstBankMSP, 4000)
+
+	outcome, err := n.settle(id, zenithBankMSP, firstBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "SUCCESS", outcome)
+
+	pd := n.payment(id, zenithBankMSP, firstBankMSP)
+	require.Equal(t, "SETTLED", pd.Status)
+	require.Zero(t, pd.AmountToSettle)
+	require.Equal(t, n.ledger.Now().Unix(), pd.SettledAt)
+	require.Equal(t, "SETTLED", n.stubStatus(id))
+	requireAmount(t, startingBalance-4000, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance+4000, n.balance(firstBankMSP))
+	requireAmount(t, startingBalance*float64(len(banks)), n.totalBalance())
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentSettled", events[len(events)-1].Name)
+
+	_, err = n.settle(id, zenithBankMSP, firstBankMSP)
+	require.ErrorContains(t, err, "is not in ACKNOWLEDGED or QUEUED state, current status: SETTLED")
+	requireAmount(t, startingBalance+4000, n.balance(firstBankMSP))
+}
+
+func TestSettlePayment_QueuesOnInsufficientFunds(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	id := n.acknowledged(accessBankMSP, gtBankMSP, startingBalance+500)
+
+	outcome, err := n.settle(id, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "QUEUED", outcome)
+	pd := n.payment(id, accessBankMSP, gtBankMSP)
+	require.Equal(t, "QUEUED", pd.Status)
+	require.Equal(t, "insufficient_funds", pd.QueueReason)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentQueued", events[len(events)-1].Name)
+
+	// Retrying before the payer is funded leaves it queued
+	outcome, err = n.settle(id, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "QUEUED", outcome)
+
+	// An incoming gross payment funds the payer
+	incoming := n.acknowledged(zenithBankMSP, accessBankMSP, 1000)
+	outcome, err = n.settle(incoming, zenithBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "SUCCESS", outcome)
+
+	outcome, err = n.settle(id, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "SUCCESS", outcome)
+	pd = n.payment(id, accessBankMSP, gtBankMSP)
+	require.Equal(t, "SETTLED", pd.Status)
+	require.Empty(t, pd.QueueReason)
+	requireAmount(t, 500, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance*float64(len(banks)), n.totalBalance())
+}
+
+func TestRecoverDebitedPayments_CompletesStaleDebits(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	stale := n.strandDebit(accessBankMSP, zenithBankMSP, 2500)
+	requireAmount(t, startingBalance*float64(len(banks))-2500, n.totalBalance(), "the stranded debit has left the system")
+
+	n.ledger.Advance(10 * time.Minute)
+	recent := n.strandDebit(gtBankMSP, firstBankMSP, 800)
+
+	result, err := n.recoverDebited(5*time.Minute, settlement.RecoveryComplete)
+	require.NoError(t, err)
+	require.Equal(t, settlement.RecoveryComplete, result.Action)
+	require.Equal(t, int64(300), result.OlderThanSeconds)
+	require.Equal(t, 1, result.RecoveredCount)
+	require.Equal(t, []string{stale}, result.RecoveredPayments)
+	requireAmount(t, 2500, result.RecoveredAmount)
+
+	pd := n.payment(stale, accessBankMSP, zenithBankMSP)
+	require.Equal(t, "SETTLED", pd.Status)
+	require.Zero(t, pd.AmountToSettle)
+	require.Equal(t, "SETTLED", n.stubStatus(stale))
+	requireAmount(t, startingBalance-2500, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+2500, n.balance(zenithBankMSP))
+
+	// The recent debit is left for the settlement still in flight
+	require.Equal(t, "DEBITED", n.payment(recent, gtBankMSP, firstBankMSP).Status)
+	requireAmount(t, startingBalance*float64(len(banks))-800, n.totalBalance())
+
+	events := n.ledger.Events()
+	require.Equal(t, "DebitedPaymentsRecovered", events[len(events)-1].Name)
+}
+
+func TestRecoverDebitedPayments_ReversesDebits(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	id := n.strandDebit(firstBankMSP, gtBankMSP, 1200)
+	settled := n.acknowledged(gtBankMSP, zenithBankMSP, 300)
+	_, err := n.settle(settled, gtBankMSP, zenithBankMSP)
+	require.NoError(t, err)
+
+	result, err := n.recoverDebited(0, settlement.RecoveryReverse)
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, result.RecoveredPayments)
+
+	pd := n.payment(id, firstBankMSP, gtBankMSP)
+	require.Equal(t, "RETURNED_UNSETTLED", pd.Status)
+	require.Equal(t, 1200.0, pd.AmountToSettle)
+	require.Equal(t, "RETURNED_UNSETTLED", n.stubStatus(id))
+	requireAmount(t, startingBalance, n.balance(firstBankMSP))
+	requireAmount(t, startingBalance-300, n.balance(gtBankMSP))
+	requireAmount(t, startingBalance*float64(len(banks)), n.totalBalance())
+
+	// Nothing is left to recover
+	result, err = n.recoverDebited(0, settlement.RecoveryReverse)
+	require.NoError(t, err)
+	require.Zero(t, result.RecoveredCount)
+	require.Empty(t, result.RecoveredPayments)
+}
+
+func TestRecoverDebitedPayments_RejectsInvalidArguments(t *testing.T) {
+	n := newNetwork(t)
+
+	_, err := n.recoverDebited(time.Minute, "REFUND")
+	require.ErrorContains(t, err, "unknown recovery action REFUND")
+
+	_, err = n.recoverDebited(-time.Minute, settlement.RecoveryComplete)
+	require.ErrorContains(t, err, "olderThanSeconds must not be negative")
+}
@@ -0,0 +1,186 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// collectContributions submits CollectGuaranteeContributions as the Central Bank
+func (n *network) collectContributions() *settlement.GuaranteeCollectionResult {
+	n.t.Helper()
+	var result *settlement.GuaranteeCollectionResult
+	require.NoError(n.t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = n.contract.CollectGuaranteeContributions(ctx)
+		return err
+	}))
+	return result
+}
+
+// guaranteeFund evaluates GetGuaranteeFund as the Central Bank
+func (n *network) guaranteeFund() *settlement.GuaranteeFund {
+	n.t.Helper()
+	var fund *settlement.GuaranteeFund
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		fund, err = n.contract.GetGuaranteeFund(ctx)
+		return err
+	}))
+	return fund
+}
+
+// guaranteeShare evaluates GetGuaranteeShare as the bank itself
+func (n *network) guaranteeShare(msp string) *settlement.GuaranteeShare {
+	n.t.Helper()
+	var share *settlement.GuaranteeShare
+	require.NoError(n.t, n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		share, err = n.contract.GetGuaranteeShare(ctx, msp)
+		return err
+	}))
+	return share
+}
+
+// guaranteeUsage evaluates GetGuaranteeFundUsage as the Central Bank
+func (n *network) guaranteeUsage() []*settlement.GuaranteeUsage {
+	n.t.Helper()
+	var usages []*settlement.GuaranteeUsage
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		usages, err = n.contract.GetGuaranteeFundUsage(ctx)
+		return err
+	}))
+	return usages
+}
+
+func TestCollectGuaranteeContributions_FollowsPeakNetDebits(t *testing.T) {
+	n := newNetwork(t)
+
+	n.pay(accessBankMSP, gtBankMSP, 100_000)
+	n.pay(zenithBankMSP, firstBankMSP, 300_000)
+	n.settleBatch()
+
+	// The fund covers the largest peak, 300,000, shared 1:3 between the two debtors
+	result := n.collectContributions()
+	requireAmount(t, 75_000, result.Required[accessBankMSP])
+	requireAmount(t, 225_000, result.Required[zenithBankMSP])
+	require.Zero(t, result.Required[gtBankMSP])
+	requireAmount(t, 300_000, result.FundBalance)
+	requireAmount(t, startingBalance-100_000-75_000, n.balance(accessBankMSP))
+
+	fund := n.guaranteeFund()
+	requireAmount(t, 300_000, fund.PeakNetDebits[zenithBankMSP])
+	requireAmount(t, 225_000, fund.Contributions[zenithBankMSP])
+	share := n.guaranteeShare(zenithBankMSP)
+	requireAmount(t, 300_000, share.PeakNetDebit)
+	requireAmount(t, 225_000, share.Contribution)
+	invariant := n.supplyInvariant()
+	require.True(t, invariant.Holds)
+	requireAmount(t, 300_000, invariant.GuaranteeFund)
+
+	// Halving the cover refunds half of every contribution
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetGuaranteeCoverRatio(ctx, 0.5)
+	}))
+	result = n.collectContributions()
+	requireAmount(t, -37_500, result.Collected[accessBankMSP])
+	requireAmount(t, 150_000, result.FundBalance)
+	requireAmount(t, startingBalance-100_000-37_500, n.balance(accessBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+
+	// Nothing is collected again once contributions match the formula
+	require.Empty(t, n.collectContributions().Collected)
+}
+
+func TestApplyNettingOffsets_GuaranteeFundCoversDefaultingDebtor(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 100_000)
+	n.pay(zenithBankMSP, firstBankMSP, 300_000)
+	n.settleBatch()
+	n.collectContributions()
+
+	// Zenith cannot cover its net debit: its own 225,000 goes first, Access loses 15,000
+	n.drain(zenithBankMSP, 10_000)
+	n.pay(zenithBankMSP, firstBankMSP, 250_000)
+	result := n.settleBatch()
+	require.Empty(t, result.FailedBanks)
+	requireAmount(t, 240_000, result.GuaranteeCovered[zenithBankMSP])
+	require.Zero(t, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance+300_000+250_000, n.balance(firstBankMSP))
+
+	fund := n.guaranteeFund()
+	requireAmount(t, 60_000, fund.Balance)
+	requireAmount(t, 60_000, fund.Contributions[accessBankMSP])
+	require.Zero(t, fund.Contributions[zenithBankMSP])
+	requireAmount(t, 240_000, fund.Liabilities[zenithBankMSP])
+	requireAmount(t, 240_000, fund.TotalUsed)
+	requireAmount(t, 60_000, n.guaranteeShare(accessBankMSP).Contribution)
+	requireAmount(t, 240_000, n.guaranteeShare(zenithBankMSP).Liability)
+
+	usages := n.guaranteeUsage()
+	require.Len(t, usages, 1)
+	require.Equal(t, zenithBankMSP, usages[0].DebtorMSP)
+	requireAmount(t, 225_000, usages[0].Charges[zenithBankMSP])
+	requireAmount(t, 15_000, usages[0].Charges[accessBankMSP])
+	require.Zero(t, usages[0].Uncovered)
+	require.True(t, n.supplyInvariant().Holds)
+
+	// A debit beyond what is left in the fund is unwound, leaving the fund untouched
+	n.pay(zenithBankMSP, firstBankMSP, 100_000)
+	result = n.settleBatch()
+	require.Len(t, result.FailedBanks, 1)
+	require.Empty(t, result.GuaranteeCovered)
+	require.Len(t, n.guaranteeUsage(), 1)
+	requireAmount(t, 60_000, n.guaranteeFund().Balance)
+	require.True(t, n.supplyInvariant().Holds)
+}
+
+func TestApplyNettingOffsets_UnwoundDebitsDoNotRaisePeaks(t *testing.T) {
+	n := newNetwork(t)
+	n.drain(zenithBankMSP, 10_000)
+	n.pay(zenithBankMSP, firstBankMSP, 500_000)
+	n.pay(accessBankMSP, gtBankMSP, 100_000)
+
+	// Zenith's debit is unwound, so only Access settles a net debit
+	result := n.settleBatch()
+	require.Len(t, result.FailedBanks, 1)
+	require.Equal(t, zenithBankMSP, result.FailedBanks[0].BankMSP)
+	requireAmount(t, -500_000, result.Unwind.OriginalNetPositions[zenithBankMSP])
+
+	fund := n.guaranteeFund()
+	require.Zero(t, fund.PeakNetDebits[zenithBankMSP])
+	requireAmount(t, 100_000, fund.PeakNetDebits[accessBankMSP])
+	require.Zero(t, n.collectContributions().Required[zenithBankMSP])
+}
+
+func TestGuaranteeFund_IsPrivateToTheCentralBank(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 100_000)
+	n.pay(zenithBankMSP, firstBankMSP, 300_000)
+	n.settleBatch()
+	n.collectContributions()
+
+	// Nothing about the fund is written to public state
+	require.Nil(t, n.ledger.State("GUARANTEE_FUND"))
+
+	// Nor can a bank read another's share; the fund itself is in the CBN-only functions
+	err := n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.GetGuaranteeShare(ctx, zenithBankMSP)
+		return err
+	})
+	require.ErrorContains(t, err, "only ZenithBankMSP or Central Bank can access its guarantee share")
+
+	// Each bank sees its own part, and the Central Bank sees every bank's
+	requireAmount(t, 75_000, n.guaranteeShare(accessBankMSP).Contribution)
+	require.Zero(t, n.guaranteeShare(gtBankMSP).Contribution)
+	require.NoError(t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		share, err := n.contract.GetGuaranteeShare(ctx, zenithBankMSP)
+		if err == nil {
+			requireAmount(t, 225_000, share.Contribution)
+		}
+		return err
+	}))
+}
@@ -0,0 +1,97 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// exposureLimits evaluates GetExposureLimits for payer as msp
+func (n *network) exposureLimits(msp, payer string) ([]*settlement.ExposureUtilisation, error) {
+	var limits []*settlement.ExposureUtilisation
+	err := n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		limits, err = n.contract.GetExposureLimits(ctx, payer)
+		return err
+	})
+	return limits, err
+}
+
+func TestSetBilateralLimit_QueuesOnlyPaymentsToThatCounterparty(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetBilateralLimit(ctx, accessBankMSP, gtBankMSP, 1000)
+	}))
+
+	events := n.ledger.Events()
+	require.Equal(t, "ExposureLimitSet", events[len(events)-1].Name)
+	var limit settlement.ExposureLimit
+	require.NoError(t, json.Unmarshal(events[len(events)-1].Payload, &limit))
+	require.Equal(t, "BILATERAL", limit.Type)
+	require.Equal(t, accessBankMSP, limit.SetBy)
+	require.Equal(t, n.ledger.Now().Unix(), limit.UpdatedAt)
+
+	// Incoming payments from the counterparty count against the net debit
+	n.pay(gtBankMSP, accessBankMSP, 300)
+	within := n.pay(accessBankMSP, gtBankMSP, 1200)
+	over := n.pay(accessBankMSP, gtBankMSP, 200)
+	elsewhere := n.pay(accessBankMSP, zenithBankMSP, 5000)
+
+	require.Equal(t, "BATCHED", n.payment(within, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "QUEUED", n.payment(over, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "BATCHED", n.payment(elsewhere, accessBankMSP, zenithBankMSP).Status)
+
+	limits, err := n.exposureLimits(centralBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.Len(t, limits, 1)
+	require.Equal(t, gtBankMSP, limits[0].CounterpartyMSP)
+	requireAmount(t, 900, limits[0].Utilisation)
+	requireAmount(t, 100, limits[0].Headroom)
+	requireAmount(t, 90, limits[0].UtilisationPct)
+}
+
+func TestRemoveExposureLimit_LetsPaymentsBatch(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 500)
+	require.Equal(t, "QUEUED", n.payment(n.pay(accessBankMSP, gtBankMSP, 800), accessBankMSP, gtBankMSP).Status)
+
+	require.NoError(t, n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RemoveExposureLimit(ctx, accessBankMSP, "")
+	}))
+
+	events := n.ledger.Events()
+	require.Equal(t, "ExposureLimitRemoved", events[len(events)-1].Name)
+	var removed map[string]interface{}
+	require.NoError(t, json.Unmarshal(events[len(events)-1].Payload, &removed))
+	require.Equal(t, float64(n.ledger.Now().Unix()), removed["timestamp"])
+
+	limits, err := n.exposureLimits(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.Empty(t, limits)
+	require.Equal(t, "BATCHED", n.payment(n.pay(accessBankMSP, gtBankMSP, 800), accessBankMSP, gtBankMSP).Status)
+}
+
+func TestExposureLimits_ManagedOnlyByThePayerOrCentralBank(t *testing.T) {
+	n := newNetwork(t)
+
+	err := n.submit(gtBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetMultilateralLimit(ctx, accessBankMSP, 100)
+	})
+	require.ErrorContains(t, err, "only AccessBankMSP or Central Bank can manage its exposure limits")
+
+	err = n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetBilateralLimit(ctx, accessBankMSP, accessBankMSP, 100)
+	})
+	require.ErrorContains(t, err, "bilateral limit requires two different banks")
+
+	err = n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetMultilateralLimit(ctx, accessBankMSP, -1)
+	})
+	require.ErrorContains(t, err, "exposure limit must not be negative")
+
+	_, err = n.exposureLimits(zenithBankMSP, accessBankMSP)
+	require.ErrorContains(t, err, "only AccessBankMSP or Central Bank can manage its exposure limits")
+}
@@ -0,0 +1,259 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// pledge submits PledgeCollateral as bank
+func (n *network) pledge(bank, assetClass string, faceValue float64) (*settlement.Collateral, error) {
+	var collateral *settlement.Collateral
+	err := n.submit(bank, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		collateral, err = n.contract.PledgeCollateral(ctx, assetClass, "NGFGN-"+bank, faceValue)
+		return err
+	})
+	return collateral, err
+}
+
+// grantCredit submits GrantIntradayCredit as msp
+func (n *network) grantCredit(msp, bank string, limit float64) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.GrantIntradayCredit(ctx, bank, limit)
+		return err
+	})
+}
+
+// facility evaluates GetIntradayFacility for bank as msp
+func (n *network) facility(msp, bank string) (*settlement.IntradayFacility, error) {
+	var facility *settlement.IntradayFacility
+	err := n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		facility, err = n.contract.GetIntradayFacility(ctx, bank)
+		return err
+	})
+	return facility, err
+}
+
+// openFacility pledges FGN bonds for bank and has the Central Bank grant limit against them
+func (n *network) openFacility(bank string, faceValue, limit float64) {
+	n.t.Helper()
+	_, err := n.pledge(bank, "FGN_BOND", faceValue)
+	require.NoError(n.t, err)
+	require.NoError(n.t, n.grantCredit(centralBankMSP, bank, limit))
+}
+
+// drain burns a bank's settlement balance down to what is left
+func (n *network) drain(bank string, left float64) {
+	n.t.Helper()
+	_, err := n.burn(centralBankMSP, bank, n.balance(bank)-left)
+	require.NoError(n.t, err)
+}
+
+// closeDay submits CloseIntradayFacilities as the Central Bank
+func (n *network) closeDay() *settlement.IntradayCloseResult {
+	n.t.Helper()
+	var result *settlement.IntradayCloseResult
+	require.NoError(n.t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = n.contract.CloseIntradayFacilities(ctx)
+		return err
+	}))
+	return result
+}
+
+func TestGrantIntradayCredit_CappedByCollateralAfterHaircut(t *testing.T) {
+	n := newNetwork(t)
+
+	collateral, err := n.pledge(accessBankMSP, "FGN_BOND", 1_000_000)
+	require.NoError(t, err)
+	require.Equal(t, settlement.CollateralPledged, collateral.Status)
+	_, err = n.pledge(accessBankMSP, "CORPORATE_BOND", 400_000)
+	require.NoError(t, err)
+
+	// 1,000,000 less 5% plus 400,000 less 25%
+	facility, err := n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 1_250_000, facility.CollateralValue)
+	require.Zero(t, facility.Available)
+
+	err = n.grantCredit(centralBankMSP, accessBankMSP, 1_250_000.01)
+	require.ErrorContains(t, err, "credit limit 1250000.01 exceeds 1250000.00 of collateral pledged by AccessBankMSP")
+	require.NoError(t, n.grantCredit(centralBankMSP, accessBankMSP, 1_000_000))
+
+	facility, err = n.facility(centralBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 1_000_000, facility.Available)
+
+	// A higher haircut shrinks the credit the collateral secures
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetCollateralHaircut(ctx, "CORPORATE_BOND", 0.5)
+	}))
+	facility, err = n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 1_150_000, facility.CollateralValue)
+
+	_, err = n.facility(gtBankMSP, accessBankMSP)
+	require.ErrorContains(t, err, "only AccessBankMSP or Central Bank can access its intraday facility")
+	_, err = n.pledge(accessBankMSP, "EQUITY", 10_000)
+	require.ErrorContains(t, err, "unknown asset class EQUITY")
+}
+
+func TestSettlePayment_DrawsIntradayCreditForShortfall(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	n.drain(accessBankMSP, 1000)
+	n.openFacility(accessBankMSP, 100_000, 50_000)
+
+	id := n.acknowledged(accessBankMSP, gtBankMSP, 21_000)
+	outcome, err := n.settle(id, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "SUCCESS", outcome)
+	require.Zero(t, n.balance(accessBankMSP))
+
+	facility, err := n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 20_000, facility.Drawn)
+	requireAmount(t, 30_000, facility.Available)
+
+	// The credit drawn accounts for money beyond the total supply until it is repaid
+	invariant := n.supplyInvariant()
+	require.True(t, invariant.Holds)
+	requireAmount(t, 20_000, invariant.IntradayCredit)
+
+	// A shortfall the facility cannot cover queues without drawing anything
+	id = n.acknowledged(accessBankMSP, zenithBankMSP, 30_001)
+	outcome, err = n.settle(id, accessBankMSP, zenithBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "QUEUED", outcome)
+	facility, err = n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 20_000, facility.Drawn)
+}
+
+func TestNettingDebit_DrawsOnFacilityBeforeUnwinding(t *testing.T) {
+	n := newNetwork(t)
+	n.drain(zenithBankMSP, 5000)
+	n.openFacility(zenithBankMSP, 200_000, 100_000)
+
+	n.pay(zenithBankMSP, firstBankMSP, 80_000)
+	n.settleBatch()
+
+	require.Zero(t, n.balance(zenithBankMSP))
+	facility, err := n.facility(zenithBankMSP, zenithBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 75_000, facility.Drawn)
+	require.True(t, n.supplyInvariant().Holds)
+
+	// Beyond the facility the debtor is unwound before anything is drawn
+	id := n.pay(zenithBankMSP, firstBankMSP, 40_000)
+	result := n.settleBatch()
+	require.Len(t, result.FailedBanks, 1)
+	require.Equal(t, "QUEUED", n.payment(id, zenithBankMSP, firstBankMSP).Status)
+	require.Zero(t, n.balance(zenithBankMSP))
+	facility, err = n.facility(zenithBankMSP, zenithBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 75_000, facility.Drawn)
+}
+
+func TestCloseIntradayFacilities_RepaysAndMarksUnpaidCreditOverdue(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	n.drain(accessBankMSP, 0)
+	n.drain(gtBankMSP, 0)
+	n.openFacility(accessBankMSP, 100_000, 60_000)
+	n.openFacility(gtBankMSP, 100_000, 60_000)
+
+	// Access draws 50,000 and is paid 20,000 back; GT pays out of what it received
+	_, err := n.settle(n.acknowledged(accessBankMSP, gtBankMSP, 50_000), accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	_, err = n.settle(n.acknowledged(gtBankMSP, accessBankMSP, 20_000), gtBankMSP, accessBankMSP)
+	require.NoError(t, err)
+
+	drawn, err := n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	requireAmount(t, 50_000, drawn.Drawn)
+
+	result := n.closeDay()
+	requireAmount(t, 20_000, result.Repaid[accessBankMSP])
+	requireAmount(t, 30_000, result.Outstanding[accessBankMSP])
+	requireAmount(t, 0, result.Repaid[gtBankMSP])
+	require.NotContains(t, result.Outstanding, gtBankMSP)
+	require.Zero(t, n.balance(accessBankMSP))
+	requireAmount(t, 30_000, n.balance(gtBankMSP))
+
+	overdue, err := n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.True(t, overdue.Overdue)
+	require.Zero(t, overdue.Available)
+	invariant := n.supplyInvariant()
+	require.True(t, invariant.Holds)
+	requireAmount(t, 30_000, invariant.IntradayCredit)
+
+	// Repaying in full reopens the facility
+	_, err = n.mint(centralBankMSP, accessBankMSP, 30_000)
+	require.NoError(t, err)
+	require.NoError(t, n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.RepayIntradayCredit(ctx, accessBankMSP, 30_000)
+		return err
+	}))
+	reopened, err := n.facility(accessBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.False(t, reopened.Overdue)
+	requireAmount(t, 60_000, reopened.Available)
+	require.Zero(t, n.supplyInvariant().IntradayCredit)
+}
+
+func TestReleaseCollateral_RefusedWhileItSecuresDrawnCredit(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	n.drain(firstBankMSP, 0)
+	collateral, err := n.pledge(firstBankMSP, "TREASURY_BILL", 50_000)
+	require.NoError(t, err)
+	require.NoError(t, n.grantCredit(centralBankMSP, firstBankMSP, 40_000))
+
+	_, err = n.settle(n.acknowledged(firstBankMSP, gtBankMSP, 10_000), firstBankMSP, gtBankMSP)
+	require.NoError(t, err)
+
+	release := func() error {
+		return n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.ReleaseCollateral(ctx, firstBankMSP, collateral.ID)
+			return err
+		})
+	}
+	require.ErrorContains(t, release(), "remaining collateral 0.00 would not cover 10000.00 of intraday credit drawn by FirstBankMSP")
+
+	_, err = n.mint(centralBankMSP, firstBankMSP, 10_000)
+	require.NoError(t, err)
+	n.closeDay()
+	require.NoError(t, release())
+
+	facility, err := n.facility(firstBankMSP, firstBankMSP)
+	require.NoError(t, err)
+	require.Zero(t, facility.CollateralValue)
+	require.Zero(t, facility.Available)
+}
+
+func TestCheckSupplyInvariant_CountsCreditDrawnByBanksInOneCycle(t *testing.T) {
+	n := newNetwork(t)
+	n.drain(accessBankMSP, 0)
+	n.drain(gtBankMSP, 0)
+	n.openFacility(accessBankMSP, 100_000, 50_000)
+	n.openFacility(gtBankMSP, 100_000, 50_000)
+
+	n.pay(accessBankMSP, zenithBankMSP, 30_000)
+	n.pay(gtBankMSP, firstBankMSP, 45_000)
+	n.settleBatch()
+
+	invariant := n.supplyInvariant()
+	require.True(t, invariant.Holds)
+	requireAmount(t, 75_000, invariant.IntradayCredit)
+
+	result := n.closeDay()
+	requireAmount(t, 30_000, result.Outstanding[accessBankMSP])
+	requireAmount(t, 45_000, result.Outstanding[gtBankMSP])
+	require.True(t, n.supplyInvariant().Holds)
+}
@@ -0,0 +1,103 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// setMode submits SetSettlementMode as msp
+func (n *network) setMode(msp, mode string) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetSettlementMode(ctx, mode)
+	})
+}
+
+// settlementConfig evaluates GetSettlementConfig as msp
+func (n *network) settlementConfig(msp string) *settlement.SettlementConfig {
+	n.t.Helper()
+	var config *settlement.SettlementConfig
+	require.NoError(n.t, n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		config, err = n.contract.GetSettlementConfig(ctx)
+		return err
+	}))
+	return config
+}
+
+// acknowledged creates a payment and has the payee acknowledge it
+func (n *network) acknowledged(payer, payee string, amount float64) string {
+	n.t.Helper()
+	id, err := n.createPayment(payer, payee, amount)
+	require.NoError(n.t, err)
+	require.NoError(n.t, n.acknowledge(id, payer, payee))
+	return id
+}
+
+func TestGetSettlementConfig_DefaultsToDeferredNet(t *testing.T) {
+	n := newNetwork(t)
+
+	for _, msp := range append([]string{centralBankMSP}, banks...) {
+		config := n.settlementConfig(msp)
+		require.Equal(t, settlement.SettlementModeDeferredNet, config.Mode, msp)
+		require.Equal(t, settlement.ContractVersion, config.Version, msp)
+		require.Empty(t, config.UpdatedBy, msp)
+	}
+}
+
+func TestSetSettlementMode_RefusedWhilePaymentsInSettlement(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 1200)
+
+	err := n.setMode(centralBankMSP, settlement.SettlementModeGross)
+	require.ErrorContains(t, err, "cannot switch from DEFERRED_NET to GROSS: 1 payments are still in settlement")
+	require.Equal(t, settlement.SettlementModeDeferredNet, n.settlementConfig(centralBankMSP).Mode)
+
+	n.settleBatch()
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+
+	config := n.settlementConfig(gtBankMSP)
+	require.Equal(t, settlement.SettlementModeGross, config.Mode)
+	require.Equal(t, centralBankMSP, config.UpdatedBy)
+	require.Equal(t, n.ledger.Now().Unix(), config.UpdatedAt)
+
+	events := n.ledger.Events()
+	require.Equal(t, "SettlementModeChanged", events[len(events)-1].Name)
+
+	// Choosing the current mode again changes nothing
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	require.Equal(t, config.UpdatedAt, n.settlementConfig(centralBankMSP).UpdatedAt)
+}
+
+func TestSetSettlementMode_RejectsUnknownMode(t *testing.T) {
+	n := newNetwork(t)
+
+	err := n.setMode(centralBankMSP, "NET")
+	require.ErrorContains(t, err, "unknown settlement mode NET")
+	require.Equal(t, settlement.SettlementModeDeferredNet, n.settlementConfig(centralBankMSP).Mode)
+}
+
+func TestSettlementMode_GatesOperationsOfTheOtherMode(t *testing.T) {
+	n := newNetwork(t)
+	id := n.acknowledged(gtBankMSP, zenithBankMSP, 700)
+
+	_, err := n.settle(id, gtBankMSP, zenithBankMSP)
+	require.ErrorContains(t, err, "SettlePayment is not available: channel settles in DEFERRED_NET mode, not GROSS")
+
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+
+	err = n.batch(centralBankMSP, id, gtBankMSP, zenithBankMSP)
+	require.ErrorContains(t, err, "batching is not available: channel settles in GROSS mode, not DEFERRED_NET")
+	require.Equal(t, "ACKNOWLEDGED", n.payment(id, gtBankMSP, zenithBankMSP).Status)
+
+	err = n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.CalculateNettingOffsets(ctx)
+		return err
+	})
+	require.ErrorContains(t, err, "CalculateNettingOffsets is not available")
+
+	_, err = n.executeMultilateral(centralBankMSP)
+	require.ErrorContains(t, err, "ExecuteScheduledMultilateralNetting is not available")
+}
@@ -0,0 +1,373 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// payTriangle batches A->B 1000, B->C 400 and C->A 250, which net to A -750, B +600, C +150
+func payTriangle(n *network) []string {
+	return []string{
+		n.pay(accessBankMSP, gtBankMSP, 1000),
+		n.pay(gtBankMSP, zenithBankMSP, 400),
+		n.pay(zenithBankMSP, accessBankMSP, 250),
+	}
+}
+
+func TestCalculateNettingOffsets(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	_, calculation := n.calculateNetting()
+	require.Equal(t, map[string]float64{accessBankMSP: -750, gtBankMSP: 600, zenithBankMSP: 150}, calculation.NetPositions)
+	require.Equal(t, 3, calculation.TotalPayments)
+	requireAmount(t, 750, calculation.TotalNetAmount)
+	require.Equal(t, n.ledger.Now().Unix(), calculation.Timestamp)
+	require.Len(t, calculation.PaymentUpdates, 3)
+	for _, update := range calculation.PaymentUpdates {
+		require.Contains(t, ids, update.ID)
+		require.Equal(t, "SETTLED", update.Status)
+		require.Zero(t, update.AmountToSettle)
+	}
+
+	// Calculating is read-only
+	require.Equal(t, "BATCHED", n.payment(ids[0], accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+}
+
+func TestCalculateNettingOffsets_ReportsEfficiencyAndTransferPlan(t *testing.T) {
+	n := newNetwork(t)
+	payTriangle(n)
+	n.pay(firstBankMSP, gtBankMSP, 100)
+
+	_, calculation := n.calculateNetting()
+	efficiency := calculation.Efficiency
+	require.Equal(t, map[string]float64{accessBankMSP: 1000, gtBankMSP: 400, zenithBankMSP: 250, firstBankMSP: 100}, efficiency.GrossOutgoing)
+	require.Equal(t, map[string]float64{gtBankMSP: 1100, zenithBankMSP: 400, accessBankMSP: 250}, efficiency.GrossIncoming)
+	requireAmount(t, 1750, efficiency.TotalGross)
+	requireAmount(t, 850, efficiency.TotalNet)
+	requireAmount(t, 900, efficiency.LiquiditySaved)
+	require.InDelta(t, 850.0/1750, efficiency.NettingRatio, 1e-9)
+	require.InDelta(t, 900.0/1750, efficiency.SavingsRatio, 1e-9)
+
+	// The largest debtor pays the largest creditor first, so four banks need at most three transfers
+	require.Equal(t, []settlement.FundsTransfer{
+		{FromMSP: accessBankMSP, ToMSP: gtBankMSP, Amount: 700},
+		{FromMSP: firstBankMSP, ToMSP: zenithBankMSP, Amount: 100},
+		{FromMSP: accessBankMSP, ToMSP: zenithBankMSP, Amount: 50},
+	}, calculation.TransferPlan)
+
+	var preview *settlement.NetPositionPreview
+	require.NoError(t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		preview, err = n.contract.GetNetPositions(ctx)
+		return err
+	}))
+	require.Equal(t, calculation.NetPositions, preview.NetPositions)
+	require.Equal(t, calculation.Efficiency, preview.Efficiency)
+	require.Equal(t, calculation.TransferPlan, preview.TransferPlan)
+}
+
+func TestCalculateNettingOffsets_SkipsQueuedPayments(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(firstBankMSP, 100)
+
+	batched := n.pay(accessBankMSP, firstBankMSP, 300)
+	n.pay(firstBankMSP, gtBankMSP, 500)
+
+	_, calculation := n.calculateNetting()
+	require.Equal(t, map[string]float64{accessBankMSP: -300, firstBankMSP: 300}, calculation.NetPositions)
+	require.Len(t, calculation.PaymentUpdates, 1)
+	require.Equal(t, batched, calculation.PaymentUpdates[0].ID)
+}
+
+func TestApplyNettingOffsets(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	result := n.settleBatch()
+	require.Equal(t, 3, result.SettledPayments)
+	require.Zero(t, result.FailedPayments)
+	require.Empty(t, result.FailedBanks)
+	require.Equal(t, map[string]float64{accessBankMSP: -750, gtBankMSP: 600, zenithBankMSP: 150}, result.SettledBanks)
+
+	requireAmount(t, startingBalance-750, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+600, n.balance(gtBankMSP))
+	requireAmount(t, startingBalance+150, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance, n.balance(firstBankMSP))
+	requireAmount(t, 4*startingBalance, n.totalBalance())
+
+	pd := n.payment(ids[1], gtBankMSP, zenithBankMSP)
+	require.Equal(t, "SETTLED", pd.Status)
+	require.Zero(t, pd.AmountToSettle)
+	require.Equal(t, n.ledger.Now().Unix(), pd.SettledAt)
+	require.NotEmpty(t, pd.SettlementCycleID)
+
+	var cycle settlement.SettlementCycleRecord
+	require.NoError(t, json.Unmarshal(n.ledger.State("LAST_SETTLEMENT_CYCLE"), &cycle))
+	require.Equal(t, pd.SettlementCycleID, cycle.CycleID)
+	require.Equal(t, 3, cycle.Result.SettledPayments)
+
+	// Nothing is left to net
+	_, calculation := n.calculateNetting()
+	require.Empty(t, calculation.NetPositions)
+	require.Empty(t, calculation.PaymentUpdates)
+}
+
+func TestApplyNettingOffsets_RequiresTransientOffsets(t *testing.T) {
+	n := newNetwork(t)
+
+	err := n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.ApplyNettingOffsets(ctx)
+		return err
+	})
+	require.ErrorContains(t, err, "netting offsets must be provided in transient data")
+}
+
+func TestApplyNettingOffsets_RejectsBanks(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+	calculationJSON, _ := n.calculateNetting()
+
+	for _, bank := range banks {
+		_, err := n.applyNetting(bank, calculationJSON)
+		require.ErrorContains(t, err, "only Central Bank can apply netting offsets", bank)
+	}
+
+	require.Equal(t, "BATCHED", n.payment(ids[0], accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+}
+
+func TestExecuteScheduledMultilateralNetting_NothingQueued(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 1000)
+
+	response, err := n.executeMultilateral(centralBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, true, response["success"])
+	require.Equal(t, "No queued payments found for multilateral netting", response["message"])
+	require.Equal(t, float64(0), response["updatesCount"])
+
+	events := n.ledger.Events()
+	require.Equal(t, "MultilateralNettingSkipped", events[len(events)-1].Name)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+}
+
+func TestExecuteScheduledMultilateralNetting_ResolvesGridlock(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 500)
+	n.setMultilateralLimit(gtBankMSP, 500)
+
+	// Each payment breaches its payer's limit on its own, but they offset to a net of 200
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 800)
+	require.Equal(t, "QUEUED", n.payment(out, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "QUEUED", n.payment(back, accessBankMSP, gtBankMSP).Status)
+
+	response, err := n.executeMultilateral(centralBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "Multilateral netting executed successfully", response["message"])
+	require.Equal(t, float64(2), response["updatesCount"])
+	require.Equal(t, float64(200), response["totalSettled"])
+	require.Equal(t, float64(n.ledger.Now().Unix()), response["timestamp"])
+
+	events := n.ledger.Events()
+	var executed struct {
+		Timestamp      int64    `json:"timestamp"`
+		ProcessedBanks []string `json:"processedBanks"`
+	}
+	require.NoError(t, json.Unmarshal(events[len(events)-1].Payload, &executed))
+	require.Equal(t, n.ledger.Now().Unix(), executed.Timestamp)
+	require.Equal(t, []string{accessBankMSP, gtBankMSP}, executed.ProcessedBanks)
+
+	for _, id := range []string{out, back} {
+		require.Equal(t, "SETTLED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+		require.Equal(t, "SETTLED", n.stubStatus(id))
+	}
+	requireAmount(t, startingBalance-200, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+200, n.balance(gtBankMSP))
+}
+
+func TestExecuteScheduledMultilateralNetting_KeepsGridlockedPaymentsQueued(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(zenithBankMSP, 500)
+
+	id := n.pay(zenithBankMSP, firstBankMSP, 900)
+
+	response, err := n.executeMultilateral(centralBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, "Queued payments remain gridlocked: none can settle within balances and limits", response["message"])
+	require.Equal(t, "QUEUED", n.payment(id, zenithBankMSP, firstBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(zenithBankMSP))
+}
+
+func TestGetBatchWindowSummary(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 100)
+	n.pay(zenithBankMSP, accessBankMSP, 250)
+	_, err := n.createPayment(accessBankMSP, firstBankMSP, 40)
+	require.NoError(t, err)
+	n.pay(gtBankMSP, zenithBankMSP, 700) // outside AccessBank's collections
+
+	// Every payment falls in the two-minute window the ledger clock started in
+	window := genesis.Unix() / 120
+
+	var summary *settlement.BatchWindowSummary
+	require.NoError(t, n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		summary, err = n.contract.GetBatchWindowSummary(ctx, window)
+		return err
+	}))
+	require.Equal(t, accessBankMSP, summary.CallerMSP)
+	require.Equal(t, window, summary.BatchWindow)
+	require.Equal(t, n.ledger.Now().Unix(), summary.Timestamp)
+	require.Equal(t, 3, summary.TotalCount)
+	requireAmount(t, 390, summary.TotalAmount)
+	require.Equal(t, map[string]int{"BATCHED": 2, "PENDING": 1}, summary.StatusCounts)
+	require.Equal(t, map[string]float64{"BATCHED": 350, "PENDING": 40}, summary.StatusAmounts)
+
+	require.NoError(t, n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		summary, err = n.contract.GetBatchWindowSummary(ctx, window+1)
+		return err
+	}))
+	require.Zero(t, summary.TotalCount)
+	require.Empty(t, summary.StatusCounts)
+
+	err = n.evaluate("UnknownBankMSP", func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.GetBatchWindowSummary(ctx, window)
+		return err
+	})
+	require.ErrorContains(t, err, "unauthorized MSP: UnknownBankMSP")
+}
+
+func TestApplyNettingOffsets_UnwindsDefaultingDebtorsAndReruns(t *testing.T) {
+	n := newNetwork(t)
+	n.drain(accessBankMSP, 0)
+	n.drain(gtBankMSP, 0)
+
+	// GT can only pay Zenith out of what Access owes it, so Access failing takes GT down too
+	fromAccess := n.pay(accessBankMSP, gtBankMSP, 50_000)
+	fromGT := n.pay(gtBankMSP, zenithBankMSP, 40_000)
+	fromZenith := n.pay(zenithBankMSP, firstBankMSP, 10_000)
+	result := n.settleBatch()
+
+	require.Len(t, result.FailedBanks, 2)
+	require.Equal(t, accessBankMSP, result.FailedBanks[0].BankMSP)
+	requireAmount(t, -50_000, result.FailedBanks[0].NetAmount)
+	require.Equal(t, gtBankMSP, result.FailedBanks[1].BankMSP)
+	requireAmount(t, -40_000, result.FailedBanks[1].NetAmount)
+	require.NotNil(t, result.Unwind)
+	require.Equal(t, 2, result.Unwind.Reruns)
+	require.ElementsMatch(t, []string{fromAccess, fromGT}, result.Unwind.ExcludedPayments)
+	requireAmount(t, 10_000, result.Unwind.OriginalNetPositions[firstBankMSP])
+	require.Equal(t, 1, result.SettledPayments)
+	requireAmount(t, 10_000, result.TotalNetAmount)
+
+	for _, p := range []struct{ id, payer, payee string }{
+		{fromAccess, accessBankMSP, gtBankMSP},
+		{fromGT, gtBankMSP, zenithBankMSP},
+	} {
+		payment := n.payment(p.id, p.payer, p.payee)
+		require.Equal(t, "QUEUED", payment.Status)
+		require.Equal(t, "participant_default", payment.QueueReason)
+		require.NotZero(t, payment.AmountToSettle)
+	}
+	require.Equal(t, "SETTLED", n.payment(fromZenith, zenithBankMSP, firstBankMSP).Status)
+	require.Zero(t, n.balance(accessBankMSP))
+	require.Zero(t, n.balance(gtBankMSP))
+	requireAmount(t, startingBalance-10_000, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance+10_000, n.balance(firstBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+
+	// The cycle record reports what was settled and the unwind
+	var overview *settlement.SystemOverview
+	require.NoError(t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		overview, err = n.contract.GetSystemOverview(ctx, 0)
+		return err
+	}))
+	require.Equal(t, map[string]float64{accessBankMSP: 0, gtBankMSP: 0, zenithBankMSP: -10_000, firstBankMSP: 10_000}, overview.LastCycle.NetPositions)
+	require.Equal(t, result.Unwind.ExcludedPayments, overview.LastCycle.Result.Unwind.ExcludedPayments)
+}
+
+func TestApplyNettingOffsets_RejectsPositionsThePaymentsDoNotGive(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	// Shifting 100 of Access's debit onto GT still nets to zero, but the payments disagree
+	_, calculation := n.calculateNetting()
+	calculation.NetPositions[accessBankMSP] += 100
+	calculation.NetPositions[gtBankMSP] -= 100
+	forged, err := json.Marshal(calculation)
+	require.NoError(t, err)
+	_, err = n.applyNetting(centralBankMSP, string(forged))
+	require.EqualError(t, err, "net position of AccessBankMSP does not match its payments: calculated -650.00, payments give -750.00")
+
+	require.Equal(t, "BATCHED", n.payment(ids[0], accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+}
+
+func TestApplyNettingOffsets_RejectsDuplicatedAndReplayedPayments(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	// Listing a payment twice would settle it twice
+	calculationJSON, calculation := n.calculateNetting()
+	calculation.PaymentUpdates = append(calculation.PaymentUpdates, calculation.PaymentUpdates[0])
+	forged, err := json.Marshal(calculation)
+	require.NoError(t, err)
+	_, err = n.applyNetting(centralBankMSP, string(forged))
+	require.EqualError(t, err, "payment "+calculation.PaymentUpdates[0].ID+" is listed more than once")
+	require.Equal(t, "BATCHED", n.payment(ids[0], accessBankMSP, gtBankMSP).Status)
+
+	// Once settled, the same calculation cannot be applied again
+	_, err = n.applyNetting(centralBankMSP, calculationJSON)
+	require.NoError(t, err)
+	_, err = n.applyNetting(centralBankMSP, calculationJSON)
+	require.EqualError(t, err, "payment "+calculation.PaymentUpdates[0].ID+" is not batched, current status: SETTLED")
+	requireAmount(t, startingBalance-750, n.balance(accessBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+}
+
+func TestApplyNettingOffsets_SettlesPaymentsWhateverTheUpdatesSay(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	_, calculation := n.calculateNetting()
+	for i := range calculation.PaymentUpdates {
+		calculation.PaymentUpdates[i].Status = "QUEUED"
+		calculation.PaymentUpdates[i].AmountToSettle = 999
+	}
+	forged, err := json.Marshal(calculation)
+	require.NoError(t, err)
+	_, err = n.applyNetting(centralBankMSP, string(forged))
+	require.NoError(t, err)
+
+	for _, p := range []struct{ id, payer, payee string }{
+		{ids[0], accessBankMSP, gtBankMSP},
+		{ids[1], gtBankMSP, zenithBankMSP},
+		{ids[2], zenithBankMSP, accessBankMSP},
+	} {
+		payment := n.payment(p.id, p.payer, p.payee)
+		require.Equal(t, "SETTLED", payment.Status)
+		require.Zero(t, payment.AmountToSettle)
+	}
+}
+
+func TestApplyNettingOffsets_FailsWhenADebtorAccountCannotBeRead(t *testing.T) {
+	n := newNetwork(t)
+	ids := payTriangle(n)
+
+	// A debtor without an account is an error, not a bank with nothing to pay from
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().DelPrivateData("col-settlement-"+accessBankMSP, accessBankMSP)
+	}))
+	calculationJSON, _ := n.calculateNetting()
+	_, err := n.applyNetting(centralBankMSP, calculationJSON)
+	require.EqualError(t, err, "settlement account for AccessBankMSP not found")
+	require.Equal(t, "BATCHED", n.payment(ids[0], accessBankMSP, gtBankMSP).Status)
+}
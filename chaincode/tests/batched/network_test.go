@@ -0,0 +1,296 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	accessBankMSP  = "AccessBankMSP"
+	gtBankMSP      = "GTBankMSP"
+	zenithBankMSP  = "ZenithBankMSP"
+	firstBankMSP   = "FirstBankMSP"
+	centralBankMSP = "CentralBankMSP"
+
+	// startingBalance is the opening balance every bank is onboarded with
+	startingBalance = 15_000_000.0
+)
+
+var banks = []string{accessBankMSP, gtBankMSP, zenithBankMSP, firstBankMSP}
+
+// genesis is the ledger clock at the start of every test network
+var genesis = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+// network is a channel with the four banks and the Central Bank, defined by the
+// collections in private-data/collections_config.json
+type network struct {
+	t        *testing.T
+	ledger   *memstub.Ledger
+	contract *settlement.SmartContract
+	payments int
+}
+
+// newNetwork defines the collections and has the Central Bank onboard every bank
+func newNetwork(t *testing.T) *network {
+	t.Helper()
+
+	ledger := memstub.NewLedger(genesis)
+	for i, a := range banks {
+		for _, b := range banks[i+1:] {
+			name := fmt.Sprintf("col-%s-%s", a, b)
+			if a > b {
+				name = fmt.Sprintf("col-%s-%s", b, a)
+			}
+			ledger.AddCollection(memstub.Collection{
+				Name:            name,
+				Members:         []string{a, b, centralBankMSP},
+				MemberOnlyRead:  true,
+				MemberOnlyWrite: true,
+			})
+		}
+		ledger.AddCollection(memstub.Collection{
+			Name:            "col-settlement-" + a,
+			Members:         []string{a, centralBankMSP},
+			MemberOnlyWrite: true,
+		})
+		ledger.AddCollection(memstub.Collection{
+			Name:            "col-liquidity-" + a,
+			Members:         []string{a, centralBankMSP},
+			MemberOnlyRead:  true,
+			MemberOnlyWrite: true,
+		})
+	}
+	ledger.AddCollection(memstub.Collection{
+		Name:            "col-BVN",
+		Members:         append([]string{centralBankMSP}, banks...),
+		MemberOnlyRead:  true,
+		MemberOnlyWrite: true,
+	})
+	ledger.AddCollection(memstub.Collection{
+		Name:            "col-guarantee",
+		Members:         []string{centralBankMSP},
+		MemberOnlyRead:  true,
+		MemberOnlyWrite: true,
+	})
+
+	n := &network{t: t, ledger: ledger, contract: new(settlement.SmartContract)}
+	ledger.ContextHandler = n.contract.GetTransactionContextHandler()
+	for _, bank := range banks {
+		require.NoError(t, n.onboard(centralBankMSP, bank, startingBalance))
+	}
+	return n
+}
+
+// onboard submits InitLedger for bank as msp
+func (n *network) onboard(msp, bank string, openingBalance float64) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.InitLedger(ctx, bank, openingBalance)
+	})
+}
+
+// submit runs fn as a committed transaction of the given MSP
+func (n *network) submit(msp string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	n.ledger.Advance(time.Second)
+	return n.ledger.Submit(memstub.NewIdentity(msp), fn)
+}
+
+// evaluate runs fn as a query of the given MSP
+func (n *network) evaluate(msp string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return n.ledger.Evaluate(memstub.NewIdentity(msp), fn)
+}
+
+// createPayment submits a PENDING payment from payer to payee and returns its ID
+func (n *network) createPayment(payer, payee string, amount float64) (string, error) {
+	return n.createPaymentAs(payer, payer, payee, amount)
+}
+
+// createPaymentAs submits CreatePayment as caller for a payment from payer to payee
+func (n *network) createPaymentAs(caller, payer, payee string, amount float64) (string, error) {
+	return n.createPaymentWithPriority(caller, payer, payee, amount, "")
+}
+
+// createPaymentWithPriority submits CreatePayment as caller for a payment of the given priority
+func (n *network) createPaymentWithPriority(caller, payer, payee string, amount float64, priority string) (string, error) {
+	n.payments++
+	id := fmt.Sprintf("pay-%04d", n.payments)
+	details := settlement.PaymentDetails{
+		ID:        id,
+		PayerAcct: "0123456789",
+		PayeeAcct: "9876543210",
+		Amount:    amount,
+		Currency:  "NGN",
+		BVN:       "22133455678",
+		PayerMSP:  payer,
+		PayeeMSP:  payee,
+		Priority:  priority,
+		Timestamp: n.ledger.Now().UnixMilli(),
+		User: settlement.BankUser{
+			BVN:       "22133455678",
+			Firstname: "Oluwaseun",
+			Lastname:  "Adebanjo",
+			Birthdate: "15-04-1990",
+			Gender:    "Female",
+		},
+	}
+	detailsJSON, err := json.Marshal(details)
+	require.NoError(n.t, err)
+
+	n.ledger.Advance(time.Second)
+	transient := map[string][]byte{"payment": detailsJSON}
+	err = n.ledger.SubmitWithTransient(memstub.NewIdentity(caller), transient, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.CreatePayment(ctx)
+	})
+	return id, err
+}
+
+// acknowledge submits the payee's acknowledgement of a payment
+func (n *network) acknowledge(id, payer, payee string) error {
+	return n.submit(payee, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcknowledgePayment(ctx, settlement.PaymentEventDetails{ID: id, PayerMSP: payer, PayeeMSP: payee})
+	})
+}
+
+// batch submits BatchAcknowledgedPayment as the given MSP
+func (n *network) batch(msp, id, payer, payee string) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.BatchAcknowledgedPayment(ctx, settlement.PaymentEventDetails{ID: id, PayerMSP: payer, PayeeMSP: payee})
+	})
+}
+
+// pay creates a payment and walks it to BATCHED (or QUEUED when it breaches a limit)
+func (n *network) pay(payer, payee string, amount float64) string {
+	n.t.Helper()
+	id, err := n.createPayment(payer, payee, amount)
+	require.NoError(n.t, err)
+	require.NoError(n.t, n.acknowledge(id, payer, payee))
+	require.NoError(n.t, n.batch(centralBankMSP, id, payer, payee))
+	return id
+}
+
+// calculateNetting evaluates CalculateNettingOffsets as the Central Bank
+func (n *network) calculateNetting() (string, *settlement.NettingCalculationResult) {
+	n.t.Helper()
+	var calculationJSON string
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		calculationJSON, err = n.contract.CalculateNettingOffsets(ctx)
+		return err
+	}))
+
+	var calculation settlement.NettingCalculationResult
+	require.NoError(n.t, json.Unmarshal([]byte(calculationJSON), &calculation))
+	return calculationJSON, &calculation
+}
+
+// applyNetting submits ApplyNettingOffsets as msp with the calculation in transient data
+func (n *network) applyNetting(msp, calculationJSON string) (*settlement.NettingApplicationResult, error) {
+	var resultJSON string
+	n.ledger.Advance(time.Second)
+	transient := map[string][]byte{"nettingOffsets": []byte(calculationJSON)}
+	err := n.ledger.SubmitWithTransient(memstub.NewIdentity(msp), transient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		resultJSON, err = n.contract.ApplyNettingOffsets(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result settlement.NettingApplicationResult
+	require.NoError(n.t, json.Unmarshal([]byte(resultJSON), &result))
+	return &result, nil
+}
+
+// settleBatch calculates and applies netting for every BATCHED payment
+func (n *network) settleBatch() *settlement.NettingApplicationResult {
+	n.t.Helper()
+	calculationJSON, _ := n.calculateNetting()
+	result, err := n.applyNetting(centralBankMSP, calculationJSON)
+	require.NoError(n.t, err)
+	return result
+}
+
+// executeMultilateral submits ExecuteScheduledMultilateralNetting as msp
+func (n *network) executeMultilateral(msp string) (map[string]interface{}, error) {
+	var responseJSON string
+	err := n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		responseJSON, err = n.contract.ExecuteScheduledMultilateralNetting(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[string]interface{})
+	require.NoError(n.t, json.Unmarshal([]byte(responseJSON), &response))
+	return response, nil
+}
+
+// setMultilateralLimit caps the payer's net debit
+func (n *network) setMultilateralLimit(payer string, limit float64) {
+	n.t.Helper()
+	require.NoError(n.t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetMultilateralLimit(ctx, payer, limit)
+	}))
+}
+
+// balance reads a bank's committed settlement account
+func (n *network) balance(msp string) float64 {
+	n.t.Helper()
+	var account settlement.BankAccount
+	require.NoError(n.t, json.Unmarshal(n.ledger.PrivateData("col-settlement-"+msp, msp), &account))
+	return account.Balance
+}
+
+// totalBalance sums every bank's settlement account
+func (n *network) totalBalance() float64 {
+	n.t.Helper()
+	var total float64
+	for _, bank := range banks {
+		total += n.balance(bank)
+	}
+	return total
+}
+
+// payment reads a committed payment from its bilateral collection
+func (n *network) payment(id, payer, payee string) *settlement.PaymentDetails {
+	n.t.Helper()
+	coll := fmt.Sprintf("col-%s-%s", payer, payee)
+	if payer > payee {
+		coll = fmt.Sprintf("col-%s-%s", payee, payer)
+	}
+	paymentJSON := n.ledger.PrivateData(coll, id)
+	require.NotNil(n.t, paymentJSON, "payment %s not found in %s", id, coll)
+
+	var pd settlement.PaymentDetails
+	require.NoError(n.t, json.Unmarshal(paymentJSON, &pd))
+	return &pd
+}
+
+// stubStatus reads the status of a payment's public stub
+func (n *network) stubStatus(id string) string {
+	n.t.Helper()
+	var stub settlement.PaymentStub
+	require.NoError(n.t, json.Unmarshal(n.ledger.State(id), &stub))
+	return stub.Status
+}
+
+// requireAmount compares Naira amounts to the kobo
+func requireAmount(t *testing.T, expected, actual float64, msgAndArgs ...interface{}) {
+	t.Helper()
+	require.InDelta(t, expected, actual, 0.005, msgAndArgs...)
+}
+
+// roundKobo rounds a generated amount to whole kobo
+func roundKobo(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,34 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitLedger_RejectsRepeatOnboarding(t *testing.T) {
+	n := newNetwork(t)
+	n.pay(accessBankMSP, gtBankMSP, 4_000_000)
+	n.settleBatch()
+	requireAmount(t, startingBalance-4_000_000, n.balance(accessBankMSP))
+
+	// Neither the bank nor the Central Bank can reset a balance after net debits
+	err := n.onboard(accessBankMSP, accessBankMSP, startingBalance)
+	require.ErrorContains(t, err, "only Central Bank can onboard banks")
+	err = n.onboard(centralBankMSP, accessBankMSP, startingBalance)
+	require.ErrorContains(t, err, "bank AccessBankMSP is already onboarded")
+
+	requireAmount(t, startingBalance-4_000_000, n.balance(accessBankMSP))
+	requireAmount(t, 4*startingBalance, n.totalSupply(centralBankMSP).TotalSupply)
+}
+
+func TestInitLedger_RejectsUnknownBanksAndNegativeBalances(t *testing.T) {
+	n := newNetwork(t)
+
+	err := n.onboard(centralBankMSP, centralBankMSP, startingBalance)
+	require.ErrorContains(t, err, "unknown bank CentralBankMSP")
+	err = n.onboard(centralBankMSP, "UnknownBankMSP", startingBalance)
+	require.ErrorContains(t, err, "unknown bank UnknownBankMSP")
+	err = n.onboard(centralBankMSP, zenithBankMSP, -1)
+	require.ErrorContains(t, err, "opening balance must not be negative")
+}
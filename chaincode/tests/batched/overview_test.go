@@ -0,0 +1,93 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// systemOverview evaluates GetSystemOverview as msp
+func (n *network) systemOverview(msp string, staleQueueWindows int) (*settlement.SystemOverview, error) {
+	var overview *settlement.SystemOverview
+	err := n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		overview, err = n.contract.GetSystemOverview(ctx, staleQueueWindows)
+		return err
+	})
+	return overview, err
+}
+
+func TestGetSystemOverview_ReportsBanksLastCycleAndAlerts(t *testing.T) {
+	n := newNetwork(t)
+	overview, err := n.systemOverview(centralBankMSP, 0)
+	require.NoError(t, err)
+	require.Nil(t, overview.LastCycle)
+	require.Empty(t, overview.Alerts)
+
+	// ZenithBank cannot cover its net debit, so the cycle fails it and requeues its payment
+	n.pay(accessBankMSP, gtBankMSP, 150)
+	defaulted := n.pay(zenithBankMSP, firstBankMSP, 100)
+	n.drain(zenithBankMSP, 10)
+	n.ledger.Advance(time.Minute)
+	n.settleBatch()
+	applied := n.ledger.Now().Unix()
+	require.Equal(t, "QUEUED", n.payment(defaulted, zenithBankMSP, firstBankMSP).Status)
+
+	n.pay(accessBankMSP, gtBankMSP, 400)
+	n.setMultilateralLimit(accessBankMSP, 300)
+	_, err = n.createPayment(firstBankMSP, gtBankMSP, 40)
+	require.NoError(t, err)
+	n.ledger.Advance(10 * time.Minute)
+
+	_, err = n.systemOverview(accessBankMSP, 0)
+	require.ErrorContains(t, err, "only Central Bank can view the system overview")
+	_, err = n.systemOverview(centralBankMSP, -1)
+	require.ErrorContains(t, err, "stale queue windows must not be negative")
+
+	overview, err = n.systemOverview(centralBankMSP, 0)
+	require.NoError(t, err)
+	require.Equal(t, n.ledger.Now().Unix(), overview.GeneratedAt)
+	require.Equal(t, n.ledger.Now().Unix()/120, overview.Cycle.CurrentWindow)
+	require.Equal(t, n.ledger.Now().Unix()%120, int64(overview.Cycle.TimeInWindow.Seconds()))
+
+	banks := make(map[string]settlement.BankOverview, len(overview.Banks))
+	for _, bank := range overview.Banks {
+		banks[bank.BankMSP] = bank
+	}
+	access := banks[accessBankMSP]
+	requireAmount(t, startingBalance-150, access.Balance)
+	requireAmount(t, 300, access.MultilateralLimit)
+	requireAmount(t, 400, access.BatchedNetDebit)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 400}, access.Batched)
+	requireAmount(t, -400, banks[gtBankMSP].BatchedNetDebit)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 40}, banks[firstBankMSP].Pending)
+	zenith := banks[zenithBankMSP]
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 100}, zenith.Queued)
+	require.Equal(t, n.ledger.Now().Unix()-applied, zenith.OldestQueuedSeconds)
+
+	require.NotNil(t, overview.LastCycle)
+	require.Equal(t, applied, overview.LastCycle.Result.Timestamp)
+	require.Equal(t, applied/120, overview.LastCycle.BatchWindow)
+	require.Equal(t, 1, overview.LastCycle.Result.SettledPayments)
+	require.Len(t, overview.LastCycle.Result.FailedBanks, 1)
+
+	alerts := make(map[string]string, len(overview.Alerts))
+	for _, alert := range overview.Alerts {
+		alerts[alert.Type] = alert.BankMSP
+	}
+	require.Equal(t, map[string]string{
+		"STALE_QUEUE":        zenithBankMSP,
+		"LIMIT_EXCEEDED":     accessBankMSP,
+		"SETTLEMENT_FAILURE": zenithBankMSP,
+	}, alerts)
+
+	// A wider threshold no longer flags the queue
+	overview, err = n.systemOverview(centralBankMSP, 10)
+	require.NoError(t, err)
+	for _, alert := range overview.Alerts {
+		require.NotEqual(t, "STALE_QUEUE", alert.Type)
+	}
+}
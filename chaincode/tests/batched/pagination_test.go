@@ -0,0 +1,122 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStub counts the payment records a query loads
+type countingStub struct {
+	*memstub.Stub
+	reads int
+}
+
+func (s *countingStub) GetPrivateData(collection, key string) ([]byte, error) {
+	s.reads++
+	return s.Stub.GetPrivateData(collection, key)
+}
+
+// bankTransactionsPage evaluates GetAllBankTransactionsPaginated as msp and reports how
+// many records it read one by one
+func (n *network) bankTransactionsPage(msp string, query settlement.PaymentPageQuery) (*settlement.PaymentPage, int) {
+	n.t.Helper()
+	stub := &countingStub{Stub: n.ledger.NewTransaction(memstub.NewIdentity(msp), "GetAllBankTransactionsPaginated")}
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(memstub.NewIdentity(msp))
+
+	page, err := n.contract.GetAllBankTransactionsPaginated(ctx, query)
+	require.NoError(n.t, err)
+	return page, stub.reads
+}
+
+// pageIDs lists the payment IDs of a page
+func pageIDs(page *settlement.PaymentPage) []string {
+	ids := make([]string, 0, len(page.Records))
+	for _, pd := range page.Records {
+		ids = append(ids, pd.ID)
+	}
+	return ids
+}
+
+func TestGetAllBankTransactionsPaginated_WalksCollectionsInKeyOrder(t *testing.T) {
+	n := newNetwork(t)
+	a := n.pay(accessBankMSP, gtBankMSP, 100)
+	b := n.pay(zenithBankMSP, accessBankMSP, 200)
+	c := n.pay(accessBankMSP, gtBankMSP, 300)
+	n.pay(gtBankMSP, zenithBankMSP, 400)
+	d := n.pay(accessBankMSP, firstBankMSP, 500)
+
+	query := settlement.PaymentPageQuery{PageSize: 2}
+	page, _ := n.bankTransactionsPage(accessBankMSP, query)
+	require.Equal(t, []string{a, c}, pageIDs(page))
+	require.Equal(t, 2, page.Metadata.FetchedRecordsCount)
+	require.NotEmpty(t, page.Metadata.Bookmark)
+
+	// A payment created between pages sorts after the cursor, so nothing repeats
+	e := n.pay(accessBankMSP, gtBankMSP, 600)
+	query.Bookmark = page.Metadata.Bookmark
+	page, _ = n.bankTransactionsPage(accessBankMSP, query)
+	require.Equal(t, []string{e, b}, pageIDs(page))
+	require.NotEmpty(t, page.Metadata.Bookmark)
+
+	query.Bookmark = page.Metadata.Bookmark
+	page, _ = n.bankTransactionsPage(accessBankMSP, query)
+	require.Equal(t, []string{d}, pageIDs(page))
+	require.Empty(t, page.Metadata.Bookmark)
+
+	query = settlement.PaymentPageQuery{PageSize: 10, Counterparty: gtBankMSP, MinAmount: 200}
+	page, _ = n.bankTransactionsPage(accessBankMSP, query)
+	require.Len(t, page.Records, 2)
+	require.Equal(t, c, page.Records[0].ID)
+	require.Empty(t, page.Metadata.Bookmark)
+}
+
+func TestGetAllBankTransactionsPaginated_ReadsOnlyThePageFromTheStatusIndex(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	queued := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		queued = append(queued, n.pay(accessBankMSP, gtBankMSP, float64(100*(i+1))))
+	}
+	_, err := n.createPayment(accessBankMSP, gtBankMSP, 700)
+	require.NoError(t, err)
+
+	coll := collectionName(accessBankMSP, gtBankMSP)
+	var reindex *settlement.ReindexResult
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		reindex, err = n.contract.ReindexCollection(ctx, coll)
+		return err
+	}))
+	require.Equal(t, 6, reindex.IndexedPayments)
+	require.Equal(t, n.ledger.Now().Unix(), reindex.Timestamp)
+
+	query := settlement.PaymentPageQuery{PageSize: 2, Status: "QUEUED", Counterparty: gtBankMSP}
+	var ids []string
+	for {
+		page, reads := n.bankTransactionsPage(accessBankMSP, query)
+		// The index state, then the page and the one record that shows whether more follow
+		require.LessOrEqual(t, reads, 1+len(page.Records)+1)
+		ids = append(ids, pageIDs(page)...)
+		if page.Metadata.Bookmark == "" {
+			break
+		}
+		query.Bookmark = page.Metadata.Bookmark
+	}
+	require.Equal(t, queued, ids)
+
+	// A bookmark only resumes a query over its own collection
+	query = settlement.PaymentPageQuery{PageSize: 2, Status: "QUEUED", Counterparty: gtBankMSP}
+	page, _ := n.bankTransactionsPage(accessBankMSP, query)
+	query.Counterparty = zenithBankMSP
+	query.Bookmark = page.Metadata.Bookmark
+	err = n.evaluate(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.GetAllBankTransactionsPaginated(ctx, query)
+		return err
+	})
+	require.ErrorContains(t, err, "invalid bookmark: collection "+coll+" is not part of this query")
+}
@@ -0,0 +1,229 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// setParticipantState submits SetParticipantState as msp
+func (n *network) setParticipantState(msp, bank, state string) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetParticipantState(ctx, bank, state, "test")
+	})
+}
+
+// participantState evaluates GetParticipantState for bank as msp
+func (n *network) participantState(msp, bank string) *settlement.ParticipantStatus {
+	n.t.Helper()
+	var status *settlement.ParticipantStatus
+	require.NoError(n.t, n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		status, err = n.contract.GetParticipantState(ctx, bank)
+		return err
+	}))
+	return status
+}
+
+func TestSetParticipantState_NotifiesCounterparties(t *testing.T) {
+	n := newNetwork(t)
+	require.Equal(t, settlement.ParticipantActive, n.participantState(gtBankMSP, accessBankMSP).State)
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantDefaulted))
+	status := n.participantState(zenithBankMSP, accessBankMSP)
+	require.Equal(t, settlement.ParticipantDefaulted, status.State)
+	require.Equal(t, "test", status.Reason)
+	require.Equal(t, centralBankMSP, status.UpdatedBy)
+
+	events := n.ledger.Events()
+	event := events[len(events)-1]
+	require.Equal(t, "ParticipantStateChanged", event.Name)
+	var payload struct {
+		MSP            string   `json:"msp"`
+		PreviousState  string   `json:"previousState"`
+		State          string   `json:"state"`
+		Counterparties []string `json:"counterparties"`
+	}
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	require.Equal(t, accessBankMSP, payload.MSP)
+	require.Equal(t, settlement.ParticipantActive, payload.PreviousState)
+	require.Equal(t, settlement.ParticipantDefaulted, payload.State)
+	require.ElementsMatch(t, []string{gtBankMSP, zenithBankMSP, firstBankMSP}, payload.Counterparties)
+
+	require.ErrorContains(t, n.setParticipantState(centralBankMSP, accessBankMSP, "CLOSED"), "unknown participant state CLOSED")
+	require.ErrorContains(t, n.setParticipantState(centralBankMSP, "UnknownBankMSP", settlement.ParticipantSuspended), "unknown bank UnknownBankMSP")
+}
+
+func TestCreatePayment_CheckedAgainstParticipantStates(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantSuspendedSending))
+	require.NoError(t, n.setParticipantState(centralBankMSP, zenithBankMSP, settlement.ParticipantSuspended))
+
+	_, err := n.createPayment(accessBankMSP, gtBankMSP, 1000)
+	require.ErrorContains(t, err, "AccessBankMSP cannot send payments: participant is SUSPENDED_SENDING")
+	_, err = n.createPayment(gtBankMSP, zenithBankMSP, 1000)
+	require.ErrorContains(t, err, "ZenithBankMSP cannot receive payments: participant is SUSPENDED")
+
+	// A bank suspended from sending still receives
+	n.pay(gtBankMSP, accessBankMSP, 1000)
+
+	// Reinstated, it sends again
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantActive))
+	n.pay(accessBankMSP, gtBankMSP, 1000)
+}
+
+func TestAcknowledgePayment_RefusedOnceThePayerIsSuspended(t *testing.T) {
+	n := newNetwork(t)
+	id, err := n.createPayment(firstBankMSP, gtBankMSP, 5000)
+	require.NoError(t, err)
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, firstBankMSP, settlement.ParticipantSuspended))
+	err = n.acknowledge(id, firstBankMSP, gtBankMSP)
+	require.ErrorContains(t, err, "FirstBankMSP cannot send payments: participant is SUSPENDED")
+	require.Equal(t, "PENDING", n.payment(id, firstBankMSP, gtBankMSP).Status)
+}
+
+func TestNetting_LeavesOutPaymentsOfSuspendedParticipants(t *testing.T) {
+	n := newNetwork(t)
+	held := n.pay(accessBankMSP, gtBankMSP, 20_000)
+	settled := n.pay(zenithBankMSP, firstBankMSP, 30_000)
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantDefaulted))
+	result := n.settleBatch()
+	require.Equal(t, 1, result.SettledPayments)
+	require.Equal(t, "BATCHED", n.payment(held, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "SETTLED", n.payment(settled, zenithBankMSP, firstBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+
+	// Once reinstated, the held payment settles in the next cycle
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantActive))
+	n.settleBatch()
+	require.Equal(t, "SETTLED", n.payment(held, accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance+20_000, n.balance(gtBankMSP))
+}
+
+func TestApplyNettingOffsets_HoldsPaymentsOfBanksSuspendedSinceTheCalculation(t *testing.T) {
+	n := newNetwork(t)
+	held := n.pay(accessBankMSP, gtBankMSP, 20_000)
+	settled := n.pay(zenithBankMSP, firstBankMSP, 30_000)
+
+	// The calculation still nets GT, which is suspended before it is applied
+	calculationJSON, _ := n.calculateNetting()
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantSuspended))
+	result, err := n.applyNetting(centralBankMSP, calculationJSON)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{held}, result.HeldPayments)
+	require.Equal(t, 1, result.SettledPayments)
+	requireAmount(t, 30_000, result.TotalNetAmount)
+	require.Equal(t, "BATCHED", n.payment(held, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "SETTLED", n.payment(settled, zenithBankMSP, firstBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+}
+
+func TestSettlePayment_RefusedOnceAParticipantIsSuspended(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	id := n.acknowledged(accessBankMSP, gtBankMSP, 1000)
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantSuspendedSending))
+	_, err := n.settle(id, accessBankMSP, gtBankMSP)
+	require.ErrorContains(t, err, "AccessBankMSP cannot send payments: participant is SUSPENDED_SENDING")
+	require.Equal(t, "ACKNOWLEDGED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+}
+
+func TestBatchAcknowledgedPayment_UrgentRefusedOnceThePayeeIsSuspended(t *testing.T) {
+	n := newNetwork(t)
+	id, err := n.createPaymentWithPriority(accessBankMSP, accessBankMSP, gtBankMSP, 1000, "URGENT")
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(id, accessBankMSP, gtBankMSP))
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantSuspended))
+	err = n.batch(centralBankMSP, id, accessBankMSP, gtBankMSP)
+	require.ErrorContains(t, err, "GTBankMSP cannot receive payments: participant is SUSPENDED")
+	require.Equal(t, "ACKNOWLEDGED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+}
+
+func TestReleaseQueuedPayment_RefusedOnceThePayerIsSuspended(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	id := n.pay(accessBankMSP, gtBankMSP, 400)
+	require.Equal(t, "QUEUED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+	n.setMultilateralLimit(accessBankMSP, 1000)
+
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantSuspendedSending))
+	err := n.release(centralBankMSP, id)
+	require.ErrorContains(t, err, "payment "+id+" cannot be released: AccessBankMSP cannot send payments: participant is SUSPENDED_SENDING")
+	require.Equal(t, "QUEUED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+
+	// Once reinstated, the payment is released into the batch
+	require.NoError(t, n.setParticipantState(centralBankMSP, accessBankMSP, settlement.ParticipantActive))
+	require.NoError(t, n.release(centralBankMSP, id))
+	require.Equal(t, "BATCHED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+}
+
+func TestBilateralSettlement_LeavesOutPaymentsOfSuspendedParticipants(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	n.setMultilateralLimit(gtBankMSP, 0)
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 400)
+
+	// GT may still receive, but its own payment can no longer be offset
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantSuspendedSending))
+	calculation := n.bilateralOffset(accessBankMSP, gtBankMSP)
+	require.Zero(t, calculation.Offset)
+	require.Empty(t, calculation.Updates)
+
+	result, err := n.executeBilateral(centralBankMSP, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Zero(t, result.Offset)
+	requireAmount(t, 1000, result.ResidualSettled)
+	require.Equal(t, "SETTLED", n.payment(out, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "QUEUED", n.payment(back, gtBankMSP, accessBankMSP).Status)
+	requireAmount(t, startingBalance-1000, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+1000, n.balance(gtBankMSP))
+}
+
+func TestApplyMultilateralOffset_HoldsPaymentsOfBanksSuspendedSinceTheCalculation(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+	n.setMultilateralLimit(gtBankMSP, 0)
+	n.setMultilateralLimit(zenithBankMSP, 0)
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 800)
+	settled := n.pay(zenithBankMSP, firstBankMSP, 300)
+	for _, bank := range []string{accessBankMSP, gtBankMSP, zenithBankMSP} {
+		n.setMultilateralLimit(bank, 1000)
+	}
+
+	// The calculation still nets GT, which is suspended before it is applied
+	calculation := n.multilateralOffset()
+	require.Len(t, calculation.Updates, 3)
+	require.NoError(t, n.setParticipantState(centralBankMSP, gtBankMSP, settlement.ParticipantSuspended))
+	payload, err := json.Marshal(calculation)
+	require.NoError(t, err)
+	n.ledger.Advance(time.Second)
+	require.NoError(t, n.ledger.SubmitWithTransient(memstub.NewIdentity(centralBankMSP), map[string][]byte{"multilateralUpdate": payload},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ApplyMultilateralOffset(ctx)
+		}))
+
+	require.Equal(t, "QUEUED", n.payment(out, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "QUEUED", n.payment(back, gtBankMSP, accessBankMSP).Status)
+	require.Equal(t, "SETTLED", n.payment(settled, zenithBankMSP, firstBankMSP).Status)
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance, n.balance(gtBankMSP))
+	requireAmount(t, startingBalance-300, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance+300, n.balance(firstBankMSP))
+	require.True(t, n.supplyInvariant().Holds)
+}
@@ -0,0 +1,135 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchAcknowledgedPayment_Batches(t *testing.T) {
+	n := newNetwork(t)
+
+	id, err := n.createPayment(accessBankMSP, gtBankMSP, 2500)
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(id, accessBankMSP, gtBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, id, accessBankMSP, gtBankMSP))
+
+	pd := n.payment(id, accessBankMSP, gtBankMSP)
+	require.Equal(t, "BATCHED", pd.Status)
+	require.Equal(t, 2500.0, pd.AmountToSettle)
+	require.Empty(t, pd.QueueReason)
+	require.Equal(t, n.ledger.Now().Unix(), pd.BatchedAt)
+	require.NotZero(t, pd.CreatedAt)
+	require.NotZero(t, pd.AcknowledgedAt)
+	require.Equal(t, "BATCHED", n.stubStatus(id))
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentBatched", events[len(events)-1].Name)
+}
+
+func TestBatchAcknowledgedPayment_QueuesOverLimit(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 1000)
+
+	within := n.pay(accessBankMSP, gtBankMSP, 600)
+	over := n.pay(accessBankMSP, zenithBankMSP, 600)
+
+	require.Equal(t, "BATCHED", n.payment(within, accessBankMSP, gtBankMSP).Status)
+
+	pd := n.payment(over, accessBankMSP, zenithBankMSP)
+	require.Equal(t, "QUEUED", pd.Status)
+	require.Equal(t, "limit_exceeded", pd.QueueReason)
+	require.Equal(t, n.ledger.Now().Unix(), pd.QueuedAt)
+	require.Equal(t, "QUEUED", n.stubStatus(over))
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentQueued", events[len(events)-1].Name)
+}
+
+func TestBatchAcknowledgedPayment_RequiresAcknowledgement(t *testing.T) {
+	n := newNetwork(t)
+
+	id, err := n.createPayment(gtBankMSP, firstBankMSP, 100)
+	require.NoError(t, err)
+
+	err = n.batch(centralBankMSP, id, gtBankMSP, firstBankMSP)
+	require.ErrorContains(t, err, "is not in ACKNOWLEDGED status, current status: PENDING")
+	require.Equal(t, "PENDING", n.payment(id, gtBankMSP, firstBankMSP).Status)
+
+	err = n.batch(centralBankMSP, "missing", gtBankMSP, firstBankMSP)
+	require.ErrorContains(t, err, "failed to get payment details")
+}
+
+func TestAcknowledgePayment_OnlyPayee(t *testing.T) {
+	n := newNetwork(t)
+
+	id, err := n.createPayment(zenithBankMSP, accessBankMSP, 100)
+	require.NoError(t, err)
+
+	err = n.submit(zenithBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcknowledgePaymentSimple(ctx, id, zenithBankMSP, accessBankMSP)
+	})
+	require.ErrorContains(t, err, "only payee bank can acknowledge payment")
+	require.Equal(t, "PENDING", n.payment(id, zenithBankMSP, accessBankMSP).Status)
+}
+
+func TestCreatePayment_PayerMustBeCaller(t *testing.T) {
+	n := newNetwork(t)
+
+	id, err := n.createPaymentAs(gtBankMSP, accessBankMSP, gtBankMSP, 100)
+	require.ErrorContains(t, err, "payer MSP must match calling MSP")
+	require.Nil(t, n.ledger.State(id))
+}
+
+func TestBatchAcknowledgedPayment_SettlesUrgentPaymentGross(t *testing.T) {
+	n := newNetwork(t)
+	// Gross settlement is prefunded, so net debit limits do not hold urgent payments back
+	n.setMultilateralLimit(accessBankMSP, 1000)
+
+	urgent, err := n.createPaymentWithPriority(accessBankMSP, accessBankMSP, gtBankMSP, 7500, "URGENT")
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(urgent, accessBankMSP, gtBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, urgent, accessBankMSP, gtBankMSP))
+
+	pd := n.payment(urgent, accessBankMSP, gtBankMSP)
+	require.Equal(t, "SETTLED", pd.Status)
+	require.Zero(t, pd.AmountToSettle)
+	require.Zero(t, pd.BatchedAt)
+	require.Equal(t, n.ledger.Now().Unix(), pd.SettledAt)
+	require.Equal(t, "SETTLED", n.stubStatus(urgent))
+	requireAmount(t, startingBalance-7500, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+7500, n.balance(gtBankMSP))
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentSettled", events[len(events)-1].Name)
+
+	// Retail payments still wait for the netting window, which leaves the urgent one alone
+	retail := n.pay(gtBankMSP, accessBankMSP, 500)
+	require.Equal(t, "BATCHED", n.payment(retail, gtBankMSP, accessBankMSP).Status)
+	n.settleBatch()
+	require.Equal(t, "SETTLED", n.payment(retail, gtBankMSP, accessBankMSP).Status)
+	requireAmount(t, startingBalance-7000, n.balance(accessBankMSP))
+	requireAmount(t, startingBalance+7000, n.balance(gtBankMSP))
+	requireAmount(t, startingBalance*float64(len(banks)), n.totalBalance())
+}
+
+func TestBatchAcknowledgedPayment_QueuesUnfundedUrgentPayment(t *testing.T) {
+	n := newNetwork(t)
+
+	id, err := n.createPaymentWithPriority(zenithBankMSP, zenithBankMSP, firstBankMSP, startingBalance+1, "URGENT")
+	require.NoError(t, err)
+	require.NoError(t, n.acknowledge(id, zenithBankMSP, firstBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, id, zenithBankMSP, firstBankMSP))
+
+	pd := n.payment(id, zenithBankMSP, firstBankMSP)
+	require.Equal(t, "QUEUED", pd.Status)
+	require.Equal(t, "insufficient_funds", pd.QueueReason)
+	require.Equal(t, startingBalance+1, pd.AmountToSettle)
+	require.Equal(t, "QUEUED", n.stubStatus(id))
+	requireAmount(t, startingBalance, n.balance(zenithBankMSP))
+	requireAmount(t, startingBalance, n.balance(firstBankMSP))
+
+	events := n.ledger.Events()
+	require.Equal(t, "PaymentQueued", events[len(events)-1].Name)
+}
@@ -0,0 +1,88 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// settlementPerformance evaluates GetSettlementPerformance as msp
+func (n *network) settlementPerformance(msp string, from, to int64) (*settlement.SettlementPerformance, error) {
+	var perf *settlement.SettlementPerformance
+	err := n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		perf, err = n.contract.GetSettlementPerformance(ctx, from, to)
+		return err
+	})
+	return perf, err
+}
+
+func TestGetSettlementPerformance_ReportsStageLatenciesQueueAgeAndThroughput(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(firstBankMSP, 0)
+
+	toGT, err := n.createPayment(accessBankMSP, gtBankMSP, 100)
+	require.NoError(t, err)
+	n.ledger.Advance(59 * time.Second)
+	require.NoError(t, n.acknowledge(toGT, accessBankMSP, gtBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, toGT, accessBankMSP, gtBankMSP))
+
+	fromZenith, err := n.createPayment(zenithBankMSP, accessBankMSP, 200)
+	require.NoError(t, err)
+	n.ledger.Advance(179 * time.Second)
+	require.NoError(t, n.acknowledge(fromZenith, zenithBankMSP, accessBankMSP))
+	require.NoError(t, n.batch(centralBankMSP, fromZenith, zenithBankMSP, accessBankMSP))
+
+	queued := n.pay(firstBankMSP, accessBankMSP, 50)
+	n.ledger.Advance(20 * time.Minute)
+	n.settleBatch()
+	settled := n.ledger.Now().Unix()
+
+	gt := n.payment(toGT, accessBankMSP, gtBankMSP)
+	zenith := n.payment(fromZenith, zenithBankMSP, accessBankMSP)
+	first := n.payment(queued, firstBankMSP, accessBankMSP)
+	require.Equal(t, int64(60), gt.AcknowledgedAt-gt.CreatedAt)
+	require.Equal(t, int64(180), zenith.AcknowledgedAt-zenith.CreatedAt)
+
+	perf, err := n.settlementPerformance(accessBankMSP, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, settled, perf.To)
+	require.Equal(t, settled, perf.GeneratedAt)
+
+	// Nearest rank over the acknowledgement times 1s, 60s and 180s
+	require.Equal(t, settlement.LatencyPercentiles{Count: 3, P50: 60, P95: 180, P99: 180}, perf.Overall.Acknowledgement)
+	require.Equal(t, settlement.LatencyPercentiles{
+		Count: 2,
+		P50:   float64(settled - zenith.AcknowledgedAt),
+		P95:   float64(settled - gt.AcknowledgedAt),
+		P99:   float64(settled - gt.AcknowledgedAt),
+	}, perf.Overall.Settlement)
+
+	require.Len(t, perf.ByCounterparty, 3)
+	require.Equal(t, []string{firstBankMSP, gtBankMSP, zenithBankMSP},
+		[]string{perf.ByCounterparty[0].Counterparty, perf.ByCounterparty[1].Counterparty, perf.ByCounterparty[2].Counterparty})
+	require.Zero(t, perf.ByCounterparty[0].Settlement.Count)
+	require.Equal(t, float64(settled-gt.AcknowledgedAt), perf.ByCounterparty[1].Settlement.P50)
+	require.NotEmpty(t, perf.ByWindow)
+
+	age := settled - first.QueuedAt
+	require.Equal(t, age, perf.OldestQueued)
+	require.Equal(t, settlement.QueueAgeBucket{Label: "15m-1h", MaxAgeSeconds: 60 * 60, Count: 1, Amount: 50}, perf.QueueAge[1])
+	require.Zero(t, perf.QueueAge[0].Count)
+
+	require.Equal(t, []settlement.HourlyThroughput{
+		{HourStart: settled - settled%3600, SettledCount: 2, SettledVolume: 300},
+	}, perf.Throughput)
+
+	// Stage latencies only cover payments created in the range
+	perf, err = n.settlementPerformance(accessBankMSP, first.CreatedAt+1, settled)
+	require.NoError(t, err)
+	require.Zero(t, perf.Overall.Acknowledgement.Count)
+	require.Len(t, perf.Throughput, 1)
+
+	_, err = n.settlementPerformance(accessBankMSP, settled+1, settled)
+	require.ErrorContains(t, err, "invalid range")
+}
@@ -0,0 +1,156 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/require"
+)
+
+// modelPayment is what the test remembers about a payment it created
+type modelPayment struct {
+	id, payer, payee string
+	amount           float64
+}
+
+// randomScenario drives one ledger through a random sequence of payments, limits and
+// netting runs, checking after every step that settlement only moves money between banks
+func randomScenario(t *testing.T, seed int64, steps int) {
+	n := newNetwork(t)
+	rng := rand.New(rand.NewSource(seed))
+	var payments []modelPayment
+
+	withStatus := func(status string) []modelPayment {
+		var matching []modelPayment
+		for _, p := range payments {
+			if n.payment(p.id, p.payer, p.payee).Status == status {
+				matching = append(matching, p)
+			}
+		}
+		return matching
+	}
+
+	for step := 0; step < steps; step++ {
+		switch op := rng.Intn(10); {
+		case op < 3:
+			payer := banks[rng.Intn(len(banks))]
+			payee := banks[rng.Intn(len(banks))]
+			if payer == payee {
+				continue
+			}
+			// Pareto-distributed amounts: mostly small payments with an occasional large one
+			amount := roundKobo(math.Min(1000/math.Pow(1-rng.Float64(), 1/1.2), 5_000_000))
+			id, err := n.createPayment(payer, payee, amount)
+			require.NoError(t, err)
+			payments = append(payments, modelPayment{id: id, payer: payer, payee: payee, amount: amount})
+		case op < 5:
+			if pending := withStatus("PENDING"); len(pending) > 0 {
+				p := pending[rng.Intn(len(pending))]
+				require.NoError(t, n.acknowledge(p.id, p.payer, p.payee))
+			}
+		case op < 7:
+			if acknowledged := withStatus("ACKNOWLEDGED"); len(acknowledged) > 0 {
+				p := acknowledged[rng.Intn(len(acknowledged))]
+				require.NoError(t, n.batch(centralBankMSP, p.id, p.payer, p.payee))
+			}
+		case op == 7:
+			n.setMultilateralLimit(banks[rng.Intn(len(banks))], roundKobo(rng.Float64()*20_000))
+		case op == 8:
+			calculationJSON, calculation := n.calculateNetting()
+
+			// A bank replaying the calculation with its own position inflated must be refused
+			forger := banks[rng.Intn(len(banks))]
+			calculation.NetPositions[forger] += 1_000_000
+			forged, err := json.Marshal(calculation)
+			require.NoError(t, err)
+			_, err = n.applyNetting(forger, string(forged))
+			require.Error(t, err)
+
+			_, err = n.applyNetting(centralBankMSP, calculationJSON)
+			require.NoError(t, err)
+		default:
+			_, err := n.executeMultilateral(centralBankMSP)
+			require.NoError(t, err)
+		}
+
+		requireConservation(t, n, payments, seed, step)
+	}
+}
+
+// requireConservation checks that the banks' balances sum to their opening balances and
+// that every bank's balance moved by exactly its settled incoming minus settled outgoing
+func requireConservation(t *testing.T, n *network, payments []modelPayment, seed int64, step int) {
+	t.Helper()
+	requireAmount(t, 4*startingBalance, n.totalBalance(), "seed %d step %d: money was created or destroyed", seed, step)
+	require.True(t, n.supplyInvariant().Holds, "seed %d step %d: balances drifted from total supply", seed, step)
+
+	expected := make(map[string]float64)
+	for _, bank := range banks {
+		expected[bank] = startingBalance
+	}
+	for _, p := range payments {
+		pd := n.payment(p.id, p.payer, p.payee)
+		if pd.Status == "SETTLED" {
+			require.Zero(t, pd.AmountToSettle, "seed %d step %d: %s", seed, step, p.id)
+			expected[p.payer] -= p.amount
+			expected[p.payee] += p.amount
+			continue
+		}
+		requireAmount(t, p.amount, pd.AmountToSettle, "seed %d step %d: %s is %s", seed, step, p.id, pd.Status)
+	}
+
+	for _, bank := range banks {
+		requireAmount(t, expected[bank], n.balance(bank), "seed %d step %d: %s", seed, step, bank)
+	}
+}
+
+func TestMoneyIsConservedAcrossRandomPaymentsAndNetting(t *testing.T) {
+	property := func(seed int64) bool {
+		randomScenario(t, seed, 60)
+		return !t.Failed()
+	}
+	config := &quick.Config{MaxCount: 25, Rand: rand.New(rand.NewSource(2025))}
+	if testing.Short() {
+		config.MaxCount = 5
+	}
+	require.NoError(t, quick.Check(property, config))
+}
+
+func TestSettlementDrainsEveryBatchedAndQueuedPayment(t *testing.T) {
+	n := newNetwork(t)
+	rng := rand.New(rand.NewSource(7))
+
+	var payments []modelPayment
+	for i := 0; i < 40; i++ {
+		from := rng.Intn(len(banks))
+		payer, payee := banks[from], banks[(from+1+rng.Intn(len(banks)-1))%len(banks)]
+		amount := roundKobo(100 + rng.Float64()*10_000)
+		payments = append(payments, modelPayment{id: n.pay(payer, payee, amount), payer: payer, payee: payee, amount: amount})
+		if i == 20 {
+			// Half way through, cap every bank so later payments queue
+			for _, bank := range banks {
+				n.setMultilateralLimit(bank, 1)
+			}
+		}
+	}
+
+	n.settleBatch()
+	for _, bank := range banks {
+		n.setMultilateralLimit(bank, 10_000_000)
+	}
+	_, err := n.executeMultilateral(centralBankMSP)
+	require.NoError(t, err)
+
+	for _, p := range payments {
+		require.Equal(t, "SETTLED", n.payment(p.id, p.payer, p.payee).Status, p.id)
+	}
+	requireConservation(t, n, payments, 7, 0)
+
+	var cycle settlement.SettlementCycleRecord
+	require.NoError(t, json.Unmarshal(n.ledger.State("LAST_SETTLEMENT_CYCLE"), &cycle))
+	require.Empty(t, cycle.Result.FailedBanks)
+}
@@ -0,0 +1,101 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// useCouchDB answers rich queries like a CouchDB peer and fails any transaction that
+// writes after one, since peers never re-validate rich query results
+func (n *network) useCouchDB() {
+	n.ledger.Backend = memstub.CouchDB
+	n.ledger.RejectUnvalidatedReads = true
+}
+
+func TestUpdateTransactions_ReadPaymentsThroughRangeScans(t *testing.T) {
+	n := newNetwork(t)
+	n.useCouchDB()
+	n.setMultilateralLimit(accessBankMSP, 500)
+	n.setMultilateralLimit(gtBankMSP, 500)
+
+	// Batching checks exposure limits against the BATCHED payments
+	n.pay(zenithBankMSP, firstBankMSP, 300)
+	out := n.pay(accessBankMSP, gtBankMSP, 1000)
+	back := n.pay(gtBankMSP, accessBankMSP, 800)
+	require.Equal(t, "QUEUED", n.payment(out, accessBankMSP, gtBankMSP).Status)
+
+	result, err := n.executeBilateral(centralBankMSP, accessBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.SettledPayments)
+	require.Equal(t, "SETTLED", n.payment(back, accessBankMSP, gtBankMSP).Status)
+
+	n.setMultilateralLimit(zenithBankMSP, 500)
+	n.pay(accessBankMSP, zenithBankMSP, 900)
+	n.pay(zenithBankMSP, accessBankMSP, 600)
+	response, err := n.executeMultilateral(centralBankMSP)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), response["updatesCount"])
+
+	held := n.pay(accessBankMSP, firstBankMSP, 700)
+	n.setMultilateralLimit(accessBankMSP, 5000)
+	require.NoError(t, n.release(accessBankMSP, held))
+
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.ExpireQueuedPayments(ctx)
+		return err
+	}))
+
+	// Rich queries stay available to evaluate-only functions such as the calculation
+	n.settleBatch()
+	require.Equal(t, "SETTLED", n.payment(held, accessBankMSP, firstBankMSP).Status)
+
+	require.NoError(t, n.setMode(centralBankMSP, settlement.SettlementModeGross))
+	stale := n.strandDebit(gtBankMSP, zenithBankMSP, 400)
+	n.ledger.Advance(10 * time.Minute)
+	recovery, err := n.recoverDebited(5*time.Minute, settlement.RecoveryComplete)
+	require.NoError(t, err)
+	require.Equal(t, []string{stale}, recovery.RecoveredPayments)
+}
+
+// statusIndexEntries lists the committed status index keys of one payment
+func (n *network) statusIndexEntries(coll, id string) []string {
+	entries := make([]string, 0)
+	for _, key := range n.ledger.PrivateKeys(coll) {
+		if strings.HasPrefix(key, "\x00status~window~paymentID\x00") && strings.HasSuffix(key, "\x00"+id+"\x00") {
+			entries = append(entries, key)
+		}
+	}
+	return entries
+}
+
+func TestWritePayment_SecondWriteInATransactionMovesItsOwnIndexEntry(t *testing.T) {
+	n := newNetwork(t)
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetQueueTTL(ctx, 3600)
+	}))
+	n.setMultilateralLimit(accessBankMSP, 0)
+	id := n.pay(accessBankMSP, gtBankMSP, 400)
+	n.ledger.Advance(2 * time.Hour)
+	n.setMultilateralLimit(accessBankMSP, 1000)
+
+	// Both calls read the committed QUEUED record, so only the context knows it was batched
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		if err := n.contract.ReleaseQueuedPayment(ctx, id); err != nil {
+			return err
+		}
+		_, err := n.contract.ExpireQueuedPayments(ctx)
+		return err
+	}))
+
+	coll := collectionName(accessBankMSP, gtBankMSP)
+	require.Equal(t, "RETURNED_UNSETTLED", n.payment(id, accessBankMSP, gtBankMSP).Status)
+	entries := n.statusIndexEntries(coll, id)
+	require.Len(t, entries, 1)
+	require.Contains(t, entries[0], "\x00RETURNED_UNSETTLED\x00")
+}
@@ -0,0 +1,130 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// reprioritize submits ReprioritizePayment as msp
+func (n *network) reprioritize(msp, id, priority string) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.ReprioritizePayment(ctx, id, priority)
+	})
+}
+
+// paymentQueue evaluates GetPaymentQueue for payer as the Central Bank
+func (n *network) paymentQueue(payer string) []*settlement.QueueEntry {
+	n.t.Helper()
+	var queue []*settlement.QueueEntry
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		queue, err = n.contract.GetPaymentQueue(ctx, payer)
+		return err
+	}))
+	return queue
+}
+
+// release submits ReleaseQueuedPayment as msp
+func (n *network) release(msp, id string) error {
+	return n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.ReleaseQueuedPayment(ctx, id)
+	})
+}
+
+func TestGetPaymentQueue_OrdersUrgentFirstThenFIFO(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+
+	first := n.pay(accessBankMSP, gtBankMSP, 100)
+	second := n.pay(accessBankMSP, zenithBankMSP, 200)
+	urgent := n.pay(accessBankMSP, firstBankMSP, 300)
+	require.NoError(t, n.reprioritize(accessBankMSP, urgent, "URGENT"))
+
+	queue := n.paymentQueue(accessBankMSP)
+	require.Len(t, queue, 3)
+	require.Equal(t, []string{urgent, first, second}, []string{queue[0].ID, queue[1].ID, queue[2].ID})
+	for i, entry := range queue {
+		require.Equal(t, i+1, entry.Position)
+		require.Equal(t, "limit_exceeded", entry.QueueReason)
+		require.Equal(t, entry.QueuedAt+24*60*60, entry.ExpiresAt)
+	}
+
+	require.ErrorContains(t, n.reprioritize(zenithBankMSP, second, "URGENT"), "only payer bank or Central Bank can reprioritize")
+	require.ErrorContains(t, n.reprioritize(accessBankMSP, second, "HIGH"), "invalid priority")
+	require.NoError(t, n.reprioritize(accessBankMSP, second, "urgent"))
+	queue = n.paymentQueue(accessBankMSP)
+	// Both are URGENT now, so the earlier-queued payment goes first
+	require.Equal(t, "URGENT", queue[0].Priority)
+	require.Equal(t, []string{second, urgent, first}, []string{queue[0].ID, queue[1].ID, queue[2].ID})
+}
+
+func TestReleaseQueuedPayment_HeadOfQueueWithinLimits(t *testing.T) {
+	n := newNetwork(t)
+	n.setMultilateralLimit(accessBankMSP, 0)
+
+	first := n.pay(accessBankMSP, gtBankMSP, 100)
+	second := n.pay(accessBankMSP, zenithBankMSP, 200)
+	urgent := n.pay(accessBankMSP, firstBankMSP, 300)
+	require.NoError(t, n.reprioritize(accessBankMSP, urgent, "URGENT"))
+	n.setMultilateralLimit(accessBankMSP, 350)
+
+	require.ErrorContains(t, n.release(accessBankMSP, first), "is not at the head of the queue (next is "+urgent+")")
+	require.ErrorContains(t, n.release(firstBankMSP, urgent), "only payer bank or Central Bank can release payment")
+
+	require.NoError(t, n.release(accessBankMSP, urgent))
+	pd := n.payment(urgent, accessBankMSP, firstBankMSP)
+	require.Equal(t, "BATCHED", pd.Status)
+	require.Empty(t, pd.QueueReason)
+	require.Equal(t, n.ledger.Now().Unix()/120, pd.BatchWindow)
+	require.Equal(t, "BATCHED", n.stubStatus(urgent))
+
+	// Limits are re-checked even for the Central Bank, which may skip the head
+	require.ErrorContains(t, n.release(centralBankMSP, second), "multilateral net debit 500.00 would exceed limit 350.00")
+	n.setMultilateralLimit(accessBankMSP, 1000)
+	require.NoError(t, n.release(centralBankMSP, second))
+	require.Equal(t, "QUEUED", n.payment(first, accessBankMSP, gtBankMSP).Status)
+
+	require.ErrorContains(t, n.release(centralBankMSP, second), "is not in QUEUED status, current status: BATCHED")
+}
+
+func TestExpireQueuedPayments_ReturnsPaymentsOlderThanTheTTL(t *testing.T) {
+	n := newNetwork(t)
+	err := n.submit(accessBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetQueueTTL(ctx, 3600)
+	})
+	require.ErrorContains(t, err, "only Central Bank can configure the payment queue")
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetQueueTTL(ctx, 3600)
+	}))
+
+	var config *settlement.QueueConfig
+	require.NoError(t, n.evaluate(gtBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		config, err = n.contract.GetQueueConfig(ctx)
+		return err
+	}))
+	require.Equal(t, int64(3600), config.TTLSeconds)
+	require.Equal(t, n.ledger.Now().Unix(), config.UpdatedAt)
+
+	n.setMultilateralLimit(accessBankMSP, 0)
+	stale := n.pay(accessBankMSP, gtBankMSP, 400)
+	n.ledger.Advance(30 * time.Minute)
+	fresh := n.pay(accessBankMSP, zenithBankMSP, 250)
+	n.ledger.Advance(31 * time.Minute)
+
+	var result *settlement.QueueExpiryResult
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		result, err = n.contract.ExpireQueuedPayments(ctx)
+		return err
+	}))
+	require.Equal(t, []string{stale}, result.ExpiredPayments)
+	require.Equal(t, 1, result.ExpiredCount)
+	requireAmount(t, 400, result.ExpiredAmount)
+	require.Equal(t, n.ledger.Now().Unix(), result.Timestamp)
+
+	require.Equal(t, "RETURNED_UNSETTLED", n.payment(stale, accessBankMSP, gtBankMSP).Status)
+	require.Equal(t, "QUEUED", n.payment(fresh, accessBankMSP, zenithBankMSP).Status)
+}
@@ -0,0 +1,185 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// mint submits MintToBank as msp
+func (n *network) mint(msp, bank string, amount float64) (*settlement.MintRecord, error) {
+	var record *settlement.MintRecord
+	err := n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		record, err = n.contract.MintToBank(ctx, bank, amount)
+		return err
+	})
+	return record, err
+}
+
+// burn submits BurnFromBank as msp
+func (n *network) burn(msp, bank string, amount float64) (*settlement.MintRecord, error) {
+	var record *settlement.MintRecord
+	err := n.submit(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		record, err = n.contract.BurnFromBank(ctx, bank, amount)
+		return err
+	})
+	return record, err
+}
+
+// totalSupply evaluates GetTotalSupply as msp
+func (n *network) totalSupply(msp string) *settlement.SupplyRecord {
+	n.t.Helper()
+	var supply *settlement.SupplyRecord
+	require.NoError(n.t, n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		supply, err = n.contract.GetTotalSupply(ctx)
+		return err
+	}))
+	return supply
+}
+
+// supplyInvariant evaluates CheckSupplyInvariant as the Central Bank
+func (n *network) supplyInvariant() *settlement.SupplyInvariantResult {
+	n.t.Helper()
+	var result *settlement.SupplyInvariantResult
+	require.NoError(n.t, n.evaluate(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = n.contract.CheckSupplyInvariant(ctx)
+		return err
+	}))
+	return result
+}
+
+// mintRecords evaluates GetMintRecords for bank as msp
+func (n *network) mintRecords(msp, bank string) ([]*settlement.MintRecord, error) {
+	var records []*settlement.MintRecord
+	err := n.evaluate(msp, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		records, err = n.contract.GetMintRecords(ctx, bank)
+		return err
+	})
+	return records, err
+}
+
+func TestInitLedger_MintsOpeningBalance(t *testing.T) {
+	n := newNetwork(t)
+
+	supply := n.totalSupply(accessBankMSP)
+	requireAmount(t, 4*startingBalance, supply.TotalSupply)
+	requireAmount(t, 4*startingBalance, supply.TotalMinted)
+	require.Zero(t, supply.TotalBurned)
+
+	records, err := n.mintRecords(gtBankMSP, gtBankMSP)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, settlement.MintTypeMint, records[0].Type)
+	require.Equal(t, gtBankMSP, records[0].ToMSP)
+	requireAmount(t, startingBalance, records[0].Amount)
+
+	require.Equal(t, "BankOnboarded", n.ledger.Events()[len(banks)-1].Name)
+	require.True(t, n.supplyInvariant().Holds)
+}
+
+func TestMintAndBurn_MoveTotalSupply(t *testing.T) {
+	n := newNetwork(t)
+
+	minted, err := n.mint(centralBankMSP, zenithBankMSP, 2_500_000)
+	require.NoError(t, err)
+	require.Equal(t, settlement.MintTypeMint, minted.Type)
+	require.Equal(t, zenithBankMSP, minted.ToMSP)
+	requireAmount(t, 4*startingBalance+2_500_000, minted.TotalSupply)
+	requireAmount(t, startingBalance+2_500_000, n.balance(zenithBankMSP))
+
+	burned, err := n.burn(centralBankMSP, firstBankMSP, 1_000_000.5)
+	require.NoError(t, err)
+	require.Equal(t, settlement.MintTypeBurn, burned.Type)
+	require.Equal(t, firstBankMSP, burned.FromMSP)
+	require.Empty(t, burned.ToMSP)
+	requireAmount(t, startingBalance-1_000_000.5, n.balance(firstBankMSP))
+
+	supply := n.totalSupply(zenithBankMSP)
+	requireAmount(t, 4*startingBalance+1_499_999.5, supply.TotalSupply)
+	requireAmount(t, 4*startingBalance+2_500_000, supply.TotalMinted)
+	requireAmount(t, 1_000_000.5, supply.TotalBurned)
+	require.Equal(t, n.ledger.Now().Unix(), supply.UpdatedAt)
+
+	events := n.ledger.Events()
+	require.Equal(t, "EnairaBurned", events[len(events)-1].Name)
+
+	// Settlement between banks leaves the supply where it was
+	n.pay(zenithBankMSP, accessBankMSP, 750_000)
+	n.settleBatch()
+
+	result := n.supplyInvariant()
+	require.True(t, result.Holds)
+	requireAmount(t, supply.TotalSupply, result.TotalBalances)
+	requireAmount(t, startingBalance+750_000, result.Balances[accessBankMSP])
+	require.Len(t, result.Balances, len(banks))
+}
+
+func TestBurnFromBank_RejectsMoreThanBalance(t *testing.T) {
+	n := newNetwork(t)
+
+	_, err := n.burn(centralBankMSP, accessBankMSP, startingBalance+1)
+	require.ErrorContains(t, err, "cannot burn 15000001.00 from AccessBankMSP: balance is only 15000000.00")
+	requireAmount(t, startingBalance, n.balance(accessBankMSP))
+	requireAmount(t, 4*startingBalance, n.totalSupply(centralBankMSP).TotalSupply)
+}
+
+func TestMintToBank_RejectsInvalidAmountsAndUnknownBanks(t *testing.T) {
+	n := newNetwork(t)
+
+	for _, amount := range []float64{0, -50, 10.005} {
+		_, err := n.mint(centralBankMSP, accessBankMSP, amount)
+		require.Error(t, err, "%v", amount)
+	}
+	_, err := n.mint(centralBankMSP, "UnknownBankMSP", 100)
+	require.ErrorContains(t, err, "failed to get settlement account for UnknownBankMSP")
+
+	requireAmount(t, 4*startingBalance, n.totalSupply(centralBankMSP).TotalSupply)
+}
+
+func TestCheckSupplyInvariant_DetectsUnissuedMoney(t *testing.T) {
+	n := newNetwork(t)
+
+	// A write outside the contract adds money no mint accounts for
+	require.NoError(t, n.submit(centralBankMSP, func(ctx contractapi.TransactionContextInterface) error {
+		coll := "col-settlement-" + gtBankMSP
+		accountBytes, err := ctx.GetStub().GetPrivateData(coll, gtBankMSP)
+		if err != nil {
+			return err
+		}
+		var account settlement.BankAccount
+		if err := json.Unmarshal(accountBytes, &account); err != nil {
+			return err
+		}
+		account.Balance += 1234.56
+		accountBytes, err = json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		return ctx.GetStub().PutPrivateData(coll, gtBankMSP, accountBytes)
+	}))
+
+	result := n.supplyInvariant()
+	require.False(t, result.Holds)
+	requireAmount(t, 1234.56, result.Difference)
+}
+
+func TestGetMintRecords_RestrictedToBankAndCentralBank(t *testing.T) {
+	n := newNetwork(t)
+	_, err := n.mint(centralBankMSP, accessBankMSP, 500)
+	require.NoError(t, err)
+
+	records, err := n.mintRecords(centralBankMSP, accessBankMSP)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	_, err = n.mintRecords(gtBankMSP, accessBankMSP)
+	require.ErrorContains(t, err, "GTBankMSP cannot read mint records of AccessBankMSP")
+}
@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"testing"
 
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement"
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement/mocks"
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -26,11 +27,7 @@ func createQueuedPayment(id, payerMSP, payeeMSP string, amount float64) settleme
 
 // Helper function to prepare mocks
 func prepMocks() (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
-	chaincodeStub := &mocks.ChaincodeStubInterface{}
-	transactionContext := &mocks.TransactionContextInterface{}
-	transactionContext.On("GetStub").Return(chaincodeStub)
-	transactionContext.On("GetClientIdentity").Return(nil).Maybe()
-	return transactionContext, chaincodeStub
+	return prepMocksAs(bankAMSP)
 }
 
 // Bank MSPs for testing
@@ -40,6 +37,8 @@ const (
 	bankCMSP = "ZenithBankMSP"
 	bankDMSP = "FirstBankMSP"
 	bankEMSP = "UBABankMSP"
+
+	centralBankMSP = "CentralBankMSP"
 )
 
 // Helper to set bilateral offset update in transient data
@@ -112,12 +111,56 @@ func setupMockIterator(payments []settlement.PaymentDetails) *MockStateQueryIter
 	return iterator
 }
 
+// Helper to serve payments to the contract's reads of a collection: status queries the
+// way CouchDB answers a {"selector":{"status":...}} query, and the range scan update
+// transactions use on a collection whose status index has not been built
+func expectPaymentQuery(chaincodeStub *mocks.ChaincodeStubInterface, collection string, payments []settlement.PaymentDetails) {
+	chaincodeStub.On("GetPrivateDataQueryResult", collection, mock.Anything).Return(
+		func(_ string, query string) (shim.StateQueryIteratorInterface, error) {
+			var q struct {
+				Selector struct {
+					Status string `json:"status"`
+				} `json:"selector"`
+			}
+			if err := json.Unmarshal([]byte(query), &q); err != nil {
+				return nil, err
+			}
+
+			var matched []settlement.PaymentDetails
+			for _, payment := range payments {
+				if payment.Status == q.Selector.Status {
+					matched = append(matched, payment)
+				}
+			}
+			return setupMockIterator(matched), nil
+		}).Maybe()
+	chaincodeStub.On("GetPrivateData", collection, "\x00statusIndexState\x00").Return(nil, nil).Maybe()
+	chaincodeStub.On("GetPrivateDataByRange", collection, "", "").Return(
+		func(string, string, string) (shim.StateQueryIteratorInterface, error) {
+			return setupMockIterator(payments), nil
+		}).Maybe()
+}
+
+// Helper to serve a payment's public stub and accept its updated status
+func expectPublicStubUpdate(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, payment settlement.PaymentDetails) {
+	stubJSON, err := json.Marshal(settlement.PaymentStub{
+		ID:       payment.ID,
+		PayerMSP: payment.PayerMSP,
+		PayeeMSP: payment.PayeeMSP,
+		Status:   payment.Status,
+	})
+	require.NoError(t, err)
+
+	chaincodeStub.On("GetState", payment.ID).Return(stubJSON, nil).Maybe()
+	chaincodeStub.On("PutState", payment.ID, mock.Anything).Return(nil).Maybe()
+}
+
 // =============================================================================
 // Integration Tests for Complete Bilateral Netting Flow
 // =============================================================================
 func TestBilateralNetting_CompleteFlow(t *testing.T) {
-	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	// Setup: the Central Bank applies offsets
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	// Calculate offset
@@ -126,9 +169,8 @@ func TestBilateralNetting_CompleteFlow(t *testing.T) {
 		createQueuedPayment("pay2", bankBMSP, bankAMSP, 800.0),
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	offsetResult, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
 	require.NoError(t, err)
@@ -146,6 +188,9 @@ func TestBilateralNetting_CompleteFlow(t *testing.T) {
 	chaincodeStub.On("GetPrivateData", collectionName, "pay2").Return(pay2JSON, nil)
 	chaincodeStub.On("PutPrivateData", collectionName, "pay1", mock.Anything).Return(nil)
 	chaincodeStub.On("PutPrivateData", collectionName, "pay2", mock.Anything).Return(nil)
+	expectStatusIndexUpdate(chaincodeStub, collectionName)
+	expectPublicStubUpdate(t, chaincodeStub, payments[0])
+	expectPublicStubUpdate(t, chaincodeStub, payments[1])
 	chaincodeStub.On("SetEvent", "BilateralOffsetExecuted", mock.Anything).Return(nil)
 	logGreen(t, " ✓ Offset Calculation operations verified")
 
@@ -3,13 +3,17 @@ package chaincode_test
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement"
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement/mocks"
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // =============================================================================
@@ -53,13 +57,34 @@ func logBlue(t *testing.T, message string) {
 
 // Helper function to prepare mocks
 func prepPaymentMocks() (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
+	return prepMocksAs(accessBankMSP)
+}
+
+// prepMocksAs prepares mocks for a client of msp. Ledger plumbing the tests don't assert
+// on (timestamps, composite keys, the channel's settlement mode) gets defaults.
+func prepMocksAs(msp string) (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
 	chaincodeStub := &mocks.ChaincodeStubInterface{}
 	transactionContext := &mocks.TransactionContextInterface{}
 	transactionContext.On("GetStub").Return(chaincodeStub)
-	transactionContext.On("GetClientIdentity").Return(nil).Maybe()
+	transactionContext.On("GetClientIdentity").Return(memstub.NewIdentity(msp)).Maybe()
+
+	chaincodeStub.On("GetTxID").Return("tx-123").Maybe()
+	chaincodeStub.On("GetTxTimestamp").Return(timestamppb.Now(), nil).Maybe()
+	chaincodeStub.On("CreateCompositeKey", mock.Anything, mock.Anything).Return(shim.CreateCompositeKey).Maybe()
+	chaincodeStub.On("GetState", "SETTLEMENT_CONFIG").Return(nil, nil).Maybe()
+	// No participant has been suspended, so every bank is ACTIVE
+	chaincodeStub.On("GetState", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "\x00participant\x00")
+	})).Return(nil, nil).Maybe()
 	return transactionContext, chaincodeStub
 }
 
+// expectStatusIndexUpdate accepts the status index writes that accompany a payment update
+func expectStatusIndexUpdate(chaincodeStub *mocks.ChaincodeStubInterface, collection string) {
+	chaincodeStub.On("PutPrivateData", collection, mock.Anything, mock.Anything).Return(nil).Maybe()
+	chaincodeStub.On("DelPrivateData", collection, mock.Anything).Return(nil).Maybe()
+}
+
 // Helper function to create test payment details
 func createTestPaymentDetails() settlement.PaymentDetails {
 	return settlement.PaymentDetails{
@@ -136,7 +161,9 @@ func TestCreatePaymentAndRetrieve_Success_EndToEndFlow(t *testing.T) {
 	expectedCollection := getCollectionName(testPayment.PayerMSP, testPayment.PayeeMSP)
 
 	// Mock successful creation operations
+	chaincodeStub.On("GetPrivateData", expectedCollection, testPayment.ID).Return(nil, nil)
 	chaincodeStub.On("PutPrivateData", expectedCollection, testPayment.ID, mock.Anything).Return(nil)
+	expectStatusIndexUpdate(chaincodeStub, expectedCollection)
 	chaincodeStub.On("PutState", testPayment.ID, mock.Anything).Return(nil)
 	chaincodeStub.On("SetEvent", "PaymentPending", mock.Anything).Return(nil)
 
@@ -167,9 +194,8 @@ func TestCreatePaymentAndRetrieve_Success_EndToEndFlow(t *testing.T) {
 	require.NotNil(t, storedStubData, "Stub data should be captured from creation")
 	logGreen(t, " ✓ Created payment data captured for retrieval phase")
 
-	// Clear previous mock expectations and set up for retrieval
-	chaincodeStub.ExpectedCalls = nil
-	chaincodeStub.Calls = nil
+	// The payee retrieves the payment with fresh mocks
+	transactionContext, chaincodeStub = prepMocksAs(testPayment.PayeeMSP)
 
 	// Mock retrieval operations using the stored data
 	chaincodeStub.On("GetState", testPayment.ID).Return(storedStubData, nil)
@@ -0,0 +1,64 @@
+package memstub
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Identity is a client identity for test transactions. It implements cid.ClientIdentity.
+type Identity struct {
+	MSPID      string
+	ID         string
+	Attributes map[string]string
+}
+
+// NewIdentity returns an identity of the given MSP with a derived user ID
+func NewIdentity(mspID string) *Identity {
+	return &Identity{
+		MSPID:      mspID,
+		ID:         fmt.Sprintf("x509::CN=user@%s", mspID),
+		Attributes: make(map[string]string),
+	}
+}
+
+// GetID returns the ID of the identity
+func (i *Identity) GetID() (string, error) {
+	return i.ID, nil
+}
+
+// GetMSPID returns the MSP the identity belongs to
+func (i *Identity) GetMSPID() (string, error) {
+	return i.MSPID, nil
+}
+
+// GetAttributeValue returns the value of an attribute, if the identity has it
+func (i *Identity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := i.Attributes[attrName]
+	return value, found, nil
+}
+
+// AssertAttributeValue fails unless the identity has the attribute with the given value
+func (i *Identity) AssertAttributeValue(attrName, attrValue string) error {
+	value, found := i.Attributes[attrName]
+	if !found {
+		return fmt.Errorf("attribute '%s' was not found", attrName)
+	}
+	if value != attrValue {
+		return fmt.Errorf("attribute '%s' equals '%s', not '%s'", attrName, value, attrValue)
+	}
+	return nil
+}
+
+// GetX509Certificate returns nil: test identities carry no certificate
+func (i *Identity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// serialize encodes the identity the way a proposal carries its creator
+func (i *Identity) serialize() ([]byte, error) {
+	return proto.Marshal(protoadapt.MessageV2Of(&msp.SerializedIdentity{Mspid: i.MSPID, IdBytes: []byte(i.ID)}))
+}
@@ -0,0 +1,70 @@
+package memstub
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// errIteratorExhausted is returned by Next past the last result
+var errIteratorExhausted = errors.New("no more results")
+
+// iterator serves a snapshot of query results
+type iterator struct {
+	kvs    []*queryresult.KV
+	pos    int
+	closed bool
+}
+
+func newIterator(kvs []*queryresult.KV) *iterator {
+	return &iterator{kvs: kvs}
+}
+
+// HasNext reports whether another result is available
+func (it *iterator) HasNext() bool {
+	return !it.closed && it.pos < len(it.kvs)
+}
+
+// Next returns the next result
+func (it *iterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errIteratorExhausted
+	}
+	kv := it.kvs[it.pos]
+	it.pos++
+	return kv, nil
+}
+
+// Close releases the iterator
+func (it *iterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// historyIterator serves the history of one key
+type historyIterator struct {
+	mods   []*queryresult.KeyModification
+	pos    int
+	closed bool
+}
+
+// HasNext reports whether another modification is available
+func (it *historyIterator) HasNext() bool {
+	return !it.closed && it.pos < len(it.mods)
+}
+
+// Next returns the next modification
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errIteratorExhausted
+	}
+	mod := it.mods[it.pos]
+	it.pos++
+	return mod, nil
+}
+
+// Close releases the iterator
+func (it *historyIterator) Close() error {
+	it.closed = true
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package memstub is an in-memory Fabric ledger for chaincode tests. It implements
+// shim.ChaincodeStubInterface with the semantics of a peer: writes are buffered until
+// the transaction commits and are not visible to its own reads, private collections
+// enforce membership, a transaction carries at most one event, and rich queries either
+// fail like LevelDB or are evaluated like CouchDB.
+package memstub
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Backend selects how the ledger answers rich (Mango) queries
+type Backend string
+
+const (
+	// LevelDB rejects rich queries, like a peer with the default state database
+	LevelDB Backend = "leveldb"
+	// CouchDB evaluates the selector of rich queries
+	CouchDB Backend = "couchdb"
+)
+
+// Collection holds the membership rules of a private data collection
+type Collection struct {
+	Name            string
+	Members         []string
+	MemberOnlyRead  bool
+	MemberOnlyWrite bool
+}
+
+// Event is the event committed by one transaction
+type Event struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+// Ledger is the committed world state shared by every transaction
+type Ledger struct {
+	// Backend answers rich queries; the zero value behaves like LevelDB
+	Backend Backend
+	// RejectUnvalidatedReads fails the commit of a transaction that writes after a rich
+	// query. Peers accept such transactions but never re-check the query results at
+	// validation, so tests set it to keep rich queries out of update transactions.
+	RejectUnvalidatedReads bool
+	// ContextHandler is the context type each transaction receives, as returned by a
+	// contract's GetTransactionContextHandler; nil means a plain TransactionContext
+	ContextHandler contractapi.SettableTransactionContextInterface
+
+	state       map[string][]byte
+	history     map[string][]historyEntry
+	private     map[string]map[string][]byte
+	collections map[string]*Collection
+	validation  map[string][]byte
+	events      []Event
+	now         time.Time
+	txCount     int
+}
+
+// historyEntry is one committed write of a public key
+type historyEntry struct {
+	txID      string
+	value     []byte
+	timestamp time.Time
+	isDelete  bool
+}
+
+// NewLedger returns an empty ledger whose clock starts at start
+func NewLedger(start time.Time) *Ledger {
+	return &Ledger{
+		Backend:     LevelDB,
+		state:       make(map[string][]byte),
+		history:     make(map[string][]historyEntry),
+		private:     make(map[string]map[string][]byte),
+		collections: make(map[string]*Collection),
+		validation:  make(map[string][]byte),
+		now:         start,
+	}
+}
+
+// AddCollection defines a private data collection. Reads and writes by non-members
+// fail when the matching member-only flag is set.
+func (l *Ledger) AddCollection(c Collection) {
+	coll := c
+	l.collections[c.Name] = &coll
+	if l.private[c.Name] == nil {
+		l.private[c.Name] = make(map[string][]byte)
+	}
+}
+
+// Now returns the ledger clock, used as the timestamp of the next transaction
+func (l *Ledger) Now() time.Time {
+	return l.now
+}
+
+// Advance moves the ledger clock forward
+func (l *Ledger) Advance(d time.Duration) {
+	l.now = l.now.Add(d)
+}
+
+// SetTime moves the ledger clock to t
+func (l *Ledger) SetTime(t time.Time) {
+	l.now = t
+}
+
+// Events returns the events of every committed transaction, oldest first
+func (l *Ledger) Events() []Event {
+	return append([]Event(nil), l.events...)
+}
+
+// State returns the committed value of a public key
+func (l *Ledger) State(key string) []byte {
+	return l.state[key]
+}
+
+// PrivateData returns the committed value of a key in a collection
+func (l *Ledger) PrivateData(collection, key string) []byte {
+	return l.private[collection][key]
+}
+
+// PrivateKeys lists the committed keys of a collection in key order
+func (l *Ledger) PrivateKeys(collection string) []string {
+	return sortedKeys(l.private[collection])
+}
+
+// NewTransaction starts a transaction submitted by identity. Writes reach the
+// ledger only through Commit.
+func (l *Ledger) NewTransaction(identity *Identity, function string, args ...string) *Stub {
+	l.txCount++
+	return &Stub{
+		ledger:    l,
+		identity:  identity,
+		txID:      fmt.Sprintf("tx%06d", l.txCount),
+		timestamp: l.now,
+		args:      append([]string{function}, args...),
+		transient: make(map[string][]byte),
+		writes:    make(map[string]*write),
+		pvtWrites: make(map[string]map[string]*write),
+	}
+}
+
+// Context wraps a stub in the transaction context contract functions receive. Like the
+// contract API, it creates a fresh context of the ledger's ContextHandler type.
+func Context(stub *Stub) contractapi.TransactionContextInterface {
+	var ctx contractapi.SettableTransactionContextInterface = new(contractapi.TransactionContext)
+	if handler := stub.ledger.ContextHandler; handler != nil {
+		ctx = reflect.New(reflect.TypeOf(handler).Elem()).Interface().(contractapi.SettableTransactionContextInterface)
+	}
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(stub.identity)
+	return ctx.(contractapi.TransactionContextInterface)
+}
+
+// Submit runs fn as one transaction and commits its writes when it succeeds
+func (l *Ledger) Submit(identity *Identity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return l.SubmitWithTransient(identity, nil, fn)
+}
+
+// SubmitWithTransient runs fn as one transaction with a transient map and commits its
+// writes when it succeeds
+func (l *Ledger) SubmitWithTransient(identity *Identity, transient map[string][]byte, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	stub := l.NewTransaction(identity, "")
+	for k, v := range transient {
+		stub.transient[k] = v
+	}
+	if err := fn(Context(stub)); err != nil {
+		return err
+	}
+	return stub.Commit()
+}
+
+// Evaluate runs fn as a query: it sees the committed state and its writes are discarded
+func (l *Ledger) Evaluate(identity *Identity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return fn(Context(l.NewTransaction(identity, "")))
+}
+
+// collectionFor returns the collection definition or an error naming the unknown collection
+func (l *Ledger) collectionFor(name string) (*Collection, error) {
+	c, ok := l.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %s could not be found", name)
+	}
+	return c, nil
+}
+
+// isMember reports whether the MSP belongs to the collection
+func (c *Collection) isMember(mspID string) bool {
+	for _, member := range c.Members {
+		if member == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in lexical order
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
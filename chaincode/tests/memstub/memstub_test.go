@@ -0,0 +1,286 @@
+package memstub_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+func newTestLedger() *memstub.Ledger {
+	ledger := memstub.NewLedger(start)
+	ledger.AddCollection(memstub.Collection{
+		Name:            "col-AccessBankMSP-GTBankMSP",
+		Members:         []string{"AccessBankMSP", "GTBankMSP", "CentralBankMSP"},
+		MemberOnlyRead:  true,
+		MemberOnlyWrite: true,
+	})
+	return ledger
+}
+
+func drain(t *testing.T, iter shim.StateQueryIteratorInterface) []string {
+	t.Helper()
+	defer iter.Close()
+
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestWritesAreInvisibleUntilCommit(t *testing.T) {
+	ledger := newTestLedger()
+	stub := ledger.NewTransaction(memstub.NewIdentity("AccessBankMSP"), "Put")
+
+	require.NoError(t, stub.PutState("k", []byte("v1")))
+	value, err := stub.GetState("k")
+	require.NoError(t, err)
+	require.Nil(t, value, "a transaction must not read its own writes")
+
+	require.NoError(t, stub.Commit())
+	require.Equal(t, []byte("v1"), ledger.State("k"))
+	require.Error(t, stub.Commit())
+}
+
+func TestFailedSubmitDiscardsWrites(t *testing.T) {
+	ledger := newTestLedger()
+	identity := memstub.NewIdentity("AccessBankMSP")
+
+	err := ledger.Submit(identity, func(ctx contractapi.TransactionContextInterface) error {
+		require.NoError(t, ctx.GetStub().PutState("k", []byte("v")))
+		return errors.New("endorsement failed")
+	})
+	require.Error(t, err)
+	require.Nil(t, ledger.State("k"))
+
+	err = ledger.Evaluate(identity, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutState("k", []byte("v"))
+	})
+	require.NoError(t, err)
+	require.Nil(t, ledger.State("k"), "evaluated transactions are never committed")
+}
+
+func TestCollectionMembership(t *testing.T) {
+	ledger := newTestLedger()
+	coll := "col-AccessBankMSP-GTBankMSP"
+
+	member := ledger.NewTransaction(memstub.NewIdentity("GTBankMSP"), "Put")
+	require.NoError(t, member.PutPrivateData(coll, "p1", []byte(`{"id":"p1"}`)))
+	require.NoError(t, member.Commit())
+
+	outsider := ledger.NewTransaction(memstub.NewIdentity("ZenithBankMSP"), "Get")
+	_, err := outsider.GetPrivateData(coll, "p1")
+	require.ErrorContains(t, err, "read access")
+	require.ErrorContains(t, outsider.PutPrivateData(coll, "p2", []byte("x")), "write access")
+
+	hash, err := outsider.GetPrivateDataHash(coll, "p1")
+	require.NoError(t, err)
+	require.Len(t, hash, 32)
+
+	_, err = member.GetPrivateData("col-unknown", "p1")
+	require.ErrorContains(t, err, "could not be found")
+}
+
+func TestRangeScansSkipCompositeKeys(t *testing.T) {
+	ledger := newTestLedger()
+	coll := "col-AccessBankMSP-GTBankMSP"
+	stub := ledger.NewTransaction(memstub.NewIdentity("CentralBankMSP"), "Seed")
+
+	indexKey, err := stub.CreateCompositeKey("status~id", []string{"QUEUED", "p1"})
+	require.NoError(t, err)
+	otherKey, err := stub.CreateCompositeKey("status~id", []string{"SETTLED", "p2"})
+	require.NoError(t, err)
+	for _, key := range []string{"p2", "p1", indexKey, otherKey} {
+		require.NoError(t, stub.PutPrivateData(coll, key, []byte(`{}`)))
+	}
+	require.NoError(t, stub.Commit())
+
+	reader := ledger.NewTransaction(memstub.NewIdentity("AccessBankMSP"), "Read")
+	iter, err := reader.GetPrivateDataByRange(coll, "", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"p1", "p2"}, drain(t, iter))
+
+	iter, err = reader.GetPrivateDataByPartialCompositeKey(coll, "status~id", []string{"QUEUED"})
+	require.NoError(t, err)
+	keys := drain(t, iter)
+	require.Equal(t, []string{indexKey}, keys)
+
+	objectType, attrs, err := reader.SplitCompositeKey(keys[0])
+	require.NoError(t, err)
+	require.Equal(t, "status~id", objectType)
+	require.Equal(t, []string{"QUEUED", "p1"}, attrs)
+}
+
+func TestRichQueriesFollowTheBackend(t *testing.T) {
+	ledger := newTestLedger()
+	coll := "col-AccessBankMSP-GTBankMSP"
+	stub := ledger.NewTransaction(memstub.NewIdentity("AccessBankMSP"), "Seed")
+	for id, doc := range map[string]map[string]interface{}{
+		"p1": {"status": "BATCHED", "batchWindow": 7, "amount": 100},
+		"p2": {"status": "BATCHED", "batchWindow": 8, "amount": 250},
+		"p3": {"status": "SETTLED", "batchWindow": 7, "amount": 50},
+	} {
+		docBytes, _ := json.Marshal(doc)
+		require.NoError(t, stub.PutPrivateData(coll, id, docBytes))
+	}
+	require.NoError(t, stub.Commit())
+
+	reader := ledger.NewTransaction(memstub.NewIdentity("GTBankMSP"), "Query")
+	_, err := reader.GetPrivateDataQueryResult(coll, `{"selector":{"status":"BATCHED"}}`)
+	require.ErrorContains(t, err, "not supported for leveldb")
+
+	ledger.Backend = memstub.CouchDB
+	for query, want := range map[string][]string{
+		`{"selector":{"status":"BATCHED"}}`:                          {"p1", "p2"},
+		`{"selector":{"status":"BATCHED","batchWindow":7}}`:          {"p1"},
+		`{"selector":{"status":{"$gt":""}}}`:                         {"p1", "p2", "p3"},
+		`{"selector":{"amount":{"$gte":100,"$lt":250}}}`:             {"p1"},
+		`{"selector":{"$or":[{"status":"SETTLED"},{"amount":250}]}}`: {"p2", "p3"},
+		`{"selector":{"status":{"$in":["SETTLED","QUEUED"]}}}`:       {"p3"},
+		`{"selector":{"queueReason":{"$exists":false},"amount":50}}`: {"p3"},
+	} {
+		iter, err := reader.GetPrivateDataQueryResult(coll, query)
+		require.NoError(t, err, query)
+		require.Equal(t, want, drain(t, iter), query)
+	}
+}
+
+func TestRejectUnvalidatedReads(t *testing.T) {
+	ledger := newTestLedger()
+	ledger.Backend = memstub.CouchDB
+	ledger.RejectUnvalidatedReads = true
+	coll := "col-AccessBankMSP-GTBankMSP"
+	identity := memstub.NewIdentity("AccessBankMSP")
+
+	query := func(ctx contractapi.TransactionContextInterface) error {
+		iter, err := ctx.GetStub().GetPrivateDataQueryResult(coll, `{"selector":{"status":"BATCHED"}}`)
+		if err != nil {
+			return err
+		}
+		return iter.Close()
+	}
+
+	// Queries alone commit, and so do writes after range reads
+	require.NoError(t, ledger.Submit(identity, query))
+	require.NoError(t, ledger.Submit(identity, func(ctx contractapi.TransactionContextInterface) error {
+		iter, err := ctx.GetStub().GetPrivateDataByRange(coll, "", "")
+		if err != nil {
+			return err
+		}
+		iter.Close()
+		return ctx.GetStub().PutPrivateData(coll, "p1", []byte(`{"status":"BATCHED"}`))
+	}))
+
+	err := ledger.Submit(identity, func(ctx contractapi.TransactionContextInterface) error {
+		if err := query(ctx); err != nil {
+			return err
+		}
+		return ctx.GetStub().PutPrivateData(coll, "p2", []byte(`{"status":"BATCHED"}`))
+	})
+	require.ErrorContains(t, err, "writes after a rich query")
+	require.Nil(t, ledger.PrivateData(coll, "p2"))
+}
+
+// countingContext stands in for a contract's own transaction context type
+type countingContext struct {
+	contractapi.TransactionContext
+	calls int
+}
+
+func TestContextHandlerGivesEachTransactionAFreshContext(t *testing.T) {
+	ledger := newTestLedger()
+	ledger.ContextHandler = new(countingContext)
+	identity := memstub.NewIdentity("AccessBankMSP")
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ledger.Submit(identity, func(ctx contractapi.TransactionContextInterface) error {
+			counting, ok := ctx.(*countingContext)
+			require.True(t, ok)
+			counting.calls++
+			require.Equal(t, 1, counting.calls)
+
+			mspID, err := ctx.GetClientIdentity().GetMSPID()
+			require.NoError(t, err)
+			require.Equal(t, "AccessBankMSP", mspID)
+			return ctx.GetStub().PutState("k", []byte("v"))
+		}))
+	}
+	require.Equal(t, []byte("v"), ledger.State("k"))
+}
+
+func TestTransientEventsAndTimestamps(t *testing.T) {
+	ledger := newTestLedger()
+	identity := memstub.NewIdentity("CentralBankMSP")
+
+	ledger.Advance(90 * time.Second)
+	err := ledger.SubmitWithTransient(identity, map[string][]byte{"nettingOffsets": []byte(`{}`)}, func(ctx contractapi.TransactionContextInterface) error {
+		transient, err := ctx.GetStub().GetTransient()
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{}`), transient["nettingOffsets"])
+
+		ts, err := ctx.GetStub().GetTxTimestamp()
+		require.NoError(t, err)
+		require.Equal(t, start.Add(90*time.Second).Unix(), ts.GetSeconds())
+
+		mspID, err := ctx.GetClientIdentity().GetMSPID()
+		require.NoError(t, err)
+		require.Equal(t, "CentralBankMSP", mspID)
+
+		require.NoError(t, ctx.GetStub().SetEvent("First", []byte("1")))
+		return ctx.GetStub().SetEvent("Second", []byte("2"))
+	})
+	require.NoError(t, err)
+
+	events := ledger.Events()
+	require.Len(t, events, 1, "a transaction carries a single event")
+	require.Equal(t, "Second", events[0].Name)
+	require.Equal(t, []byte("2"), events[0].Payload)
+}
+
+func TestHistoryAndPagination(t *testing.T) {
+	ledger := newTestLedger()
+	identity := memstub.NewIdentity("CentralBankMSP")
+
+	for _, value := range []string{"a", "b"} {
+		stub := ledger.NewTransaction(identity, "Put")
+		require.NoError(t, stub.PutState("k1", []byte(value)))
+		require.NoError(t, stub.PutState("k2", []byte(value)))
+		require.NoError(t, stub.PutState("k3", []byte(value)))
+		require.NoError(t, stub.Commit())
+	}
+
+	reader := ledger.NewTransaction(identity, "History")
+	history, err := reader.GetHistoryForKey("k1")
+	require.NoError(t, err)
+	var values []string
+	for history.HasNext() {
+		mod, err := history.Next()
+		require.NoError(t, err)
+		values = append(values, string(mod.Value))
+	}
+	require.Equal(t, []string{"a", "b"}, values)
+
+	_, _, err = reader.GetStateByRangeWithPagination("", "", 2, "")
+	require.Error(t, err, "paginated queries need a read-only transaction")
+
+	reader.SetReadOnly(true)
+	iter, metadata, err := reader.GetStateByRangeWithPagination("", "", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"k1", "k2"}, drain(t, iter))
+	require.Equal(t, "k3", metadata.Bookmark)
+
+	iter, metadata, err = reader.GetStateByRangeWithPagination("", "", 2, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"k3"}, drain(t, iter))
+	require.Empty(t, metadata.Bookmark)
+}
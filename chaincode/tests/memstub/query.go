@@ -0,0 +1,239 @@
+package memstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// richQuery evaluates the selector of a CouchDB query over JSON values in key order.
+// It supports field equality, dotted field paths, $eq, $ne, $gt, $gte, $lt, $lte, $in,
+// $nin, $exists, $and, $or and $not, plus limit. Values that are not JSON objects never match.
+func richQuery(data map[string][]byte, query string) ([]*queryresult.KV, error) {
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+		Limit    int                    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+	if q.Selector == nil {
+		return nil, fmt.Errorf("query %s has no selector", query)
+	}
+
+	var kvs []*queryresult.KV
+	for _, kv := range rangeOf(data, "", "") {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(kv.Value, &doc); err != nil {
+			continue
+		}
+		ok, err := matchSelector(doc, q.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kvs = append(kvs, kv)
+			if q.Limit > 0 && len(kvs) == q.Limit {
+				break
+			}
+		}
+	}
+	return kvs, nil
+}
+
+// matchSelector reports whether a document satisfies every clause of a selector
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, cond := range selector {
+		var ok bool
+		var err error
+
+		switch field {
+		case "$and", "$or":
+			ok, err = matchCombination(doc, field, cond)
+		case "$not":
+			sub, isMap := cond.(map[string]interface{})
+			if !isMap {
+				return false, fmt.Errorf("$not expects a selector")
+			}
+			ok, err = matchSelector(doc, sub)
+			ok = !ok
+		default:
+			value, present := lookupField(doc, field)
+			ok, err = matchCondition(value, present, cond)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchCombination evaluates $and and $or over a list of selectors
+func matchCombination(doc map[string]interface{}, op string, cond interface{}) (bool, error) {
+	list, ok := cond.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s expects an array of selectors", op)
+	}
+	for _, item := range list {
+		sub, ok := item.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s expects an array of selectors", op)
+		}
+		matched, err := matchSelector(doc, sub)
+		if err != nil {
+			return false, err
+		}
+		if op == "$or" && matched {
+			return true, nil
+		}
+		if op == "$and" && !matched {
+			return false, nil
+		}
+	}
+	return op == "$and", nil
+}
+
+// matchCondition applies a field condition: a plain value means equality
+func matchCondition(value interface{}, present bool, cond interface{}) (bool, error) {
+	ops, isOps := cond.(map[string]interface{})
+	if !isOps || !hasOperators(ops) {
+		return present && compare(value, cond) == 0, nil
+	}
+
+	for op, arg := range ops {
+		var ok bool
+		switch op {
+		case "$eq":
+			ok = present && compare(value, arg) == 0
+		case "$ne":
+			ok = !present || compare(value, arg) != 0
+		case "$gt":
+			ok = present && compare(value, arg) > 0
+		case "$gte":
+			ok = present && compare(value, arg) >= 0
+		case "$lt":
+			ok = present && compare(value, arg) < 0
+		case "$lte":
+			ok = present && compare(value, arg) <= 0
+		case "$in", "$nin":
+			list, isList := arg.([]interface{})
+			if !isList {
+				return false, fmt.Errorf("%s expects an array", op)
+			}
+			found := false
+			for _, item := range list {
+				if present && compare(value, item) == 0 {
+					found = true
+					break
+				}
+			}
+			ok = found == (op == "$in")
+		case "$exists":
+			want, isBool := arg.(bool)
+			if !isBool {
+				return false, fmt.Errorf("$exists expects a boolean")
+			}
+			ok = present == want
+		default:
+			return false, fmt.Errorf("unsupported operator %s", op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hasOperators reports whether a condition object is made of operators rather than a literal object
+func hasOperators(cond map[string]interface{}) bool {
+	for key := range cond {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField resolves a dotted field path in a document
+func lookupField(doc map[string]interface{}, field string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(field, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// compare orders two JSON values using CouchDB collation between types:
+// null < false < true < numbers < strings < arrays < objects
+func compare(a, b interface{}) int {
+	ra, rb := collationRank(a), collationRank(b)
+	if ra != rb {
+		return sign(float64(ra - rb))
+	}
+
+	switch av := a.(type) {
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		}
+		if !av {
+			return -1
+		}
+		return 1
+	case float64:
+		return sign(av - b.(float64))
+	case string:
+		return strings.Compare(av, b.(string))
+	case nil:
+		return 0
+	default:
+		// arrays and objects compare by their JSON encoding
+		aj, _ := json.Marshal(a)
+		bj, _ := json.Marshal(b)
+		return strings.Compare(string(aj), string(bj))
+	}
+}
+
+// collationRank places a JSON value's type in CouchDB collation order
+func collationRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// sign maps a difference to -1, 0 or 1
+func sign(d float64) int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	default:
+		return 0
+	}
+}
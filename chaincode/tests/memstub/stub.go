@@ -0,0 +1,532 @@
+package memstub
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// emptyKeySubstitute replaces an empty start key so range scans skip composite keys
+	emptyKeySubstitute = "\x01"
+	// maxUnicodeRune closes the range of a partial composite key
+	maxUnicodeRune = "\U0010FFFF"
+)
+
+// errReadOnlyQuery matches the peer's rejection of paginated queries in a submitted transaction
+var errReadOnlyQuery = errors.New("paginated queries are only supported in a read only transaction")
+
+// write is a buffered put or delete
+type write struct {
+	value    []byte
+	isDelete bool
+}
+
+// Stub is one transaction against a Ledger. It implements shim.ChaincodeStubInterface.
+type Stub struct {
+	ledger    *Ledger
+	identity  *Identity
+	txID      string
+	timestamp time.Time
+	args      []string
+	transient map[string][]byte
+
+	writes     map[string]*write
+	pvtWrites  map[string]map[string]*write
+	writeOrder []string
+	event      *pb.ChaincodeEvent
+	committed  bool
+	readOnly   bool
+	// richQueried records that the transaction read through a rich query
+	richQueried bool
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// SetTransient sets a transient field of the proposal
+func (s *Stub) SetTransient(key string, value []byte) {
+	s.transient[key] = value
+}
+
+// SetReadOnly marks the transaction as an evaluation, which allows paginated queries
+func (s *Stub) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// Event returns the event set by the transaction, or nil
+func (s *Stub) Event() *pb.ChaincodeEvent {
+	return s.event
+}
+
+// Commit applies the buffered writes and the event to the ledger
+func (s *Stub) Commit() error {
+	if s.committed {
+		return fmt.Errorf("transaction %s already committed", s.txID)
+	}
+	s.committed = true
+
+	if s.ledger.RejectUnvalidatedReads && s.richQueried && (len(s.writes) > 0 || len(s.pvtWrites) > 0) {
+		return fmt.Errorf("transaction %s writes after a rich query, whose results are not re-validated at commit", s.txID)
+	}
+
+	for _, key := range s.writeOrder {
+		w := s.writes[key]
+		if w.isDelete {
+			delete(s.ledger.state, key)
+		} else {
+			s.ledger.state[key] = w.value
+		}
+		s.ledger.history[key] = append(s.ledger.history[key], historyEntry{
+			txID:      s.txID,
+			value:     w.value,
+			timestamp: s.timestamp,
+			isDelete:  w.isDelete,
+		})
+	}
+
+	for coll, writes := range s.pvtWrites {
+		for key, w := range writes {
+			if w.isDelete {
+				delete(s.ledger.private[coll], key)
+			} else {
+				s.ledger.private[coll][key] = w.value
+			}
+		}
+	}
+
+	if s.event != nil {
+		s.ledger.events = append(s.ledger.events, Event{TxID: s.txID, Name: s.event.EventName, Payload: s.event.Payload})
+	}
+	return nil
+}
+
+// GetArgs returns the function name and arguments as bytes
+func (s *Stub) GetArgs() [][]byte {
+	args := make([][]byte, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+
+// GetStringArgs returns the function name and arguments
+func (s *Stub) GetStringArgs() []string {
+	return append([]string(nil), s.args...)
+}
+
+// GetFunctionAndParameters splits the arguments into function name and parameters
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	if len(s.args) == 0 {
+		return "", []string{}
+	}
+	return s.args[0], append([]string{}, s.args[1:]...)
+}
+
+// GetArgsSlice returns the arguments concatenated
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	var slice []byte
+	for _, arg := range s.GetArgs() {
+		slice = append(slice, arg...)
+	}
+	return slice, nil
+}
+
+// GetTxID returns the transaction ID
+func (s *Stub) GetTxID() string {
+	return s.txID
+}
+
+// GetChannelID returns the test channel name
+func (s *Stub) GetChannelID() string {
+	return "testchannel"
+}
+
+// InvokeChaincode is not supported: the ledger holds a single chaincode
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	return shim.Error(fmt.Sprintf("chaincode-to-chaincode calls are not supported (called %s)", chaincodeName))
+}
+
+// GetState returns the committed value of a key; the transaction's own writes are not visible
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.ledger.state[key], nil
+}
+
+// PutState buffers a write of a public key
+func (s *Stub) PutState(key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		return s.DelState(key)
+	}
+	s.bufferWrite(key, &write{value: append([]byte(nil), value...)})
+	return nil
+}
+
+// DelState buffers a delete of a public key
+func (s *Stub) DelState(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	s.bufferWrite(key, &write{isDelete: true})
+	return nil
+}
+
+// SetStateValidationParameter stores a key-level endorsement policy
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	s.ledger.validation[key] = ep
+	return nil
+}
+
+// GetStateValidationParameter returns a key-level endorsement policy
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.ledger.validation[key], nil
+}
+
+// GetStateByRange iterates committed public keys in [startKey, endKey)
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if err := validateRange(startKey, endKey); err != nil {
+		return nil, err
+	}
+	return newIterator(rangeOf(s.ledger.state, startKey, endKey)), nil
+}
+
+// GetStateByRangeWithPagination iterates one page of committed public keys
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if !s.readOnly {
+		return nil, nil, errReadOnlyQuery
+	}
+	if err := validateRange(startKey, endKey); err != nil {
+		return nil, nil, err
+	}
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	return paginate(rangeOf(s.ledger.state, startKey, endKey), pageSize)
+}
+
+// GetStateByPartialCompositeKey iterates committed public keys sharing a composite key prefix
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeOf(s.ledger.state, prefix, prefix+maxUnicodeRune)), nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination iterates one page of a composite key prefix
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if !s.readOnly {
+		return nil, nil, errReadOnlyQuery
+	}
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	startKey := prefix
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	return paginate(rangeOf(s.ledger.state, startKey, prefix+maxUnicodeRune), pageSize)
+}
+
+// CreateCompositeKey builds a composite key exactly like the shim
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits a composite key into its object type and attributes
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, "\x00") {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	parts := strings.Split(strings.TrimSuffix(compositeKey[1:], "\x00"), "\x00")
+	return parts[0], parts[1:], nil
+}
+
+// GetQueryResult runs a rich query over committed public state
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	if s.ledger.Backend != CouchDB {
+		return nil, errors.New("ExecuteQuery not supported for leveldb")
+	}
+	kvs, err := richQuery(s.ledger.state, query)
+	if err != nil {
+		return nil, err
+	}
+	s.richQueried = true
+	return newIterator(kvs), nil
+}
+
+// GetQueryResultWithPagination runs a rich query and returns one page
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if !s.readOnly {
+		return nil, nil, errReadOnlyQuery
+	}
+	if s.ledger.Backend != CouchDB {
+		return nil, nil, errors.New("ExecuteQuery not supported for leveldb")
+	}
+	kvs, err := richQuery(s.ledger.state, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bookmark != "" {
+		for i, kv := range kvs {
+			if kv.Key >= bookmark {
+				kvs = kvs[i:]
+				break
+			}
+		}
+	}
+	return paginate(kvs, pageSize)
+}
+
+// GetHistoryForKey returns every committed write of a public key, oldest first
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	entries := s.ledger.history[key]
+	mods := make([]*queryresult.KeyModification, 0, len(entries))
+	for _, entry := range entries {
+		mods = append(mods, &queryresult.KeyModification{
+			TxId:      entry.txID,
+			Value:     entry.value,
+			Timestamp: timestamppb.New(entry.timestamp),
+			IsDelete:  entry.isDelete,
+		})
+	}
+	return &historyIterator{mods: mods}, nil
+}
+
+// GetPrivateData returns the committed value of a key in a collection
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	if err := s.checkRead(collection); err != nil {
+		return nil, err
+	}
+	return s.ledger.private[collection][key], nil
+}
+
+// GetPrivateDataHash returns the hash of a private value; non-members may read it
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	if _, err := s.ledger.collectionFor(collection); err != nil {
+		return nil, err
+	}
+	value := s.ledger.private[collection][key]
+	if value == nil {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+// PutPrivateData buffers a write to a collection
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if err := s.checkWrite(collection); err != nil {
+		return err
+	}
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("value for key %s in collection %s is empty", key, collection)
+	}
+	s.bufferPrivateWrite(collection, key, &write{value: append([]byte(nil), value...)})
+	return nil
+}
+
+// DelPrivateData buffers a delete from a collection
+func (s *Stub) DelPrivateData(collection, key string) error {
+	if err := s.checkWrite(collection); err != nil {
+		return err
+	}
+	s.bufferPrivateWrite(collection, key, &write{isDelete: true})
+	return nil
+}
+
+// PurgePrivateData buffers a delete; the ledger keeps no private history to purge
+func (s *Stub) PurgePrivateData(collection, key string) error {
+	return s.DelPrivateData(collection, key)
+}
+
+// SetPrivateDataValidationParameter stores a key-level endorsement policy for a private key
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	s.ledger.validation[collection+"\x00"+key] = ep
+	return nil
+}
+
+// GetPrivateDataValidationParameter returns a key-level endorsement policy for a private key
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return s.ledger.validation[collection+"\x00"+key], nil
+}
+
+// GetPrivateDataByRange iterates committed keys of a collection in [startKey, endKey)
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkRead(collection); err != nil {
+		return nil, err
+	}
+	if err := validateRange(startKey, endKey); err != nil {
+		return nil, err
+	}
+	return newIterator(rangeOf(s.ledger.private[collection], startKey, endKey)), nil
+}
+
+// GetPrivateDataByPartialCompositeKey iterates committed keys of a collection sharing a composite key prefix
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkRead(collection); err != nil {
+		return nil, err
+	}
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeOf(s.ledger.private[collection], prefix, prefix+maxUnicodeRune)), nil
+}
+
+// GetPrivateDataQueryResult runs a rich query over a collection
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkRead(collection); err != nil {
+		return nil, err
+	}
+	if s.ledger.Backend != CouchDB {
+		return nil, errors.New("ExecuteQueryOnPrivateData not supported for leveldb")
+	}
+	kvs, err := richQuery(s.ledger.private[collection], query)
+	if err != nil {
+		return nil, err
+	}
+	s.richQueried = true
+	return newIterator(kvs), nil
+}
+
+// GetCreator returns the serialized identity of the submitter
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.identity.serialize()
+}
+
+// GetTransient returns the transient map of the proposal
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetBinding returns nil: test proposals are not bound
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+// GetDecorations returns no decorations
+func (s *Stub) GetDecorations() map[string][]byte {
+	return map[string][]byte{}
+}
+
+// GetSignedProposal returns an empty proposal
+func (s *Stub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return &pb.SignedProposal{}, nil
+}
+
+// GetTxTimestamp returns the ledger time at which the transaction was created
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.timestamp), nil
+}
+
+// SetEvent sets the transaction's event. As on a peer, a later call replaces an earlier one.
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	s.event = &pb.ChaincodeEvent{TxId: s.txID, EventName: name, Payload: payload}
+	return nil
+}
+
+// bufferWrite records a public write; the last write to a key wins
+func (s *Stub) bufferWrite(key string, w *write) {
+	if _, seen := s.writes[key]; !seen {
+		s.writeOrder = append(s.writeOrder, key)
+	}
+	s.writes[key] = w
+}
+
+// bufferPrivateWrite records a private write; the last write to a key wins
+func (s *Stub) bufferPrivateWrite(collection, key string, w *write) {
+	if s.pvtWrites[collection] == nil {
+		s.pvtWrites[collection] = make(map[string]*write)
+	}
+	s.pvtWrites[collection][key] = w
+}
+
+// checkRead applies the collection's read membership rule
+func (s *Stub) checkRead(collection string) error {
+	c, err := s.ledger.collectionFor(collection)
+	if err != nil {
+		return err
+	}
+	if c.MemberOnlyRead && !c.isMember(s.identity.MSPID) {
+		return fmt.Errorf("tx creator does not have read access permission on privatedata in collectionName:%s", collection)
+	}
+	return nil
+}
+
+// checkWrite applies the collection's write membership rule
+func (s *Stub) checkWrite(collection string) error {
+	c, err := s.ledger.collectionFor(collection)
+	if err != nil {
+		return err
+	}
+	if c.MemberOnlyWrite && !c.isMember(s.identity.MSPID) {
+		return fmt.Errorf("tx creator does not have write access permission on privatedata in collectionName:%s", collection)
+	}
+	return nil
+}
+
+// validateKey rejects keys the peer would reject
+func validateKey(key string) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key %x is not a valid utf8 string", key)
+	}
+	return nil
+}
+
+// validateRange rejects composite keys as range bounds, like the shim
+func validateRange(startKey, endKey string) error {
+	for _, key := range []string{startKey, endKey} {
+		if strings.HasPrefix(key, "\x00") {
+			return fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return nil
+}
+
+// rangeOf returns the entries with startKey <= key < endKey in key order. An empty
+// startKey skips composite keys and an empty endKey leaves the range open.
+func rangeOf(data map[string][]byte, startKey, endKey string) []*queryresult.KV {
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+
+	var kvs []*queryresult.KV
+	for _, key := range sortedKeys(data) {
+		if key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		kvs = append(kvs, &queryresult.KV{Key: key, Value: data[key]})
+	}
+	return kvs
+}
+
+// paginate cuts the first page from a result set; the bookmark is the first key of the next page
+func paginate(kvs []*queryresult.KV, pageSize int32) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	metadata := &pb.QueryResponseMetadata{}
+	if pageSize > 0 && int(pageSize) < len(kvs) {
+		metadata.Bookmark = kvs[pageSize].Key
+		kvs = kvs[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(kvs))
+	return newIterator(kvs), metadata, nil
+}
@@ -5,8 +5,10 @@ elif [ "$1" == "integration" ]; then
   test_path="./integration"
 elif [ "$1" == "unit" ]; then
   test_path="./unit"
+elif [ "$1" == "batched" ]; then
+  test_path="./batched"
 else
-  echo "Invalid argument. Use 'integration' or 'unit'."
+  echo "Invalid argument. Use 'integration', 'unit' or 'batched'."
   exit 1
 fi
 
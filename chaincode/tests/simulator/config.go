@@ -0,0 +1,208 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// knownBanks are the banks the chaincode has bilateral collections for, in rank order
+var knownBanks = []string{"AccessBankMSP", "GTBankMSP", "ZenithBankMSP", "FirstBankMSP"}
+
+// WindowLength is the chaincode's batch window
+const WindowLength = 2 * time.Minute
+
+// Config describes one simulation run. The zero value of every optional field picks
+// the default noted beside it.
+type Config struct {
+	Seed  int64
+	Start time.Time // start of the first window, rounded down to a batch window; default 2025-01-06 09:00 UTC
+
+	Banks             []string // participating banks, a subset of the four the chaincode knows; default all
+	Windows           int      // batch windows to simulate
+	PaymentsPerWindow float64  // mean of the Poisson number of payments created per window
+
+	Amounts        AmountDistribution       // default Pareto{Min: 1000, Alpha: 1.2, Max: 5_000_000}
+	Counterparties CounterpartyDistribution // default Zipf{Skew: 1}
+
+	AckDelay   time.Duration // mean of the exponential delay before the payee acknowledges; default 20s
+	BatchDelay time.Duration // delay between acknowledgement and CBN batching; default 1s
+
+	// StartingBalance is the opening balance every bank is onboarded with; default 15,000,000
+	StartingBalance float64
+	// MultilateralLimits caps the net debit of individual banks; payments over it queue
+	MultilateralLimits map[string]float64
+	// MultilateralEvery runs ExecuteScheduledMultilateralNetting every n windows (0 never)
+	MultilateralEvery int
+}
+
+// withDefaults validates the config and fills in defaults
+func (c Config) withDefaults() (Config, error) {
+	if c.Windows <= 0 {
+		return c, fmt.Errorf("windows must be positive")
+	}
+	if c.PaymentsPerWindow < 0 || c.PaymentsPerWindow > 500 {
+		return c, fmt.Errorf("payments per window must be between 0 and 500")
+	}
+	if c.Start.IsZero() {
+		c.Start = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	}
+	// Line simulated windows up with the chaincode's batch windows
+	c.Start = c.Start.Truncate(WindowLength)
+	if len(c.Banks) == 0 {
+		c.Banks = append([]string(nil), knownBanks...)
+	}
+	if len(c.Banks) < 2 {
+		return c, fmt.Errorf("at least two banks are needed, got %d", len(c.Banks))
+	}
+	seen := make(map[string]bool)
+	for _, bank := range c.Banks {
+		if !isKnownBank(bank) {
+			return c, fmt.Errorf("unknown bank %s: the chaincode only has collections for %v", bank, knownBanks)
+		}
+		if seen[bank] {
+			return c, fmt.Errorf("bank %s is listed twice", bank)
+		}
+		seen[bank] = true
+	}
+	for bank := range c.MultilateralLimits {
+		if !seen[bank] {
+			return c, fmt.Errorf("limit set for non-participating bank %s", bank)
+		}
+	}
+	if c.Amounts == nil {
+		c.Amounts = Pareto{Min: 1000, Alpha: 1.2, Max: 5_000_000}
+	}
+	if c.Counterparties == nil {
+		c.Counterparties = Zipf{Skew: 1}
+	}
+	if c.AckDelay == 0 {
+		c.AckDelay = 20 * time.Second
+	}
+	if c.BatchDelay == 0 {
+		c.BatchDelay = time.Second
+	}
+	if c.StartingBalance < 0 {
+		return c, fmt.Errorf("starting balance must not be negative")
+	}
+	if c.StartingBalance == 0 {
+		c.StartingBalance = 15_000_000
+	}
+	return c, nil
+}
+
+// isKnownBank reports whether the chaincode has collections for the bank
+func isKnownBank(bank string) bool {
+	for _, known := range knownBanks {
+		if bank == known {
+			return true
+		}
+	}
+	return false
+}
+
+// AmountDistribution draws payment amounts in Naira
+type AmountDistribution interface {
+	Sample(rng *rand.Rand) float64
+}
+
+// Pareto draws heavy-tailed amounts of at least Min with tail index Alpha, capped at Max (0 = no cap)
+type Pareto struct {
+	Min   float64
+	Alpha float64
+	Max   float64
+}
+
+// Sample draws one amount by inverting the Pareto CDF
+func (p Pareto) Sample(rng *rand.Rand) float64 {
+	amount := p.Min / math.Pow(1-rng.Float64(), 1/p.Alpha)
+	if p.Max > 0 {
+		amount = math.Min(amount, p.Max)
+	}
+	return roundToKobo(amount)
+}
+
+// UniformAmount draws amounts evenly between Min and Max
+type UniformAmount struct {
+	Min float64
+	Max float64
+}
+
+// Sample draws one amount
+func (u UniformAmount) Sample(rng *rand.Rand) float64 {
+	return roundToKobo(u.Min + rng.Float64()*(u.Max-u.Min))
+}
+
+// CounterpartyDistribution picks the payer and payee of a payment among the banks
+type CounterpartyDistribution interface {
+	Pick(rng *rand.Rand, banks []string) (payer, payee string)
+}
+
+// Zipf favours the first banks: the bank of rank r is chosen with weight 1/r^Skew, for
+// payers and payees alike. Skew 0 picks uniformly.
+type Zipf struct {
+	Skew float64
+}
+
+// Pick draws a payer, then a different payee
+func (z Zipf) Pick(rng *rand.Rand, banks []string) (string, string) {
+	weights := make([]float64, len(banks))
+	for i := range banks {
+		weights[i] = 1 / math.Pow(float64(i+1), z.Skew)
+	}
+
+	payer := pickWeighted(rng, weights)
+	payerWeight := weights[payer]
+	weights[payer] = 0
+	payee := pickWeighted(rng, weights)
+	weights[payer] = payerWeight
+
+	return banks[payer], banks[payee]
+}
+
+// pickWeighted returns an index with probability proportional to its weight
+func pickWeighted(rng *rand.Rand, weights []float64) int {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	target := rng.Float64() * total
+	for i, w := range weights {
+		if target < w {
+			return i
+		}
+		target -= w
+	}
+	// Float rounding can leave target just above the last weight
+	for i := len(weights) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// poisson draws a Poisson-distributed count with the given mean (Knuth's method)
+func poisson(rng *rand.Rand, mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	limit := math.Exp(-mean)
+	count, product := 0, rng.Float64()
+	for product > limit {
+		count++
+		product *= rng.Float64()
+	}
+	return count
+}
+
+// exponential draws a duration with the given mean
+func exponential(rng *rand.Rand, mean time.Duration) time.Duration {
+	return time.Duration(rng.ExpFloat64() * float64(mean))
+}
+
+// roundToKobo rounds a Naira amount to the nearest kobo
+func roundToKobo(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
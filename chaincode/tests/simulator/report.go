@@ -0,0 +1,244 @@
+package simulator
+
+import (
+	"math"
+	"sort"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+)
+
+// Report summarises a simulation run. Amounts are in Naira and delays in seconds.
+type Report struct {
+	Seed    int64    `json:"seed"`
+	Banks   []string `json:"banks"`
+	Windows int      `json:"windows"`
+
+	Payments      int     `json:"payments"`
+	Settled       int     `json:"settled"`
+	GrossVolume   float64 `json:"grossVolume"`
+	SettledVolume float64 `json:"settledVolume"`
+	// NetTransferred is the liquidity that actually moved between settlement accounts
+	NetTransferred float64 `json:"netTransferred"`
+	// LiquiditySaving is the share of settled volume that netting spared: 1 - NetTransferred/SettledVolume
+	LiquiditySaving float64 `json:"liquiditySaving"`
+
+	Liquidity  []BankLiquidity `json:"liquidity"`
+	QueueDepth QueueDepthStats `json:"queueDepth"`
+	Gridlock   GridlockStats   `json:"gridlock"`
+	Delay      DelayStats      `json:"settlementDelay"`
+	PerWindow  []WindowStats   `json:"perWindow"`
+}
+
+// BankLiquidity is one bank's use of its settlement account over the run
+type BankLiquidity struct {
+	Bank            string  `json:"bank"`
+	StartingBalance float64 `json:"startingBalance"`
+	EndingBalance   float64 `json:"endingBalance"`
+	Sent            float64 `json:"sent"`     // settled outgoing payments
+	Received        float64 `json:"received"` // settled incoming payments
+	// PeakNetDebit is the largest drop below the starting balance seen at a window end
+	PeakNetDebit float64 `json:"peakNetDebit"`
+	// PeakUsage is PeakNetDebit as a share of the starting balance
+	PeakUsage float64 `json:"peakUsage"`
+}
+
+// QueueDepthStats describes the QUEUED payments left at the end of each window
+type QueueDepthStats struct {
+	Max        int     `json:"max"`
+	Mean       float64 `json:"mean"`
+	Final      int     `json:"final"`
+	FinalValue float64 `json:"finalValue"`
+}
+
+// GridlockStats counts multilateral netting runs that found payments queued. A run is
+// gridlocked when it could settle none of them and partial when it settled only some.
+type GridlockStats struct {
+	Runs       int     `json:"runs"`
+	Gridlocked int     `json:"gridlocked"`
+	Partial    int     `json:"partial"`
+	Frequency  float64 `json:"frequency"` // Gridlocked / Runs
+}
+
+// DelayStats describes the time from creation to settlement of settled payments
+type DelayStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+// WindowStats is the activity of one batch window, measured when it closes
+type WindowStats struct {
+	Window         int     `json:"window"`
+	Created        int     `json:"created"`
+	Settled        int     `json:"settled"`
+	SettledVolume  float64 `json:"settledVolume"`
+	NetTransferred float64 `json:"netTransferred"`
+	QueueDepth     int     `json:"queueDepth"`
+	QueuedValue    float64 `json:"queuedValue"`
+	Gridlocked     bool    `json:"gridlocked"`
+}
+
+// tracker accumulates the measurements of a run
+type tracker struct {
+	cfg       Config
+	starting  map[string]float64
+	liquidity map[string]*BankLiquidity
+	delays    []float64
+	gridlock  GridlockStats
+	windows   []*WindowStats
+	payments  int
+	gross     float64
+}
+
+// newTracker starts tracking from the banks' balances after setup
+func newTracker(cfg Config, starting map[string]float64) *tracker {
+	t := &tracker{
+		cfg:       cfg,
+		starting:  starting,
+		liquidity: make(map[string]*BankLiquidity),
+	}
+	for _, bank := range cfg.Banks {
+		t.liquidity[bank] = &BankLiquidity{
+			Bank:            bank,
+			StartingBalance: starting[bank],
+			EndingBalance:   starting[bank],
+		}
+	}
+	return t
+}
+
+// startWindow opens the stats of the next window
+func (t *tracker) startWindow(window int) *WindowStats {
+	stats := &WindowStats{Window: window}
+	t.windows = append(t.windows, stats)
+	return stats
+}
+
+// created counts a payment accepted by the chaincode
+func (t *tracker) created(p *payment, stats *WindowStats) {
+	t.payments++
+	t.gross += p.amount
+	stats.Created++
+}
+
+// settled counts a payment found SETTLED when its window closed
+func (t *tracker) settled(p *payment, pd *settlement.PaymentDetails, stats *WindowStats) {
+	stats.Settled++
+	stats.SettledVolume += p.amount
+	t.liquidity[p.payer].Sent += p.amount
+	t.liquidity[p.payee].Received += p.amount
+	t.delays = append(t.delays, float64(pd.SettledAt-pd.CreatedAt))
+}
+
+// multilateralRun classifies a multilateral netting run over queued payments
+func (t *tracker) multilateralRun(queued, settled int, stats *WindowStats) {
+	if queued == 0 {
+		return
+	}
+	t.gridlock.Runs++
+	switch {
+	case settled == 0:
+		t.gridlock.Gridlocked++
+		stats.Gridlocked = true
+	case settled < queued:
+		t.gridlock.Partial++
+	}
+}
+
+// balance records a bank's balance at a window end
+func (t *tracker) balance(bank string, balance float64) {
+	l := t.liquidity[bank]
+	l.EndingBalance = balance
+	l.PeakNetDebit = math.Max(l.PeakNetDebit, t.starting[bank]-balance)
+}
+
+// report assembles the final report
+func (t *tracker) report() *Report {
+	r := &Report{
+		Seed:        t.cfg.Seed,
+		Banks:       t.cfg.Banks,
+		Windows:     t.cfg.Windows,
+		Payments:    t.payments,
+		GrossVolume: roundToKobo(t.gross),
+		Liquidity:   make([]BankLiquidity, 0, len(t.cfg.Banks)),
+		Gridlock:    t.gridlock,
+		Delay:       delayStats(t.delays),
+		PerWindow:   make([]WindowStats, 0, len(t.windows)),
+	}
+
+	var queueTotal int
+	for _, w := range t.windows {
+		w.SettledVolume = roundToKobo(w.SettledVolume)
+		w.NetTransferred = roundToKobo(w.NetTransferred)
+		w.QueuedValue = roundToKobo(w.QueuedValue)
+		r.PerWindow = append(r.PerWindow, *w)
+
+		r.Settled += w.Settled
+		r.SettledVolume += w.SettledVolume
+		r.NetTransferred += w.NetTransferred
+		queueTotal += w.QueueDepth
+		if w.QueueDepth > r.QueueDepth.Max {
+			r.QueueDepth.Max = w.QueueDepth
+		}
+	}
+	r.SettledVolume = roundToKobo(r.SettledVolume)
+	r.NetTransferred = roundToKobo(r.NetTransferred)
+	if r.SettledVolume > 0 {
+		r.LiquiditySaving = 1 - r.NetTransferred/r.SettledVolume
+	}
+	if len(t.windows) > 0 {
+		last := t.windows[len(t.windows)-1]
+		r.QueueDepth.Mean = float64(queueTotal) / float64(len(t.windows))
+		r.QueueDepth.Final = last.QueueDepth
+		r.QueueDepth.FinalValue = last.QueuedValue
+	}
+	if r.Gridlock.Runs > 0 {
+		r.Gridlock.Frequency = float64(r.Gridlock.Gridlocked) / float64(r.Gridlock.Runs)
+	}
+
+	for _, bank := range t.cfg.Banks {
+		l := *t.liquidity[bank]
+		l.Sent = roundToKobo(l.Sent)
+		l.Received = roundToKobo(l.Received)
+		l.EndingBalance = roundToKobo(l.EndingBalance)
+		l.PeakNetDebit = roundToKobo(l.PeakNetDebit)
+		if l.StartingBalance > 0 {
+			l.PeakUsage = l.PeakNetDebit / l.StartingBalance
+		}
+		r.Liquidity = append(r.Liquidity, l)
+	}
+
+	return r
+}
+
+// delayStats summarises settlement delays with nearest-rank percentiles
+func delayStats(delays []float64) DelayStats {
+	stats := DelayStats{Count: len(delays)}
+	if len(delays) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), delays...)
+	sort.Float64s(sorted)
+
+	var total float64
+	for _, d := range sorted {
+		total += d
+	}
+	stats.Mean = total / float64(len(sorted))
+	stats.P50 = nearestRank(sorted, 0.50)
+	stats.P95 = nearestRank(sorted, 0.95)
+	stats.Max = sorted[len(sorted)-1]
+	return stats
+}
+
+// nearestRank returns the p-th percentile of sorted values
+func nearestRank(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,467 @@
+// Package simulator stress-tests netting policies by driving the batched settlement
+// chaincode through simulated batch windows on an in-memory ledger. Payment flows are
+// drawn from configurable distributions, and a run is reproducible from its seed.
+package simulator
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const centralBankMSP = "CentralBankMSP"
+
+// customer is a BVN record loaded by InitLedger, used as the sender of every payment
+var customer = settlement.BankUser{
+	BVN:       "22133455678",
+	Firstname: "Oluwaseun",
+	Lastname:  "Adebanjo",
+	Birthdate: "15-04-1990",
+	Gender:    "Female",
+}
+
+// eventKind is the next step of a payment's lifecycle
+type eventKind int
+
+const (
+	createPayment eventKind = iota
+	acknowledgePayment
+	batchPayment
+)
+
+// event is a scheduled contract call; seq breaks ties so equal times keep schedule order
+type event struct {
+	at      time.Time
+	seq     int
+	kind    eventKind
+	payment *payment
+}
+
+// eventQueue orders events by time, then by when they were scheduled
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// payment is what the simulator remembers about a payment it generated
+type payment struct {
+	id     string
+	payer  string
+	payee  string
+	amount float64
+}
+
+// simulation is the state of one run
+type simulation struct {
+	cfg      Config
+	rng      *rand.Rand
+	ledger   *memstub.Ledger
+	contract *settlement.SmartContract
+	events   eventQueue
+	seq      int
+	created  int
+	open     []*payment // payments not yet settled, in creation order
+	tracker  *tracker
+}
+
+// Run simulates cfg.Windows batch windows and reports how settlement performed
+func Run(cfg Config) (*Report, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &simulation{
+		cfg:      cfg,
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		ledger:   newLedger(cfg.Start),
+		contract: new(settlement.SmartContract),
+	}
+	sim.ledger.ContextHandler = sim.contract.GetTransactionContextHandler()
+	if err := sim.setup(); err != nil {
+		return nil, err
+	}
+
+	for window := 0; window < cfg.Windows; window++ {
+		if err := sim.runWindow(window); err != nil {
+			return nil, fmt.Errorf("window %d: %v", window, err)
+		}
+	}
+
+	return sim.tracker.report(), nil
+}
+
+// newLedger defines the collections of private-data/collections_config.json
+func newLedger(start time.Time) *memstub.Ledger {
+	ledger := memstub.NewLedger(start)
+	for i, a := range knownBanks {
+		for _, b := range knownBanks[i+1:] {
+			ledger.AddCollection(memstub.Collection{
+				Name:            collectionName(a, b),
+				Members:         []string{a, b, centralBankMSP},
+				MemberOnlyRead:  true,
+				MemberOnlyWrite: true,
+			})
+		}
+		ledger.AddCollection(memstub.Collection{
+			Name:            settlementCollection(a),
+			Members:         []string{a, centralBankMSP},
+			MemberOnlyWrite: true,
+		})
+		ledger.AddCollection(memstub.Collection{
+			Name:            liquidityCollection(a),
+			Members:         []string{a, centralBankMSP},
+			MemberOnlyRead:  true,
+			MemberOnlyWrite: true,
+		})
+	}
+	ledger.AddCollection(memstub.Collection{
+		Name:            "col-BVN",
+		Members:         append([]string{centralBankMSP}, knownBanks...),
+		MemberOnlyRead:  true,
+		MemberOnlyWrite: true,
+	})
+	ledger.AddCollection(memstub.Collection{
+		Name:            "col-guarantee",
+		Members:         []string{centralBankMSP},
+		MemberOnlyRead:  true,
+		MemberOnlyWrite: true,
+	})
+	return ledger
+}
+
+// setup onboards every bank with the configured opening balance, then applies the limits
+func (sim *simulation) setup() error {
+	for _, bank := range knownBanks {
+		err := sim.submit(centralBankMSP, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return sim.contract.InitLedger(ctx, bank, sim.cfg.StartingBalance)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to onboard %s: %v", bank, err)
+		}
+	}
+
+	for _, bank := range sim.cfg.Banks {
+		limit, ok := sim.cfg.MultilateralLimits[bank]
+		if !ok {
+			continue
+		}
+		err := sim.submit(centralBankMSP, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return sim.contract.SetMultilateralLimit(ctx, bank, limit)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set limit for %s: %v", bank, err)
+		}
+	}
+
+	balances := make(map[string]float64)
+	for _, bank := range sim.cfg.Banks {
+		account, err := sim.account(bank)
+		if err != nil {
+			return err
+		}
+		balances[bank] = account.Balance
+	}
+	sim.tracker = newTracker(sim.cfg, balances)
+	return nil
+}
+
+// runWindow generates the window's payments, plays every event due before the window
+// closes, then nets at the window boundary
+func (sim *simulation) runWindow(window int) error {
+	start := sim.cfg.Start.Add(time.Duration(window) * WindowLength)
+	end := start.Add(WindowLength)
+	stats := sim.tracker.startWindow(window)
+
+	count := poisson(sim.rng, sim.cfg.PaymentsPerWindow)
+	for i := 0; i < count; i++ {
+		payer, payee := sim.cfg.Counterparties.Pick(sim.rng, sim.cfg.Banks)
+		sim.created++
+		p := &payment{
+			id:     fmt.Sprintf("sim-%06d", sim.created),
+			payer:  payer,
+			payee:  payee,
+			amount: sim.cfg.Amounts.Sample(sim.rng),
+		}
+		offset := time.Duration(sim.rng.Int63n(int64(WindowLength)))
+		sim.schedule(start.Add(offset), createPayment, p)
+	}
+
+	for sim.events.Len() > 0 && sim.events[0].at.Before(end) {
+		e := heap.Pop(&sim.events).(*event)
+		sim.ledger.SetTime(e.at)
+		if err := sim.play(e, stats); err != nil {
+			return err
+		}
+	}
+
+	sim.ledger.SetTime(end)
+	return sim.net(window, stats)
+}
+
+// schedule queues a contract call
+func (sim *simulation) schedule(at time.Time, kind eventKind, p *payment) {
+	sim.seq++
+	heap.Push(&sim.events, &event{at: at, seq: sim.seq, kind: kind, payment: p})
+}
+
+// play submits one lifecycle step and schedules the next
+func (sim *simulation) play(e *event, stats *WindowStats) error {
+	p := e.payment
+	details := settlement.PaymentEventDetails{ID: p.id, PayerMSP: p.payer, PayeeMSP: p.payee}
+
+	switch e.kind {
+	case createPayment:
+		if err := sim.create(p); err != nil {
+			return err
+		}
+		sim.open = append(sim.open, p)
+		sim.tracker.created(p, stats)
+		sim.schedule(e.at.Add(time.Second+exponential(sim.rng, sim.cfg.AckDelay)), acknowledgePayment, p)
+	case acknowledgePayment:
+		err := sim.submit(p.payee, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return sim.contract.AcknowledgePayment(ctx, details)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to acknowledge %s: %v", p.id, err)
+		}
+		sim.schedule(e.at.Add(sim.cfg.BatchDelay), batchPayment, p)
+	case batchPayment:
+		err := sim.submit(centralBankMSP, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return sim.contract.BatchAcknowledgedPayment(ctx, details)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to batch %s: %v", p.id, err)
+		}
+	}
+	return nil
+}
+
+// create submits CreatePayment with the payment in transient data, as the payer
+func (sim *simulation) create(p *payment) error {
+	details := settlement.PaymentDetails{
+		ID:        p.id,
+		PayerAcct: "0123456789",
+		PayeeAcct: "9876543210",
+		Amount:    p.amount,
+		Currency:  "NGN",
+		BVN:       customer.BVN,
+		PayerMSP:  p.payer,
+		PayeeMSP:  p.payee,
+		Timestamp: sim.ledger.Now().UnixMilli(),
+		User:      customer,
+	}
+	detailsBytes, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment %s: %v", p.id, err)
+	}
+
+	err = sim.submit(p.payer, map[string][]byte{"payment": detailsBytes}, sim.contract.CreatePayment)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", p.id, err)
+	}
+	return nil
+}
+
+// net settles the window's BATCHED payments the way the CBN service does, runs
+// multilateral netting over the queue when it is due, and records the window
+func (sim *simulation) net(window int, stats *WindowStats) error {
+	var calculationJSON string
+	err := sim.ledger.Evaluate(memstub.NewIdentity(centralBankMSP), func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		calculationJSON, err = sim.contract.CalculateNettingOffsets(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to calculate netting offsets: %v", err)
+	}
+
+	var calculation settlement.NettingCalculationResult
+	if err := json.Unmarshal([]byte(calculationJSON), &calculation); err != nil {
+		return fmt.Errorf("failed to unmarshal netting calculation: %v", err)
+	}
+	if calculation.TotalPayments > 0 {
+		var resultJSON string
+		transient := map[string][]byte{"nettingOffsets": []byte(calculationJSON)}
+		err := sim.submit(centralBankMSP, transient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			resultJSON, err = sim.contract.ApplyNettingOffsets(ctx)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply netting offsets: %v", err)
+		}
+
+		var result settlement.NettingApplicationResult
+		if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+			return fmt.Errorf("failed to unmarshal netting result: %v", err)
+		}
+		stats.NetTransferred += positiveSum(result.SettledBanks)
+	}
+
+	if every := sim.cfg.MultilateralEvery; every > 0 && (window+1)%every == 0 {
+		if err := sim.netQueue(stats); err != nil {
+			return err
+		}
+	}
+
+	return sim.closeWindow(stats)
+}
+
+// netQueue runs ExecuteScheduledMultilateralNetting and classifies the outcome
+func (sim *simulation) netQueue(stats *WindowStats) error {
+	queued, _, err := sim.queue()
+	if err != nil {
+		return err
+	}
+
+	var responseJSON string
+	err = sim.submit(centralBankMSP, nil, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		responseJSON, err = sim.contract.ExecuteScheduledMultilateralNetting(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute multilateral netting: %v", err)
+	}
+
+	var response struct {
+		NetPositions map[string]float64 `json:"netPositions"`
+		UpdatesCount int                `json:"updatesCount"`
+	}
+	if err := json.Unmarshal([]byte(responseJSON), &response); err != nil {
+		return fmt.Errorf("failed to unmarshal multilateral netting response: %v", err)
+	}
+	stats.NetTransferred += positiveSum(response.NetPositions)
+	sim.tracker.multilateralRun(queued, response.UpdatesCount, stats)
+	return nil
+}
+
+// closeWindow retires settled payments and records balances and the queue at the window end
+func (sim *simulation) closeWindow(stats *WindowStats) error {
+	stillOpen := sim.open[:0]
+	for _, p := range sim.open {
+		pd, err := sim.payment(p)
+		if err != nil {
+			return err
+		}
+		if pd.Status == "SETTLED" {
+			sim.tracker.settled(p, pd, stats)
+			continue
+		}
+		stillOpen = append(stillOpen, p)
+	}
+	sim.open = stillOpen
+
+	depth, value, err := sim.queue()
+	if err != nil {
+		return err
+	}
+	stats.QueueDepth = depth
+	stats.QueuedValue = value
+
+	for _, bank := range sim.cfg.Banks {
+		account, err := sim.account(bank)
+		if err != nil {
+			return err
+		}
+		sim.tracker.balance(bank, account.Balance)
+	}
+	return nil
+}
+
+// queue counts the open payments waiting in the queue and their remaining value
+func (sim *simulation) queue() (int, float64, error) {
+	var depth int
+	var value float64
+	for _, p := range sim.open {
+		pd, err := sim.payment(p)
+		if err != nil {
+			return 0, 0, err
+		}
+		if pd.Status == "QUEUED" || pd.Status == "PARTIALLY_SETTLED" {
+			depth++
+			value += pd.AmountToSettle
+		}
+	}
+	return depth, value, nil
+}
+
+// submit runs fn as a committed transaction of msp at the current ledger time
+func (sim *simulation) submit(msp string, transient map[string][]byte, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return sim.ledger.SubmitWithTransient(memstub.NewIdentity(msp), transient, fn)
+}
+
+// payment reads the committed state of a simulated payment
+func (sim *simulation) payment(p *payment) (*settlement.PaymentDetails, error) {
+	paymentBytes := sim.ledger.PrivateData(collectionName(p.payer, p.payee), p.id)
+	if paymentBytes == nil {
+		return nil, fmt.Errorf("payment %s not found", p.id)
+	}
+	var pd settlement.PaymentDetails
+	if err := json.Unmarshal(paymentBytes, &pd); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment %s: %v", p.id, err)
+	}
+	return &pd, nil
+}
+
+// account reads the committed settlement account of a bank
+func (sim *simulation) account(bank string) (*settlement.BankAccount, error) {
+	accountBytes := sim.ledger.PrivateData(settlementCollection(bank), bank)
+	if accountBytes == nil {
+		return nil, fmt.Errorf("no settlement account found for %s", bank)
+	}
+	var account settlement.BankAccount
+	if err := json.Unmarshal(accountBytes, &account); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account for %s: %v", bank, err)
+	}
+	return &account, nil
+}
+
+// collectionName mirrors the chaincode's bilateral collection naming
+func collectionName(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("col-%s-%s", a, b)
+}
+
+// settlementCollection is the collection holding a bank's settlement account
+func settlementCollection(bank string) string {
+	return fmt.Sprintf("col-settlement-%s", bank)
+}
+
+// liquidityCollection is the collection holding a bank's collateral and intraday facility
+func liquidityCollection(bank string) string {
+	return fmt.Sprintf("col-liquidity-%s", bank)
+}
+
+// positiveSum adds up the net credits of a set of positions, which is the liquidity moved
+func positiveSum(positions map[string]float64) float64 {
+	var sum float64
+	for _, amount := range positions {
+		if amount > 0 {
+			sum += amount
+		}
+	}
+	return sum
+}
@@ -0,0 +1,99 @@
+package simulator_test
+
+import (
+	"testing"
+
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIsReproducibleFromSeed(t *testing.T) {
+	cfg := simulator.Config{Seed: 42, Windows: 6, PaymentsPerWindow: 12, MultilateralEvery: 2}
+
+	first, err := simulator.Run(cfg)
+	require.NoError(t, err)
+	second, err := simulator.Run(cfg)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	cfg.Seed = 43
+	other, err := simulator.Run(cfg)
+	require.NoError(t, err)
+	require.NotEqual(t, first.PerWindow, other.PerWindow)
+}
+
+func TestRunSettlesBatchedPaymentsEveryWindow(t *testing.T) {
+	report, err := simulator.Run(simulator.Config{Seed: 7, Windows: 8, PaymentsPerWindow: 15})
+	require.NoError(t, err)
+
+	require.Len(t, report.PerWindow, 8)
+	require.Positive(t, report.Payments)
+	require.Positive(t, report.Settled)
+	require.LessOrEqual(t, report.Settled, report.Payments)
+	require.Zero(t, report.QueueDepth.Max, "without limits nothing queues")
+	require.Zero(t, report.Gridlock.Runs)
+
+	// Netting moves less than the gross value it settles
+	require.Positive(t, report.LiquiditySaving)
+	require.Less(t, report.NetTransferred, report.SettledVolume)
+
+	var starting, ending, sent, received float64
+	for _, bank := range report.Liquidity {
+		starting += bank.StartingBalance
+		ending += bank.EndingBalance
+		sent += bank.Sent
+		received += bank.Received
+		require.InDelta(t, bank.StartingBalance-bank.Sent+bank.Received, bank.EndingBalance, 0.01, bank.Bank)
+	}
+	require.InDelta(t, starting, ending, 0.01, "settlement only moves money between banks")
+	require.InDelta(t, sent, received, 0.01)
+	require.InDelta(t, report.SettledVolume, sent, 0.01)
+
+	// Payments settle at the first window boundary after they are batched
+	require.Positive(t, report.Delay.Count)
+	require.LessOrEqual(t, report.Delay.P50, report.Delay.P95)
+	require.LessOrEqual(t, report.Delay.P95, report.Delay.Max)
+}
+
+func TestRunReportsQueuesAndGridlockUnderTightLimits(t *testing.T) {
+	banks := []string{"AccessBankMSP", "GTBankMSP", "ZenithBankMSP"}
+	report, err := simulator.Run(simulator.Config{
+		Seed:              11,
+		Banks:             banks,
+		Windows:           10,
+		PaymentsPerWindow: 10,
+		Amounts:           simulator.UniformAmount{Min: 5_000, Max: 50_000},
+		Counterparties:    simulator.Zipf{Skew: 2},
+		StartingBalance:   60_000,
+		MultilateralLimits: map[string]float64{
+			"AccessBankMSP": 20_000,
+			"GTBankMSP":     20_000,
+			"ZenithBankMSP": 20_000,
+		},
+		MultilateralEvery: 3,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, banks, report.Banks)
+	require.Positive(t, report.QueueDepth.Max)
+	require.Positive(t, report.Gridlock.Runs)
+	require.Equal(t, float64(report.Gridlock.Gridlocked)/float64(report.Gridlock.Runs), report.Gridlock.Frequency)
+	for _, bank := range report.Liquidity {
+		require.Equal(t, 60_000.0, bank.StartingBalance)
+		require.InDelta(t, bank.PeakNetDebit/60_000, bank.PeakUsage, 1e-9)
+	}
+}
+
+func TestRunRejectsInvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]simulator.Config{
+		"no windows":       {PaymentsPerWindow: 1},
+		"one bank":         {Windows: 1, Banks: []string{"AccessBankMSP"}},
+		"unknown bank":     {Windows: 1, Banks: []string{"AccessBankMSP", "UBABankMSP"}},
+		"duplicate bank":   {Windows: 1, Banks: []string{"GTBankMSP", "GTBankMSP"}},
+		"limit for absent": {Windows: 1, Banks: []string{"AccessBankMSP", "GTBankMSP"}, MultilateralLimits: map[string]float64{"FirstBankMSP": 1}},
+		"negative rate":    {Windows: 1, PaymentsPerWindow: -1},
+	} {
+		_, err := simulator.Run(cfg)
+		require.Error(t, err, name)
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement"
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement/mocks"
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -45,6 +46,8 @@ const (
 	bankCMSP = "ZenithBankMSP"
 	bankDMSP = "FirstBankMSP"
 	bankEMSP = "UBABankMSP"
+
+	centralBankMSP = "CentralBankMSP"
 )
 
 // =============================================================================
@@ -88,6 +91,36 @@ func setupMockIterator(payments []settlement.PaymentDetails) *MockStateQueryIter
 	return iterator
 }
 
+// Helper to serve payments to the contract's reads of a collection: status queries the
+// way CouchDB answers a {"selector":{"status":...}} query, and the range scan update
+// transactions use on a collection whose status index has not been built
+func expectPaymentQuery(chaincodeStub *mocks.ChaincodeStubInterface, collection string, payments []settlement.PaymentDetails) {
+	chaincodeStub.On("GetPrivateDataQueryResult", collection, mock.Anything).Return(
+		func(_ string, query string) (shim.StateQueryIteratorInterface, error) {
+			var q struct {
+				Selector struct {
+					Status string `json:"status"`
+				} `json:"selector"`
+			}
+			if err := json.Unmarshal([]byte(query), &q); err != nil {
+				return nil, err
+			}
+
+			var matched []settlement.PaymentDetails
+			for _, payment := range payments {
+				if payment.Status == q.Selector.Status {
+					matched = append(matched, payment)
+				}
+			}
+			return setupMockIterator(matched), nil
+		}).Maybe()
+	chaincodeStub.On("GetPrivateData", collection, "\x00statusIndexState\x00").Return(nil, nil).Maybe()
+	chaincodeStub.On("GetPrivateDataByRange", collection, "", "").Return(
+		func(string, string, string) (shim.StateQueryIteratorInterface, error) {
+			return setupMockIterator(payments), nil
+		}).Maybe()
+}
+
 // Helper to set bilateral offset update in transient data
 func setBilateralOffsetInTransientData(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, offset float64, updates []settlement.OffsetUpdate) {
 	payload := struct {
@@ -123,9 +156,8 @@ func TestCalculateBilateralOffset_Success_EqualAmounts(t *testing.T) {
 		createQueuedPayment("pay2", bankBMSP, bankAMSP, 1000.0),
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -156,9 +188,8 @@ func TestCalculateBilateralOffset_Success_UnequalAmounts(t *testing.T) {
 		createQueuedPayment("pay2", bankBMSP, bankAMSP, 800.0),
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -179,9 +210,9 @@ func TestCalculateBilateralOffset_Success_UnequalAmounts(t *testing.T) {
 		}
 	}
 
-	// pay1 should have remaining amount
+	// pay1 keeps its remainder queued
 	require.Equal(t, 700.0, pay1Update.AmountToSettle) // 1500 - 800
-	require.Equal(t, "QUEUED", pay1Update.Status)
+	require.Equal(t, "PARTIALLY_SETTLED", pay1Update.Status)
 
 	// pay2 should be fully settled
 	require.Equal(t, 0.0, pay2Update.AmountToSettle)
@@ -201,9 +232,8 @@ func TestCalculateBilateralOffset_Success_NoQueuedPayments(t *testing.T) {
 		{ID: "pay2", PayerMSP: bankBMSP, PayeeMSP: bankAMSP, AmountToSettle: 800.0, Status: "SETTLED"},
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -223,18 +253,25 @@ func TestCalculateBilateralOffset_InvalidPaymentJSON(t *testing.T) {
 	smartContract := settlement.SmartContract{}
 
 	// Create mock iterator with invalid JSON
-	iterator := &MockStateQueryIterator{}
-	iterator.On("HasNext").Return(true).Once()
-	kv := &queryresult.KV{
-		Key:   "invalid",
-		Value: []byte("invalid json"),
+	newIterator := func(string, string) (shim.StateQueryIteratorInterface, error) {
+		iterator := &MockStateQueryIterator{}
+		iterator.On("HasNext").Return(true).Once()
+		kv := &queryresult.KV{
+			Key:   "invalid",
+			Value: []byte("invalid json"),
+		}
+		iterator.On("Next").Return(kv, nil).Once()
+		iterator.On("HasNext").Return(false).Once()
+		iterator.On("Close").Return(nil)
+		return iterator, nil
 	}
-	iterator.On("Next").Return(kv, nil).Once()
-	iterator.On("HasNext").Return(false).Once()
-	iterator.On("Close").Return(nil)
 
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	chaincodeStub.On("GetPrivateData", collectionName, "\x00statusIndexState\x00").Return(nil, nil)
+	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(
+		func(string, string, string) (shim.StateQueryIteratorInterface, error) {
+			return newIterator("", "")
+		})
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -253,7 +290,7 @@ func TestApplyBilateralOffset_InvalidJSON(t *testing.T) {
 	t.Log("✓ Invalid Offset Update JSON Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	transientData := map[string][]byte{
@@ -274,28 +311,59 @@ func TestApplyBilateralOffset_PutPrivateDataFailure(t *testing.T) {
 	t.Log("✓ Private Data Update Failure Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
+	existingPayment := createQueuedPayment("pay1", bankAMSP, bankBMSP, 100.0)
+	collectionName := getCollectionName(bankAMSP, bankBMSP)
+	expectPaymentQuery(chaincodeStub, collectionName, []settlement.PaymentDetails{
+		existingPayment,
+		createQueuedPayment("pay2", bankBMSP, bankAMSP, 100.0),
+	})
+
 	updates := []settlement.OffsetUpdate{
-		{ID: "pay1", AmountToSettle: 0.0, Status: "SETTLED"},
+		{ID: "pay1", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
+		{ID: "pay2", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
 	}
-
 	setBilateralOffsetInTransientData(t, chaincodeStub, 100.0, updates)
 
-	existingPayment := createQueuedPayment("pay1", bankAMSP, bankBMSP, 100.0)
 	existingPaymentJSON, _ := json.Marshal(existingPayment)
-
-	collectionName := getCollectionName(bankAMSP, bankBMSP)
 	chaincodeStub.On("GetPrivateData", collectionName, "pay1").Return(existingPaymentJSON, nil)
 	chaincodeStub.On("PutPrivateData", collectionName, "pay1", mock.Anything).Return(fmt.Errorf("write failed"))
+	expectStatusIndexUpdate(chaincodeStub, collectionName)
 
 	// Execute
 	err := smartContract.ApplyBilateralOffset(transactionContext, bankAMSP, bankBMSP)
 
 	// Assert
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "write failed for pay1")
+	require.Contains(t, err.Error(), "failed to update payment pay1: write failed")
+}
+
+func TestApplyBilateralOffset_RejectsOffsetsTheQueueDoesNotGive(t *testing.T) {
+	t.Log("✓ Stale Offset Rejected")
+
+	// Setup
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
+	smartContract := settlement.SmartContract{}
+
+	// GT's 100 was settled since the calculation, so only 60 can be offset now
+	collectionName := getCollectionName(bankAMSP, bankBMSP)
+	expectPaymentQuery(chaincodeStub, collectionName, []settlement.PaymentDetails{
+		createQueuedPayment("pay1", bankAMSP, bankBMSP, 100.0),
+		createQueuedPayment("pay2", bankBMSP, bankAMSP, 60.0),
+	})
+	setBilateralOffsetInTransientData(t, chaincodeStub, 100.0, []settlement.OffsetUpdate{
+		{ID: "pay1", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
+		{ID: "pay3", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
+	})
+
+	// Execute
+	err := smartContract.ApplyBilateralOffset(transactionContext, bankAMSP, bankBMSP)
+
+	// Assert
+	require.EqualError(t, err, "offset does not match the queued payments: calculated 100.00, payments give 60.00")
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
 }
 
 // =============================================================================
@@ -316,9 +384,8 @@ func TestCalculateBilateralOffset_MultiplePaymentsSameDirection(t *testing.T) {
 		createQueuedPayment("pay4", bankBMSP, bankAMSP, 600.0),
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -365,7 +432,7 @@ func TestCalculateBilateralOffset_MultiplePaymentsSameDirection(t *testing.T) {
 				require.Equal(t, "SETTLED", update.Status)
 				settledPayments++
 			} else {
-				require.Equal(t, "QUEUED", update.Status)
+				require.Equal(t, "PARTIALLY_SETTLED", update.Status)
 				remainingPayments++
 			}
 		}
@@ -384,9 +451,8 @@ func TestCalculateBilateralOffset_ZeroAmountPayments(t *testing.T) {
 		createQueuedPayment("pay2", bankBMSP, bankAMSP, 1000.0),
 	}
 
-	iterator := setupMockIterator(payments)
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+	expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 	// Execute
 	result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
@@ -402,15 +468,17 @@ func TestApplyBilateralOffset_EmptyUpdatesArray(t *testing.T) {
 	t.Log("✓ Empty Updates Array Handled Without Error")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
+	// Nothing is queued between the banks, so there is nothing to offset
+	expectPaymentQuery(chaincodeStub, getCollectionName(bankAMSP, bankBMSP), nil)
 	setBilateralOffsetInTransientData(t, chaincodeStub, 0.0, []settlement.OffsetUpdate{})
 
 	chaincodeStub.On("SetEvent", "BilateralOffsetExecuted", mock.Anything).Return(nil)
 
 	// Execute
-	err := smartContract.ApplyBilateralOffset(transactionContext, "BankA", "BankB")
+	err := smartContract.ApplyBilateralOffset(transactionContext, bankAMSP, bankBMSP)
 
 	// Assert
 	require.NoError(t, err)
@@ -449,9 +517,8 @@ func TestCalculateBilateralOffset_DifferentAmounts(t *testing.T) {
 				createQueuedPayment("pay2", bankBMSP, bankAMSP, tc.amountBtoA),
 			}
 
-			iterator := setupMockIterator(payments)
 			collectionName := getCollectionName(bankAMSP, bankBMSP)
-			chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil)
+			expectPaymentQuery(chaincodeStub, collectionName, payments)
 
 			result, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
 
@@ -469,26 +536,34 @@ func TestApplyBilateralOffset_DifferentMSPs(t *testing.T) {
 	}{
 		{"Standard banks", bankAMSP, bankBMSP},
 		{"Reversed banks", bankBMSP, bankAMSP},
-		{"Different banks", "FirstBankMSP", "SecondBankMSP"},
+		{"Different banks", "FirstBankMSP", "ZenithBankMSP"},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			transactionContext, chaincodeStub := prepMocks()
+			transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 			smartContract := settlement.SmartContract{}
 
-			updates := []settlement.OffsetUpdate{
-				{ID: "pay1", AmountToSettle: 0.0, Status: "SETTLED"},
+			payments := []settlement.PaymentDetails{
+				createQueuedPayment("pay1", tc.payerMSP, tc.payeeMSP, 100.0),
+				createQueuedPayment("pay2", tc.payeeMSP, tc.payerMSP, 100.0),
 			}
+			collectionName := getCollectionName(tc.payerMSP, tc.payeeMSP)
+			expectPaymentQuery(chaincodeStub, collectionName, payments)
 
+			updates := []settlement.OffsetUpdate{
+				{ID: "pay1", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
+				{ID: "pay2", AmountToSettle: 0.0, SettledPortion: 100.0, Status: "SETTLED"},
+			}
 			setBilateralOffsetInTransientData(t, chaincodeStub, 100.0, updates)
 
-			existingPayment := createQueuedPayment("pay1", tc.payerMSP, tc.payeeMSP, 100.0)
-			existingPaymentJSON, _ := json.Marshal(existingPayment)
-
-			collectionName := getCollectionName(tc.payerMSP, tc.payeeMSP)
-			chaincodeStub.On("GetPrivateData", collectionName, "pay1").Return(existingPaymentJSON, nil)
-			chaincodeStub.On("PutPrivateData", collectionName, "pay1", mock.Anything).Return(nil)
+			for _, payment := range payments {
+				paymentJSON, _ := json.Marshal(payment)
+				chaincodeStub.On("GetPrivateData", collectionName, payment.ID).Return(paymentJSON, nil)
+				chaincodeStub.On("PutPrivateData", collectionName, payment.ID, mock.Anything).Return(nil)
+				expectPublicStubUpdate(t, chaincodeStub, payment.ID)
+			}
+			expectStatusIndexUpdate(chaincodeStub, collectionName)
 			chaincodeStub.On("SetEvent", "BilateralOffsetExecuted", mock.Anything).Return(nil)
 
 			err := smartContract.ApplyBilateralOffset(transactionContext, tc.payerMSP, tc.payeeMSP)
@@ -496,3 +571,28 @@ func TestApplyBilateralOffset_DifferentMSPs(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyBilateralOffset_RequiresCentralBankAndTwoBanks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		clientMSP string
+		mspA      string
+		mspB      string
+		err       string
+	}{
+		{"Bank client", bankAMSP, bankAMSP, bankBMSP, "only Central Bank can apply bilateral offsets"},
+		{"Same bank", centralBankMSP, bankAMSP, bankAMSP, "bilateral offset requires two different banks"},
+		{"Unknown bank", centralBankMSP, "FirstBankMSP", "SecondBankMSP", "bilateral offset requires two different banks"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transactionContext, chaincodeStub := prepMocksAs(tc.clientMSP)
+			smartContract := settlement.SmartContract{}
+
+			err := smartContract.ApplyBilateralOffset(transactionContext, tc.mspA, tc.mspB)
+			require.EqualError(t, err, tc.err)
+			chaincodeStub.AssertNotCalled(t, "GetTransient")
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// timedQueuedPayment builds a queued payment created at timestamp
+func timedQueuedPayment(id, payerMSP, payeeMSP string, amount float64, timestamp int64) settlement.PaymentDetails {
+	pd := queuedPayment(id, payerMSP, payeeMSP, "NORMAL", amount, timestamp)
+	pd.Timestamp = timestamp
+	return pd
+}
+
+// =============================================================================
+// FIFO Bilateral Offset Tests
+// =============================================================================
+
+func TestCalculateBilateralOffset_ConsumesOldestPaymentsFirst(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	// The IDs sort against the timestamps, so only FIFO ordering settles pay-z first
+	expectCollectionScan(chaincodeStub, getCollectionName(bankAMSP, bankBMSP),
+		batchedPaymentKV(timedQueuedPayment("pay-a", bankAMSP, bankBMSP, 400, 2000)),
+		batchedPaymentKV(timedQueuedPayment("pay-z", bankAMSP, bankBMSP, 300, 1000)),
+		batchedPaymentKV(timedQueuedPayment("pay-m", bankBMSP, bankAMSP, 500.005, 1500)),
+	)
+
+	calc, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
+	require.NoError(t, err)
+	require.Equal(t, 500.01, calc.Offset)
+	require.Len(t, calc.Updates, 3)
+
+	require.Equal(t, settlement.OffsetUpdate{ID: "pay-z", AmountToSettle: 0, SettledPortion: 300, Status: "SETTLED"}, calc.Updates[0])
+	require.Equal(t, settlement.OffsetUpdate{ID: "pay-a", AmountToSettle: 199.99, SettledPortion: 200.01, Status: "PARTIALLY_SETTLED"}, calc.Updates[1])
+	require.Equal(t, "pay-m", calc.Updates[2].ID)
+	require.Equal(t, "SETTLED", calc.Updates[2].Status)
+}
+
+func TestCalculateBilateralOffset_IncludesPartiallySettledRemainders(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	remainder := timedQueuedPayment("pay-1", bankAMSP, bankBMSP, 1000, 1000)
+	remainder.Status = "PARTIALLY_SETTLED"
+	remainder.AmountToSettle = 250
+	expectCollectionScan(chaincodeStub, getCollectionName(bankAMSP, bankBMSP),
+		batchedPaymentKV(remainder),
+		batchedPaymentKV(timedQueuedPayment("pay-2", bankBMSP, bankAMSP, 250, 2000)),
+	)
+
+	calc, err := smartContract.CalculateBilateralOffset(transactionContext, bankAMSP, bankBMSP)
+	require.NoError(t, err)
+	require.Equal(t, 250.0, calc.Offset)
+	require.Len(t, calc.Updates, 2)
+	for _, u := range calc.Updates {
+		require.Equal(t, "SETTLED", u.Status)
+		require.Equal(t, 0.0, u.AmountToSettle)
+	}
+}
+
+// =============================================================================
+// Bilateral Settlement Tests
+// =============================================================================
+
+func TestExecuteBilateralSettlement_MovesTheResidualTheDebtorCanFund(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	outgoing := timedQueuedPayment("pay-1", bankAMSP, bankBMSP, 1000, 1000)
+	incoming := timedQueuedPayment("pay-2", bankBMSP, bankAMSP, 400, 2000)
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	expectCollectionScan(chaincodeStub, coll, batchedPaymentKV(outgoing), batchedPaymentKV(incoming))
+	expectPaymentRecord(t, chaincodeStub, outgoing)
+	expectPaymentRecord(t, chaincodeStub, incoming)
+	expectSettlementBalances(t, chaincodeStub, map[string]float64{bankAMSP: 500, bankBMSP: 0})
+	chaincodeStub.On("GetTxID").Return("tx-1")
+
+	// Access can fund 500 of the 600 residual; the last 100 of pay-1 stays queued
+	chaincodeStub.On("PutPrivateData", "col-settlement-"+bankAMSP, bankAMSP, mock.MatchedBy(func(value []byte) bool {
+		var account settlement.BankAccount
+		return json.Unmarshal(value, &account) == nil && account.Balance == 0
+	})).Return(nil)
+	chaincodeStub.On("PutPrivateData", "col-settlement-"+bankBMSP, bankBMSP, mock.MatchedBy(func(value []byte) bool {
+		var account settlement.BankAccount
+		return json.Unmarshal(value, &account) == nil && account.Balance == 500
+	})).Return(nil)
+	chaincodeStub.On("PutPrivateData", coll, "pay-1", writtenPayment("PARTIALLY_SETTLED", func(pd settlement.PaymentDetails) bool {
+		return pd.AmountToSettle == 100
+	})).Return(nil)
+	chaincodeStub.On("PutPrivateData", coll, "pay-2", writtenPayment("SETTLED", nil)).Return(nil)
+	chaincodeStub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	resultKey, err := shim.CreateCompositeKey("bilateralSettlement", []string{"tx-1"})
+	require.NoError(t, err)
+	chaincodeStub.On("PutPrivateData", coll, resultKey, mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := smartContract.ExecuteBilateralSettlement(transactionContext, bankAMSP, bankBMSP)
+	require.NoError(t, err)
+	require.Equal(t, 400.0, result.Offset)
+	require.Equal(t, bankAMSP, result.DebtorMSP)
+	require.Equal(t, 600.0, result.Residual)
+	require.Equal(t, 500.0, result.ResidualSettled)
+	require.Equal(t, 100.0, result.ResidualUnsettled)
+	require.Equal(t, 1, result.SettledPayments)
+	require.Equal(t, 1, result.PartialPayments)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestExecuteBilateralSettlement_RequiresCentralBankAndTwoBanks(t *testing.T) {
+	transactionContext, _ := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	_, err := smartContract.ExecuteBilateralSettlement(transactionContext, bankAMSP, bankBMSP)
+	require.EqualError(t, err, "only Central Bank can execute bilateral settlement")
+
+	transactionContext, _ = prepBatchedMocksAs("CentralBankMSP")
+	_, err = smartContract.ExecuteBilateralSettlement(transactionContext, bankAMSP, bankAMSP)
+	require.EqualError(t, err, "bilateral settlement requires two different banks")
+}
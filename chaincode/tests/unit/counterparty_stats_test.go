@@ -0,0 +1,56 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Counterparty Exposure Tests
+// =============================================================================
+
+func TestGetCounterpartyStats_BreaksExposureDownByStage(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	withStatus := func(pd settlement.PaymentDetails, status string) settlement.PaymentDetails {
+		pd.Status = status
+		return pd
+	}
+	pending := withStatus(acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 100), "PENDING")
+	batchedIn := withStatus(acknowledgedPayment("pay-2", bankBMSP, bankAMSP, 250), "BATCHED")
+	partial := withStatus(acknowledgedPayment("pay-3", bankAMSP, bankBMSP, 1000), "PARTIALLY_SETTLED")
+	partial.AmountToSettle = 400
+	settledOut := withStatus(acknowledgedPayment("pay-4", bankAMSP, bankBMSP, 500), "SETTLED")
+	settledOut.SettledAt = 86400*3 + 100
+	settledIn := withStatus(acknowledgedPayment("pay-5", bankBMSP, bankAMSP, 300), "SETTLED")
+	settledIn.SettledAt = 86400*2 + 500
+	returned := withStatus(acknowledgedPayment("pay-6", bankAMSP, bankBMSP, 50), "RETURNED_UNSETTLED")
+	expectAllCollectionScans(chaincodeStub, pending, batchedIn, partial, settledOut, settledIn, returned)
+
+	stats, err := smartContract.GetCounterpartyStats(transactionContext)
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	gt := stats[0]
+	require.Equal(t, bankBMSP, gt.BankMSP)
+	require.Equal(t, 6, gt.TransactionCount)
+	require.Equal(t, 2200.0, gt.TransactionVolume)
+	require.Equal(t, 250.0, gt.GrossIncoming)
+	require.Equal(t, 500.0, gt.GrossOutgoing)
+	require.Equal(t, -100.0, gt.NetPending)
+	require.Equal(t, 250.0, gt.NetBatched)
+	require.Equal(t, -400.0, gt.NetQueued)
+	require.Equal(t, -250.0, gt.NetPosition)
+	require.Equal(t, 800.0, gt.SettledVolume)
+	require.Equal(t, []settlement.SettledPeriod{
+		{PeriodStart: 86400 * 2, Count: 1, Volume: 300},
+		{PeriodStart: 86400 * 3, Count: 1, Volume: 500},
+	}, gt.SettledByPeriod)
+
+	// Banks without activity still get an entry
+	require.Zero(t, stats[1].TransactionCount)
+	require.NotNil(t, stats[1].SettledByPeriod)
+}
@@ -0,0 +1,250 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// =============================================================================
+// HELPERS FOR BATCHED SETTLEMENT TESTS
+// =============================================================================
+
+// batchedTxTime is the transaction timestamp every batched test transaction carries
+const batchedTxTime = 1_700_000_000
+
+// prepBatchedMocksAs prepares mocks for a client of msp, with real composite keys
+func prepBatchedMocksAs(msp string) (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
+	chaincodeStub := &mocks.ChaincodeStubInterface{}
+	transactionContext := &mocks.TransactionContextInterface{}
+	transactionContext.On("GetStub").Return(chaincodeStub)
+	transactionContext.On("GetClientIdentity").Return(memstub.NewIdentity(msp)).Maybe()
+	chaincodeStub.On("CreateCompositeKey", mock.Anything, mock.Anything).Return(shim.CreateCompositeKey).Maybe()
+	chaincodeStub.On("GetTxTimestamp").Return(timestamppb.New(time.Unix(batchedTxTime, 0)), nil).Maybe()
+	chaincodeStub.On("GetState", "SETTLEMENT_CONFIG").Return(nil, nil).Maybe()
+	// No participant has been suspended, so every bank is ACTIVE
+	chaincodeStub.On("GetState", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "\x00participant\x00")
+	})).Return(nil, nil).Maybe()
+	return transactionContext, chaincodeStub
+}
+
+// kvIterator serves a fixed result set
+type kvIterator struct {
+	results []*queryresult.KV
+	pos     int
+}
+
+func (it *kvIterator) HasNext() bool { return it.pos < len(it.results) }
+func (it *kvIterator) Close() error  { return nil }
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	kv := it.results[it.pos]
+	it.pos++
+	return kv, nil
+}
+
+// batchedPaymentKV stores a payment under its ID
+func batchedPaymentKV(pd settlement.PaymentDetails) *queryresult.KV {
+	value, _ := json.Marshal(pd)
+	return &queryresult.KV{Key: pd.ID, Value: value}
+}
+
+// expectCollectionScan answers every range scan of a collection with the given records.
+// Rich queries fail as they do on LevelDB peers and the collection has no status index
+// yet, so indexed reads fall back to the scan.
+func expectCollectionScan(chaincodeStub *mocks.ChaincodeStubInterface, collection string, kvs ...*queryresult.KV) {
+	chaincodeStub.On("GetPrivateDataQueryResult", collection, mock.Anything).Return(
+		nil, errors.New("ExecuteQuery not supported for leveldb")).Maybe()
+	indexStateKey, _ := shim.CreateCompositeKey("statusIndexState", []string{})
+	chaincodeStub.On("GetPrivateData", collection, indexStateKey).Return(nil, nil).Maybe()
+	chaincodeStub.On("GetPrivateDataByRange", collection, "", "").Return(
+		func(string, string, string) (shim.StateQueryIteratorInterface, error) {
+			return &kvIterator{results: kvs}, nil
+		}).Maybe()
+}
+
+// expectExposureLimits answers the payer's limit lookup with the given limits
+func expectExposureLimits(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, payerMSP string, limits ...settlement.ExposureLimit) {
+	kvs := make([]*queryresult.KV, 0, len(limits))
+	for _, limit := range limits {
+		counterparty := limit.CounterpartyMSP
+		if counterparty == "" {
+			counterparty = "ALL"
+		}
+		key, err := shim.CreateCompositeKey("limit", []string{payerMSP, counterparty})
+		require.NoError(t, err)
+		value, err := json.Marshal(limit)
+		require.NoError(t, err)
+		kvs = append(kvs, &queryresult.KV{Key: key, Value: value})
+	}
+	chaincodeStub.On("GetPrivateDataByPartialCompositeKey", "col-settlement-"+payerMSP, "limit", []string{payerMSP}).Return(
+		func(string, string, []string) (shim.StateQueryIteratorInterface, error) {
+			return &kvIterator{results: kvs}, nil
+		}).Maybe()
+}
+
+// expectPaymentRecord answers reads of a payment and its public stub, and lets a write
+// of the payment move its status index entry
+func expectPaymentRecord(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, pd settlement.PaymentDetails) {
+	paymentJSON, err := json.Marshal(pd)
+	require.NoError(t, err)
+	stubJSON, err := json.Marshal(settlement.PaymentStub{ID: pd.ID, PayerMSP: pd.PayerMSP, PayeeMSP: pd.PayeeMSP, Status: pd.Status})
+	require.NoError(t, err)
+	chaincodeStub.On("GetPrivateData", getCollectionName(pd.PayerMSP, pd.PayeeMSP), pd.ID).Return(paymentJSON, nil).Maybe()
+	chaincodeStub.On("GetState", pd.ID).Return(stubJSON, nil).Maybe()
+
+	coll := getCollectionName(pd.PayerMSP, pd.PayeeMSP)
+	indexEntry := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "\x00status~window~paymentID\x00") && strings.HasSuffix(key, "\x00"+pd.ID+"\x00")
+	})
+	chaincodeStub.On("DelPrivateData", coll, indexEntry).Return(nil).Maybe()
+	chaincodeStub.On("PutPrivateData", coll, indexEntry, mock.Anything).Return(nil).Maybe()
+}
+
+// writtenPayment matches a payment record written with the given status
+func writtenPayment(status string, check func(pd settlement.PaymentDetails) bool) interface{} {
+	return mock.MatchedBy(func(value []byte) bool {
+		var pd settlement.PaymentDetails
+		if err := json.Unmarshal(value, &pd); err != nil || pd.Status != status {
+			return false
+		}
+		return check == nil || check(pd)
+	})
+}
+
+// acknowledgedPayment builds an ACKNOWLEDGED payment ready to batch
+func acknowledgedPayment(id, payerMSP, payeeMSP string, amount float64) settlement.PaymentDetails {
+	return settlement.PaymentDetails{
+		ID:             id,
+		PayerMSP:       payerMSP,
+		PayeeMSP:       payeeMSP,
+		Amount:         amount,
+		AmountToSettle: amount,
+		Currency:       "NGN",
+		Status:         "ACKNOWLEDGED",
+	}
+}
+
+// expectBatchedExposure gives the payer's bilateral collections the given BATCHED payments
+func expectBatchedExposure(chaincodeStub *mocks.ChaincodeStubInterface, payerMSP string, payments ...settlement.PaymentDetails) {
+	byCollection := make(map[string][]*queryresult.KV)
+	for _, pd := range payments {
+		coll := getCollectionName(pd.PayerMSP, pd.PayeeMSP)
+		byCollection[coll] = append(byCollection[coll], batchedPaymentKV(pd))
+	}
+	for _, other := range []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP} {
+		if other != payerMSP {
+			coll := getCollectionName(payerMSP, other)
+			expectCollectionScan(chaincodeStub, coll, byCollection[coll]...)
+		}
+	}
+}
+
+// =============================================================================
+// Exposure Limit Tests
+// =============================================================================
+
+func TestSetBilateralLimit_StoresLimitInPayerCollection(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	key, err := shim.CreateCompositeKey("limit", []string{bankAMSP, bankBMSP})
+	require.NoError(t, err)
+	chaincodeStub.On("PutPrivateData", "col-settlement-"+bankAMSP, key, mock.MatchedBy(func(value []byte) bool {
+		var limit settlement.ExposureLimit
+		return json.Unmarshal(value, &limit) == nil && limit.Type == "BILATERAL" &&
+			limit.CounterpartyMSP == bankBMSP && limit.Limit == 5000 && limit.SetBy == bankAMSP
+	})).Return(nil)
+	chaincodeStub.On("SetEvent", "ExposureLimitSet", mock.Anything).Return(nil)
+
+	err = smartContract.SetBilateralLimit(transactionContext, bankAMSP, bankBMSP, 5000)
+	require.NoError(t, err)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestSetMultilateralLimit_RejectsOtherBanksAndNegativeLimits(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankBMSP)
+	smartContract := settlement.SmartContract{}
+
+	err := smartContract.SetMultilateralLimit(transactionContext, bankAMSP, 1000)
+	require.EqualError(t, err, "only AccessBankMSP or Central Bank can manage its exposure limits")
+
+	transactionContext, chaincodeStub = prepBatchedMocksAs("CentralBankMSP")
+	err = smartContract.SetMultilateralLimit(transactionContext, bankAMSP, -1)
+	require.EqualError(t, err, "exposure limit must not be negative")
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchAcknowledgedPayment_QueuesPaymentOverBilateralLimit(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	// GT already owes Access 500 in the batch, which counts against Access's debit
+	payment := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 6000)
+	incoming := acknowledgedPayment("pay-0", bankBMSP, bankAMSP, 500)
+	incoming.Status = "BATCHED"
+	expectPaymentRecord(t, chaincodeStub, payment)
+	expectExposureLimits(t, chaincodeStub, bankAMSP, settlement.ExposureLimit{
+		PayerMSP: bankAMSP, CounterpartyMSP: bankBMSP, Type: "BILATERAL", Limit: 5000,
+	})
+	expectBatchedExposure(chaincodeStub, bankAMSP, incoming)
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	chaincodeStub.On("PutPrivateData", coll, "pay-1", writtenPayment("QUEUED", func(pd settlement.PaymentDetails) bool {
+		return pd.QueueReason == "limit_exceeded"
+	})).Return(nil)
+	chaincodeStub.On("PutState", "pay-1", mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "PaymentQueued", mock.Anything).Return(nil)
+
+	err := smartContract.BatchAcknowledgedPayment(transactionContext, settlement.PaymentEventDetails{ID: "pay-1", PayerMSP: bankAMSP, PayeeMSP: bankBMSP})
+	require.NoError(t, err)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestBatchAcknowledgedPayment_BatchesWithinMultilateralLimit(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	// Zenith owes Access 1000, so 5500 to GT nets to a 4500 multilateral debit
+	payment := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 5500)
+	incoming := acknowledgedPayment("pay-0", bankCMSP, bankAMSP, 1000)
+	incoming.Status = "BATCHED"
+	expectPaymentRecord(t, chaincodeStub, payment)
+	expectExposureLimits(t, chaincodeStub, bankAMSP, settlement.ExposureLimit{
+		PayerMSP: bankAMSP, Type: "MULTILATERAL", Limit: 5000,
+	})
+	expectBatchedExposure(chaincodeStub, bankAMSP, incoming)
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	chaincodeStub.On("PutPrivateData", coll, "pay-1", writtenPayment("BATCHED", nil)).Return(nil)
+	chaincodeStub.On("PutState", "pay-1", mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "PaymentBatched", mock.Anything).Return(nil)
+
+	err := smartContract.BatchAcknowledgedPayment(transactionContext, settlement.PaymentEventDetails{ID: "pay-1", PayerMSP: bankAMSP, PayeeMSP: bankBMSP})
+	require.NoError(t, err)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestGetExposureLimits_FailsOnUnreadableLimit(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	key, err := shim.CreateCompositeKey("limit", []string{bankAMSP, bankBMSP})
+	require.NoError(t, err)
+	chaincodeStub.On("GetPrivateDataByPartialCompositeKey", "col-settlement-"+bankAMSP, "limit", []string{bankAMSP}).Return(
+		&kvIterator{results: []*queryresult.KV{{Key: key, Value: []byte("{")}}}, nil)
+
+	_, err = smartContract.GetExposureLimits(transactionContext, bankAMSP)
+	require.ErrorContains(t, err, "failed to unmarshal exposure limit")
+}
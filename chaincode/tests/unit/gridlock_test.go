@@ -0,0 +1,115 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSettlementBalances answers the settlement account reads of the four banks
+func expectSettlementBalances(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, balances map[string]float64) {
+	for _, msp := range []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP} {
+		accountJSON, err := json.Marshal(settlement.BankAccount{MSP: msp, Balance: balances[msp]})
+		require.NoError(t, err)
+		chaincodeStub.On("GetPrivateData", "col-settlement-"+msp, msp).Return(accountJSON, nil).Maybe()
+		expectExposureLimits(t, chaincodeStub, msp)
+	}
+}
+
+// =============================================================================
+// Gridlock Resolution Tests
+// =============================================================================
+
+func TestCalculateMultilateralOffset_DropsLastPaymentsOfShortPayer(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	// Access owes 550 against 300 incoming but only holds 100, so its payments
+	// come out from the back of the queue until it can fund its position
+	expectSettlementBalances(t, chaincodeStub, map[string]float64{
+		bankAMSP: 100, bankBMSP: 1000, bankCMSP: 1000, bankDMSP: 1000,
+	})
+	expectAllCollectionScans(chaincodeStub,
+		queuedPayment("pay-1", bankAMSP, bankBMSP, "NORMAL", 500, 1000),
+		queuedPayment("pay-2", bankBMSP, bankAMSP, "NORMAL", 300, 2000),
+		queuedPayment("pay-3", bankAMSP, bankCMSP, "NORMAL", 50, 3000),
+	)
+
+	calc, err := smartContract.CalculateMultilateralOffset(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, 2, calc.Iterations)
+	require.Len(t, calc.Updates, 1)
+	require.Equal(t, "pay-2", calc.Updates[0].ID)
+	require.Equal(t, "SETTLED", calc.Updates[0].Status)
+	require.Equal(t, 300.0, calc.NetPositions[bankAMSP])
+	require.Equal(t, -300.0, calc.NetPositions[bankBMSP])
+
+	require.Len(t, calc.Decisions, 3)
+	require.Equal(t, "pay-3", calc.Decisions[0].ID)
+	require.False(t, calc.Decisions[0].Selected)
+	require.Equal(t, "pay-1", calc.Decisions[1].ID)
+	require.False(t, calc.Decisions[1].Selected)
+	require.True(t, calc.Decisions[2].Selected)
+}
+
+func TestCalculateMultilateralOffset_HonoursBilateralLimits(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	expectExposureLimits(t, chaincodeStub, bankAMSP, settlement.ExposureLimit{
+		PayerMSP: bankAMSP, CounterpartyMSP: bankBMSP, Type: "BILATERAL", Limit: 400,
+	})
+	expectSettlementBalances(t, chaincodeStub, map[string]float64{
+		bankAMSP: 5000, bankBMSP: 5000, bankCMSP: 5000, bankDMSP: 5000,
+	})
+	expectAllCollectionScans(chaincodeStub,
+		queuedPayment("pay-1", bankAMSP, bankBMSP, "NORMAL", 300, 1000),
+		queuedPayment("pay-2", bankAMSP, bankCMSP, "NORMAL", 700, 2000),
+		queuedPayment("pay-3", bankAMSP, bankBMSP, "NORMAL", 300, 3000),
+	)
+
+	calc, err := smartContract.CalculateMultilateralOffset(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, 1, calc.Iterations)
+	require.Len(t, calc.Updates, 2)
+	require.Equal(t, "pay-3", calc.Decisions[0].ID)
+	require.Contains(t, calc.Decisions[0].Reason, "exceeds bilateral limit 400.00")
+	require.Equal(t, -1000.0, calc.NetPositions[bankAMSP])
+}
+
+// =============================================================================
+// Netting Efficiency Tests
+// =============================================================================
+
+func TestCalculateMultilateralOffset_ReportsEfficiencyAndTransferPlan(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	expectSettlementBalances(t, chaincodeStub, map[string]float64{
+		bankAMSP: 1000, bankBMSP: 1000, bankCMSP: 1000, bankDMSP: 1000,
+	})
+	// A cycle of 1200 plus 100 from First nets down to two transfers of 100
+	expectAllCollectionScans(chaincodeStub,
+		queuedPayment("pay-1", bankAMSP, bankBMSP, "NORMAL", 500, 1000),
+		queuedPayment("pay-2", bankBMSP, bankCMSP, "NORMAL", 400, 1000),
+		queuedPayment("pay-3", bankCMSP, bankAMSP, "NORMAL", 300, 1000),
+		queuedPayment("pay-4", bankDMSP, bankAMSP, "NORMAL", 100, 1000),
+	)
+
+	calc, err := smartContract.CalculateMultilateralOffset(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, 1300.0, calc.Efficiency.TotalGross)
+	require.Equal(t, 200.0, calc.Efficiency.TotalNet)
+	require.Equal(t, 1100.0, calc.Efficiency.LiquiditySaved)
+	require.InDelta(t, 200.0/1300.0, calc.Efficiency.NettingRatio, 1e-9)
+	require.Equal(t, 500.0, calc.Efficiency.GrossOutgoing[bankAMSP])
+	require.Equal(t, 400.0, calc.Efficiency.GrossIncoming[bankAMSP])
+
+	require.Equal(t, []settlement.FundsTransfer{
+		{FromMSP: bankAMSP, ToMSP: bankBMSP, Amount: 100},
+		{FromMSP: bankDMSP, ToMSP: bankCMSP, Amount: 100},
+	}, calc.TransferPlan)
+}
@@ -0,0 +1,84 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitLedger_Success_OpensAccountWithOpeningBalance(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAs("CentralBankMSP")
+
+	accountJSON, err := json.Marshal(settlement.BankAccount{MSP: myOrg1Clientid, Balance: 2_000_000})
+	require.NoError(t, err)
+
+	chaincodeStub.On("GetPrivateData", "col-settlement-AccessBankMSP", myOrg1Clientid).Return(nil, nil)
+	chaincodeStub.On("PutPrivateData", "col-settlement-AccessBankMSP", myOrg1Clientid, accountJSON).Return(nil)
+	chaincodeStub.On("PutPrivateData", "col-settlement-AccessBankMSP", mock.Anything, mock.Anything).Return(nil)
+	chaincodeStub.On("GetState", "TOTAL_SUPPLY").Return(nil, nil)
+	chaincodeStub.On("PutState", "TOTAL_SUPPLY", mock.Anything).Return(nil)
+	chaincodeStub.On("GetPrivateData", "col-BVN", "22133455678").Return(nil, nil)
+	chaincodeStub.On("PutPrivateData", "col-BVN", mock.Anything, mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", mock.Anything, mock.Anything).Return(nil)
+
+	contract := settlement.SmartContract{}
+	err = contract.InitLedger(transactionContext, myOrg1Clientid, 2_000_000)
+
+	require.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutPrivateData", "col-settlement-AccessBankMSP", myOrg1Clientid, accountJSON)
+	chaincodeStub.AssertCalled(t, "PutState", "TOTAL_SUPPLY", mock.Anything)
+	chaincodeStub.AssertCalled(t, "PutPrivateData", "col-BVN", "22133455678", mock.Anything)
+	chaincodeStub.AssertCalled(t, "SetEvent", "BankOnboarded", mock.Anything)
+}
+
+func TestInitLedger_LaterOnboardingKeepsLoadedBVNRecords(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAs("CentralBankMSP")
+
+	// An earlier onboarding has already loaded the BVN records
+	bvnJSON, err := json.Marshal(settlement.BVNRecord{BVN: "22133455678", Firstname: "Oluwaseun"})
+	require.NoError(t, err)
+
+	chaincodeStub.On("GetPrivateData", "col-settlement-GTBankMSP", "GTBankMSP").Return(nil, nil)
+	chaincodeStub.On("PutPrivateData", "col-settlement-GTBankMSP", mock.Anything, mock.Anything).Return(nil)
+	chaincodeStub.On("GetState", "TOTAL_SUPPLY").Return(nil, nil)
+	chaincodeStub.On("PutState", "TOTAL_SUPPLY", mock.Anything).Return(nil)
+	chaincodeStub.On("GetPrivateData", "col-BVN", "22133455678").Return(bvnJSON, nil)
+	chaincodeStub.On("SetEvent", mock.Anything, mock.Anything).Return(nil)
+
+	contract := settlement.SmartContract{}
+	err = contract.InitLedger(transactionContext, "GTBankMSP", 15_000_000)
+
+	require.NoError(t, err)
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", "col-BVN", mock.Anything, mock.Anything)
+	chaincodeStub.AssertCalled(t, "SetEvent", "BankOnboarded", mock.Anything)
+}
+
+func TestInitLedger_RepeatInvocationRejected(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAs("CentralBankMSP")
+
+	// The bank has run down its balance since it was onboarded
+	accountJSON, err := json.Marshal(settlement.BankAccount{MSP: myOrg1Clientid, Balance: 1250.75})
+	require.NoError(t, err)
+	chaincodeStub.On("GetPrivateData", "col-settlement-AccessBankMSP", myOrg1Clientid).Return(accountJSON, nil)
+
+	contract := settlement.SmartContract{}
+	err = contract.InitLedger(transactionContext, myOrg1Clientid, 15_000_000)
+
+	require.EqualError(t, err, "bank AccessBankMSP is already onboarded")
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
+	chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestInitLedger_BankCannotOnboardItself(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAs(myOrg1Clientid)
+
+	contract := settlement.SmartContract{}
+	err := contract.InitLedger(transactionContext, myOrg1Clientid, 15_000_000)
+
+	require.EqualError(t, err, "only Central Bank can onboard banks")
+	chaincodeStub.AssertNotCalled(t, "GetPrivateData", mock.Anything, mock.Anything)
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,70 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Payment Lifecycle Tests
+// =============================================================================
+
+func TestBatchAcknowledgedPayment_StampsBatchedAtFromTheTransaction(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	payment := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 100)
+	payment.CreatedAt = batchedTxTime - 60
+	payment.AcknowledgedAt = batchedTxTime - 30
+	expectPaymentRecord(t, chaincodeStub, payment)
+	expectExposureLimits(t, chaincodeStub, bankAMSP)
+	expectBatchedExposure(chaincodeStub, bankAMSP)
+	chaincodeStub.On("PutPrivateData", getCollectionName(bankAMSP, bankBMSP), "pay-1", writtenPayment("BATCHED", func(pd settlement.PaymentDetails) bool {
+		return pd.BatchedAt == batchedTxTime && pd.CreatedAt == batchedTxTime-60 && pd.AcknowledgedAt == batchedTxTime-30
+	})).Return(nil)
+	chaincodeStub.On("PutState", "pay-1", mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "PaymentBatched", mock.Anything).Return(nil)
+
+	err := smartContract.BatchAcknowledgedPayment(transactionContext, settlement.PaymentEventDetails{ID: "pay-1", PayerMSP: bankAMSP, PayeeMSP: bankBMSP})
+	require.NoError(t, err)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestGetTransactionHistoryPaginated_ReportsSettlementTimes(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	settled := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 100)
+	settled.Status = "SETTLED"
+	settled.Timestamp = 2000 * 1000
+	settled.CreatedAt = 1000
+	settled.SettledAt = 1600
+	settled.SettlementCycleID = "tx-9"
+	// Created before lifecycle times were recorded, so timed from the client timestamp
+	legacy := acknowledgedPayment("pay-2", bankCMSP, bankAMSP, 200)
+	legacy.Status = "SETTLED"
+	legacy.Timestamp = 1000 * 1000
+	legacy.SettledAt = 1300
+	pending := acknowledgedPayment("pay-3", bankAMSP, bankDMSP, 300)
+	pending.Timestamp = 3000 * 1000
+	expectAllCollectionScans(chaincodeStub, settled, legacy, pending)
+
+	page, err := smartContract.GetTransactionHistoryPaginated(transactionContext, settlement.PaymentPageQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 3)
+
+	require.Equal(t, "pay-1", page.Records[0].PaymentId)
+	require.Equal(t, time.Unix(1600, 0).UTC().Format(time.RFC3339), page.Records[0].SettledAt)
+	require.Equal(t, "tx-9", page.Records[0].SettlementCycleID)
+	require.Equal(t, int64(600), page.Records[0].TimeToSettleSeconds)
+
+	require.Equal(t, "pay-2", page.Records[1].PaymentId)
+	require.Equal(t, int64(300), page.Records[1].TimeToSettleSeconds)
+
+	require.Empty(t, page.Records[2].SettledAt)
+	require.Zero(t, page.Records[2].TimeToSettleSeconds)
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement"
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement/mocks"
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -48,44 +49,23 @@ func setupComprehensiveMocking(chaincodeStub *mocks.ChaincodeStubInterface, paym
 	// All possible MSPs that might be in authorizedMSPs array
 	allMSPs := []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP, bankEMSP}
 
-	// Mock every possible collection combination
-	for _, a := range allMSPs {
-		for _, b := range allMSPs {
-			if a == b {
-				continue
-			}
+	// Mock every possible collection combination; collections without payments answer empty
+	for i, a := range allMSPs {
+		for _, b := range allMSPs[i+1:] {
 			collectionName := getCollectionName(a, b)
-
-			if payments, exists := paymentsByCollection[collectionName]; exists && len(payments) > 0 {
-				// Collection with payments
-				iterator := &MockMultilateralStateQueryIterator{}
-
-				// Setup calls for this specific collection
-				for i := 0; i < len(payments); i++ {
-					iterator.On("HasNext").Return(true).Once()
-				}
-				iterator.On("HasNext").Return(false).Once()
-
-				for _, payment := range payments {
-					paymentJSON, _ := json.Marshal(payment)
-					kv := &queryresult.KV{
-						Key:   payment.ID,
-						Value: paymentJSON,
-					}
-					iterator.On("Next").Return(kv, nil).Once()
-				}
-
-				iterator.On("Close").Return(nil).Once()
-				chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil).Once()
-			} else {
-				// Empty collection
-				iterator := &MockMultilateralStateQueryIterator{}
-				iterator.On("HasNext").Return(false).Once()
-				iterator.On("Close").Return(nil).Once()
-				chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(iterator, nil).Once()
-			}
+			expectPaymentQuery(chaincodeStub, collectionName, paymentsByCollection[collectionName])
 		}
 	}
+
+	// Every bank can fund its queue and has no exposure limits, so gridlock resolution selects everything
+	for _, msp := range allMSPs {
+		accountJSON, _ := json.Marshal(createBankAccount(msp, 1_000_000.0))
+		chaincodeStub.On("GetPrivateData", "col-settlement-"+msp, msp).Return(accountJSON, nil).Maybe()
+		chaincodeStub.On("GetPrivateDataByPartialCompositeKey", "col-settlement-"+msp, mock.Anything, []string{msp}).Return(
+			func(string, string, []string) (shim.StateQueryIteratorInterface, error) {
+				return setupMockIterator(nil), nil
+			}).Maybe()
+	}
 }
 
 // =============================================================================
@@ -310,6 +290,7 @@ func TestCalculateMultilateralOffset_CollectionReadError(t *testing.T) {
 	secondMSP := bankBMSP // GTBankMSP (next in alphabetical order)
 	errorCollectionName := getCollectionName(firstMSP, secondMSP)
 
+	chaincodeStub.On("GetPrivateData", errorCollectionName, "\x00statusIndexState\x00").Return(nil, nil)
 	chaincodeStub.On("GetPrivateDataByRange", errorCollectionName, "", "").Return(nil, fmt.Errorf("collection access denied"))
 
 	// Execute
@@ -336,60 +317,23 @@ func TestCalculateMultilateralOffset_InvalidPaymentJSON(t *testing.T) {
 		},
 	}
 
-	// Setup most collections normally first
-	setupComprehensiveMocking(chaincodeStub, paymentsByCollection)
-
-	// Override one specific collection with invalid JSON mixed with valid data
-	iterator := &MockMultilateralStateQueryIterator{}
-
-	// Setup for: 1 valid payment + 1 invalid JSON + HasNext=false
-	iterator.On("HasNext").Return(true).Once()  // For valid payment
-	iterator.On("HasNext").Return(true).Once()  // For invalid JSON
-	iterator.On("HasNext").Return(false).Once() // End iteration
-
-	// Valid payment first
-	validPayment := createQueuedPayment("pay1", bankAMSP, bankBMSP, 500.0)
-	validPaymentJSON, _ := json.Marshal(validPayment)
-	validKV := &queryresult.KV{
-		Key:   "pay1",
-		Value: validPaymentJSON,
-	}
-	iterator.On("Next").Return(validKV, nil).Once()
-
-	// Invalid JSON second
-	invalidKV := &queryresult.KV{
-		Key:   "invalid",
-		Value: []byte("invalid json data"),
-	}
-	iterator.On("Next").Return(invalidKV, nil).Once()
-
-	iterator.On("Close").Return(nil).Once()
-
-	// Replace the mock for this specific collection
+	// Serve the AccessBank-GTBank queue as 1 valid payment + 1 invalid JSON record
 	collectionName := getCollectionName(bankAMSP, bankBMSP)
-
-	// Clear previous expectations for this collection and set new one
-	chaincodeStub.ExpectedCalls = nil
-
-	// Setup all other collections as empty
-	allMSPs := []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP, bankEMSP}
-	for i, a := range allMSPs {
-		for j := i + 1; j < len(allMSPs); j++ {
-			b := allMSPs[j]
-			coll := getCollectionName(a, b)
-
-			if coll == collectionName {
-				// Use our special iterator with invalid JSON
-				chaincodeStub.On("GetPrivateDataByRange", coll, "", "").Return(iterator, nil).Once()
-			} else {
-				// Empty collection
-				emptyIterator := &MockMultilateralStateQueryIterator{}
-				emptyIterator.On("HasNext").Return(false).Once()
-				emptyIterator.On("Close").Return(nil).Once()
-				chaincodeStub.On("GetPrivateDataByRange", coll, "", "").Return(emptyIterator, nil).Once()
-			}
-		}
-	}
+	validPaymentJSON, _ := json.Marshal(paymentsByCollection[collectionName][0])
+	chaincodeStub.On("GetPrivateData", collectionName, "\x00statusIndexState\x00").Return(nil, nil)
+	chaincodeStub.On("GetPrivateDataByRange", collectionName, "", "").Return(
+		func(string, string, string) (shim.StateQueryIteratorInterface, error) {
+			iterator := &MockMultilateralStateQueryIterator{}
+			iterator.On("HasNext").Return(true).Twice()
+			iterator.On("HasNext").Return(false).Once()
+			iterator.On("Next").Return(&queryresult.KV{Key: "pay1", Value: validPaymentJSON}, nil).Once()
+			iterator.On("Next").Return(&queryresult.KV{Key: "invalid", Value: []byte("invalid json data")}, nil).Once()
+			iterator.On("Close").Return(nil).Once()
+			return iterator, nil
+		})
+
+	// Every other query is served normally; the expectation above takes precedence
+	setupComprehensiveMocking(chaincodeStub, paymentsByCollection)
 
 	// Execute
 	result, err := smartContract.CalculateMultilateralOffset(transactionContext)
@@ -443,7 +387,7 @@ func TestApplyMultilateralOffset_Success(t *testing.T) {
 	t.Log("✓ Multilateral Offset Successfully Applied")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	// Create net positions and updates from a typical calculation
@@ -480,6 +424,10 @@ func TestApplyMultilateralOffset_Success(t *testing.T) {
 	chaincodeStub.On("PutPrivateData", coll1, "pay1", mock.Anything).Return(nil)
 	chaincodeStub.On("PutPrivateData", coll2, "pay2", mock.Anything).Return(nil)
 	chaincodeStub.On("PutPrivateData", coll3, "pay3", mock.Anything).Return(nil)
+	for i, coll := range []string{coll1, coll2, coll3} {
+		expectStatusIndexUpdate(chaincodeStub, coll)
+		expectPublicStubUpdate(t, chaincodeStub, fmt.Sprintf("pay%d", i+1))
+	}
 
 	// Mock settlement accounts
 	accessBankAccount := createBankAccount(bankAMSP, 1000.0)
@@ -529,7 +477,7 @@ func TestApplyMultilateralOffset_NoTransientData(t *testing.T) {
 	t.Log("✓ Missing Transient Data Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	chaincodeStub.On("GetTransient").Return(nil, fmt.Errorf("no transient data"))
@@ -550,7 +498,7 @@ func TestApplyMultilateralOffset_MissingMultilateralUpdate(t *testing.T) {
 	t.Log("✓ Missing Multilateral Update Key Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	// Transient data without "multilateralUpdate" key
@@ -576,7 +524,7 @@ func TestApplyMultilateralOffset_InvalidJSON(t *testing.T) {
 	t.Log("✓ Invalid Multilateral Update JSON Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	// Invalid JSON in transient data
@@ -598,7 +546,7 @@ func TestApplyMultilateralOffset_PutPrivateDataFailure(t *testing.T) {
 	t.Log("✓ Payment Update Failure Error Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
 	// Create valid update data
@@ -618,41 +566,75 @@ func TestApplyMultilateralOffset_PutPrivateDataFailure(t *testing.T) {
 
 	// Mock PutPrivateData failure
 	chaincodeStub.On("PutPrivateData", collectionName, "pay1", mock.Anything).Return(fmt.Errorf("write failed"))
+	expectStatusIndexUpdate(chaincodeStub, collectionName)
 
 	// Execute
 	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "write failed pay1")
+	require.Contains(t, err.Error(), "failed to update payment pay1: write failed")
 }
 
 // =============================================================================
-// Settlement Account Tests (DebitNetting & CreditNetting)
+// Settlement Account Tests (debitNetting & creditNetting)
 // =============================================================================
+
+// prepNettingTransfer passes a multilateral offset settling one queued 300 payment from
+// AccessBank to GTBank, so applying it debits AccessBank and credits GTBank
+func prepNettingTransfer(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface) {
+	netPositions := map[string]float64{bankAMSP: -300.0, bankBMSP: 300.0}
+	updates := []settlement.MultiOffsetUpdate{
+		{ID: "pay1", PayerMSP: bankAMSP, PayeeMSP: bankBMSP, AmountToSettle: 0.0, Status: "SETTLED"},
+	}
+	setMultilateralOffsetInTransientData(t, chaincodeStub, netPositions, updates)
+
+	paymentJSON, err := json.Marshal(createQueuedPayment("pay1", bankAMSP, bankBMSP, 300.0))
+	require.NoError(t, err)
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	chaincodeStub.On("GetPrivateData", coll, "pay1").Return(paymentJSON, nil)
+	chaincodeStub.On("PutPrivateData", coll, "pay1", mock.Anything).Return(nil)
+	expectStatusIndexUpdate(chaincodeStub, coll)
+	expectPublicStubUpdate(t, chaincodeStub, "pay1")
+}
+
+// expectNettingAccount serves a bank's settlement account and accepts its update
+func expectNettingAccount(t *testing.T, chaincodeStub *mocks.ChaincodeStubInterface, msp string, balance float64) {
+	accountJSON, err := json.Marshal(createBankAccount(msp, balance))
+	require.NoError(t, err)
+	collectionName := fmt.Sprintf("col-settlement-%s", msp)
+	chaincodeStub.On("GetPrivateData", collectionName, msp).Return(accountJSON, nil).Maybe()
+	chaincodeStub.On("PutPrivateData", collectionName, msp, mock.Anything).Return(nil).Maybe()
+}
+
+// accountWithBalance matches a stored settlement account holding balance
+func accountWithBalance(balance float64) interface{} {
+	return mock.MatchedBy(func(value []byte) bool {
+		var account settlement.BankAccount
+		return json.Unmarshal(value, &account) == nil && account.Balance == balance
+	})
+}
+
 func TestDebitNetting_Success(t *testing.T) {
 	t.Log("✓ Debit Netting Successfully Applied")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
-	// Mock existing settlement account with sufficient balance
-	existingAccount := createBankAccount(bankAMSP, 1000.0)
-	accountJSON, _ := json.Marshal(existingAccount)
+	prepNettingTransfer(t, chaincodeStub)
+	expectNettingAccount(t, chaincodeStub, bankAMSP, 1000.0)
+	expectNettingAccount(t, chaincodeStub, bankBMSP, 500.0)
+	chaincodeStub.On("SetEvent", mock.Anything, mock.Anything).Return(nil)
 
-	collectionName := fmt.Sprintf("col-settlement-%s", bankAMSP)
-	chaincodeStub.On("GetPrivateData", collectionName, bankAMSP).Return(accountJSON, nil)
-	chaincodeStub.On("PutPrivateData", collectionName, bankAMSP, mock.Anything).Return(nil)
-	chaincodeStub.On("SetEvent", "NettingDebitExecuted", mock.Anything).Return(nil)
-
-	// Execute - debit 300 from account
-	err := smartContract.DebitNetting(transactionContext, bankAMSP, 300.0)
+	// Execute - debit 300 from AccessBank's account
+	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
 	require.NoError(t, err)
+	collectionName := fmt.Sprintf("col-settlement-%s", bankAMSP)
 	chaincodeStub.AssertCalled(t, "GetPrivateData", collectionName, bankAMSP)
-	chaincodeStub.AssertCalled(t, "PutPrivateData", collectionName, bankAMSP, mock.Anything)
+	chaincodeStub.AssertCalled(t, "PutPrivateData", collectionName, bankAMSP, accountWithBalance(700.0))
 	chaincodeStub.AssertCalled(t, "SetEvent", "NettingDebitExecuted", mock.Anything)
 }
 
@@ -660,25 +642,22 @@ func TestCreditNetting_Success_ExistingAccount(t *testing.T) {
 	t.Log("✓ Credit Netting Successfully Applied to Existing Account")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
-	// Mock existing settlement account
-	existingAccount := createBankAccount(bankBMSP, 500.0)
-	accountJSON, _ := json.Marshal(existingAccount)
-
-	collectionName := fmt.Sprintf("col-settlement-%s", bankBMSP)
-	chaincodeStub.On("GetPrivateData", collectionName, bankBMSP).Return(accountJSON, nil)
-	chaincodeStub.On("PutPrivateData", collectionName, bankBMSP, mock.Anything).Return(nil)
-	chaincodeStub.On("SetEvent", "NettingCreditExecuted", mock.Anything).Return(nil)
+	prepNettingTransfer(t, chaincodeStub)
+	expectNettingAccount(t, chaincodeStub, bankAMSP, 1000.0)
+	expectNettingAccount(t, chaincodeStub, bankBMSP, 500.0)
+	chaincodeStub.On("SetEvent", mock.Anything, mock.Anything).Return(nil)
 
-	// Execute - credit 200 to account
-	err := smartContract.CreditNetting(transactionContext, bankBMSP, 200.0)
+	// Execute - credit 300 to GTBank's account
+	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
 	require.NoError(t, err)
+	collectionName := fmt.Sprintf("col-settlement-%s", bankBMSP)
 	chaincodeStub.AssertCalled(t, "GetPrivateData", collectionName, bankBMSP)
-	chaincodeStub.AssertCalled(t, "PutPrivateData", collectionName, bankBMSP, mock.Anything)
+	chaincodeStub.AssertCalled(t, "PutPrivateData", collectionName, bankBMSP, accountWithBalance(800.0))
 	chaincodeStub.AssertCalled(t, "SetEvent", "NettingCreditExecuted", mock.Anything)
 }
 
@@ -686,15 +665,19 @@ func TestDebitNetting_GetPrivateDataFailure(t *testing.T) {
 	t.Log("✓ Debit Netting Get Private Data Failure Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
+	prepNettingTransfer(t, chaincodeStub)
+	expectNettingAccount(t, chaincodeStub, bankBMSP, 500.0)
+	chaincodeStub.On("SetEvent", "NettingCreditExecuted", mock.Anything).Return(nil).Maybe()
+
 	// Mock GetPrivateData failure (network/permission error)
 	collectionName := fmt.Sprintf("col-settlement-%s", bankAMSP)
 	chaincodeStub.On("GetPrivateData", collectionName, bankAMSP).Return(nil, fmt.Errorf("network timeout"))
 
 	// Execute
-	err := smartContract.DebitNetting(transactionContext, bankAMSP, 300.0)
+	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
 	require.Error(t, err)
@@ -710,21 +693,21 @@ func TestCreditNetting_PutPrivateDataFailure(t *testing.T) {
 	t.Log("✓ Credit Netting Put Private Data Failure Handled")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
-	// Mock existing account
-	existingAccount := createBankAccount(bankBMSP, 500.0)
-	accountJSON, _ := json.Marshal(existingAccount)
+	prepNettingTransfer(t, chaincodeStub)
+	expectNettingAccount(t, chaincodeStub, bankAMSP, 1000.0)
+	chaincodeStub.On("SetEvent", "NettingDebitExecuted", mock.Anything).Return(nil).Maybe()
 
+	// Mock PutPrivateData failure (storage/permission error)
+	accountJSON, _ := json.Marshal(createBankAccount(bankBMSP, 500.0))
 	collectionName := fmt.Sprintf("col-settlement-%s", bankBMSP)
 	chaincodeStub.On("GetPrivateData", collectionName, bankBMSP).Return(accountJSON, nil)
-
-	// Mock PutPrivateData failure (storage/permission error)
 	chaincodeStub.On("PutPrivateData", collectionName, bankBMSP, mock.Anything).Return(fmt.Errorf("permission denied"))
 
 	// Execute
-	err := smartContract.CreditNetting(transactionContext, bankBMSP, 200.0)
+	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
 	require.Error(t, err)
@@ -738,13 +721,13 @@ func TestCreditNetting_PutPrivateDataFailure(t *testing.T) {
 }
 
 func TestApplyMultilateralOffset_EmptyUpdatesArray(t *testing.T) {
-	t.Log("✓ Empty Updates Array Handled Without Error")
+	t.Log("✓ Net Positions Without Payment Updates Rejected")
 
 	// Setup
-	transactionContext, chaincodeStub := prepMocks()
+	transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
 	smartContract := settlement.SmartContract{}
 
-	// Create scenario with net positions but no payment updates (unusual but possible)
+	// Net positions no payment accounts for would move money out of thin air
 	netPositions := map[string]float64{
 		bankAMSP: -100.0,
 		bankBMSP: 100.0,
@@ -753,30 +736,69 @@ func TestApplyMultilateralOffset_EmptyUpdatesArray(t *testing.T) {
 
 	setMultilateralOffsetInTransientData(t, chaincodeStub, netPositions, updates)
 
-	// Mock settlement accounts for the net position processing
-	accessBankAccount := createBankAccount(bankAMSP, 1000.0)
-	gtBankAccount := createBankAccount(bankBMSP, 500.0)
-	accessBankJSON, _ := json.Marshal(accessBankAccount)
-	gtBankJSON, _ := json.Marshal(gtBankAccount)
-
-	chaincodeStub.On("GetPrivateData", fmt.Sprintf("col-settlement-%s", bankAMSP), bankAMSP).Return(accessBankJSON, nil)
-	chaincodeStub.On("GetPrivateData", fmt.Sprintf("col-settlement-%s", bankBMSP), bankBMSP).Return(gtBankJSON, nil)
-	chaincodeStub.On("PutPrivateData", fmt.Sprintf("col-settlement-%s", bankAMSP), bankAMSP, mock.Anything).Return(nil)
-	chaincodeStub.On("PutPrivateData", fmt.Sprintf("col-settlement-%s", bankBMSP), bankBMSP, mock.Anything).Return(nil)
-
-	chaincodeStub.On("SetEvent", "NettingDebitExecuted", mock.Anything).Return(nil)
-	chaincodeStub.On("SetEvent", "NettingCreditExecuted", mock.Anything).Return(nil)
-	chaincodeStub.On("SetEvent", "MultilateralOffsetExecuted", mock.Anything).Return(nil)
-
 	// Execute
 	err := smartContract.ApplyMultilateralOffset(transactionContext)
 
 	// Assert
-	require.NoError(t, err)
+	require.EqualError(t, err, "net position of AccessBankMSP does not match its payments: calculated -100.00, payments give 0.00")
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
+}
 
-	// Verify no payment updates (since updates array was empty)
-	// But settlement account operations should still happen
-	chaincodeStub.AssertCalled(t, "PutPrivateData", fmt.Sprintf("col-settlement-%s", bankAMSP), bankAMSP, mock.Anything)
-	chaincodeStub.AssertCalled(t, "PutPrivateData", fmt.Sprintf("col-settlement-%s", bankBMSP), bankBMSP, mock.Anything)
-	chaincodeStub.AssertCalled(t, "SetEvent", "MultilateralOffsetExecuted", mock.Anything)
+func TestApplyMultilateralOffset_RejectsStaleDuplicateAndUnqueuedPayments(t *testing.T) {
+	t.Log("✓ Calculations The Ledger No Longer Supports Rejected")
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	queued := createQueuedPayment("pay1", bankAMSP, bankBMSP, 100.0)
+	update := settlement.MultiOffsetUpdate{ID: "pay1", PayerMSP: bankAMSP, PayeeMSP: bankBMSP, AmountToSettle: 0.0, Status: "SETTLED"}
+
+	tests := []struct {
+		name         string
+		payment      settlement.PaymentDetails
+		netPositions map[string]float64
+		updates      []settlement.MultiOffsetUpdate
+		err          string
+	}{
+		{
+			name:         "positions the payments do not give",
+			payment:      queued,
+			netPositions: map[string]float64{bankAMSP: -150.0, bankBMSP: 150.0},
+			updates:      []settlement.MultiOffsetUpdate{update},
+			err:          "net position of AccessBankMSP does not match its payments: calculated -150.00, payments give -100.00",
+		},
+		{
+			name:         "a payment listed twice",
+			payment:      queued,
+			netPositions: map[string]float64{bankAMSP: -200.0, bankBMSP: 200.0},
+			updates:      []settlement.MultiOffsetUpdate{update, update},
+			err:          "payment pay1 is listed more than once",
+		},
+		{
+			name: "a payment settled since the calculation",
+			payment: func() settlement.PaymentDetails {
+				settled := queued
+				settled.Status = "SETTLED"
+				settled.AmountToSettle = 0
+				return settled
+			}(),
+			netPositions: map[string]float64{bankAMSP: -100.0, bankBMSP: 100.0},
+			updates:      []settlement.MultiOffsetUpdate{update},
+			err:          "payment pay1 is not queued, current status: SETTLED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactionContext, chaincodeStub := prepMocksAs(centralBankMSP)
+			smartContract := settlement.SmartContract{}
+			setMultilateralOffsetInTransientData(t, chaincodeStub, tt.netPositions, tt.updates)
+
+			paymentJSON, err := json.Marshal(tt.payment)
+			require.NoError(t, err)
+			chaincodeStub.On("GetPrivateData", coll, "pay1").Return(paymentJSON, nil)
+
+			err = smartContract.ApplyMultilateralOffset(transactionContext)
+			require.EqualError(t, err, tt.err)
+			chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
 }
@@ -0,0 +1,206 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	batched "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// =============================================================================
+// Netting cost per cycle: status index lookups vs range scans
+// =============================================================================
+
+// countingIterator serves a fixed result set and counts every record read
+type countingIterator struct {
+	results []*queryresult.KV
+	pos     int
+	reads   *int
+}
+
+func (it *countingIterator) HasNext() bool { return it.pos < len(it.results) }
+func (it *countingIterator) Close() error  { return nil }
+func (it *countingIterator) Next() (*queryresult.KV, error) {
+	kv := it.results[it.pos]
+	it.pos++
+	*it.reads++
+	return kv, nil
+}
+
+// simulatedPDC holds one bilateral collection plus the status~window~paymentID
+// composite key entries per status
+type simulatedPDC struct {
+	all        []*queryresult.KV
+	records    map[string][]byte
+	indexByKey map[string][]*queryresult.KV
+}
+
+// buildSimulatedLedger fills every bilateral collection with settled history plus a
+// small set of BATCHED payments waiting for the next cycle
+func buildSimulatedLedger(settledHistory, batchedPending int) map[string]*simulatedPDC {
+	banks := []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP}
+	ledger := make(map[string]*simulatedPDC)
+
+	for i, a := range banks {
+		for j := i + 1; j < len(banks); j++ {
+			b := banks[j]
+			pdc := &simulatedPDC{
+				records:    make(map[string][]byte),
+				indexByKey: make(map[string][]*queryresult.KV),
+			}
+
+			add := func(n int, status string) {
+				for k := 0; k < n; k++ {
+					payer, payee := a, b
+					if k%2 == 1 {
+						payer, payee = b, a
+					}
+					payment := batched.PaymentDetails{
+						ID:             fmt.Sprintf("%s-%s-%s-%d", a, b, status, k),
+						PayerMSP:       payer,
+						PayeeMSP:       payee,
+						Amount:         float64(1000 + k),
+						AmountToSettle: float64(1000 + k),
+						Currency:       "NGN",
+						Status:         status,
+						BatchWindow:    1,
+					}
+					paymentJSON, _ := json.Marshal(payment)
+					kv := &queryresult.KV{Key: payment.ID, Value: paymentJSON}
+					pdc.all = append(pdc.all, kv)
+					pdc.records[payment.ID] = paymentJSON
+
+					indexKey, _ := shim.CreateCompositeKey("status~window~paymentID", []string{status, "1", payment.ID})
+					pdc.indexByKey[status] = append(pdc.indexByKey[status], &queryresult.KV{Key: indexKey, Value: []byte{0x00}})
+				}
+			}
+			add(settledHistory, "SETTLED")
+			add(batchedPending, "BATCHED")
+
+			ledger[getCollectionName(a, b)] = pdc
+		}
+	}
+
+	return ledger
+}
+
+// prepNettingBenchmark wires a stub that behaves like a peer with or without a rebuilt
+// status index. Netting runs in update transactions, so it never issues rich queries.
+func prepNettingBenchmark(ledger map[string]*simulatedPDC, backend string, reads *int) *mocks.TransactionContextInterface {
+	chaincodeStub := &mocks.ChaincodeStubInterface{}
+	transactionContext := &mocks.TransactionContextInterface{}
+	transactionContext.On("GetStub").Return(chaincodeStub)
+	chaincodeStub.On("GetTxTimestamp").Return(timestamppb.New(time.Unix(batchedTxTime, 0)), nil)
+	chaincodeStub.On("GetState", "SETTLEMENT_CONFIG").Return(nil, nil)
+	chaincodeStub.On("GetState", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "\x00participant\x00")
+	})).Return(nil, nil)
+
+	chaincodeStub.On("GetPrivateDataByRange", mock.Anything, "", "").Return(
+		func(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+			return &countingIterator{results: ledger[collection].all, reads: reads}, nil
+		})
+
+	chaincodeStub.On("CreateCompositeKey", mock.Anything, mock.Anything).Return(
+		func(objectType string, attributes []string) (string, error) {
+			return shim.CreateCompositeKey(objectType, attributes)
+		})
+
+	chaincodeStub.On("SplitCompositeKey", mock.Anything).Return(
+		func(key string) (string, []string, error) {
+			parts := strings.Split(strings.Trim(key, "\x00"), "\x00")
+			return parts[0], parts[1:], nil
+		})
+
+	chaincodeStub.On("GetPrivateDataByPartialCompositeKey", mock.Anything, "status~window~paymentID", mock.Anything).Return(
+		func(collection, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+			return &countingIterator{results: ledger[collection].indexByKey[attributes[0]], reads: reads}, nil
+		})
+
+	chaincodeStub.On("GetPrivateData", mock.Anything, mock.Anything).Return(
+		func(collection, key string) ([]byte, error) {
+			if strings.HasPrefix(key, "\x00statusIndexState") {
+				if backend == "leveldb-indexed" {
+					return []byte("{}"), nil
+				}
+				return nil, nil
+			}
+			*reads++
+			return ledger[collection].records[key], nil
+		})
+
+	return transactionContext
+}
+
+func TestCalculateNettingOffsets_IndexedQueriesMatchRangeScan(t *testing.T) {
+	ledger := buildSimulatedLedger(100, 4)
+	smartContract := batched.SmartContract{}
+
+	results := make(map[string]batched.NettingCalculationResult)
+	reads := make(map[string]int)
+	for _, backend := range []string{"leveldb-indexed", "leveldb"} {
+		count := 0
+		transactionContext := prepNettingBenchmark(ledger, backend, &count)
+		resultJSON, err := smartContract.CalculateNettingOffsets(transactionContext)
+		require.NoError(t, err)
+
+		var result batched.NettingCalculationResult
+		require.NoError(t, json.Unmarshal([]byte(resultJSON), &result))
+		results[backend] = result
+		reads[backend] = count
+	}
+
+	// Both net the same 24 BATCHED payments; only the indexed one skips the history
+	require.Equal(t, 24, results["leveldb"].TotalPayments)
+	require.Equal(t, results["leveldb"].NetPositions, results["leveldb-indexed"].NetPositions)
+	require.Equal(t, results["leveldb"].TotalNetAmount, results["leveldb-indexed"].TotalNetAmount)
+	require.Equal(t, 2*24, reads["leveldb-indexed"])
+	require.Equal(t, 6*104, reads["leveldb"])
+}
+
+// BenchmarkCalculateNettingOffsets reports ledger records read per netting cycle
+// ("reads/cycle") as the settled history grows while the pending batch stays fixed.
+func BenchmarkCalculateNettingOffsets(b *testing.B) {
+	const batchedPending = 20
+
+	for _, history := range []int{1000, 10000} {
+		ledger := buildSimulatedLedger(history, batchedPending)
+
+		for _, backend := range []string{"leveldb-indexed", "leveldb"} {
+			b.Run(fmt.Sprintf("%s/history=%d", backend, history), func(b *testing.B) {
+				reads := 0
+				transactionContext := prepNettingBenchmark(ledger, backend, &reads)
+				smartContract := batched.SmartContract{}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := smartContract.CalculateNettingOffsets(transactionContext); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(reads)/float64(b.N), "reads/cycle")
+			})
+		}
+	}
+}
+
+func TestCalculateNettingOffsets_FailsWhenACollectionCannotBeRead(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := batched.SmartContract{}
+
+	chaincodeStub.On("GetPrivateData", mock.Anything, "\x00statusIndexState\x00").Return(nil, nil)
+	chaincodeStub.On("GetPrivateDataByRange", mock.Anything, "", "").Return(nil, errors.New("peer unavailable"))
+
+	_, err := smartContract.CalculateNettingOffsets(transactionContext)
+	require.ErrorContains(t, err, "peer unavailable")
+}
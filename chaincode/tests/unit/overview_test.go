@@ -0,0 +1,83 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// System Overview Tests
+// =============================================================================
+
+func TestGetSystemOverview_SummarisesBanksAndRaisesAlerts(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	// Zenith has not opened its account yet; Access runs over its limit and overdrawn
+	chaincodeStub.On("GetPrivateData", "col-settlement-"+bankCMSP, bankCMSP).Return(nil, nil)
+	expectExposureLimits(t, chaincodeStub, bankAMSP, settlement.ExposureLimit{
+		PayerMSP: bankAMSP, Type: "MULTILATERAL", Limit: 100,
+	})
+	expectSettlementBalances(t, chaincodeStub, map[string]float64{
+		bankAMSP: -50, bankBMSP: 1000, bankDMSP: 100,
+	})
+
+	batchedOut := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 300)
+	batchedOut.Status = "BATCHED"
+	pending := acknowledgedPayment("pay-2", bankBMSP, bankAMSP, 40)
+	pending.Status = "PENDING"
+	stale := queuedPayment("pay-3", bankCMSP, bankDMSP, "NORMAL", 200, batchedTxTime-1000)
+	settled := acknowledgedPayment("pay-4", bankDMSP, bankAMSP, 999)
+	settled.Status = "SETTLED"
+	expectAllCollectionScans(chaincodeStub, batchedOut, pending, stale, settled)
+
+	lastCycle, err := json.Marshal(settlement.SettlementCycleRecord{
+		CycleID: "tx-7",
+		Result: settlement.NettingApplicationResult{
+			FailedBanks: []settlement.FailedBankSettlement{{BankMSP: bankDMSP, NetAmount: 75, Error: "insufficient funds"}},
+		},
+	})
+	require.NoError(t, err)
+	chaincodeStub.On("GetState", "LAST_SETTLEMENT_CYCLE").Return(lastCycle, nil)
+
+	overview, err := smartContract.GetSystemOverview(transactionContext, 0)
+	require.NoError(t, err)
+	require.Len(t, overview.Banks, 4)
+
+	access := overview.Banks[0]
+	require.Equal(t, -50.0, access.Balance)
+	require.Equal(t, 100.0, access.MultilateralLimit)
+	require.Equal(t, 300.0, access.BatchedNetDebit)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 300}, access.Batched)
+	require.Equal(t, -300.0, overview.Banks[1].BatchedNetDebit)
+	require.Equal(t, settlement.TransactionStats{Count: 1, Volume: 40}, overview.Banks[1].Pending)
+	require.Zero(t, overview.Banks[2].Balance)
+	require.Equal(t, int64(1000), overview.Banks[2].OldestQueuedSeconds)
+	require.Equal(t, "tx-7", overview.LastCycle.CycleID)
+
+	var alerts []string
+	for _, alert := range overview.Alerts {
+		alerts = append(alerts, alert.Type+" "+alert.BankMSP)
+	}
+	require.Equal(t, []string{
+		"NEGATIVE_BALANCE " + bankAMSP,
+		"LIMIT_EXCEEDED " + bankAMSP,
+		"STALE_QUEUE " + bankCMSP,
+		"SETTLEMENT_FAILURE " + bankDMSP,
+	}, alerts)
+}
+
+func TestGetSystemOverview_CentralBankOnly(t *testing.T) {
+	transactionContext, _ := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	_, err := smartContract.GetSystemOverview(transactionContext, 0)
+	require.EqualError(t, err, "only Central Bank can view the system overview")
+
+	transactionContext, _ = prepBatchedMocksAs("CentralBankMSP")
+	_, err = smartContract.GetSystemOverview(transactionContext, -1)
+	require.EqualError(t, err, "stale queue windows must not be negative")
+}
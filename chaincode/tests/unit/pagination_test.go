@@ -0,0 +1,116 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Pagination Tests
+// =============================================================================
+
+// expectKeyRange answers range reads of a collection from any start key
+func expectKeyRange(chaincodeStub *mocks.ChaincodeStubInterface, collection string, records []*queryresult.KV) {
+	chaincodeStub.On("GetPrivateDataByRange", collection, mock.Anything, "").Return(
+		func(_, startKey, _ string) (shim.StateQueryIteratorInterface, error) {
+			var from []*queryresult.KV
+			for _, kv := range records {
+				if kv.Key >= startKey {
+					from = append(from, kv)
+				}
+			}
+			return &kvIterator{results: from}, nil
+		})
+}
+
+func TestGetBilateralPaymentsPaginated_WalksFilteredPagesByBookmark(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankBMSP)
+	smartContract := settlement.SmartContract{}
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	var kvs []*queryresult.KV
+	amounts := map[string]float64{"pay-a": 300, "pay-b": 50, "pay-c": 500, "pay-d": 100, "pay-e": 300}
+	for i, id := range []string{"pay-a", "pay-b", "pay-c", "pay-d", "pay-e"} {
+		pd := acknowledgedPayment(id, bankAMSP, bankBMSP, amounts[id])
+		pd.Timestamp = int64(1000 + i)
+		kvs = append(kvs, batchedPaymentKV(pd))
+	}
+	expectKeyRange(chaincodeStub, coll, kvs)
+
+	query := settlement.PaymentPageQuery{PageSize: 2, MinAmount: 100}
+	var ids []string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		page, err := smartContract.GetBilateralPaymentsPaginated(transactionContext, bankAMSP, bankBMSP, query)
+		require.NoError(t, err)
+		require.Equal(t, len(page.Records), page.Metadata.FetchedRecordsCount)
+		for _, pd := range page.Records {
+			ids = append(ids, pd.ID)
+		}
+		if page.Metadata.Bookmark == "" {
+			break
+		}
+		query.Bookmark = page.Metadata.Bookmark
+	}
+
+	// Pages follow key order, and the 50 is filtered out
+	require.Equal(t, []string{"pay-a", "pay-c", "pay-d", "pay-e"}, ids)
+}
+
+func TestGetBilateralPaymentsPaginated_RejectsBadQueriesAndOutsiders(t *testing.T) {
+	transactionContext, _ := prepBatchedMocksAs(bankCMSP)
+	smartContract := settlement.SmartContract{}
+
+	_, err := smartContract.GetBilateralPaymentsPaginated(transactionContext, bankAMSP, bankBMSP, settlement.PaymentPageQuery{})
+	require.EqualError(t, err, "unauthorized access to bilateral payments")
+
+	transactionContext, _ = prepBatchedMocksAs(bankAMSP)
+	_, err = smartContract.GetBilateralPaymentsPaginated(transactionContext, bankAMSP, bankBMSP, settlement.PaymentPageQuery{PageSize: 501})
+	require.EqualError(t, err, "page size must be between 1 and 500")
+	_, err = smartContract.GetBilateralPaymentsPaginated(transactionContext, bankAMSP, bankBMSP, settlement.PaymentPageQuery{Bookmark: "not a bookmark"})
+	require.ErrorContains(t, err, "invalid bookmark")
+}
+
+func TestGetAllPrivateDataPaginated_ResumesAfterTheLastKey(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	records := []*queryresult.KV{
+		{Key: "k1", Value: []byte(`{"n":1}`)},
+		{Key: "k2", Value: []byte("raw")},
+		{Key: "k3", Value: []byte(`{"n":3}`)},
+	}
+	expectKeyRange(chaincodeStub, coll, records)
+
+	type page struct {
+		Records []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		} `json:"records"`
+		Metadata settlement.PageMetadata `json:"metadata"`
+	}
+
+	firstJSON, err := smartContract.GetAllPrivateDataPaginated(transactionContext, coll, 2, "")
+	require.NoError(t, err)
+	var first page
+	require.NoError(t, json.Unmarshal([]byte(firstJSON), &first))
+	require.Len(t, first.Records, 2)
+	require.JSONEq(t, `"raw"`, string(first.Records[1].Value))
+	require.NotEmpty(t, first.Metadata.Bookmark)
+
+	secondJSON, err := smartContract.GetAllPrivateDataPaginated(transactionContext, coll, 2, first.Metadata.Bookmark)
+	require.NoError(t, err)
+	var second page
+	require.NoError(t, json.Unmarshal([]byte(secondJSON), &second))
+	require.Len(t, second.Records, 1)
+	require.Equal(t, "k3", second.Records[0].Key)
+	require.Empty(t, second.Metadata.Bookmark)
+}
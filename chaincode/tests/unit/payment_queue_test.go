@@ -0,0 +1,149 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// expectAllCollectionScans spreads the payments over the bilateral collections of the
+// four banks; collections without payments answer empty
+func expectAllCollectionScans(chaincodeStub *mocks.ChaincodeStubInterface, payments ...settlement.PaymentDetails) {
+	byCollection := make(map[string][]*queryresult.KV)
+	for _, pd := range payments {
+		coll := getCollectionName(pd.PayerMSP, pd.PayeeMSP)
+		byCollection[coll] = append(byCollection[coll], batchedPaymentKV(pd))
+	}
+	banks := []string{bankAMSP, bankBMSP, bankCMSP, bankDMSP}
+	for i, a := range banks {
+		for _, b := range banks[i+1:] {
+			coll := getCollectionName(a, b)
+			expectCollectionScan(chaincodeStub, coll, byCollection[coll]...)
+		}
+	}
+}
+
+// queuedPayment builds a payment that entered the queue at queuedAt
+func queuedPayment(id, payerMSP, payeeMSP, priority string, amount float64, queuedAt int64) settlement.PaymentDetails {
+	pd := acknowledgedPayment(id, payerMSP, payeeMSP, amount)
+	pd.Status = "QUEUED"
+	pd.QueueReason = "limit_exceeded"
+	pd.Priority = priority
+	pd.QueuedAt = queuedAt
+	return pd
+}
+
+// =============================================================================
+// Payment Queue Tests
+// =============================================================================
+
+func TestGetPaymentQueue_OrdersUrgentFirstThenFIFO(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	batchedOut := acknowledgedPayment("pay-4", bankAMSP, bankBMSP, 50)
+	batchedOut.Status = "BATCHED"
+	chaincodeStub.On("GetState", "QUEUE_CONFIG").Return(nil, nil)
+	expectAllCollectionScans(chaincodeStub,
+		queuedPayment("pay-1", bankAMSP, bankBMSP, "NORMAL", 100, 1000),
+		batchedOut,
+		queuedPayment("pay-2", bankAMSP, bankCMSP, "URGENT", 200, 3000),
+		queuedPayment("pay-3", bankAMSP, bankDMSP, "NORMAL", 300, 500),
+		// Queued towards Access, so not in its queue
+		queuedPayment("pay-5", bankDMSP, bankAMSP, "URGENT", 400, 100),
+	)
+
+	queue, err := smartContract.GetPaymentQueue(transactionContext, bankAMSP)
+	require.NoError(t, err)
+	require.Len(t, queue, 3)
+	require.Equal(t, "pay-2", queue[0].ID)
+	require.Equal(t, "pay-3", queue[1].ID)
+	require.Equal(t, "pay-1", queue[2].ID)
+	require.Equal(t, 3, queue[2].Position)
+	require.Equal(t, int64(500+24*60*60), queue[1].ExpiresAt)
+
+	// Other banks cannot see the queue
+	transactionContext, _ = prepBatchedMocksAs(bankBMSP)
+	_, err = smartContract.GetPaymentQueue(transactionContext, bankAMSP)
+	require.EqualError(t, err, "only AccessBankMSP or Central Bank can view its payment queue")
+}
+
+func TestReleaseQueuedPayment_PayerReleasesOnlyTheHeadOfItsQueue(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	head := queuedPayment("pay-1", bankAMSP, bankBMSP, "URGENT", 100, 2000)
+	next := queuedPayment("pay-2", bankAMSP, bankCMSP, "NORMAL", 200, 1000)
+	expectPaymentRecord(t, chaincodeStub, head)
+	expectPaymentRecord(t, chaincodeStub, next)
+	expectAllCollectionScans(chaincodeStub, head, next)
+
+	err := smartContract.ReleaseQueuedPayment(transactionContext, "pay-2")
+	require.EqualError(t, err, "payment pay-2 is not at the head of the queue (next is pay-1)")
+
+	// The head goes back into the batch once it fits the payer's limits
+	expectExposureLimits(t, chaincodeStub, bankAMSP)
+	chaincodeStub.On("PutPrivateData", getCollectionName(bankAMSP, bankBMSP), "pay-1", writtenPayment("BATCHED", func(pd settlement.PaymentDetails) bool {
+		return pd.QueueReason == "" && pd.BatchWindow > 0
+	})).Return(nil)
+	chaincodeStub.On("PutState", "pay-1", mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "PaymentReleased", mock.Anything).Return(nil)
+
+	err = smartContract.ReleaseQueuedPayment(transactionContext, "pay-1")
+	require.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "SetEvent", "PaymentReleased", mock.Anything)
+}
+
+func TestExpireQueuedPayments_ReturnsPaymentsPastTheTTL(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	configJSON, err := json.Marshal(settlement.QueueConfig{TTLSeconds: 3600})
+	require.NoError(t, err)
+	chaincodeStub.On("GetState", "QUEUE_CONFIG").Return(configJSON, nil)
+
+	stale := queuedPayment("pay-1", bankAMSP, bankBMSP, "NORMAL", 100, batchedTxTime-7200)
+	fresh := queuedPayment("pay-2", bankCMSP, bankDMSP, "NORMAL", 200, batchedTxTime-60)
+	expectPaymentRecord(t, chaincodeStub, stale)
+	expectAllCollectionScans(chaincodeStub, stale, fresh)
+	chaincodeStub.On("PutPrivateData", getCollectionName(bankAMSP, bankBMSP), "pay-1", writtenPayment("RETURNED_UNSETTLED", nil)).Return(nil)
+	chaincodeStub.On("PutState", "pay-1", mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "QueuedPaymentsExpired", mock.Anything).Return(nil)
+
+	result, err := smartContract.ExpireQueuedPayments(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pay-1"}, result.ExpiredPayments)
+	require.Equal(t, 100.0, result.ExpiredAmount)
+	chaincodeStub.AssertNotCalled(t, "PutPrivateData", mock.Anything, "pay-2", mock.Anything)
+}
+
+func TestReprioritizePayment_ValidatesPriorityAndStatus(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	settled := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 100)
+	settled.Status = "SETTLED"
+	expectPaymentRecord(t, chaincodeStub, settled)
+
+	err := smartContract.ReprioritizePayment(transactionContext, "pay-1", "HIGH")
+	require.EqualError(t, err, `invalid priority "HIGH": must be URGENT or NORMAL`)
+	err = smartContract.ReprioritizePayment(transactionContext, "pay-1", "urgent")
+	require.EqualError(t, err, "payment pay-1 cannot be reprioritized in SETTLED status")
+
+	// A batched payment is already in the netting cycle, so its priority no longer matters
+	batchedOut := acknowledgedPayment("pay-2", bankAMSP, bankBMSP, 100)
+	batchedOut.Status = "BATCHED"
+	expectPaymentRecord(t, chaincodeStub, batchedOut)
+	err = smartContract.ReprioritizePayment(transactionContext, "pay-2", "URGENT")
+	require.EqualError(t, err, "payment pay-2 cannot be reprioritized in BATCHED status")
+
+	transactionContext, chaincodeStub = prepBatchedMocksAs(bankBMSP)
+	expectPaymentRecord(t, chaincodeStub, settled)
+	err = smartContract.ReprioritizePayment(transactionContext, "pay-1", "URGENT")
+	require.EqualError(t, err, "only payer bank or Central Bank can reprioritize payment pay-1")
+}
@@ -3,13 +3,17 @@ package chaincode_test
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement"
-	"github.com/SundayOlubode/interbank_settlement/chaincode/settlement/mocks"
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement/mocks"
+	"github.com/SundayOlubode/interbank_settlement/chaincode/tests/memstub"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
@@ -17,12 +21,27 @@ const (
 	myOrg2Clientid = "GTBankMSP"
 )
 
-// Helper function to prepare mocks
+// Helper function to prepare mocks for a client of myOrg1Clientid
 func prepMocks() (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
+	return prepMocksAs(myOrg1Clientid)
+}
+
+// prepMocksAs prepares mocks for a client of msp. Ledger plumbing the tests don't assert
+// on (timestamps, composite keys, the channel's settlement mode) gets defaults.
+func prepMocksAs(msp string) (*mocks.TransactionContextInterface, *mocks.ChaincodeStubInterface) {
 	chaincodeStub := &mocks.ChaincodeStubInterface{}
 	transactionContext := &mocks.TransactionContextInterface{}
 	transactionContext.On("GetStub").Return(chaincodeStub)
-	transactionContext.On("GetClientIdentity").Return(nil).Maybe()
+	transactionContext.On("GetClientIdentity").Return(memstub.NewIdentity(msp)).Maybe()
+
+	chaincodeStub.On("GetTxID").Return("tx-123").Maybe()
+	chaincodeStub.On("GetTxTimestamp").Return(timestamppb.Now(), nil).Maybe()
+	chaincodeStub.On("CreateCompositeKey", mock.Anything, mock.Anything).Return(shim.CreateCompositeKey).Maybe()
+	chaincodeStub.On("GetState", "SETTLEMENT_CONFIG").Return(nil, nil).Maybe()
+	// No participant has been suspended, so every bank is ACTIVE
+	chaincodeStub.On("GetState", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "\x00participant\x00")
+	})).Return(nil, nil).Maybe()
 	return transactionContext, chaincodeStub
 }
 
@@ -73,6 +92,9 @@ func setPaymentInTransientData(t *testing.T, chaincodeStub *mocks.ChaincodeStubI
 
 	// Mock the BVN collection call
 	chaincodeStub.On("GetPrivateData", "col-BVN", payment.User.BVN).Return(bvnJSON, nil)
+
+	// The payment does not exist yet
+	chaincodeStub.On("GetPrivateData", getCollectionName(payment.PayerMSP, payment.PayeeMSP), payment.ID).Return(nil, nil)
 }
 
 func TestInitiatePayment_Success(t *testing.T) {
@@ -249,7 +271,7 @@ func TestInitiatePayment_DifferentMSPs(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			transactionContext, chaincodeStub := prepMocks()
+			transactionContext, chaincodeStub := prepMocksAs(tc.payerMSP)
 			smartContract := settlement.SmartContract{}
 
 			testPayment := createTestPaymentDetails()
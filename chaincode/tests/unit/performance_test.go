@@ -0,0 +1,73 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/stretchr/testify/require"
+)
+
+// timedPayment builds a payment with its lifecycle times relative to the test transaction
+func timedPayment(id, payerMSP, payeeMSP string, amount float64, window, created, acknowledged int64) settlement.PaymentDetails {
+	pd := acknowledgedPayment(id, payerMSP, payeeMSP, amount)
+	pd.BatchWindow = window
+	pd.CreatedAt = batchedTxTime - created
+	pd.AcknowledgedAt = batchedTxTime - acknowledged
+	return pd
+}
+
+// =============================================================================
+// Settlement Performance Tests
+// =============================================================================
+
+func TestGetSettlementPerformance_ReportsStageLatenciesQueueAgeAndThroughput(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	fast := timedPayment("pay-1", bankAMSP, bankBMSP, 100, 5, 1000, 990)
+	fast.Status = "SETTLED"
+	fast.SettledAt = batchedTxTime - 900
+	slow := timedPayment("pay-2", bankAMSP, bankCMSP, 200, 5, 800, 770)
+	slow.Status = "SETTLED"
+	slow.SettledAt = batchedTxTime - 500
+	queued := timedPayment("pay-3", bankBMSP, bankAMSP, 300, 6, 7200, 7100)
+	queued.Status = "QUEUED"
+	queued.QueuedAt = batchedTxTime - 5400
+	expectAllCollectionScans(chaincodeStub, fast, slow, queued)
+
+	perf, err := smartContract.GetSettlementPerformance(transactionContext, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(batchedTxTime), perf.To)
+
+	require.Equal(t, settlement.LatencyPercentiles{Count: 3, P50: 30, P95: 100, P99: 100}, perf.Overall.Acknowledgement)
+	require.Equal(t, settlement.LatencyPercentiles{Count: 2, P50: 90, P95: 270, P99: 270}, perf.Overall.Settlement)
+
+	require.Len(t, perf.ByCounterparty, 2)
+	require.Equal(t, bankBMSP, perf.ByCounterparty[0].Counterparty)
+	require.Equal(t, 2, perf.ByCounterparty[0].Acknowledgement.Count)
+	require.Equal(t, bankCMSP, perf.ByCounterparty[1].Counterparty)
+	require.Equal(t, 1, perf.ByCounterparty[1].Settlement.Count)
+
+	require.Len(t, perf.ByWindow, 2)
+	require.Equal(t, int64(5), perf.ByWindow[0].BatchWindow)
+	require.Equal(t, 2, perf.ByWindow[0].Settlement.Count)
+
+	require.Equal(t, "1h-4h", perf.QueueAge[2].Label)
+	require.Equal(t, 1, perf.QueueAge[2].Count)
+	require.Equal(t, 300.0, perf.QueueAge[2].Amount)
+	require.Equal(t, int64(5400), perf.OldestQueued)
+
+	// The two settlements fall either side of an hour boundary
+	require.Len(t, perf.Throughput, 2)
+	require.Equal(t, 100.0, perf.Throughput[0].SettledVolume)
+	require.Equal(t, 200.0, perf.Throughput[1].SettledVolume)
+	require.Equal(t, int64(0), perf.Throughput[1].HourStart%3600)
+}
+
+func TestGetSettlementPerformance_RejectsInvertedRange(t *testing.T) {
+	transactionContext, _ := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	_, err := smartContract.GetSettlementPerformance(transactionContext, 200, 100)
+	require.EqualError(t, err, "invalid range: from 200 must not be after to 100")
+}
@@ -0,0 +1,65 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	settlement "github.com/SundayOlubode/interbank_settlement/chaincode/batched_settlement"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Status Index Tests
+// =============================================================================
+
+func TestReindexCollection_ReplacesEntriesFromPaymentRecords(t *testing.T) {
+	transactionContext, chaincodeStub := prepBatchedMocksAs("CentralBankMSP")
+	smartContract := settlement.SmartContract{}
+
+	coll := getCollectionName(bankAMSP, bankBMSP)
+	settled := acknowledgedPayment("pay-1", bankAMSP, bankBMSP, 100)
+	settled.Status = "SETTLED"
+	settled.BatchWindow = 7
+	batchedOut := acknowledgedPayment("pay-2", bankBMSP, bankAMSP, 200)
+	batchedOut.Status = "BATCHED"
+	batchedOut.BatchWindow = 7
+	expectCollectionScan(chaincodeStub, coll, batchedPaymentKV(settled), batchedPaymentKV(batchedOut))
+
+	// A stale entry left by an older chaincode version still lists pay-1 as BATCHED
+	staleKey, err := shim.CreateCompositeKey("status~window~paymentID", []string{"BATCHED", "7", "pay-1"})
+	require.NoError(t, err)
+	chaincodeStub.On("GetPrivateDataByPartialCompositeKey", coll, "status~window~paymentID", []string{}).Return(
+		&kvIterator{results: []*queryresult.KV{{Key: staleKey, Value: []byte{0x00}}}}, nil)
+	chaincodeStub.On("DelPrivateData", coll, staleKey).Return(nil)
+
+	settledKey, err := shim.CreateCompositeKey("status~window~paymentID", []string{"SETTLED", "7", "pay-1"})
+	require.NoError(t, err)
+	batchedKey, err := shim.CreateCompositeKey("status~window~paymentID", []string{"BATCHED", "7", "pay-2"})
+	require.NoError(t, err)
+	stateKey, err := shim.CreateCompositeKey("statusIndexState", []string{})
+	require.NoError(t, err)
+	chaincodeStub.On("PutPrivateData", coll, settledKey, []byte{0x00}).Return(nil)
+	chaincodeStub.On("PutPrivateData", coll, batchedKey, []byte{0x00}).Return(nil)
+	chaincodeStub.On("PutPrivateData", coll, stateKey, mock.Anything).Return(nil)
+	chaincodeStub.On("SetEvent", "CollectionReindexed", mock.Anything).Return(nil)
+
+	result, err := smartContract.ReindexCollection(transactionContext, coll)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.RemovedEntries)
+	require.Equal(t, 2, result.IndexedPayments)
+	chaincodeStub.AssertExpectations(t)
+}
+
+func TestReindexCollection_RequiresCentralBankAndBilateralCollection(t *testing.T) {
+	transactionContext, _ := prepBatchedMocksAs(bankAMSP)
+	smartContract := settlement.SmartContract{}
+
+	_, err := smartContract.ReindexCollection(transactionContext, getCollectionName(bankAMSP, bankBMSP))
+	require.EqualError(t, err, "only Central Bank can reindex collections")
+
+	transactionContext, _ = prepBatchedMocksAs("CentralBankMSP")
+	_, err = smartContract.ReindexCollection(transactionContext, "col-settlement-"+bankAMSP)
+	require.EqualError(t, err, "unknown bilateral collection: col-settlement-"+bankAMSP)
+}
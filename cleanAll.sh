@@ -17,8 +17,8 @@ docker volume rm $(docker volume ls -q | grep -i 'naijachain')
 docker volume prune
 docker network prune
 
-# rm ./contracts/cc-version.txt
-# rm -rf ./contracts/cc-packages
+# rm ./chaincode/cc-version.txt
+# rm -rf ./chaincode/cc-packages
 
 rm -rf ./channel-artifacts ./crypto-config
 
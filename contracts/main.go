@@ -1,17 +0,0 @@
-package main
-
-import (
-	"fmt"
-
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
-)
-
-func main() {
-	chaincode, err := contractapi.NewChaincode(new(SmartContract))
-	if err != nil {
-		panic(fmt.Sprintf("Error creating chaincode: %v", err))
-	}
-	if err := chaincode.Start(); err != nil {
-		panic(fmt.Sprintf("Error starting chaincode: %v", err))
-	}
-}
@@ -1,3 +0,0 @@
-rm go.mod go.sum
-go mod init github.com/SundayOlubode/interbank_settlement/chaincode
-go mod tidy
\ No newline at end of file
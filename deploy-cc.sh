@@ -6,7 +6,6 @@ source ./env-vars.sh
 CC_RUNTIME_LANGUAGE="golang"
 VERSION="1"
 CC_SRC_PATH="./chaincode"
-# CC_SRC_PATH="./contracts"
 CC_NAME="account"
 
 export PRIVATE_DATA_CONFIG=${PWD}/private-data/collections_config.json
@@ -22,54 +22,34 @@ chaincodeInvokeInit
 
 sleep 2
 
-chaincodeCreateAccount(){
-    setGlobalForPeer0AccessBank
-    peer chaincode invoke -o localhost:7050 \
-        --ordererTLSHostnameOverride orderer.cbn.naijachain.org \
-        --tls $CORE_PEER_TLS_ENABLED \
-        --cafile $ORDERER_CA \
-        -C $CHANNEL_NAME -n ${CC_NAME} \
-        --peerAddresses localhost:7051 --tlsRootCertFiles $PEER0ACCESSBANK_CA \
-        --peerAddresses localhost:8051 --tlsRootCertFiles $PEER0GTBANK_CA \
-        --peerAddresses localhost:9051 --tlsRootCertFiles $PEER0ZENITHBANK_CA \
-        --peerAddresses localhost:10051 --tlsRootCertFiles $PEER0FIRSTBANK_CA \
-        -c '{"function": "InitLedger","Args":[]}'
-
-    setGlobalForPeer0GTBank
-    peer chaincode invoke -o localhost:7050 \
-        --ordererTLSHostnameOverride orderer.cbn.naijachain.org \
-        --tls $CORE_PEER_TLS_ENABLED \
-        --cafile $ORDERER_CA \
-        -C $CHANNEL_NAME -n ${CC_NAME} \
-        --peerAddresses localhost:7051 --tlsRootCertFiles $PEER0ACCESSBANK_CA \
-        --peerAddresses localhost:8051 --tlsRootCertFiles $PEER0GTBANK_CA \
-        --peerAddresses localhost:9051 --tlsRootCertFiles $PEER0ZENITHBANK_CA \
-        --peerAddresses localhost:10051 --tlsRootCertFiles $PEER0FIRSTBANK_CA \
-        -c '{"function": "InitLedger","Args":[]}'
-
-    setGlobalForPeer0ZenithBank
-    peer chaincode invoke -o localhost:7050 \
-        --ordererTLSHostnameOverride orderer.cbn.naijachain.org \
-        --tls $CORE_PEER_TLS_ENABLED \
-        --cafile $ORDERER_CA \
-        -C $CHANNEL_NAME -n ${CC_NAME} \
-        --peerAddresses localhost:7051 --tlsRootCertFiles $PEER0ACCESSBANK_CA \
-        --peerAddresses localhost:8051 --tlsRootCertFiles $PEER0GTBANK_CA \
-        --peerAddresses localhost:9051 --tlsRootCertFiles $PEER0ZENITHBANK_CA \
-        --peerAddresses localhost:10051 --tlsRootCertFiles $PEER0FIRSTBANK_CA \
-        -c '{"function": "InitLedger","Args":[]}'
+# The chaincode only accepts Central Bank operations from CentralBankMSP, the MSP ID the
+# CBN services sign with, so onboarding is submitted as the Central Bank org's admin.
+# setGlobalForPeer0CBN would sign as CentralBankPeerMSP, the org of the CBN peer, which
+# endorses but is not the Central Bank to the chaincode.
+setGlobalsForCentralBank() {
+    setGlobalForPeer0CBN
+    export CORE_PEER_LOCALMSPID="CentralBankMSP"
+    export CORE_PEER_MSPCONFIGPATH=${PWD}/crypto-config/ordererOrganizations/cbn.naijachain.org/users/Admin@cbn.naijachain.org/msp
+}
 
-    setGlobalForPeerFirstBank
-    peer chaincode invoke -o localhost:7050 \
-        --ordererTLSHostnameOverride orderer.cbn.naijachain.org \
-        --tls $CORE_PEER_TLS_ENABLED \
-        --cafile $ORDERER_CA \
-        -C $CHANNEL_NAME -n ${CC_NAME} \
-        --peerAddresses localhost:7051 --tlsRootCertFiles $PEER0ACCESSBANK_CA \
-        --peerAddresses localhost:8051 --tlsRootCertFiles $PEER0GTBANK_CA \
-        --peerAddresses localhost:9051 --tlsRootCertFiles $PEER0ZENITHBANK_CA \
-        --peerAddresses localhost:10051 --tlsRootCertFiles $PEER0FIRSTBANK_CA \
-        -c '{"function": "InitLedger","Args":[]}'
+chaincodeCreateAccount(){
+    # Onboarding is a one-time Central Bank operation with an explicit opening balance.
+    # Each invoke waits for its commit: the first one loads the BVN records, and later
+    # ones must see them rather than race it.
+    setGlobalsForCentralBank
+    for BANK_MSP in AccessBankMSP GTBankMSP ZenithBankMSP FirstBankMSP; do
+        peer chaincode invoke -o localhost:7050 \
+            --ordererTLSHostnameOverride orderer.cbn.naijachain.org \
+            --tls $CORE_PEER_TLS_ENABLED \
+            --cafile $ORDERER_CA \
+            -C $CHANNEL_NAME -n ${CC_NAME} \
+            --peerAddresses localhost:7051 --tlsRootCertFiles $PEER0ACCESSBANK_CA \
+            --peerAddresses localhost:8051 --tlsRootCertFiles $PEER0GTBANK_CA \
+            --peerAddresses localhost:9051 --tlsRootCertFiles $PEER0ZENITHBANK_CA \
+            --peerAddresses localhost:10051 --tlsRootCertFiles $PEER0FIRSTBANK_CA \
+            --waitForEvent \
+            -c '{"function": "InitLedger","Args":["'$BANK_MSP'","15000000"]}'
+    done
 }
 
 chaincodeCreateAccount